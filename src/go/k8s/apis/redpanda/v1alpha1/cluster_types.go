@@ -12,7 +12,9 @@ package v1alpha1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 // ClusterSpec defines the desired state of Cluster
@@ -24,15 +26,643 @@ type ClusterSpec struct {
 	Image	string	`json:"image,omitempty"`
 	// Version is the Redpanda container tag
 	Version	string	`json:"version,omitempty"`
+	// ImageDigest pins the Redpanda container to the sha256:<hex> digest
+	// of Image, it takes precedence over Version. The upgrade guard can
+	// not check the version skew of a digest.
+	// +optional
+	ImageDigest	string	`json:"imageDigest,omitempty"`
 	// Replicas determine how big the cluster will be.
 	// +kubebuilder:validation:Minimum=0
 	Replicas	*int32	`json:"replicas,omitempty"`
 	// Resources used by each Redpanda container
 	// To calculate overall resource consumption one need to
 	// multiply replicas against limits
-	Resources	corev1.ResourceRequirements	`json:"resources"`
+	Resources	RedpandaResourceRequirements	`json:"resources"`
+	// InitContainerResources are the resources of each init container,
+	// e.g. the configurator. They default to 100m CPU and 128Mi of memory
+	// in both the requests and the limits, which keeps the Guaranteed QoS
+	// of the brokers whose requests equal their limits. The defaults are
+	// only set on new StatefulSets, the existing ones keep the resources
+	// of their init containers until they are configured here.
+	// +optional
+	InitContainerResources	*corev1.ResourceRequirements	`json:"initContainerResources,omitempty"`
+	// BrokerGroups run additional brokers with their own resources, e.g.
+	// for the brokers hosting the hot partitions. Each group is deployed
+	// by its own StatefulSet, named after the Cluster and the group, and
+	// its brokers join the same cluster as the Replicas ones. A group can
+	// not be scaled down nor removed yet.
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	BrokerGroups	[]BrokerGroup	`json:"brokerGroups,omitempty"`
+	// ClusterID identifies the cluster in the metrics and the logs of its
+	// brokers (redpanda.cluster_id). It defaults to the UID of the Cluster,
+	// which stays the same across broker restarts.
+	// +optional
+	ClusterID	string	`json:"clusterId,omitempty"`
 	// Configuration represent redpanda specific configuration
 	Configuration	RedpandaConfig	`json:"configuration,omitempty"`
+	// ConfigMapRef references a ConfigMap whose redpanda.yaml key holds a
+	// configuration fragment merged into the generated redpanda.yaml. The
+	// keys managed by the operator take precedence over the fragment.
+	// +optional
+	ConfigMapRef	*corev1.LocalObjectReference	`json:"configMapRef,omitempty"`
+	// Storage configures the data volume of each Redpanda container
+	Storage	StorageSpec	`json:"storage,omitempty"`
+	// ConfigDir configures the emptyDir volume holding the redpanda.yaml
+	// of each broker and the files it is generated from
+	ConfigDir	ConfigDirSpec	`json:"configDir,omitempty"`
+	// Service configures the service in front of the brokers
+	Service	ServiceConfig	`json:"service,omitempty"`
+	// ExternalConnectivity exposes the kafka API outside of the
+	// Kubernetes cluster
+	ExternalConnectivity	ExternalConnectivityConfig	`json:"externalConnectivity,omitempty"`
+	// Scheduling configures how the Redpanda pods are spread across the
+	// Kubernetes nodes
+	Scheduling	SchedulingSpec	`json:"scheduling,omitempty"`
+	// PodTemplate configures the metadata of the Redpanda pods
+	PodTemplate	PodTemplateSpec	`json:"podTemplate,omitempty"`
+	// PodSecurityContext of the Redpanda pods. The fsGroup defaults to the
+	// group of the redpanda user (101), so that the data volumes are
+	// writable. The fsGroupChangePolicy defaults to OnRootMismatch, the
+	// ownership of a large data volume is then only changed recursively
+	// when its root does not match, the existing brokers get it with their
+	// next rollout. Changing it rolls the brokers out.
+	// +optional
+	PodSecurityContext	*corev1.PodSecurityContext	`json:"podSecurityContext,omitempty"`
+	// AdditionalCommandlineArguments are passed to redpanda as --key=value,
+	// or as --key when the value is empty. The arguments set by the
+	// operator can not be overridden. Changing them rolls the brokers out.
+	// +optional
+	AdditionalCommandlineArguments	map[string]string	`json:"additionalCommandlineArguments,omitempty"`
+	// LogFormat is the format of the broker logs. JSON gives structured
+	// logs to the aggregators expecting them, the default is the plain
+	// text format of redpanda. Changing it rolls the brokers out.
+	// +optional
+	LogFormat	LogFormat	`json:"logFormat,omitempty"`
+	// StartupProbe configures how long a broker may take to start
+	StartupProbe	StartupProbeSpec	`json:"startupProbe,omitempty"`
+	// LivenessProbe configures when an unresponsive broker is restarted
+	LivenessProbe	LivenessProbeSpec	`json:"livenessProbe,omitempty"`
+	// SASL enables the SCRAM authentication of the kafka API
+	SASL	SASLConfig	`json:"sasl,omitempty"`
+	// TLS configures the certificates generated by the operator
+	TLS	TLSConfig	`json:"tls,omitempty"`
+	// Scaling configures how the brokers are added and removed
+	Scaling	ScalingSpec	`json:"scaling,omitempty"`
+	// BrokerReplacement replaces the brokers failing persistently, e.g.
+	// on a corrupted data volume, by brokers starting on empty volumes
+	// +optional
+	BrokerReplacement	BrokerReplacementSpec	`json:"brokerReplacement,omitempty"`
+	// PodDisruptionBudget limits the brokers evicted at once, e.g. by
+	// node drains
+	// +optional
+	PodDisruptionBudget	PodDisruptionBudgetSpec	`json:"podDisruptionBudget,omitempty"`
+	// ClusterProperties are the cluster wide properties set at runtime
+	// through the Admin API once every broker is ready. Unlike the node
+	// configuration of redpanda.yaml they are shared by all brokers and do
+	// not require a restart. Changes made outside of the operator to these
+	// properties are reverted.
+	// +optional
+	ClusterProperties	map[string]string	`json:"clusterProperties,omitempty"`
+	// HotReload applies the changes of the redpanda.yaml properties which
+	// redpanda supports at runtime through the Admin API, and rolls the
+	// brokers out on the changes of the other properties. The properties
+	// removed from the redpanda.yaml are reset to their default. Without
+	// it a changed redpanda.yaml is only applied when a broker restarts.
+	// +optional
+	HotReload	HotReloadSpec	`json:"hotReload,omitempty"`
+	// SchemaRegistry enables the Schema Registry bundled with redpanda
+	// +optional
+	SchemaRegistry	SchemaRegistrySpec	`json:"schemaRegistry,omitempty"`
+	// PandaProxy enables the HTTP REST proxy of the kafka API bundled with
+	// redpanda
+	// +optional
+	PandaProxy	PandaProxySpec	`json:"pandaProxy,omitempty"`
+	// ReadinessGate keeps the brokers unready, and out of the services,
+	// until they joined the cluster. Changing it rolls the brokers out.
+	// +optional
+	ReadinessGate	ReadinessGateSpec	`json:"readinessGate,omitempty"`
+	// MaintenanceMode restarts the brokers of a rollout one at a time,
+	// each of them in maintenance mode
+	// +optional
+	MaintenanceMode	MaintenanceModeSpec	`json:"maintenanceMode,omitempty"`
+	// LicenseSecretRef references a Secret whose license key holds the
+	// enterprise license. It is loaded through the Admin API once every
+	// broker is ready, and loaded again whenever the Secret changes.
+	// Without it the cluster runs the community edition.
+	// +optional
+	LicenseSecretRef	*corev1.LocalObjectReference	`json:"licenseSecretRef,omitempty"`
+	// CloudStorage enables the tiered storage, which uploads the closed
+	// log segments to an S3 compatible object store
+	// +optional
+	CloudStorage	CloudStorageConfig	`json:"cloudStorage,omitempty"`
+	// DependsOn lists the services the brokers wait for before starting,
+	// e.g. the object store of the tiered storage. A broker only starts
+	// once every service accepts TCP connections. Changing it rolls the
+	// brokers out.
+	// +optional
+	DependsOn	[]Dependency	`json:"dependsOn,omitempty"`
+	// Debug holds troubleshooting settings, which must not be used on a
+	// cluster serving clients
+	Debug	DebugSpec	`json:"debug,omitempty"`
+}
+
+// CloudStorageConfig maps to the redpanda cloud_storage_* settings
+type CloudStorageConfig struct {
+	// Enabled uploads the log segments to the bucket
+	// (cloud_storage_enabled). Bucket, Region and CredentialsSecretRef are
+	// required when enabled.
+	// +optional
+	Enabled	bool	`json:"enabled,omitempty"`
+	// Bucket is the name of the bucket (cloud_storage_bucket)
+	// +optional
+	Bucket	string	`json:"bucket,omitempty"`
+	// Region is the region of the bucket (cloud_storage_region)
+	// +optional
+	Region	string	`json:"region,omitempty"`
+	// Endpoint is the host name of the object store API, it defaults to
+	// the AWS S3 endpoint of the region (cloud_storage_api_endpoint)
+	// +optional
+	Endpoint	string	`json:"endpoint,omitempty"`
+	// CredentialsSecretRef references a Secret in the Cluster namespace
+	// holding the access_key and secret_key keys. They are injected in the
+	// configuration of each broker when it starts and never stored in a
+	// ConfigMap, rotating them rolls the brokers out.
+	// +optional
+	CredentialsSecretRef	*corev1.LocalObjectReference	`json:"credentialsSecretRef,omitempty"`
+	// MaxUploadLag is the upload lag above which the brokers are not
+	// decommissioned, so that no data is lost with their volumes. Without
+	// it the decommissioning does not wait for the uploads.
+	// +optional
+	MaxUploadLag	*metav1.Duration	`json:"maxUploadLag,omitempty"`
+}
+
+// Dependency is a service reached over TCP the brokers depend on
+type Dependency struct {
+	// Name identifies the service in the logs of the wait init container
+	// +kubebuilder:validation:Pattern=^[a-z0-9]([-a-z0-9]*[a-z0-9])?$
+	Name	string	`json:"name"`
+	// Address is the host name or the IP address of the service
+	// +kubebuilder:validation:MinLength=1
+	Address	string	`json:"address"`
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port	int	`json:"port"`
+}
+
+// DebugSpec configures the troubleshooting of the brokers
+type DebugSpec struct {
+	// OverrideCommand replaces the redpanda command with an idle loop,
+	// keeping the volumes mounted so the configuration and data can be
+	// inspected with kubectl exec. WARNING: the brokers stop serving
+	// clients while it is enabled, the Cluster is reported as degraded.
+	// +optional
+	OverrideCommand	bool	`json:"overrideCommand,omitempty"`
+	// ReadOnlyData mounts the data directory read-only in the redpanda
+	// container, so it can be inspected without risking writes, e.g. to
+	// recover a broker. It requires OverrideCommand, redpanda is never
+	// started on a read-only data directory, and skips the data directory
+	// verification. Changing it rolls the brokers out.
+	// +optional
+	ReadOnlyData	bool	`json:"readOnlyData,omitempty"`
+}
+
+// ScalingSpec configures the start of the brokers added when the replicas
+// are increased, and the decommissioning of the brokers removed when they
+// are decreased
+type ScalingSpec struct {
+	// DecommissionTimeout is how long the operator waits for a broker to
+	// drain its partitions before reporting the Cluster as degraded. The
+	// broker is only removed once drained, even after the timeout.
+	// Defaults to 30 minutes.
+	// +optional
+	DecommissionTimeout	*metav1.Duration	`json:"decommissionTimeout,omitempty"`
+	// JoinThrottle is the number of brokers started at once on scale up,
+	// the next ones are started once every broker is ready. The brokers
+	// otherwise all start and join the cluster at once, which can
+	// overwhelm the controller. Zero, the default, does not throttle the
+	// joins.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	JoinThrottle	int32	`json:"joinThrottle,omitempty"`
+}
+
+// BrokerReplacementSpec configures the replacement of the brokers which
+// keep failing. A broker is replaced once its redpanda container crash
+// looped for longer than the failure threshold while every other broker
+// is ready, and the Admin API reports it as not alive. It is then
+// decommissioned, its data claim is deleted and the StatefulSet recreates
+// it on an empty volume, from which it joins the cluster as a new broker.
+type BrokerReplacementSpec struct {
+	// Enabled replaces the brokers failing persistently. The data of the
+	// replaced brokers is lost, their partitions are replicated again
+	// from the other brokers.
+	// +optional
+	Enabled	bool	`json:"enabled,omitempty"`
+	// FailureThreshold is how long a broker must be unready before it is
+	// replaced, at least 5 minutes. Defaults to 15 minutes.
+	// +optional
+	FailureThreshold	*metav1.Duration	`json:"failureThreshold,omitempty"`
+	// MinRestarts is the number of restarts of the redpanda container
+	// before its broker is replaced. Defaults to 5.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MinRestarts	int32	`json:"minRestarts,omitempty"`
+}
+
+// HotReloadSpec configures how the redpanda.yaml changes are applied
+type HotReloadSpec struct {
+	// Enabled applies the hot reloadable properties without restarting
+	// the brokers
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+}
+
+// SchemaRegistrySpec configures the Schema Registry listener, it runs in
+// the redpanda process of every broker
+type SchemaRegistrySpec struct {
+	// Enabled adds the Schema Registry listener to the redpanda.yaml and
+	// its port to the brokers and to the service of the Cluster. It is not
+	// exposed by the external service. It listens on the bind address of
+	// the other listeners, or on every interface when they are bound to
+	// the pod IP.
+	// +optional
+	Enabled	bool	`json:"enabled,omitempty"`
+	// Port of the Schema Registry, it defaults to 8081
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port	int	`json:"port,omitempty"`
+}
+
+// PandaProxySpec configures the Pandaproxy listener, it runs in the
+// redpanda process of every broker. When spec.sasl is enabled the clients
+// authenticate with the credentials of their SCRAM user over HTTP basic
+// authentication, like on the kafka API.
+type PandaProxySpec struct {
+	// Enabled adds the Pandaproxy listener to the redpanda.yaml and its
+	// port to the brokers and to the service of the Cluster. It is not
+	// exposed by the external service. It listens on the bind address of
+	// the other listeners, or on every interface when they are bound to
+	// the pod IP.
+	// +optional
+	Enabled	bool	`json:"enabled,omitempty"`
+	// Port of the Pandaproxy, it defaults to 8082
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port	int	`json:"port,omitempty"`
+	// TLS serves the Pandaproxy over https
+	// +optional
+	TLS	PandaProxyTLS	`json:"tls,omitempty"`
+}
+
+// PandaProxyTLS configures the certificate of the Pandaproxy listener
+type PandaProxyTLS struct {
+	// Enabled serves the Pandaproxy over https with the certificate of
+	// CertSecretRef
+	// +optional
+	Enabled	bool	`json:"enabled,omitempty"`
+	// CertSecretRef references a Secret in the Cluster namespace holding
+	// the tls.crt, tls.key and ca.crt keys. The certificate is shared by
+	// all brokers. Required when TLS is enabled.
+	// +optional
+	CertSecretRef	*corev1.LocalObjectReference	`json:"certSecretRef,omitempty"`
+	// RequireClientAuth makes the Pandaproxy only accept the clients
+	// presenting a certificate signed by the CA of CertSecretRef
+	// +optional
+	RequireClientAuth	bool	`json:"requireClientAuth,omitempty"`
+}
+
+// ReadinessGateSpec configures the cluster membership readiness gate
+type ReadinessGateSpec struct {
+	// Enabled adds the ClusterMemberReadinessGate readiness gate to the
+	// broker pods, the operator sets its condition once the Admin API
+	// reports the broker as a member of the cluster
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+}
+
+// ClusterMemberReadinessGate is the pod condition set by the operator to
+// true once the broker is a member of the cluster
+const ClusterMemberReadinessGate corev1.PodConditionType = "redpanda.vectorized.io/cluster-member"
+
+// MaintenanceModeSpec configures how the brokers are restarted by a rollout
+type MaintenanceModeSpec struct {
+	// Enabled puts each broker in maintenance mode before the rollout
+	// restarts it, so that the leadership of its partitions is transferred
+	// to the other brokers first, and takes it out once the broker is
+	// ready again. It requires redpanda v22.1 or later.
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+}
+
+// PodDisruptionBudgetSpec configures the PodDisruptionBudget of the brokers
+type PodDisruptionBudgetSpec struct {
+	// Enabled creates a PodDisruptionBudget allowing the eviction of the
+	// brokers beyond a majority of the replicas, and at least one so that
+	// the node drains are never blocked. It follows the replicas.
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+}
+
+// TLSConfig configures the certificates generated by the operator, for the
+// clusters deployed without cert-manager
+type TLSConfig struct {
+	// SelfSigned makes the operator generate a CA, stored in the
+	// <cluster name>-selfsigned-ca Secret, and a server certificate signed
+	// by it covering every broker, service and per broker address of the
+	// Cluster, stored in the <cluster name>-selfsigned-tls Secret. Both are
+	// renewed 30 days before they expire, which rolls the brokers out. The
+	// RPC server TLS is enabled with the generated certificate, so
+	// configuration.rpcServer.tls.certSecretRef must not be set. Enabling
+	// it on an existing Cluster mounts the certificate in the brokers with
+	// a rollout as well.
+	// +optional
+	SelfSigned bool `json:"selfSigned,omitempty"`
+}
+
+// SASLConfig configures the SASL authentication and its bootstrap superuser
+type SASLConfig struct {
+	// Enabled requires the kafka clients to authenticate with SCRAM
+	Enabled	bool	`json:"enabled,omitempty"`
+	// SuperuserName is the name of the bootstrap superuser, it defaults to
+	// admin
+	// +optional
+	SuperuserName	string	`json:"superuserName,omitempty"`
+	// SuperuserSecretRef references a Secret whose password key holds the
+	// password of the bootstrap superuser. When it is not set the operator
+	// generates a random password and stores the credentials in the
+	// <cluster name>-superuser Secret.
+	// +optional
+	SuperuserSecretRef	*corev1.LocalObjectReference	`json:"superuserSecretRef,omitempty"`
+	// Mechanisms are the SASL mechanisms offered to the kafka clients,
+	// they default to SCRAM-SHA-256. The bootstrap superuser is created
+	// with the first one, which it and the Console keep using when the
+	// mechanisms change, see status.superuserMechanism. The mechanism of
+	// an existing superuser can not be removed.
+	// +optional
+	Mechanisms	[]SASLMechanism	`json:"mechanisms,omitempty"`
+}
+
+// SASLMechanism is a SASL mechanism supported by the kafka API
+// +kubebuilder:validation:Enum=SCRAM-SHA-256;SCRAM-SHA-512
+type SASLMechanism string
+
+// These are the supported SASL mechanisms
+const (
+	SASLMechanismScramSha256	SASLMechanism	= "SCRAM-SHA-256"
+	SASLMechanismScramSha512	SASLMechanism	= "SCRAM-SHA-512"
+)
+
+// SchedulingSpec configures the placement of the Redpanda pods
+type SchedulingSpec struct {
+	// AntiAffinityTopologyKey is the node label used by the pod
+	// anti-affinity, only one broker is placed in each topology domain.
+	// Defaults to kubernetes.io/hostname (one broker per node), set it to
+	// e.g. topology.kubernetes.io/zone for one broker per zone. In
+	// developer mode the anti-affinity is only preferred.
+	// +optional
+	AntiAffinityTopologyKey	string	`json:"antiAffinityTopologyKey,omitempty"`
+	// TopologySpreadConstraints of the Redpanda pods. Defaults to spreading
+	// the brokers evenly across the zones, on a best effort basis. The
+	// constraints without label selector select the pods of the Cluster.
+	// Changing them rolls the brokers out.
+	// +optional
+	TopologySpreadConstraints	[]corev1.TopologySpreadConstraint	`json:"topologySpreadConstraints,omitempty"`
+}
+
+// PodTemplateSpec configures the metadata of the Redpanda pods
+type PodTemplateSpec struct {
+	// Labels are added to the pods only, e.g. for cost allocation or mesh
+	// policies, and never to the immutable StatefulSet selector. The
+	// labels of the Cluster take precedence, and labels removed from the
+	// list are left on the pods. Changing them rolls the brokers out.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// StartupProbeSpec configures the startup probe of the redpanda container.
+// Brokers replaying large amounts of data can take long to start, the
+// probe gives them up to FailureThreshold * PeriodSeconds seconds to become
+// ready before the other probes start.
+type StartupProbeSpec struct {
+	// FailureThreshold is the number of failed probes after which the
+	// broker is restarted, defaults to 60
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	FailureThreshold	int32	`json:"failureThreshold,omitempty"`
+	// PeriodSeconds is the interval between two probes, defaults to 10
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	PeriodSeconds	int32	`json:"periodSeconds,omitempty"`
+}
+
+// LivenessProbeSpec configures the liveness probe of the redpanda
+// container. A started broker whose Admin API does not answer for
+// FailureThreshold * PeriodSeconds seconds is restarted.
+type LivenessProbeSpec struct {
+	// FailureThreshold is the number of failed probes after which the
+	// broker is restarted, defaults to 3
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	FailureThreshold	int32	`json:"failureThreshold,omitempty"`
+	// PeriodSeconds is the interval between two probes, defaults to 10
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	PeriodSeconds	int32	`json:"periodSeconds,omitempty"`
+	// RolloutFailureThreshold replaces FailureThreshold while the operator
+	// rolls the brokers out, so that a broker reloading its data is not
+	// killed before it rejoins the cluster. Restoring FailureThreshold once
+	// the rollout completes rolls the brokers out once more. Unset, the
+	// probe is the same during rollouts.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	RolloutFailureThreshold	int32	`json:"rolloutFailureThreshold,omitempty"`
+}
+
+// LogFormat is the format of the broker logs
+// +kubebuilder:validation:Enum=Text;JSON
+type LogFormat string
+
+const (
+	// LogFormatText is the human readable format of the redpanda logs
+	LogFormatText	LogFormat	= "Text"
+	// LogFormatJSON writes every log line as a JSON object
+	LogFormatJSON	LogFormat	= "JSON"
+)
+
+// ServiceType is the type of the service used by the clients
+// +kubebuilder:validation:Enum=Headless;ClusterIP
+type ServiceType string
+
+const (
+	// ServiceTypeHeadless is a service without cluster IP, providing the
+	// per broker DNS names
+	ServiceTypeHeadless	ServiceType	= "Headless"
+	// ServiceTypeClusterIP is a service with a single virtual IP balancing
+	// the connections across the brokers
+	ServiceTypeClusterIP	ServiceType	= "ClusterIP"
+)
+
+// ServiceConfig configures the service in front of the brokers
+type ServiceConfig struct {
+	// Type of the service used by the clients, defaults to Headless. The
+	// ClusterIP type adds the <name>-client service balancing the
+	// connections across the brokers. The headless service governing the
+	// StatefulSet is always kept, as it provides the per broker DNS names
+	// used by the seed servers.
+	// +optional
+	Type ServiceType `json:"type,omitempty"`
+}
+
+// ExternalConnectivityConfig configures the service reaching the brokers
+// from outside of the Kubernetes cluster
+type ExternalConnectivityConfig struct {
+	// Enabled creates a LoadBalancer service in front of the kafka API. It
+	// requires PerBrokerAddresses or PerBrokerNodePorts, so that the
+	// brokers advertise addresses reachable by the external clients.
+	Enabled	bool	`json:"enabled,omitempty"`
+	// Annotations are set on the external service, e.g. to choose the cloud
+	// load balancer type or its certificate. Changes are reconciled.
+	// +optional
+	Annotations	map[string]string	`json:"annotations,omitempty"`
+	// PerBrokerAddresses are the kafka API addresses advertised by the
+	// brokers, indexed by ordinal, e.g. their external IPs. They replace
+	// the address of the broker in the headless service, and there must be
+	// one per replica.
+	// +optional
+	PerBrokerAddresses	[]string	`json:"perBrokerAddresses,omitempty"`
+	// PerBrokerNodePorts creates one NodePort service per broker, so that
+	// the clients reach the leader of each partition directly. Every
+	// broker advertises its node port, with its PerBrokerAddresses entry
+	// or else the IP address of its node.
+	// +optional
+	PerBrokerNodePorts	*PerBrokerNodePorts	`json:"perBrokerNodePorts,omitempty"`
+}
+
+// PerBrokerNodePorts configures the NodePort service of every broker
+type PerBrokerNodePorts struct {
+	// BasePort is the node port of the broker 0, the broker with the
+	// ordinal i uses BasePort+i
+	// +kubebuilder:validation:Minimum=30000
+	// +kubebuilder:validation:Maximum=32767
+	BasePort int32 `json:"basePort"`
+}
+
+// BrokerGroup is a set of brokers sharing the same resources
+type BrokerGroup struct {
+	// Name of the group, the pods of its brokers are named
+	// <cluster>-<group>-<ordinal>
+	// +kubebuilder:validation:Pattern=^[a-z]([-a-z0-9]*[a-z0-9])?$
+	// +kubebuilder:validation:MaxLength=20
+	Name	string	`json:"name"`
+	// Replicas is the number of brokers of the group
+	// +kubebuilder:validation:Minimum=0
+	Replicas	int32	`json:"replicas"`
+	// FirstNodeID is the redpanda node id of the broker with ordinal 0 of
+	// the group, the next brokers get the following node ids. The node
+	// ids of the groups and of the Replicas brokers, which start at 0,
+	// must not overlap. It can not be changed once the group is created.
+	// +kubebuilder:validation:Minimum=1
+	FirstNodeID	int32	`json:"firstNodeId"`
+	// Resources used by each Redpanda container of the group, they replace
+	// the Cluster resources. Changing the memory rolls the brokers of the
+	// group out.
+	Resources	RedpandaResourceRequirements	`json:"resources"`
+}
+
+// RedpandaResourceRequirements extends the container resource requirements
+// with the redpanda memory allocation settings
+type RedpandaResourceRequirements struct {
+	corev1.ResourceRequirements	`json:",inline"`
+	// LockMemory locks all redpanda memory into RAM (--lock-memory) so it
+	// is never swapped. It requires a memory limit and grants the IPC_LOCK
+	// capability to the redpanda container.
+	// +optional
+	LockMemory	bool	`json:"lockMemory,omitempty"`
+	// CPUSet pins the seastar reactor threads to the given CPUs (--cpuset),
+	// e.g. "0-3" or "0,2,4". When empty seastar uses the CPUs the pod is
+	// allowed to run on, which are the exclusively assigned CPUs when the
+	// kubelet runs the static CPU manager policy.
+	// +kubebuilder:validation:Pattern=`^[0-9]+(-[0-9]+)?(,[0-9]+(-[0-9]+)?)*$`
+	// +optional
+	CPUSet	string	`json:"cpuset,omitempty"`
+	// ReserveMemoryPercent is the share of the memory limit left to the
+	// operating system and the sidecars (--reserve-memory), between 0 and
+	// 50. Nothing is reserved by default.
+	// +optional
+	ReserveMemoryPercent	int	`json:"reserveMemoryPercent,omitempty"`
+}
+
+// StorageSpec defines how the redpanda data directory is provisioned
+type StorageSpec struct {
+	// VerifyDataDirectory adds an init container that clears stale lock
+	// files left by an ungraceful shutdown and verifies the data directory
+	// is writable before the broker starts. It is skipped while
+	// spec.debug.readOnlyData is set.
+	// +optional
+	VerifyDataDirectory	bool	`json:"verifyDataDirectory,omitempty"`
+	// IOProperties are the seastar disk IO properties passed to redpanda
+	// with --io-properties-file. When unset redpanda detects them. The
+	// brokers restart when the properties, or the content of the
+	// referenced ConfigMap, change.
+	// +optional
+	IOProperties	*IOPropertiesSource	`json:"ioProperties,omitempty"`
+	// Selector binds the data volumes to statically provisioned
+	// PersistentVolumes matching the labels. When it is set the claims
+	// have an empty storage class, so no volume is dynamically
+	// provisioned.
+	// +optional
+	Selector	*metav1.LabelSelector	`json:"selector,omitempty"`
+	// StorageClassName of the data volumes, the default StorageClass is
+	// used when it is not set. The Cluster reports the StorageClassMissing
+	// condition while the class does not exist. It can not be changed once
+	// the Cluster is created.
+	// +optional
+	StorageClassName	string	`json:"storageClassName,omitempty"`
+	// ClaimLabels are added to the data claims, e.g. for cost allocation
+	// or backup policies. The labels of the Cluster take precedence. The
+	// claim templates of a StatefulSet can not be updated, the existing
+	// claims are updated instead, and the labels removed from the list are
+	// left on them.
+	// +optional
+	ClaimLabels	map[string]string	`json:"claimLabels,omitempty"`
+	// ClaimAnnotations are added to the data claims like the ClaimLabels
+	// +optional
+	ClaimAnnotations	map[string]string	`json:"claimAnnotations,omitempty"`
+}
+
+// ConfigDirSpec configures the emptyDir volume the configurator writes the
+// redpanda.yaml of each broker to
+type ConfigDirSpec struct {
+	// Medium of the volume, set it to Memory to keep the configuration on
+	// a tmpfs instead of the node disk. Defaults to the node disk.
+	// +optional
+	Medium	corev1.StorageMedium	`json:"medium,omitempty"`
+	// SizeLimit of the volume. A Memory volume counts towards the memory
+	// limit of the containers.
+	// +optional
+	SizeLimit	*resource.Quantity	`json:"sizeLimit,omitempty"`
+	// FileModes sets the permission bits of the files of the base ConfigMap
+	// read by the configurator, keyed by file name, e.g. redpanda.yaml: 0640
+	// written as the decimal 416 in JSON. The other files keep the 0754
+	// default mode and configurator.sh always stays readable and executable
+	// by the redpanda group. Changing them rolls the brokers out.
+	// +optional
+	FileModes	map[string]int32	`json:"fileModes,omitempty"`
+}
+
+// IOPropertiesSource holds the io-properties.yaml content, either inline or
+// from a ConfigMap. Exactly one of the fields must be set.
+type IOPropertiesSource struct {
+	// Inline is the content of io-properties.yaml
+	// +optional
+	Inline	string	`json:"inline,omitempty"`
+	// ConfigMapRef references a ConfigMap with an io-properties.yaml key
+	// +optional
+	ConfigMapRef	*corev1.LocalObjectReference	`json:"configMapRef,omitempty"`
 }
 
 // ClusterStatus defines the observed state of Cluster
@@ -40,16 +670,256 @@ type ClusterStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
-	// Replicas show how many nodes are working in the cluster
+	// Replicas show how many nodes are working in the cluster. It is the
+	// status replicas of the scale subresource, which only scales the
+	// Replicas brokers, the ones of the broker groups are not counted.
 	// +optional
 	Replicas	int32	`json:"replicas,omitempty"`
+	// Selector is the label selector of the pods counted in Replicas, the
+	// selector of the scale subresource which a HorizontalPodAutoscaler
+	// requires
+	// +optional
+	Selector	string	`json:"selector,omitempty"`
+	// BrokerGroups reports the ready brokers of every broker group
+	// +optional
+	BrokerGroups	[]BrokerGroupStatus	`json:"brokerGroups,omitempty"`
 	// Nodes of the provisioned redpanda nodes
 	// +optional
 	Nodes	[]string	`json:"nodes,omitempty"`
+	// Conditions describe the latest observations of the Cluster state
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions	[]metav1.Condition	`json:"conditions,omitempty"`
+	// Brokers reports the resource usage of every broker, as seen by its
+	// Admin API. It is meant for external automation deciding when to
+	// scale, which must change the Cluster replicas and never the
+	// StatefulSet directly. The disk usage and the partition counts are
+	// refreshed at most once a minute.
+	// +optional
+	Brokers	[]BrokerStatus	`json:"brokers,omitempty"`
+	// Decommission tracks the broker being decommissioned on scale down
+	// +optional
+	Decommission	*DecommissionStatus	`json:"decommission,omitempty"`
+	// Maintenance tracks the broker put in maintenance mode by the
+	// operator to be restarted
+	// +optional
+	Maintenance	*MaintenanceStatus	`json:"maintenance,omitempty"`
+	// Replacement tracks the failed broker being replaced
+	// +optional
+	Replacement	*ReplacementStatus	`json:"replacement,omitempty"`
+	// NodeIDs is the node id of every broker replaced on an empty volume,
+	// by pod name. The other brokers use the node id derived from their
+	// ordinal.
+	// +optional
+	NodeIDs	map[string]int	`json:"nodeIds,omitempty"`
+	// LastReconcileTime is the time of the last successful
+	// reconciliation, with a resolution of one minute. Combined with the
+	// periodic resync it reveals the Clusters the operator stopped
+	// managing.
+	// +optional
+	LastReconcileTime	metav1.Time	`json:"lastReconcileTime,omitempty"`
+	// License describes the loaded enterprise license, it is not set on
+	// the community edition
+	// +optional
+	License	*LicenseStatus	`json:"license,omitempty"`
+	// CloudStorage reports the progress of the tiered storage uploads, it
+	// is only set when the decommissioning is gated on the upload lag. It
+	// is refreshed at most once a minute.
+	// +optional
+	CloudStorage	*CloudStorageStatus	`json:"cloudStorage,omitempty"`
+	// ControllerLeaderLostTime is when the brokers were first seen without
+	// an elected controller leader, it is not set while the controller
+	// has a leader
+	// +optional
+	ControllerLeaderLostTime	*metav1.Time	`json:"controllerLeaderLostTime,omitempty"`
+	// ControllerQuorumLossReported is true once the brokers are without a
+	// controller leader for longer than the grace period of the operator,
+	// which reported the loss with a Warning event
+	// +optional
+	ControllerQuorumLossReported	bool	`json:"controllerQuorumLossReported,omitempty"`
+	// SuperuserMechanism is the SASL mechanism the bootstrap superuser
+	// was created with, its credentials only exist for that mechanism
+	// +optional
+	SuperuserMechanism	string	`json:"superuserMechanism,omitempty"`
+	// AdminAPIAuthRequired reports that the operator enabled
+	// admin_api_require_auth through the Admin API, so its calls must be
+	// authenticated
+	// +optional
+	AdminAPIAuthRequired	bool	`json:"adminAPIAuthRequired,omitempty"`
+	// HotReload tracks the redpanda.yaml properties applied at runtime,
+	// it is only set when the hot reload is enabled
+	// +optional
+	HotReload	*HotReloadStatus	`json:"hotReload,omitempty"`
 }
 
+// BrokerGroupStatus is the observed state of a broker group
+type BrokerGroupStatus struct {
+	// Name of the broker group
+	Name	string	`json:"name"`
+	// Replicas is the number of ready brokers of the group
+	Replicas	int32	`json:"replicas"`
+}
+
+// CloudStorageStatus is the progress of the tiered storage uploads
+type CloudStorageStatus struct {
+	// PartitionsBehind is the number of partitions with offsets not
+	// uploaded yet
+	PartitionsBehind	int	`json:"partitionsBehind"`
+	// UploadLag is the longest time since a partition behind last uploaded
+	// a segment
+	UploadLag	metav1.Duration	`json:"uploadLag"`
+}
+
+// HotReloadStatus tracks the redpanda.yaml properties applied through the
+// Admin API. The properties redpanda applies at runtime are the ones of
+// the cluster configuration schema reported by the brokers.
+type HotReloadStatus struct {
+	// Properties are the hot reloadable properties of the redpanda.yaml
+	// applied through the Admin API. The ones removed from the
+	// redpanda.yaml are reset to their default.
+	// +optional
+	Properties	[]string	`json:"properties,omitempty"`
+	// ConfigHash is the digest of the redpanda.yaml properties which
+	// require a restart, as of the last time the brokers were ready
+	// +optional
+	ConfigHash	string	`json:"configHash,omitempty"`
+	// RestartHash is the ConfigHash the brokers were last rolled out for.
+	// It is only set once a property requiring a restart changes, so the
+	// brokers are not rolled out when the schema is first reported.
+	// +optional
+	RestartHash	string	`json:"restartHash,omitempty"`
+}
+
+// LicenseStatus describes the enterprise license loaded by the cluster
+type LicenseStatus struct {
+	// Organization the license was issued to
+	Organization	string	`json:"organization,omitempty"`
+	// Type of the license
+	Type	string	`json:"type,omitempty"`
+	// Expires is the expiry time of the license
+	Expires	metav1.Time	`json:"expires,omitempty"`
+	// Valid is false once the license expired
+	Valid	bool	`json:"valid"`
+}
+
+// DecommissionStatus is the progress of a broker decommissioning
+type DecommissionStatus struct {
+	// NodeID is the redpanda node id of the decommissioned broker
+	NodeID	int	`json:"nodeId"`
+	// StartTime is when the decommissioning was requested
+	StartTime	metav1.Time	`json:"startTime"`
+}
+
+// MaintenanceStatus is the progress of a broker restart in maintenance mode
+type MaintenanceStatus struct {
+	// NodeID is the ordinal of the broker in maintenance mode, its redpanda
+	// node id unless the broker was replaced
+	NodeID	int	`json:"nodeId"`
+	// StartTime is when the maintenance mode was enabled
+	StartTime	metav1.Time	`json:"startTime"`
+	// Restarting is true once the leadership was transferred and the
+	// broker is released to the rollout
+	// +optional
+	Restarting	bool	`json:"restarting,omitempty"`
+}
+
+// ReplacementStatus is the progress of a broker replacement
+type ReplacementStatus struct {
+	// NodeID is the redpanda node id of the replaced broker
+	NodeID	int	`json:"nodeId"`
+	// Pod is the name of the pod of the replaced broker
+	Pod	string	`json:"pod"`
+	// StartTime is when the broker was decommissioned
+	StartTime	metav1.Time	`json:"startTime"`
+	// Decommissioned is true once the broker left the cluster, its data
+	// claim is then deleted
+	// +optional
+	Decommissioned	bool	`json:"decommissioned,omitempty"`
+	// NewNodeID is the node id of the broker restarted on an empty
+	// volume, it is set once the replaced broker left the cluster
+	// +optional
+	NewNodeID	int	`json:"newNodeId,omitempty"`
+	// ClaimUID is the UID of the deleted data claim, which tells it apart
+	// from the claim created for the new broker
+	// +optional
+	ClaimUID	types.UID	`json:"claimUid,omitempty"`
+}
+
+// BrokerStatus is the resource usage of a broker
+type BrokerStatus struct {
+	// NodeID is the redpanda node id of the broker
+	NodeID	int	`json:"nodeId"`
+	// DiskUsedBytes is the used space of the broker data disks
+	DiskUsedBytes	int64	`json:"diskUsedBytes"`
+	// DiskTotalBytes is the capacity of the broker data disks
+	DiskTotalBytes	int64	`json:"diskTotalBytes"`
+	// PartitionCount is the number of partition replicas hosted by the
+	// broker, it stays 0 with the brokers not reporting the cluster
+	// partitions
+	PartitionCount	int	`json:"partitionCount"`
+	// InMaintenance is true while the broker is in maintenance mode
+	// +optional
+	InMaintenance	bool	`json:"inMaintenance,omitempty"`
+}
+
+// These are the condition types set on the Cluster status
+const (
+	// ClusterProgressing is true while the brokers are rolled out to a new
+	// version, until every broker is ready and reports it through the
+	// Admin API
+	ClusterProgressing	= "Progressing"
+	// ClusterDegraded is true when the operator refuses to apply the
+	// desired state, the reason and message explain why
+	ClusterDegraded	= "Degraded"
+	// ClusterServiceRecreated is true once the service governing the
+	// StatefulSet was recreated as a headless service
+	ClusterServiceRecreated	= "ServiceRecreated"
+	// ClusterDiskSpaceLow is true when the free disk space of a broker
+	// is below the storage space alert threshold
+	ClusterDiskSpaceLow	= "DiskSpaceLow"
+	// ClusterPaused is true while the reconciliation of the Cluster is
+	// paused with the ManagedAnnotation
+	ClusterPaused	= "Paused"
+	// ClusterRollingOut is true while the StatefulSet rolls the brokers
+	// out to a new pod template
+	ClusterRollingOut	= "RollingOut"
+	// ClusterStorageClassMissing is true while the StorageClass of the data
+	// volumes does not exist, their claims then stay pending
+	ClusterStorageClassMissing	= "StorageClassMissing"
+)
+
+// ReplacedNodeIDOffset is added to the node id of a replaced broker.
+// Redpanda does not let a removed node id join the cluster again, so the
+// broker restarted on an empty volume needs a new one. The node ids derived
+// from the ordinals stay below it while the broker replacement is enabled.
+const ReplacedNodeIDOffset = 1000
+
+// ManagedAnnotation set to "false" pauses the reconciliation of a Cluster,
+// the operator then leaves all of its resources untouched until the
+// annotation is removed
+const ManagedAnnotation = "redpanda.vectorized.io/managed"
+
+// RecreateStatefulSetAnnotation set to "true" allows the operator to
+// recreate the StatefulSet, without its pods, when an immutable field like
+// its serviceName differs from the desired one
+const RecreateStatefulSetAnnotation = "redpanda.vectorized.io/recreate-statefulset"
+
+// RestartedAtAnnotation restarts every broker, one at a time, whenever its
+// value changes, e.g. to the current time. The restart starts once every
+// broker is ready.
+const RestartedAtAnnotation = "redpanda.vectorized.io/restartedAt"
+
+// LegacyNameAnnotation is the name of the resources created for the Cluster
+// by an older operator version. When it is set the operator adopts these
+// resources, see the operator README.
+const LegacyNameAnnotation = "redpanda.vectorized.io/legacy-name"
+
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:subresource:scale:specpath=.spec.replicas,statuspath=.status.replicas,selectorpath=.status.selector
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+//+kubebuilder:printcolumn:name="Last Reconcile",type="date",JSONPath=".status.lastReconcileTime"
 
 // Cluster is the Schema for the clusters API
 type Cluster struct {
@@ -71,12 +941,156 @@ type ClusterList struct {
 
 // RedpandaConfig is the definition of the main configuration
 type RedpandaConfig struct {
-	RPCServer		SocketAddress	`json:"rpcServer,omitempty"`
+	RPCServer		RPCServer	`json:"rpcServer,omitempty"`
 	AdvertisedRPCAPI	SocketAddress	`json:"advertisedRpcApi,omitempty"`
 	KafkaAPI		SocketAddress	`json:"kafkaApi,omitempty"`
 	AdvertisedKafkaAPI	SocketAddress	`json:"advertisedKafkaApi,omitempty"`
-	AdminAPI		SocketAddress	`json:"admin,omitempty"`
-	DeveloperMode		bool		`json:"developerMode,omitempty"`
+	// KafkaAPITLS serves the kafka API over TLS. The operator reports the
+	// Cluster as degraded when the certificate does not cover the address
+	// advertised by every broker.
+	// +optional
+	KafkaAPITLS	KafkaAPITLS	`json:"kafkaApiTls,omitempty"`
+	AdminAPI	AdminAPI	`json:"admin,omitempty"`
+	// BindAddress is the address the RPC, Kafka API and Admin API listeners
+	// bind to. It is either PodIP, which binds them to the IP address of
+	// each broker pod, or one of the 0.0.0.0 and :: wildcard addresses,
+	// e.g. in IPv6 clusters. An IP address is shared by every broker and
+	// would only exist in one pod. Defaults to 0.0.0.0.
+	// +optional
+	BindAddress	string	`json:"bindAddress,omitempty"`
+	// AdvertisePodIP makes every broker advertise the IP address of its
+	// pod as its kafka API and RPC address, instead of its DNS name in the
+	// headless service, in the networks where the pod IPs are routable by
+	// the clients. The seed servers are still resolved through DNS. It can
+	// not be combined with the external connectivity, whose clients can
+	// not reach the pods, nor with the kafka API and RPC server TLS, whose
+	// certificates can not cover the pod IPs. Changing it rolls the
+	// brokers out.
+	// +optional
+	AdvertisePodIP	bool	`json:"advertisePodIP,omitempty"`
+	// DeveloperMode runs redpanda in developer mode and only prefers
+	// spreading the brokers across the nodes, so that a cluster with
+	// several brokers can run on a single node. It is not meant for
+	// production.
+	DeveloperMode	bool	`json:"developerMode,omitempty"`
+	// KafkaConnectionLimits protects the brokers from too many client
+	// connections, oversized requests and idle connections
+	KafkaConnectionLimits	KafkaConnectionLimits	`json:"kafkaConnectionLimits,omitempty"`
+	// DiskAlerts configures how redpanda reacts to the disks filling up
+	DiskAlerts	DiskAlerts	`json:"diskAlerts,omitempty"`
+	// Retention sets the default retention of the topics
+	Retention	TopicRetention	`json:"retention,omitempty"`
+	// Raft tunes the heartbeats and the elections of the raft groups, e.g.
+	// to avoid spurious elections across high latency zones
+	// +optional
+	Raft	RaftConfig	`json:"raft,omitempty"`
+	// ExternalSeedServers are the RPC addresses of the brokers of an
+	// existing cluster. When set, every broker, including the one with
+	// ordinal 0, joins that cluster instead of bootstrapping a new one.
+	// +optional
+	ExternalSeedServers	[]ServerAddress	`json:"externalSeedServers,omitempty"`
+}
+
+// BindAddressPodIP binds the listeners of each broker to the IP address of
+// its pod
+const BindAddressPodIP = "PodIP"
+
+// ServerAddress is the address of a server outside of the Cluster
+type ServerAddress struct {
+	// Address is the host name or the IP address of the server
+	Address	string	`json:"address"`
+	// Port defaults to the redpanda RPC port
+	// +optional
+	Port	int	`json:"port,omitempty"`
+}
+
+// DiskAlerts maps to the redpanda disk usage settings. Zero values are not
+// rendered and leave the redpanda defaults in place.
+type DiskAlerts struct {
+	// ReservationPercent is the percentage of the disk kept free by
+	// redpanda for its own use (disk_reservation_percent)
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	ReservationPercent	int	`json:"reservationPercent,omitempty"`
+	// FreeThresholdPercent is the percentage of free disk space below
+	// which redpanda raises a storage space alert
+	// (storage_space_alert_free_threshold_percent). The operator reports
+	// the DiskSpaceLow condition using the same threshold.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	FreeThresholdPercent	int	`json:"freeThresholdPercent,omitempty"`
+}
+
+// TopicRetention maps to the redpanda default topic retention settings.
+// Unset values are not rendered and leave the redpanda defaults in place.
+type TopicRetention struct {
+	// RetentionMs is how long the data of a topic is kept, in milliseconds
+	// (log_retention_ms). -1 keeps the data forever.
+	// +kubebuilder:validation:Minimum=-1
+	// +optional
+	RetentionMs	*int64	`json:"retentionMs,omitempty"`
+	// RetentionBytes is the size above which the oldest data of a
+	// partition is deleted (retention_bytes). -1 does not limit the size.
+	// +kubebuilder:validation:Minimum=-1
+	// +optional
+	RetentionBytes	*int64	`json:"retentionBytes,omitempty"`
+	// SegmentSize is the size of the log segments, in bytes
+	// (log_segment_size). The data is deleted one segment at a time.
+	// +kubebuilder:validation:Minimum=1048576
+	// +optional
+	SegmentSize	*int64	`json:"segmentSize,omitempty"`
+}
+
+// RaftConfig maps to the redpanda raft timeout settings. Unset values are
+// not rendered and leave the redpanda defaults in place, which are used to
+// validate the configured ones.
+type RaftConfig struct {
+	// HeartbeatInterval is how often a leader sends heartbeats to its
+	// followers (raft_heartbeat_interval_ms), 150ms by default
+	// +optional
+	HeartbeatInterval	*metav1.Duration	`json:"heartbeatInterval,omitempty"`
+	// HeartbeatTimeout is how long a leader waits for the reply to a
+	// heartbeat (raft_heartbeat_timeout_ms), 3s by default. It must be
+	// longer than the heartbeat interval.
+	// +optional
+	HeartbeatTimeout	*metav1.Duration	`json:"heartbeatTimeout,omitempty"`
+	// ElectionTimeout is how long a follower waits for a heartbeat before
+	// starting an election (election_timeout_ms), 1.5s by default. It must
+	// be longer than the heartbeat interval.
+	// +optional
+	ElectionTimeout	*metav1.Duration	`json:"electionTimeout,omitempty"`
+}
+
+// KafkaConnectionLimits maps to the redpanda kafka connection settings.
+// Zero values are not rendered and leave the redpanda defaults in place.
+type KafkaConnectionLimits struct {
+	// MaxConnections is the maximum number of kafka client connections per
+	// broker (kafka_connections_max)
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxConnections	int	`json:"maxConnections,omitempty"`
+	// MaxConnectionsPerIP is the maximum number of kafka client connections
+	// per broker from a single IP address (kafka_connections_max_per_ip)
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxConnectionsPerIP	int	`json:"maxConnectionsPerIp,omitempty"`
+	// ConnectionRateLimit is the maximum number of new connections per
+	// second per core (kafka_connection_rate_limit)
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	ConnectionRateLimit	int	`json:"connectionRateLimit,omitempty"`
+	// MaxRequestBytes is the maximum size of a kafka request, larger
+	// requests are rejected (kafka_request_max_bytes)
+	// +kubebuilder:validation:Minimum=1048576
+	// +optional
+	MaxRequestBytes	int	`json:"maxRequestBytes,omitempty"`
+	// IdleTimeout closes the client connections without any request for
+	// that long, it is at least one second
+	// (kafka_connection_idle_timeout_ms)
+	// +optional
+	IdleTimeout	*metav1.Duration	`json:"idleTimeout,omitempty"`
 }
 
 // SocketAddress provide the way to configure the port
@@ -84,6 +1098,112 @@ type SocketAddress struct {
 	Port int `json:"port,omitempty"`
 }
 
+// AdminAPI configures the redpanda Admin API listener and the way
+// the operator connects to it
+type AdminAPI struct {
+	Port	int		`json:"port,omitempty"`
+	TLS	AdminAPITLS	`json:"tls,omitempty"`
+	// ServiceEnabled creates the <cluster name>-admin ClusterIP service
+	// balancing the Admin API requests across all brokers
+	// +optional
+	ServiceEnabled	bool	`json:"serviceEnabled,omitempty"`
+	// RequireAuth makes the Admin API only accept the requests of the
+	// superusers (admin_api_require_auth). The operator authenticates with
+	// the credentials of the bootstrap superuser, so SASL must be enabled.
+	// The property is set through the Admin API once the superuser exists.
+	// The superuser password can not be changed afterwards, the Cluster is
+	// degraded with the SuperuserCredentialsRejected reason until the
+	// previous password is restored.
+	// +optional
+	RequireAuth	bool	`json:"requireAuth,omitempty"`
+	// HealthCheck configures the endpoint checked by the probes of the
+	// brokers and by the operator
+	// +optional
+	HealthCheck	AdminAPIHealthCheck	`json:"healthCheck,omitempty"`
+	// URL is the http or https base URL the operator reaches the Admin API
+	// at, e.g. a proxy in front of the brokers in a hybrid setup. It
+	// replaces the per-broker DNS names of the headless service for every
+	// operator Admin API call, the probes of the brokers are unchanged. The
+	// https servers are verified with the TLS CA, or the system roots.
+	// +optional
+	URL	string	`json:"url,omitempty"`
+}
+
+// AdminAPIHealthCheck is the Admin API endpoint reporting the readiness of
+// a broker
+type AdminAPIHealthCheck struct {
+	// Scheme of the endpoint, it defaults to HTTPS when the Admin API TLS
+	// has a CA and to HTTP otherwise. Over HTTPS without a CA, the operator
+	// verifies the servers with the system roots.
+	// +kubebuilder:validation:Enum=HTTP;HTTPS
+	// +optional
+	Scheme	corev1.URIScheme	`json:"scheme,omitempty"`
+	// Path of the endpoint, it defaults to /v1/status/ready
+	// +kubebuilder:validation:Pattern=`^/`
+	// +optional
+	Path	string	`json:"path,omitempty"`
+}
+
+// RPCServer configures the listener used by the brokers to talk to each
+// other
+type RPCServer struct {
+	SocketAddress	`json:",inline"`
+	// TLS encrypts the traffic between the brokers
+	// +optional
+	TLS	RPCServerTLS	`json:"tls,omitempty"`
+}
+
+// RPCServerTLS configures the mutual TLS between the brokers
+type RPCServerTLS struct {
+	// Enabled makes the brokers only accept RPC connections from clients
+	// presenting a certificate signed by the CA of CertSecretRef
+	// +optional
+	Enabled	bool	`json:"enabled,omitempty"`
+	// CertSecretRef references a Secret in the Cluster namespace holding
+	// the tls.crt, tls.key and ca.crt keys. The certificate is shared by
+	// all brokers, its subject alternative names must cover the DNS names
+	// of every broker, e.g. with the *.<cluster>.<namespace>.svc.cluster.local
+	// wildcard. Required when TLS is enabled.
+	// +optional
+	CertSecretRef	*corev1.LocalObjectReference	`json:"certSecretRef,omitempty"`
+}
+
+// KafkaAPITLS configures the certificate of the kafka API listener
+type KafkaAPITLS struct {
+	// Enabled serves the kafka API over TLS with the certificate of
+	// CertSecretRef, or with the self-signed certificate when
+	// spec.tls.selfSigned is set
+	// +optional
+	Enabled	bool	`json:"enabled,omitempty"`
+	// CertSecretRef references a Secret in the Cluster namespace holding
+	// the tls.crt, tls.key and ca.crt keys. The certificate is shared by
+	// all brokers, its subject alternative names must cover the advertised
+	// address of every broker: its DNS name in the headless service, or
+	// its spec.externalConnectivity.perBrokerAddresses entry. Required
+	// when TLS is enabled without spec.tls.selfSigned.
+	// +optional
+	CertSecretRef	*corev1.LocalObjectReference	`json:"certSecretRef,omitempty"`
+	// RequireClientAuth makes the kafka API only accept the clients
+	// presenting a certificate signed by the CA of the certificate
+	// +optional
+	RequireClientAuth	bool	`json:"requireClientAuth,omitempty"`
+}
+
+// AdminAPITLS configures how the operator verifies the Admin API server
+// certificate
+type AdminAPITLS struct {
+	// CASecretRef references a Secret in the Cluster namespace that holds
+	// the CA certificate under the ca.crt key. When set the operator talks
+	// to the Admin API over https and trusts only the given CA.
+	// +optional
+	CASecretRef	*corev1.LocalObjectReference	`json:"caSecretRef,omitempty"`
+	// ClientCertSecretRef references a kubernetes.io/tls Secret in the
+	// Cluster namespace whose certificate the operator presents to the
+	// Admin API requiring mutual TLS. Requires CASecretRef.
+	// +optional
+	ClientCertSecretRef	*corev1.LocalObjectReference	`json:"clientCertSecretRef,omitempty"`
+}
+
 func init() {
 	SchemeBuilder.Register(&Cluster{}, &ClusterList{})
 }
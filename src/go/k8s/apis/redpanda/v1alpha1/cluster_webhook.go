@@ -11,7 +11,22 @@
 package v1alpha1
 
 import (
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
@@ -20,6 +35,10 @@ import (
 // log is for logging in this package.
 var log = logf.Log.WithName("cluster-resource")
 
+// AllowCombinedChangesAnnotation opts a Cluster out of the validation
+// rejecting updates that change both the replicas and the version
+const AllowCombinedChangesAnnotation = "redpanda.vectorized.io/allow-combined-changes"
+
 // SetupWebhookWithManager autogenerated function by kubebuilder
 func (r *Cluster) SetupWebhookWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewWebhookManagedBy(mgr).
@@ -46,18 +65,136 @@ var _ webhook.Validator = &Cluster{}
 func (r *Cluster) ValidateCreate() error {
 	log.Info("validate create", "name", r.Name)
 
-	// TODO(user): fill in your validation logic upon object creation.
-	return nil
+	return r.validate(nil)
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
 func (r *Cluster) ValidateUpdate(old runtime.Object) error {
 	log.Info("validate update", "name", r.Name)
 
-	// TODO(user): fill in your validation logic upon object update.
+	oldCluster, _ := old.(*Cluster)
+
+	return r.validate(oldCluster)
+}
+
+// validate checks the rules shared by create and update, old is nil on
+// create
+func (r *Cluster) validate(old *Cluster) error {
+	var allErrs field.ErrorList
+
+	allErrs = append(allErrs, r.validateLockMemory()...)
+	allErrs = append(allErrs, r.validateReserveMemory()...)
+	allErrs = append(allErrs, r.validateAdditionalArguments()...)
+	allErrs = append(allErrs, r.validateIOProperties()...)
+	allErrs = append(allErrs, r.validateReadOnlyData()...)
+	allErrs = append(allErrs, r.validateFileModes()...)
+	allErrs = append(allErrs, r.validateRetention()...)
+	allErrs = append(allErrs, r.validateIdleTimeout()...)
+	allErrs = append(allErrs, r.validateRaft()...)
+	allErrs = append(allErrs, r.validateBindAddress()...)
+	allErrs = append(allErrs, r.validateAdvertisePodIP()...)
+	allErrs = append(allErrs, r.validateImageDigest()...)
+	allErrs = append(allErrs, r.validateRPCServerTLS()...)
+	allErrs = append(allErrs, r.validatePandaProxyTLS()...)
+	allErrs = append(allErrs, r.validateKafkaAPITLS()...)
+	allErrs = append(allErrs, r.validateAdminAPIAuth()...)
+	allErrs = append(allErrs, r.validateSASLMechanisms()...)
+	allErrs = append(allErrs, r.validateAdminAPIURL()...)
+	allErrs = append(allErrs, r.validateCloudStorage()...)
+	allErrs = append(allErrs, r.validateExternalConnectivity(old)...)
+	allErrs = append(allErrs, r.validatePerBrokerAddresses()...)
+	allErrs = append(allErrs, r.validatePerBrokerNodePorts()...)
+	allErrs = append(allErrs, r.validateBrokerGroups()...)
+	allErrs = append(allErrs, r.validateBrokerReplacement()...)
+
+	if old != nil {
+		allErrs = append(allErrs, r.validateSingleOperation(old)...)
+		allErrs = append(allErrs, r.validateStorageClassName(old)...)
+		allErrs = append(allErrs, r.validateBrokerGroupsUpdate(old)...)
+		allErrs = append(allErrs, r.validateSuperuserMechanism(old)...)
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: GroupVersion.Group, Kind: "Cluster"},
+		r.Name, allErrs)
+}
+
+func (r *Cluster) validateLockMemory() field.ErrorList {
+	if !r.Spec.Resources.LockMemory {
+		return nil
+	}
+
+	if _, ok := r.Spec.Resources.Limits[corev1.ResourceMemory]; !ok {
+		return field.ErrorList{field.Required(
+			field.NewPath("spec").Child("resources").Child("limits").Child("memory"),
+			"locking memory requires a memory limit")}
+	}
+
 	return nil
 }
 
+// maxReserveMemoryPercent leaves at least half of the memory limit to
+// redpanda
+const maxReserveMemoryPercent = 50
+
+func (r *Cluster) validateReserveMemory() field.ErrorList {
+	percent := r.Spec.Resources.ReserveMemoryPercent
+	if percent >= 0 && percent <= maxReserveMemoryPercent {
+		return nil
+	}
+
+	return field.ErrorList{field.Invalid(
+		field.NewPath("spec").Child("resources").Child("reserveMemoryPercent"),
+		percent, fmt.Sprintf("must be between 0 and %d", maxReserveMemoryPercent))}
+}
+
+// operatorArguments are the redpanda arguments set by the operator, they
+// can not be passed as additional arguments
+var operatorArguments = []string{
+	"check", "smp", "memory", "lock-memory", "cpuset", "io-properties-file",
+	"default-log-level", "reserve-memory", "logger-format",
+}
+
+func (r *Cluster) validateAdditionalArguments() field.ErrorList {
+	var allErrs field.ErrorList
+
+	path := field.NewPath("spec").Child("additionalCommandlineArguments")
+
+	keys := make([]string, 0, len(r.Spec.AdditionalCommandlineArguments))
+	for key := range r.Spec.AdditionalCommandlineArguments {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		switch {
+		case key == "" || strings.HasPrefix(key, "-"):
+			allErrs = append(allErrs, field.Invalid(path.Key(key), key,
+				"the argument name must not be empty nor start with a dash"))
+		case contains(operatorArguments, key):
+			allErrs = append(allErrs, field.Forbidden(path.Key(key),
+				"the argument is set by the operator"))
+		}
+	}
+
+	return allErrs
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
 func (r *Cluster) ValidateDelete() error {
 	log.Info("validate delete", "name", r.Name)
@@ -65,3 +202,628 @@ func (r *Cluster) ValidateDelete() error {
 	// TODO(user): fill in your validation logic upon object deletion.
 	return nil
 }
+
+func (r *Cluster) validateIOProperties() field.ErrorList {
+	io := r.Spec.Storage.IOProperties
+	if io == nil {
+		return nil
+	}
+
+	if (io.Inline == "") == (io.ConfigMapRef == nil) {
+		return field.ErrorList{field.Invalid(
+			field.NewPath("spec").Child("storage").Child("ioProperties"),
+			io, "exactly one of inline or configMapRef must be set")}
+	}
+
+	return nil
+}
+
+func (r *Cluster) validateReadOnlyData() field.ErrorList {
+	debug := r.Spec.Debug
+	if !debug.ReadOnlyData || debug.OverrideCommand {
+		return nil
+	}
+
+	return field.ErrorList{field.Invalid(
+		field.NewPath("spec").Child("debug").Child("readOnlyData"), debug.ReadOnlyData,
+		"redpanda can not start on a read-only data directory, it requires spec.debug.overrideCommand")}
+}
+
+func (r *Cluster) validateIdleTimeout() field.ErrorList {
+	timeout := r.Spec.Configuration.KafkaConnectionLimits.IdleTimeout
+	if timeout == nil || timeout.Duration >= time.Second {
+		return nil
+	}
+
+	return field.ErrorList{field.Invalid(
+		field.NewPath("spec").Child("configuration").Child("kafkaConnectionLimits").Child("idleTimeout"),
+		timeout.Duration.String(), "the idle timeout must be at least one second")}
+}
+
+// The redpanda defaults of the raft timeouts
+const (
+	defaultRaftHeartbeatInterval	= 150 * time.Millisecond
+	defaultRaftHeartbeatTimeout	= 3 * time.Second
+	defaultRaftElectionTimeout	= 1500 * time.Millisecond
+)
+
+// validateRaft requires timeouts of at least one millisecond, the unit of
+// the rendered settings, and a heartbeat interval shorter than the
+// heartbeat and the election timeouts. Otherwise the followers would not
+// hear from their leader in time and start elections.
+func (r *Cluster) validateRaft() field.ErrorList {
+	raft := r.Spec.Configuration.Raft
+	path := field.NewPath("spec").Child("configuration").Child("raft")
+
+	var allErrs field.ErrorList
+
+	timeout := func(name string, d *metav1.Duration, def time.Duration) time.Duration {
+		if d == nil {
+			return def
+		}
+
+		if d.Duration < time.Millisecond {
+			allErrs = append(allErrs, field.Invalid(path.Child(name), d.Duration.String(),
+				"the timeout must be at least one millisecond"))
+		}
+
+		return d.Duration
+	}
+
+	interval := timeout("heartbeatInterval", raft.HeartbeatInterval, defaultRaftHeartbeatInterval)
+	heartbeat := timeout("heartbeatTimeout", raft.HeartbeatTimeout, defaultRaftHeartbeatTimeout)
+	election := timeout("electionTimeout", raft.ElectionTimeout, defaultRaftElectionTimeout)
+
+	if heartbeat <= interval {
+		allErrs = append(allErrs, field.Invalid(path.Child("heartbeatTimeout"), heartbeat.String(),
+			fmt.Sprintf("the heartbeat timeout must be longer than the heartbeat interval of %s", interval)))
+	}
+
+	if election <= interval {
+		allErrs = append(allErrs, field.Invalid(path.Child("electionTimeout"), election.String(),
+			fmt.Sprintf("the election timeout must be longer than the heartbeat interval of %s", interval)))
+	}
+
+	return allErrs
+}
+
+var imageDigestRegexp = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+func (r *Cluster) validateImageDigest() field.ErrorList {
+	if r.Spec.ImageDigest == "" || imageDigestRegexp.MatchString(r.Spec.ImageDigest) {
+		return nil
+	}
+
+	return field.ErrorList{field.Invalid(
+		field.NewPath("spec").Child("imageDigest"),
+		r.Spec.ImageDigest, "the digest must be of the sha256:<64 hex characters> form")}
+}
+
+// validateAdvertisePodIP rejects the pod IPs advertised to the external
+// clients, which can only reach the brokers through the services, and to
+// the TLS clients, as the certificates can not cover the pod IPs assigned
+// on every restart
+func (r *Cluster) validateAdvertisePodIP() field.ErrorList {
+	if !r.Spec.Configuration.AdvertisePodIP {
+		return nil
+	}
+
+	path := field.NewPath("spec").Child("configuration").Child("advertisePodIP")
+
+	external := r.Spec.ExternalConnectivity
+	if external.Enabled || len(external.PerBrokerAddresses) > 0 || external.PerBrokerNodePorts != nil {
+		return field.ErrorList{field.Invalid(path, true,
+			"the pod IPs can not be advertised along with the external connectivity")}
+	}
+
+	if r.Spec.TLS.SelfSigned || r.Spec.Configuration.KafkaAPITLS.Enabled || r.Spec.Configuration.RPCServer.TLS.Enabled {
+		return field.ErrorList{field.Invalid(path, true,
+			"the pod IPs can not be advertised along with the kafka API or the RPC server TLS")}
+	}
+
+	return nil
+}
+
+// validateBindAddress only accepts PodIP and the wildcard addresses. Every
+// broker binds to the same address, any other IP address is only assigned
+// to one pod, at best.
+func (r *Cluster) validateBindAddress() field.ErrorList {
+	address := r.Spec.Configuration.BindAddress
+	if address == "" || address == BindAddressPodIP {
+		return nil
+	}
+
+	if ip := net.ParseIP(address); ip == nil || !ip.IsUnspecified() {
+		return field.ErrorList{field.Invalid(
+			field.NewPath("spec").Child("configuration").Child("bindAddress"),
+			address, "the bind address must be PodIP, 0.0.0.0 or ::")}
+	}
+
+	return nil
+}
+
+// validateRetention rejects a zero retention, which would delete the data
+// right away, and segments larger than the retained size
+func (r *Cluster) validateRetention() field.ErrorList {
+	var allErrs field.ErrorList
+
+	retention := r.Spec.Configuration.Retention
+	path := field.NewPath("spec").Child("configuration").Child("retention")
+
+	if ms := retention.RetentionMs; ms != nil && *ms == 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("retentionMs"), *ms,
+			"a zero retention deletes the data right away, use -1 to keep it forever"))
+	}
+
+	if bytes := retention.RetentionBytes; bytes != nil && *bytes == 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("retentionBytes"), *bytes,
+			"a zero retention deletes the data right away, use -1 to not limit the size"))
+	}
+
+	if bytes, size := retention.RetentionBytes, retention.SegmentSize; bytes != nil && size != nil &&
+		*bytes > 0 && *size > *bytes {
+		allErrs = append(allErrs, field.Invalid(path.Child("segmentSize"), *size,
+			"the segments can not be larger than the retained size"))
+	}
+
+	return allErrs
+}
+
+// validateSingleOperation rejects updates scaling and upgrading the
+// Cluster at once, as the order of both operations would be ambiguous
+func (r *Cluster) validateSingleOperation(old *Cluster) field.ErrorList {
+	if r.Annotations[AllowCombinedChangesAnnotation] == "true" {
+		return nil
+	}
+
+	upgraded := r.Spec.Version != old.Spec.Version || r.Spec.ImageDigest != old.Spec.ImageDigest
+	if !upgraded || reflect.DeepEqual(r.Spec.Replicas, old.Spec.Replicas) {
+		return nil
+	}
+
+	return field.ErrorList{field.Forbidden(
+		field.NewPath("spec").Child("replicas"),
+		"replicas and version can not be changed in the same update, apply one change at a time "+
+			"or set the "+AllowCombinedChangesAnnotation+" annotation to true")}
+}
+
+// validateStorageClassName rejects changing the storage class, the volume
+// claim templates of the StatefulSet are immutable
+func (r *Cluster) validateStorageClassName(old *Cluster) field.ErrorList {
+	if r.Spec.Storage.StorageClassName == old.Spec.Storage.StorageClassName {
+		return nil
+	}
+
+	return field.ErrorList{field.Forbidden(
+		field.NewPath("spec").Child("storage").Child("storageClassName"),
+		"the storage class of the data volumes can not be changed")}
+}
+
+// validateSASLMechanisms rejects the unsupported and the duplicated SASL
+// mechanisms
+func (r *Cluster) validateSASLMechanisms() field.ErrorList {
+	var allErrs field.ErrorList
+
+	path := field.NewPath("spec").Child("sasl").Child("mechanisms")
+	seen := map[SASLMechanism]bool{}
+
+	for i, m := range r.Spec.SASL.Mechanisms {
+		switch {
+		case m != SASLMechanismScramSha256 && m != SASLMechanismScramSha512:
+			allErrs = append(allErrs, field.NotSupported(path.Index(i), m,
+				[]string{string(SASLMechanismScramSha256), string(SASLMechanismScramSha512)}))
+		case seen[m]:
+			allErrs = append(allErrs, field.Duplicate(path.Index(i), m))
+		}
+
+		seen[m] = true
+	}
+
+	return allErrs
+}
+
+// validateSuperuserMechanism rejects removing the mechanism the bootstrap
+// superuser was created with, the superuser and the Console could no
+// longer authenticate
+func (r *Cluster) validateSuperuserMechanism(old *Cluster) field.ErrorList {
+	mechanism := SASLMechanism(old.Status.SuperuserMechanism)
+	if mechanism == "" || !r.Spec.SASL.Enabled {
+		return nil
+	}
+
+	mechanisms := r.Spec.SASL.Mechanisms
+	if len(mechanisms) == 0 {
+		mechanisms = []SASLMechanism{SASLMechanismScramSha256}
+	}
+
+	for _, m := range mechanisms {
+		if m == mechanism {
+			return nil
+		}
+	}
+
+	return field.ErrorList{field.Forbidden(
+		field.NewPath("spec").Child("sasl").Child("mechanisms"),
+		fmt.Sprintf("the bootstrap superuser was created with %s, which can not be removed", mechanism))}
+}
+
+func (r *Cluster) validateAdminAPIAuth() field.ErrorList {
+	var allErrs field.ErrorList
+
+	adminAPI := r.Spec.Configuration.AdminAPI
+	path := field.NewPath("spec").Child("configuration").Child("admin")
+
+	if adminAPI.RequireAuth && !r.Spec.SASL.Enabled {
+		allErrs = append(allErrs, field.Invalid(path.Child("requireAuth"), adminAPI.RequireAuth,
+			"the operator authenticates as the bootstrap superuser, which requires spec.sasl.enabled"))
+	}
+
+	if adminAPI.TLS.ClientCertSecretRef != nil && adminAPI.TLS.CASecretRef == nil {
+		allErrs = append(allErrs, field.Required(path.Child("tls").Child("caSecretRef"),
+			"presenting a client certificate requires the Admin API over https"))
+	}
+
+	if adminAPI.HealthCheck.Scheme == corev1.URISchemeHTTP && adminAPI.TLS.CASecretRef != nil {
+		allErrs = append(allErrs, field.Invalid(path.Child("healthCheck").Child("scheme"), adminAPI.HealthCheck.Scheme,
+			"the Admin API with a CA is served over https"))
+	}
+
+	return allErrs
+}
+
+func (r *Cluster) validateAdminAPIURL() field.ErrorList {
+	adminAPI := r.Spec.Configuration.AdminAPI
+	if adminAPI.URL == "" {
+		return nil
+	}
+
+	path := field.NewPath("spec").Child("configuration").Child("admin").Child("url")
+
+	u, err := url.Parse(adminAPI.URL)
+	if err != nil {
+		return field.ErrorList{field.Invalid(path, adminAPI.URL, err.Error())}
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil ||
+		u.RawQuery != "" || u.Fragment != "" {
+		return field.ErrorList{field.Invalid(path, adminAPI.URL,
+			"must be an http or https URL without credentials, query nor fragment")}
+	}
+
+	if u.Scheme == "http" && adminAPI.TLS.CASecretRef != nil {
+		return field.ErrorList{field.Invalid(path, adminAPI.URL, "the Admin API with a CA is reached over https")}
+	}
+
+	return nil
+}
+
+// maxFileMode is the largest mode of a file of a ConfigMap volume
+const maxFileMode = 0777
+
+func (r *Cluster) validateFileModes() field.ErrorList {
+	var allErrs field.ErrorList
+
+	modes := r.Spec.ConfigDir.FileModes
+
+	names := make([]string, 0, len(modes))
+	for name := range modes {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		if mode := modes[name]; mode < 0 || mode > maxFileMode {
+			allErrs = append(allErrs, field.Invalid(
+				field.NewPath("spec").Child("configDir").Child("fileModes").Key(name), mode,
+				"the mode must be between 0 and 0777 (511)"))
+		}
+	}
+
+	return allErrs
+}
+
+func (r *Cluster) validateCloudStorage() field.ErrorList {
+	cloudStorage := r.Spec.CloudStorage
+	if !cloudStorage.Enabled {
+		return nil
+	}
+
+	var allErrs field.ErrorList
+
+	path := field.NewPath("spec").Child("cloudStorage")
+
+	if cloudStorage.Bucket == "" {
+		allErrs = append(allErrs, field.Required(path.Child("bucket"), "the tiered storage requires a bucket"))
+	}
+
+	if cloudStorage.Region == "" {
+		allErrs = append(allErrs, field.Required(path.Child("region"), "the tiered storage requires a region"))
+	}
+
+	if cloudStorage.CredentialsSecretRef == nil {
+		allErrs = append(allErrs, field.Required(path.Child("credentialsSecretRef"),
+			"the tiered storage requires the credentials of the object store"))
+	}
+
+	return allErrs
+}
+
+func (r *Cluster) validateRPCServerTLS() field.ErrorList {
+	rpcTLS := r.Spec.Configuration.RPCServer.TLS
+	path := field.NewPath("spec").Child("configuration").Child("rpcServer").Child("tls").Child("certSecretRef")
+
+	if r.Spec.TLS.SelfSigned {
+		if rpcTLS.CertSecretRef != nil {
+			return field.ErrorList{field.Forbidden(path,
+				"the RPC server uses the self-signed certificate when spec.tls.selfSigned is set")}
+		}
+
+		return nil
+	}
+
+	if rpcTLS.Enabled && rpcTLS.CertSecretRef == nil {
+		return field.ErrorList{field.Required(path,
+			"enabling the RPC server TLS requires a certificate Secret")}
+	}
+
+	return nil
+}
+
+func (r *Cluster) validateKafkaAPITLS() field.ErrorList {
+	kafkaTLS := r.Spec.Configuration.KafkaAPITLS
+	path := field.NewPath("spec").Child("configuration").Child("kafkaApiTls")
+
+	if kafkaTLS.Enabled && kafkaTLS.CertSecretRef == nil && !r.Spec.TLS.SelfSigned {
+		return field.ErrorList{field.Required(path.Child("certSecretRef"),
+			"enabling the kafka API TLS requires a certificate Secret or spec.tls.selfSigned")}
+	}
+
+	if kafkaTLS.RequireClientAuth && !kafkaTLS.Enabled {
+		return field.ErrorList{field.Invalid(path.Child("requireClientAuth"), kafkaTLS.RequireClientAuth,
+			"the client authentication requires spec.configuration.kafkaApiTls.enabled")}
+	}
+
+	return nil
+}
+
+func (r *Cluster) validatePandaProxyTLS() field.ErrorList {
+	proxyTLS := r.Spec.PandaProxy.TLS
+	path := field.NewPath("spec").Child("pandaProxy").Child("tls")
+
+	if proxyTLS.Enabled && proxyTLS.CertSecretRef == nil {
+		return field.ErrorList{field.Required(path.Child("certSecretRef"),
+			"enabling the Pandaproxy TLS requires a certificate Secret")}
+	}
+
+	if proxyTLS.RequireClientAuth && !proxyTLS.Enabled {
+		return field.ErrorList{field.Invalid(path.Child("requireClientAuth"), proxyTLS.RequireClientAuth,
+			"the client authentication requires spec.pandaProxy.tls.enabled")}
+	}
+
+	return nil
+}
+
+// validateExternalConnectivity requires an external address source when
+// the external connectivity is enabled. Without the per broker addresses
+// nor node ports, the brokers advertise their in-cluster DNS names, which
+// the clients behind the LoadBalancer service can not resolve. The
+// Clusters enabling it before the rule existed are still accepted.
+func (r *Cluster) validateExternalConnectivity(old *Cluster) field.ErrorList {
+	external := r.Spec.ExternalConnectivity
+	if !external.Enabled || len(external.PerBrokerAddresses) > 0 || external.PerBrokerNodePorts != nil {
+		return nil
+	}
+
+	if old != nil && old.Spec.ExternalConnectivity.Enabled {
+		return nil
+	}
+
+	return field.ErrorList{field.Required(
+		field.NewPath("spec").Child("externalConnectivity"),
+		"the external connectivity requires perBrokerAddresses or perBrokerNodePorts, "+
+			"otherwise the brokers advertise their in-cluster addresses")}
+}
+
+// validatePerBrokerAddresses requires one advertised address per replica,
+// each one a host name or an IP address
+func (r *Cluster) validatePerBrokerAddresses() field.ErrorList {
+	addresses := r.Spec.ExternalConnectivity.PerBrokerAddresses
+	if len(addresses) == 0 {
+		return nil
+	}
+
+	var allErrs field.ErrorList
+
+	path := field.NewPath("spec").Child("externalConnectivity").Child("perBrokerAddresses")
+
+	var replicas int32
+	if r.Spec.Replicas != nil {
+		replicas = *r.Spec.Replicas
+	}
+
+	if len(addresses) != int(replicas) {
+		allErrs = append(allErrs, field.Invalid(path, addresses,
+			fmt.Sprintf("one address is required per replica, %d replicas have %d addresses", replicas, len(addresses))))
+	}
+
+	for i, address := range addresses {
+		if net.ParseIP(address) != nil {
+			continue
+		}
+
+		if msgs := validation.IsDNS1123Subdomain(address); len(msgs) > 0 {
+			allErrs = append(allErrs, field.Invalid(path.Index(i), address, strings.Join(msgs, ", ")))
+		}
+	}
+
+	return allErrs
+}
+
+// maxNodePort is the end of the default node port range of Kubernetes
+const maxNodePort = 32767
+
+// validatePerBrokerNodePorts requires the node port of every broker to
+// fit in the node port range
+func (r *Cluster) validatePerBrokerNodePorts() field.ErrorList {
+	nodePorts := r.Spec.ExternalConnectivity.PerBrokerNodePorts
+	if nodePorts == nil || r.Spec.Replicas == nil || *r.Spec.Replicas == 0 {
+		return nil
+	}
+
+	if last := nodePorts.BasePort + *r.Spec.Replicas - 1; last > maxNodePort {
+		return field.ErrorList{field.Invalid(
+			field.NewPath("spec").Child("externalConnectivity").Child("perBrokerNodePorts").Child("basePort"),
+			nodePorts.BasePort,
+			fmt.Sprintf("the node port %d of the last broker is above %d", last, maxNodePort))}
+	}
+
+	return nil
+}
+
+// validateBrokerGroups requires unique group names and node ids which do
+// not overlap, neither between the groups nor with the Replicas brokers.
+// The per broker addresses and node ports are indexed by the ordinal of
+// the Replicas brokers, they can not be combined with the groups.
+func (r *Cluster) validateBrokerGroups() field.ErrorList {
+	groups := r.Spec.BrokerGroups
+	if len(groups) == 0 {
+		return nil
+	}
+
+	var allErrs field.ErrorList
+
+	path := field.NewPath("spec").Child("brokerGroups")
+
+	external := r.Spec.ExternalConnectivity
+	if len(external.PerBrokerAddresses) > 0 || external.PerBrokerNodePorts != nil {
+		allErrs = append(allErrs, field.Forbidden(path,
+			"the broker groups can not be combined with the per broker addresses nor node ports"))
+	}
+
+	var replicas int32
+	if r.Spec.Replicas != nil {
+		replicas = *r.Spec.Replicas
+	}
+
+	names := make(map[string]bool, len(groups))
+
+	for i := range groups {
+		g := &groups[i]
+
+		if names[g.Name] {
+			allErrs = append(allErrs, field.Duplicate(path.Index(i).Child("name"), g.Name))
+		}
+
+		names[g.Name] = true
+
+		if g.FirstNodeID < replicas {
+			allErrs = append(allErrs, field.Invalid(path.Index(i).Child("firstNodeId"), g.FirstNodeID,
+				fmt.Sprintf("the node ids 0 to %d are used by the replicas", replicas-1)))
+		}
+
+		for j := range groups[:i] {
+			o := &groups[j]
+			if g.FirstNodeID < o.FirstNodeID+o.Replicas && o.FirstNodeID < g.FirstNodeID+g.Replicas {
+				allErrs = append(allErrs, field.Invalid(path.Index(i).Child("firstNodeId"), g.FirstNodeID,
+					fmt.Sprintf("the node ids overlap with the ones of broker group %s", o.Name)))
+			}
+		}
+
+		if g.Resources.LockMemory {
+			if _, ok := g.Resources.Limits[corev1.ResourceMemory]; !ok {
+				allErrs = append(allErrs, field.Required(path.Index(i).Child("resources").Child("limits").Child("memory"),
+					"locking memory requires a memory limit"))
+			}
+		}
+	}
+
+	return allErrs
+}
+
+// validateBrokerGroupsUpdate rejects removing or scaling down a broker
+// group and changing its first node id, the brokers of a group are not
+// decommissioned
+func (r *Cluster) validateBrokerGroupsUpdate(old *Cluster) field.ErrorList {
+	var allErrs field.ErrorList
+
+	path := field.NewPath("spec").Child("brokerGroups")
+
+	for i := range old.Spec.BrokerGroups {
+		o := &old.Spec.BrokerGroups[i]
+
+		index := -1
+
+		for j := range r.Spec.BrokerGroups {
+			if r.Spec.BrokerGroups[j].Name == o.Name {
+				index = j
+			}
+		}
+
+		if index < 0 {
+			allErrs = append(allErrs, field.Forbidden(path,
+				fmt.Sprintf("broker group %s can not be removed", o.Name)))
+
+			continue
+		}
+
+		g := &r.Spec.BrokerGroups[index]
+
+		if g.Replicas < o.Replicas {
+			allErrs = append(allErrs, field.Forbidden(path.Index(index).Child("replicas"),
+				"a broker group can not be scaled down"))
+		}
+
+		if g.FirstNodeID != o.FirstNodeID {
+			allErrs = append(allErrs, field.Forbidden(path.Index(index).Child("firstNodeId"),
+				"the first node id of a broker group can not be changed"))
+		}
+	}
+
+	return allErrs
+}
+
+// minReplacementThreshold is the shortest failure threshold of the broker
+// replacement, the brokers restarted by a rollout or a node failure must
+// not be mistaken for failed ones
+const minReplacementThreshold = 5 * time.Minute
+
+// validateBrokerReplacement requires the failure threshold to be long
+// enough and 3 Replicas brokers at least, so that the controller keeps its
+// quorum while a broker is decommissioned. The node ids derived from the
+// ordinals must stay below the ones given to the replaced brokers.
+func (r *Cluster) validateBrokerReplacement() field.ErrorList {
+	replacement := r.Spec.BrokerReplacement
+	if !replacement.Enabled {
+		return nil
+	}
+
+	var allErrs field.ErrorList
+
+	path := field.NewPath("spec").Child("brokerReplacement")
+
+	if t := replacement.FailureThreshold; t != nil && t.Duration < minReplacementThreshold {
+		allErrs = append(allErrs, field.Invalid(path.Child("failureThreshold"), t.Duration.String(),
+			fmt.Sprintf("the failure threshold must be at least %s", minReplacementThreshold)))
+	}
+
+	if r.Spec.Replicas == nil || *r.Spec.Replicas < 3 {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec").Child("replicas"),
+			"the broker replacement requires 3 replicas at least"))
+	}
+
+	if r.Spec.Replicas != nil && *r.Spec.Replicas > ReplacedNodeIDOffset {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("replicas"), *r.Spec.Replicas,
+			fmt.Sprintf("the broker replacement supports %d replicas at most", ReplacedNodeIDOffset)))
+	}
+
+	for i := range r.Spec.BrokerGroups {
+		g := &r.Spec.BrokerGroups[i]
+		if g.FirstNodeID+g.Replicas > ReplacedNodeIDOffset {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("brokerGroups").Index(i).Child("firstNodeId"),
+				g.FirstNodeID, fmt.Sprintf("the node ids from %d are given to the replaced brokers", ReplacedNodeIDOffset)))
+		}
+	}
+
+	return allErrs
+}
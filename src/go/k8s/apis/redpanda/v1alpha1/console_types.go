@@ -0,0 +1,98 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConsoleSpec defines the desired state of Console
+type ConsoleSpec struct {
+	// ClusterRef references the Cluster of the Console namespace the
+	// Console connects to. The brokers, the Admin API and the Schema
+	// Registry are configured from its definition, with its TLS and SASL
+	// settings. The Console authenticates as the bootstrap superuser when
+	// SASL is enabled.
+	ClusterRef	corev1.LocalObjectReference	`json:"clusterRef"`
+	// Image is the fully qualified name of the Console container, it
+	// defaults to vectorized/console
+	// +optional
+	Image	string	`json:"image,omitempty"`
+	// Version is the Console container tag
+	// +kubebuilder:validation:MinLength=1
+	Version	string	`json:"version"`
+	// Replicas of the Console Deployment, it defaults to 1
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Replicas	*int32	`json:"replicas,omitempty"`
+	// Port of the web UI, it defaults to 8080
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port	int	`json:"port,omitempty"`
+	// Resources of the Console container
+	// +optional
+	Resources	corev1.ResourceRequirements	`json:"resources,omitempty"`
+	// KafkaClientCertSecretRef references a kubernetes.io/tls Secret of
+	// the Console namespace whose certificate the Console presents to the
+	// kafka API requiring client authentication. It defaults to the client
+	// certificate issued by the self-signed CA of the Cluster.
+	// +optional
+	KafkaClientCertSecretRef	*corev1.LocalObjectReference	`json:"kafkaClientCertSecretRef,omitempty"`
+}
+
+// ConsoleStatus defines the observed state of Console
+type ConsoleStatus struct {
+	// ReadyReplicas is the number of ready Console pods
+	// +optional
+	ReadyReplicas	int32	`json:"readyReplicas,omitempty"`
+	// Conditions describe the latest observations of the Console state
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions	[]metav1.Condition	`json:"conditions,omitempty"`
+}
+
+// These are the condition types set on the Console status
+const (
+	// ConsoleClusterAvailable is true while the referenced Cluster exists,
+	// has ready brokers and is not degraded
+	ConsoleClusterAvailable = "ClusterAvailable"
+)
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".spec.clusterRef.name"
+//+kubebuilder:printcolumn:name="Ready",type="integer",JSONPath=".status.readyReplicas"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// Console is the Schema for the consoles API, it deploys the Redpanda
+// Console web UI of a Cluster
+type Console struct {
+	metav1.TypeMeta		`json:",inline"`
+	metav1.ObjectMeta	`json:"metadata,omitempty"`
+
+	Spec	ConsoleSpec	`json:"spec,omitempty"`
+	Status	ConsoleStatus	`json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ConsoleList contains a list of Console
+type ConsoleList struct {
+	metav1.TypeMeta	`json:",inline"`
+	metav1.ListMeta	`json:"metadata,omitempty"`
+	Items		[]Console	`json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Console{}, &ConsoleList{})
+}
@@ -0,0 +1,118 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+// Command render prints the ConfigMap, Services and StatefulSets the operator
+// creates for a Cluster, so its output can be reviewed without a
+// Kubernetes cluster, e.g. in CI.
+//
+//	render -f cluster.yaml [-config redpanda.yaml]
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	redpandacontrollers "github.com/vectorizedio/redpanda/src/go/k8s/controllers/redpanda"
+	"sigs.k8s.io/yaml"
+)
+
+const defaultNamespace = "default"
+
+var errNotACluster = errors.New("expected a redpanda.vectorized.io/v1alpha1 Cluster")
+
+func main() {
+	var clusterFile, configFile string
+
+	flag.StringVar(&clusterFile, "f", "-", "The file holding the Cluster, - reads the standard input.")
+	flag.StringVar(&configFile, "config", "",
+		"The redpanda.yaml fragment of the ConfigMap referenced by the Cluster, if any.")
+	flag.Parse()
+
+	if err := run(clusterFile, configFile, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(clusterFile, configFile string, out io.Writer) error {
+	in := os.Stdin
+
+	if clusterFile != "-" {
+		f, err := os.Open(clusterFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		in = f
+	}
+
+	var fragment []byte
+
+	if configFile != "" {
+		var err error
+		if fragment, err = ioutil.ReadFile(configFile); err != nil {
+			return err
+		}
+	}
+
+	return render(in, fragment, out)
+}
+
+// render decodes the Cluster read from in, validates it as the webhook
+// would and writes the objects created by the operator to out as a
+// multi-document YAML
+func render(in io.Reader, fragment []byte, out io.Writer) error {
+	data, err := ioutil.ReadAll(in)
+	if err != nil {
+		return err
+	}
+
+	var cluster redpandav1alpha1.Cluster
+	if err = yaml.UnmarshalStrict(data, &cluster); err != nil {
+		return fmt.Errorf("unable to decode the Cluster: %w", err)
+	}
+
+	if gvk := cluster.GroupVersionKind(); gvk != redpandav1alpha1.GroupVersion.WithKind("Cluster") {
+		return fmt.Errorf("%w: %s", errNotACluster, gvk)
+	}
+
+	if cluster.Namespace == "" {
+		cluster.Namespace = defaultNamespace
+	}
+
+	cluster.Default()
+
+	if err = cluster.ValidateCreate(); err != nil {
+		return err
+	}
+
+	objects, err := redpandacontrollers.Render(&cluster, fragment)
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range objects {
+		manifest, err := yaml.Marshal(obj)
+		if err != nil {
+			return err
+		}
+
+		if _, err = fmt.Fprintf(out, "---\n%s", manifest); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+var update = flag.Bool("update", false, "Update the golden files.")
+
+func TestRenderGolden(t *testing.T) {
+	for _, tc := range []struct {
+		name	string
+		config	string
+	}{
+		{name: "cluster"},
+		{name: "customized", config: "customized-config.yaml"},
+		{name: "broker-groups"},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			config := ""
+			if tc.config != "" {
+				config = filepath.Join("testdata", tc.config)
+			}
+
+			var out bytes.Buffer
+			g.Expect(run(filepath.Join("testdata", tc.name+".yaml"), config, &out)).To(Succeed())
+
+			golden := filepath.Join("testdata", tc.name+".golden")
+			if *update {
+				g.Expect(ioutil.WriteFile(golden, out.Bytes(), 0o644)).To(Succeed())
+			}
+
+			expected, err := ioutil.ReadFile(golden)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(out.String()).To(Equal(string(expected)))
+		})
+	}
+}
+
+func TestRenderRejectsInvalidCluster(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := `
+apiVersion: redpanda.vectorized.io/v1alpha1
+kind: Cluster
+metadata:
+  name: cluster-invalid
+spec:
+  resources:
+    lockMemory: true
+`
+	var out bytes.Buffer
+	g.Expect(render(strings.NewReader(cluster), nil, &out)).NotTo(Succeed())
+	g.Expect(out.Len()).To(BeZero())
+}
+
+func TestRenderRejectsUnknownKind(t *testing.T) {
+	g := NewWithT(t)
+
+	var out bytes.Buffer
+	err := render(strings.NewReader("apiVersion: v1\nkind: Pod\nmetadata:\n  name: pod\n"), nil, &out)
+	g.Expect(err).To(HaveOccurred())
+}
@@ -0,0 +1,211 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	"github.com/vectorizedio/redpanda/src/go/k8s/pkg/admin"
+	"github.com/vectorizedio/redpanda/src/go/rpk/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// caCertKey is the Secret key holding the PEM encoded CA certificate
+const caCertKey = "ca.crt"
+
+var errInvalidCA = errors.New("secret does not contain a valid PEM encoded " + caCertKey)
+
+// AdminAPIConfig holds the settings the operator uses to connect to the
+// Admin API of a Cluster
+type AdminAPIConfig struct {
+	// TLS verifies the servers and presents the client certificate, the
+	// Admin API is reached over plain http when it is nil
+	TLS	*tls.Config
+	// Username and Password authenticate the operator when the Admin API
+	// requires it, they are empty otherwise
+	Username	string
+	Password	string
+	// ReadyPath replaces the path of the Admin API health check when it
+	// is not empty
+	ReadyPath	string
+}
+
+// AdminAPIClientFactory creates the client used to reach the Admin API of
+// the brokers of a Cluster
+type AdminAPIClientFactory func(
+	cluster *redpandav1alpha1.Cluster, cfg *AdminAPIConfig,
+) (admin.AdminAPIClient, error)
+
+// NewAdminAPIClient is the default AdminAPIClientFactory. It reaches every
+// broker through its headless service DNS name, or the Admin API URL of
+// the Cluster when it is set.
+func NewAdminAPIClient(
+	cluster *redpandav1alpha1.Cluster, cfg *AdminAPIConfig,
+) (admin.AdminAPIClient, error) {
+	client, err := newAdminAPI(cluster, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Username != "" {
+		client.SetBasicAuth(cfg.Username, cfg.Password)
+	}
+
+	if cfg.ReadyPath != "" {
+		client.SetReadyPath(cfg.ReadyPath)
+	}
+
+	return client, nil
+}
+
+func newAdminAPI(
+	cluster *redpandav1alpha1.Cluster, cfg *AdminAPIConfig,
+) (*admin.AdminAPI, error) {
+	if u := cluster.Spec.Configuration.AdminAPI.URL; u != "" {
+		return admin.NewAdminAPIFromURLs([]string{u}, cfg.TLS)
+	}
+
+	port := adminAPIPort(cluster)
+
+	hosts := brokerHosts(cluster)
+
+	addresses := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		addresses = append(addresses, fmt.Sprintf("%s:%d", h, port))
+	}
+
+	return admin.NewAdminAPI(addresses, cfg.TLS)
+}
+
+// adminAPIPort returns the port of the Admin API listener
+func adminAPIPort(cluster *redpandav1alpha1.Cluster) int {
+	if port := cluster.Spec.Configuration.AdminAPI.Port; port != 0 {
+		return port
+	}
+
+	return config.Default().Redpanda.AdminApi.Port
+}
+
+// adminAPIClient creates the Admin API client with the configured factory
+func (r *ClusterReconciler) adminAPIClient(
+	cluster *redpandav1alpha1.Cluster, cfg *AdminAPIConfig,
+) (admin.AdminAPIClient, error) {
+	if r.AdminAPIClientFactory == nil {
+		return NewAdminAPIClient(cluster, cfg)
+	}
+
+	return r.AdminAPIClientFactory(cluster, cfg)
+}
+
+// adminAPIConfig returns the settings the operator uses to connect to the
+// Admin API: the tls configuration and, when the Admin API requires
+// authentication, the credentials of the bootstrap superuser. The
+// credentials are only sent once the superuser exists, as redpanda rejects
+// the credentials of an unknown user even when it does not require them.
+func (r *ClusterReconciler) adminAPIConfig(
+	ctx context.Context, cluster *redpandav1alpha1.Cluster,
+) (*AdminAPIConfig, error) {
+	tlsConfig, err := r.adminAPITLSConfig(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &AdminAPIConfig{
+		TLS:		tlsConfig,
+		ReadyPath:	cluster.Spec.Configuration.AdminAPI.HealthCheck.Path,
+	}
+
+	if (cluster.Spec.Configuration.AdminAPI.RequireAuth || cluster.Status.AdminAPIAuthRequired) &&
+		cluster.Status.SuperuserMechanism != "" {
+		if err = r.setAdminAPICredentials(ctx, cluster, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	return cfg, nil
+}
+
+// setAdminAPICredentials authenticates the Admin API calls made with cfg as
+// the bootstrap superuser
+func (r *ClusterReconciler) setAdminAPICredentials(
+	ctx context.Context, cluster *redpandav1alpha1.Cluster, cfg *AdminAPIConfig,
+) error {
+	password, err := r.superuserPassword(ctx, cluster)
+	if err != nil {
+		return err
+	}
+
+	cfg.Username = superuserName(cluster)
+	cfg.Password = password
+
+	return nil
+}
+
+// adminAPITLSConfig returns the tls configuration the operator uses to
+// verify the Admin API servers, presenting the client certificate when one
+// is configured. Without a CA, the servers are verified with the system
+// roots over https, and nil is returned over plain http.
+func (r *ClusterReconciler) adminAPITLSConfig(
+	ctx context.Context, cluster *redpandav1alpha1.Cluster,
+) (*tls.Config, error) {
+	adminTLS := cluster.Spec.Configuration.AdminAPI.TLS
+
+	ref := adminTLS.CASecretRef
+	if ref == nil {
+		if healthCheckScheme(cluster) == corev1.URISchemeHTTPS {
+			return &tls.Config{MinVersion: tls.VersionTLS12}, nil
+		}
+
+		return nil, nil
+	}
+
+	var secret corev1.Secret
+
+	err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: cluster.Namespace}, &secret)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch Admin API CA secret %s/%s: %w", cluster.Namespace, ref.Name, err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(secret.Data[caCertKey]) {
+		return nil, fmt.Errorf("invalid Admin API CA secret %s/%s: %w", cluster.Namespace, ref.Name, errInvalidCA)
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:	tls.VersionTLS12,
+		RootCAs:	pool,
+	}
+
+	if ref = adminTLS.ClientCertSecretRef; ref != nil {
+		err = r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: cluster.Namespace}, &secret)
+		if err != nil {
+			return nil, fmt.Errorf("unable to fetch Admin API client certificate secret %s/%s: %w",
+				cluster.Namespace, ref.Name, err)
+		}
+
+		var cert tls.Certificate
+
+		cert, err = tls.X509KeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+		if err != nil {
+			return nil, fmt.Errorf("invalid Admin API client certificate secret %s/%s: %w",
+				cluster.Namespace, ref.Name, err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
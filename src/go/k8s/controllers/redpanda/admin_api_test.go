@@ -0,0 +1,639 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	redpandacontrollers "github.com/vectorizedio/redpanda/src/go/k8s/controllers/redpanda"
+	"github.com/vectorizedio/redpanda/src/go/k8s/pkg/admin"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+)
+
+var _ = Describe("Redpanda Admin API", func() {
+	Context("When the Admin API CA is configured", func() {
+		It("Should not create resources until the CA secret exists", func() {
+			key := testKey("redpanda-admin-ca")
+			caKey := testKey(key.Name + "-ca")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.Configuration.AdminAPI = v1alpha1.AdminAPI{
+				TLS: v1alpha1.AdminAPITLS{
+					CASecretRef: &corev1.LocalObjectReference{Name: caKey.Name},
+				},
+			}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			By("Not creating the StatefulSet while the CA secret is missing")
+			var sts appsv1.StatefulSet
+			Consistently(func() bool {
+				err := k8sClient.Get(context.Background(), key, &sts)
+				return err != nil
+			}, time.Second*2, interval).Should(BeTrue())
+
+			By("Creating the StatefulSet once the CA secret is present")
+			srv := httptest.NewTLSServer(http.NotFoundHandler())
+			defer srv.Close()
+			caSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:		caKey.Name,
+					Namespace:	caKey.Namespace,
+				},
+				Data: map[string][]byte{
+					"ca.crt": pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}),
+				},
+			}
+			Expect(k8sClient.Create(context.Background(), caSecret)).Should(Succeed())
+
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+		})
+
+		It("Should present the client certificate", func() {
+			key := testKey("redpanda-admin-client-cert")
+			createRPCCertificate(key.Name+"-tls", "operator")
+
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.Configuration.AdminAPI.TLS = v1alpha1.AdminAPITLS{
+				CASecretRef:		&corev1.LocalObjectReference{Name: key.Name + "-tls"},
+				ClientCertSecretRef:	&corev1.LocalObjectReference{Name: key.Name + "-tls"},
+			}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+			setReadyReplicas(key, 1)
+
+			Eventually(func() *redpandacontrollers.AdminAPIConfig {
+				return testAdminAPIs.get(key.Name).connectionConfig()
+			}, timeout, interval).ShouldNot(BeNil())
+			cfg := testAdminAPIs.get(key.Name).connectionConfig()
+			Expect(cfg.TLS).ShouldNot(BeNil())
+			Expect(cfg.TLS.Certificates).Should(HaveLen(1))
+			Expect(cfg.Username).Should(BeEmpty())
+		})
+	})
+
+	Context("When the Admin API requires authentication", func() {
+		It("Should only require it once the superuser authenticating the operator exists", func() {
+			key := testKey("redpanda-admin-auth")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.SASL.Enabled = true
+			redpandaCluster.Spec.Configuration.AdminAPI.RequireAuth = true
+			redpandaCluster.Spec.ClusterProperties = map[string]string{"auto_create_topics_enabled": "true"}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			Expect(eventuallyRedpandaConfig(key)).ShouldNot(HaveKey("admin_api_require_auth"))
+			setReadyReplicas(key, 1)
+
+			var secret corev1.Secret
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), testKey(key.Name+"-superuser"), &secret)
+			}, timeout, interval).Should(Succeed())
+
+			api := testAdminAPIs.get(key.Name)
+			Eventually(func() interface{} {
+				return api.clusterProperty("admin_api_require_auth")
+			}, timeout, interval).Should(Equal(true))
+			Expect(api.password("admin")).Should(Equal(string(secret.Data["password"])))
+
+			By("Reconciling the rest of the Cluster with the superuser credentials")
+			Eventually(func() interface{} {
+				return api.clusterProperty("auto_create_topics_enabled")
+			}, timeout, interval).Should(Equal("true"))
+			Eventually(func() bool {
+				var c v1alpha1.Cluster
+				if err := k8sClient.Get(context.Background(), key, &c); err != nil {
+					return false
+				}
+				return c.Status.AdminAPIAuthRequired
+			}, timeout, interval).Should(BeTrue())
+			Expect(api.connectionConfig()).Should(Equal(&redpandacontrollers.AdminAPIConfig{
+				Username:	"admin",
+				Password:	string(secret.Data["password"]),
+			}))
+		})
+	})
+})
+
+// mockAdminAPIs hands one mockAdminAPI per Cluster name to the test
+// reconciler, so that the specs can configure and inspect them separately
+type mockAdminAPIs struct {
+	mu	sync.Mutex
+	apis	map[string]*mockAdminAPI
+}
+
+func newMockAdminAPIs() *mockAdminAPIs {
+	return &mockAdminAPIs{apis: make(map[string]*mockAdminAPI)}
+}
+
+// get returns the Admin API of the named Cluster, creating it when needed
+func (m *mockAdminAPIs) get(cluster string) *mockAdminAPI {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.apis[cluster]; !ok {
+		m.apis[cluster] = &mockAdminAPI{
+			users:		make(map[string]string),
+			mechanisms:	make(map[string]string),
+			decommissioned:	make(map[int]bool),
+			maintenance:	make(map[int]bool),
+			versions:	make(map[int]string),
+			alive:		make(map[int]bool),
+			drain:		true,
+			clusterConfig:	make(map[string]interface{}),
+		}
+	}
+
+	return m.apis[cluster]
+}
+
+// connect returns the Admin API of the named Cluster, recording the
+// connection settings of the reconciler
+func (m *mockAdminAPIs) connect(
+	cluster string, cfg *redpandacontrollers.AdminAPIConfig,
+) *mockAdminAPI {
+	api := m.get(cluster)
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	api.config = cfg
+
+	return api
+}
+
+// mockAdminAPI is the Admin API used by the test reconciler, it records
+// the created users, the decommissioned brokers and the brokers in
+// maintenance mode
+type mockAdminAPI struct {
+	mu	sync.Mutex
+	config	*redpandacontrollers.AdminAPIConfig
+	users	map[string]string
+	// mechanisms are the SASL mechanisms of the created users
+	mechanisms	map[string]string
+	decommissioned	map[int]bool
+	maintenance	map[int]bool
+	// drain makes the decommissioned brokers drain, and the brokers in
+	// maintenance mode transfer their leadership, immediately. Otherwise
+	// they never complete.
+	drain		bool
+	clusterConfig	map[string]interface{}
+	license		[]byte
+	// cloudStorage is the upload status of the partitions, by
+	// topic/partition
+	cloudStorage	map[string]admin.CloudStorageStatus
+	// leader is the node id of the controller leader
+	leader	int
+	// versions are the versions reported by the brokers, none by default
+	versions	map[int]string
+	// alive is the liveness reported by the brokers, none by default
+	alive	map[int]bool
+}
+
+var errUnauthorized = fmt.Errorf("%w: mock", admin.ErrUnauthorized)
+
+// authenticate checks the credentials of the last client construction like
+// redpanda: the given credentials must be the ones of a created user, and
+// they are required once admin_api_require_auth is enabled
+func (m *mockAdminAPI) authenticate() error {
+	if m.config == nil || m.config.Username == "" {
+		if required, _ := m.clusterConfig["admin_api_require_auth"].(bool); required {
+			return errUnauthorized
+		}
+
+		return nil
+	}
+
+	if password, ok := m.users[m.config.Username]; !ok || password != m.config.Password {
+		return errUnauthorized
+	}
+
+	return nil
+}
+
+// connectionConfig returns the settings of the last client construction
+func (m *mockAdminAPI) connectionConfig() *redpandacontrollers.AdminAPIConfig {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.config
+}
+
+func (m *mockAdminAPI) Ready(context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.authenticate()
+}
+
+func (m *mockAdminAPI) CreateUser(_ context.Context, username, password, mechanism string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.authenticate(); err != nil {
+		return err
+	}
+
+	m.users[username] = password
+	m.mechanisms[username] = mechanism
+
+	return nil
+}
+
+// testBrokers is the resource usage reported by the mock Admin API
+var testBrokers = []admin.Broker{
+	{
+		NodeID:		1,
+		DiskSpace:	[]admin.DiskSpace{{Path: "/var/lib/redpanda/data", Free: 40, Total: 100}},
+	},
+	{
+		NodeID:		0,
+		DiskSpace:	[]admin.DiskSpace{{Path: "/var/lib/redpanda/data", Free: 10, Total: 100}},
+	},
+}
+
+// testPartitions are the partitions reported by the mock Admin API, the
+// broker 0 hosts 5 replicas and the broker 1 hosts 3
+var testPartitions = []admin.Partition{
+	{Namespace: "kafka", Topic: "orders", PartitionID: 0, Replicas: []admin.Replica{{NodeID: 0}, {NodeID: 1}}},
+	{Namespace: "kafka", Topic: "orders", PartitionID: 1, Replicas: []admin.Replica{{NodeID: 0}, {NodeID: 1}}},
+	{Namespace: "kafka", Topic: "orders", PartitionID: 2, Replicas: []admin.Replica{{NodeID: 0}, {NodeID: 1}}},
+	{Namespace: "kafka", Topic: "events", PartitionID: 0, Replicas: []admin.Replica{{NodeID: 0}}},
+	{Namespace: "redpanda", Topic: "controller", PartitionID: 0, Replicas: []admin.Replica{{NodeID: 0}}},
+}
+
+func (m *mockAdminAPI) ClusterPartitions(context.Context) ([]admin.Partition, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.authenticate(); err != nil {
+		return nil, err
+	}
+
+	return testPartitions, nil
+}
+
+func (m *mockAdminAPI) Brokers(context.Context) ([]admin.Broker, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.authenticate(); err != nil {
+		return nil, err
+	}
+
+	brokers := make([]admin.Broker, 0, len(testBrokers))
+
+	for _, b := range testBrokers {
+		if m.decommissioned[b.NodeID] {
+			b.MembershipStatus = "draining"
+			if m.drain {
+				b.MembershipStatus = admin.MembershipRemoved
+			}
+		}
+
+		if m.maintenance[b.NodeID] {
+			b.Maintenance = &admin.MaintenanceStatus{Draining: true, Finished: m.drain}
+		}
+
+		b.Version = m.versions[b.NodeID]
+
+		if alive, ok := m.alive[b.NodeID]; ok {
+			b.IsAlive = &alive
+		}
+
+		brokers = append(brokers, b)
+	}
+
+	return brokers, nil
+}
+
+func (m *mockAdminAPI) DecommissionBroker(_ context.Context, nodeID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.authenticate(); err != nil {
+		return err
+	}
+
+	m.decommissioned[nodeID] = true
+
+	return nil
+}
+
+func (m *mockAdminAPI) EnableMaintenanceMode(_ context.Context, nodeID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.authenticate(); err != nil {
+		return err
+	}
+
+	m.maintenance[nodeID] = true
+
+	return nil
+}
+
+func (m *mockAdminAPI) DisableMaintenanceMode(_ context.Context, nodeID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.authenticate(); err != nil {
+		return err
+	}
+
+	delete(m.maintenance, nodeID)
+
+	return nil
+}
+
+func (m *mockAdminAPI) ClusterConfig(context.Context) (map[string]interface{}, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.authenticate(); err != nil {
+		return nil, err
+	}
+
+	cfg := make(map[string]interface{}, len(m.clusterConfig))
+	for k, v := range m.clusterConfig {
+		cfg[k] = v
+	}
+
+	return cfg, nil
+}
+
+func (m *mockAdminAPI) PatchClusterConfig(_ context.Context, upsert map[string]interface{}, remove []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.authenticate(); err != nil {
+		return err
+	}
+
+	for k, v := range upsert {
+		m.clusterConfig[k] = v
+	}
+
+	for _, k := range remove {
+		delete(m.clusterConfig, k)
+	}
+
+	return nil
+}
+
+// testConfigSchema is the cluster configuration schema reported by the
+// mock, disk_reservation_percent requires a restart
+var testConfigSchema = map[string]admin.ConfigProperty{
+	"auto_create_topics_enabled":	{NeedsRestart: false},
+	"disk_reservation_percent":	{NeedsRestart: true},
+	"kafka_connections_max":	{NeedsRestart: false},
+	"log_segment_size":		{NeedsRestart: false},
+}
+
+func (m *mockAdminAPI) ClusterConfigSchema(context.Context) (map[string]admin.ConfigProperty, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.authenticate(); err != nil {
+		return nil, err
+	}
+
+	return testConfigSchema, nil
+}
+
+// testLicenseExpiry is the expiry of the licenses loaded in the mock
+var testLicenseExpiry = time.Date(2100, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func (m *mockAdminAPI) License(context.Context) (admin.License, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.authenticate(); err != nil {
+		return admin.License{}, err
+	}
+
+	if m.license == nil {
+		return admin.License{}, nil
+	}
+
+	return admin.License{
+		Loaded:	true,
+		Properties: admin.LicenseProperties{
+			Organization:	"vectorized",
+			Type:		"enterprise",
+			Expires:	testLicenseExpiry.Unix(),
+			Checksum:	fmt.Sprintf("%x", sha256.Sum256(m.license)),
+		},
+	}, nil
+}
+
+func (m *mockAdminAPI) SetLicense(_ context.Context, license []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.authenticate(); err != nil {
+		return err
+	}
+
+	m.license = license
+
+	return nil
+}
+
+func (m *mockAdminAPI) CloudStorageStatus(_ context.Context, topic string, partition int) (admin.CloudStorageStatus, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.authenticate(); err != nil {
+		return admin.CloudStorageStatus{}, err
+	}
+
+	return m.cloudStorage[fmt.Sprintf("%s/%d", topic, partition)], nil
+}
+
+func (m *mockAdminAPI) ControllerLeader(context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.authenticate(); err != nil {
+		return 0, err
+	}
+
+	return m.leader, nil
+}
+
+// setControllerLeader sets the controller leader reported by the mock,
+// admin.NoLeader simulates the loss of the controller quorum
+func (m *mockAdminAPI) setControllerLeader(nodeID int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.leader = nodeID
+}
+
+// setUploadLag makes the given number of partitions of the mock report
+// offsets not uploaded yet, the last upload of the first one being the
+// given lag ago
+func (m *mockAdminAPI) setUploadLag(lag time.Duration, behind int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.cloudStorage = make(map[string]admin.CloudStorageStatus)
+
+	for i, p := range testPartitions {
+		if !p.KafkaTopic() {
+			continue
+		}
+
+		uploaded, sinceUpload := int64(100), time.Second.Milliseconds()
+		if i < behind {
+			uploaded = 80
+		}
+		if i == 0 {
+			sinceUpload = lag.Milliseconds()
+		}
+
+		m.cloudStorage[fmt.Sprintf("%s/%d", p.Topic, p.PartitionID)] = admin.CloudStorageStatus{
+			Mode:				"full",
+			MsSinceLastSegmentUpload:	pointer.Int64Ptr(sinceUpload),
+			LocalLogLastOffset:		pointer.Int64Ptr(100),
+			CloudLogLastOffset:		pointer.Int64Ptr(uploaded),
+		}
+	}
+}
+
+// loadedLicense returns the license loaded through the Admin API
+func (m *mockAdminAPI) loadedLicense() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return string(m.license)
+}
+
+// clusterProperty returns a cluster property set through the Admin API
+func (m *mockAdminAPI) clusterProperty(key string) interface{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.clusterConfig[key]
+}
+
+// setClusterProperty changes a cluster property outside of the operator
+func (m *mockAdminAPI) setClusterProperty(key string, value interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.clusterConfig[key] = value
+}
+
+// password returns the password of a created user
+func (m *mockAdminAPI) password(username string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.users[username]
+}
+
+// mechanism returns the SASL mechanism of a created user
+func (m *mockAdminAPI) mechanism(username string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.mechanisms[username]
+}
+
+// isDecommissioned reports whether the broker decommissioning was requested
+func (m *mockAdminAPI) isDecommissioned(nodeID int) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.decommissioned[nodeID]
+}
+
+// inMaintenance reports whether the broker is in maintenance mode
+func (m *mockAdminAPI) inMaintenance(nodeID int) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.maintenance[nodeID]
+}
+
+// recommission cancels the decommissioning of a broker, which is a member
+// of the cluster again
+func (m *mockAdminAPI) recommission(nodeID int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.decommissioned, nodeID)
+}
+
+// setDrain configures whether the decommissioned brokers drain
+func (m *mockAdminAPI) setDrain(drain bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.drain = drain
+}
+
+func (m *mockAdminAPI) setVersion(nodeID int, version string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.versions[nodeID] = version
+}
+
+func (m *mockAdminAPI) setAlive(nodeID int, alive bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.alive[nodeID] = alive
+}
+
+func TestNewAdminAPIClientURL(t *testing.T) {
+	g := NewWithT(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, _, ok := r.BasicAuth(); !ok || username != "admin" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/brokers/v1/status/ready" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	cluster := testCluster("redpanda-admin-url")
+	cluster.Spec.Configuration.AdminAPI.URL = srv.URL + "/brokers"
+
+	// The per-broker DNS names would not resolve
+	client, err := redpandacontrollers.NewAdminAPIClient(cluster,
+		&redpandacontrollers.AdminAPIConfig{Username: "admin", Password: "secret"})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(client.Ready(context.Background())).To(Succeed())
+
+	cluster.Spec.Configuration.AdminAPI.URL = "redpanda-admin:9644"
+	_, err = redpandacontrollers.NewAdminAPIClient(cluster, &redpandacontrollers.AdminAPIConfig{})
+	g.Expect(err).To(MatchError(admin.ErrInvalidURL))
+}
@@ -0,0 +1,58 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/util/workqueue"
+)
+
+// baseBackoff is the delay before retrying the first failed reconciliation
+// of a Cluster, it doubles on every consecutive failure
+const baseBackoff = 5 * time.Millisecond
+
+// jitteredRateLimiter is the exponential backoff of the failed
+// reconciliations of each Cluster, capped to a maximum delay. Every delay
+// is shortened by a random share of at most jitter, so that the Clusters
+// failing together, e.g. while the API server is unavailable, are not
+// retried together.
+type jitteredRateLimiter struct {
+	workqueue.RateLimiter
+
+	jitter	float64
+
+	mu	sync.Mutex
+	rand	*rand.Rand
+}
+
+// newJitteredRateLimiter returns the rate limiter of the failed
+// reconciliations, jitter is the share of the delay between 0 and 1
+func newJitteredRateLimiter(
+	maxBackoff time.Duration, jitter float64,
+) workqueue.RateLimiter {
+	return &jitteredRateLimiter{
+		RateLimiter:	workqueue.NewItemExponentialFailureRateLimiter(baseBackoff, maxBackoff),
+		jitter:		jitter,
+		rand:		rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// When returns the delay before retrying the item
+func (l *jitteredRateLimiter) When(item interface{}) time.Duration {
+	delay := l.RateLimiter.When(item)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return delay - time.Duration(l.rand.Float64()*l.jitter*float64(delay))
+}
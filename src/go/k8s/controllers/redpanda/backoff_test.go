@@ -0,0 +1,50 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestJitteredRateLimiter(t *testing.T) {
+	g := NewWithT(t)
+
+	maxBackoff := time.Second
+	limiter := newJitteredRateLimiter(maxBackoff, 0.5)
+
+	delays := map[time.Duration]bool{}
+	exponential := baseBackoff
+
+	for i := 0; i < 20; i++ {
+		delay := limiter.When("cluster")
+		g.Expect(delay).To(BeNumerically("<=", exponential))
+		g.Expect(delay).To(BeNumerically(">=", exponential/2))
+
+		delays[delay] = true
+
+		if exponential *= 2; exponential > maxBackoff {
+			exponential = maxBackoff
+		}
+	}
+
+	// Once capped the delays still vary
+	for i := 0; i < 10; i++ {
+		delay := limiter.When("cluster")
+		g.Expect(delay).To(BeNumerically("<=", maxBackoff))
+		delays[delay] = true
+	}
+	g.Expect(len(delays)).To(BeNumerically(">", 20))
+
+	limiter.Forget("cluster")
+	g.Expect(limiter.When("cluster")).To(BeNumerically("<=", baseBackoff))
+}
@@ -0,0 +1,347 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// brokerGroupLabel selects the pods of a broker group, the pods of
+	// the Cluster StatefulSet do not have it
+	brokerGroupLabel	= "redpanda.vectorized.io/broker-group"
+
+	// firstNodeIDEnv is the node id of the broker with ordinal 0 of the
+	// StatefulSet, the configurator adds the ordinal of the broker to it
+	firstNodeIDEnv	= "FIRST_NODE_ID"
+
+	reasonBrokerGroupConflict	= "BrokerGroupConflict"
+	reasonBrokerGroupsOwned		= "BrokerGroupsOwned"
+)
+
+// brokerGroupName returns the name of the StatefulSet of the group, its
+// pods are named <cluster>-<group>-<ordinal>
+func brokerGroupName(
+	cluster *redpandav1alpha1.Cluster, group *redpandav1alpha1.BrokerGroup,
+) string {
+	return cluster.Name + "-" + group.Name
+}
+
+// brokerGroupCluster returns the Cluster definition the StatefulSet of the
+// group is built and reconciled from: the replicas and the resources of
+// the group, with its label on the pod template. The rollouts of the group
+// are not held by the maintenance mode, which only restarts the Replicas
+// brokers.
+func brokerGroupCluster(
+	cluster *redpandav1alpha1.Cluster, group *redpandav1alpha1.BrokerGroup,
+) *redpandav1alpha1.Cluster {
+	c := cluster.DeepCopy()
+	c.Spec.Replicas = pointer.Int32Ptr(group.Replicas)
+	c.Spec.Resources = *group.Resources.DeepCopy()
+	c.Spec.MaintenanceMode.Enabled = false
+
+	if c.Spec.PodTemplate.Labels == nil {
+		c.Spec.PodTemplate.Labels = map[string]string{}
+	}
+
+	c.Spec.PodTemplate.Labels[brokerGroupLabel] = group.Name
+
+	return c
+}
+
+// brokerGroupOf returns the broker group whose definition was returned by
+// brokerGroupCluster, or nil for the Cluster StatefulSet
+func brokerGroupOf(cluster *redpandav1alpha1.Cluster) *redpandav1alpha1.BrokerGroup {
+	name, ok := cluster.Spec.PodTemplate.Labels[brokerGroupLabel]
+	if !ok {
+		return nil
+	}
+
+	return findBrokerGroup(cluster, name)
+}
+
+func findBrokerGroup(cluster *redpandav1alpha1.Cluster, name string) *redpandav1alpha1.BrokerGroup {
+	for i := range cluster.Spec.BrokerGroups {
+		if cluster.Spec.BrokerGroups[i].Name == name {
+			return &cluster.Spec.BrokerGroups[i]
+		}
+	}
+
+	return nil
+}
+
+// statefulSetSelector returns the selector of the Cluster StatefulSet. It
+// excludes the pods with the group label, which are selected by the
+// StatefulSet of their group only. The selector of the StatefulSets created
+// before is immutable and kept, they never adopt the pods of a group as
+// these are controlled by the StatefulSet of the group.
+func statefulSetSelector(cluster *redpandav1alpha1.Cluster) *metav1.LabelSelector {
+	selector := metav1.SetAsLabelSelector(cluster.Labels)
+	selector.MatchExpressions = []metav1.LabelSelectorRequirement{{
+		Key:		brokerGroupLabel,
+		Operator:	metav1.LabelSelectorOpDoesNotExist,
+	}}
+
+	return selector
+}
+
+// scaleSelector returns the selector of the scale subresource, the
+// selector of the Cluster StatefulSet in its string form
+func scaleSelector(cluster *redpandav1alpha1.Cluster) string {
+	selector, err := metav1.LabelSelectorAsSelector(statefulSetSelector(cluster))
+	if err != nil {
+		return ""
+	}
+
+	return selector.String()
+}
+
+// brokerGroupSelector returns the selector of the StatefulSet of the group,
+// the Cluster labels and the group label
+func brokerGroupSelector(
+	cluster *redpandav1alpha1.Cluster, group *redpandav1alpha1.BrokerGroup,
+) *metav1.LabelSelector {
+	selector := make(map[string]string, len(cluster.Labels)+1)
+	for k, v := range cluster.Labels {
+		selector[k] = v
+	}
+
+	selector[brokerGroupLabel] = group.Name
+
+	return metav1.SetAsLabelSelector(selector)
+}
+
+// statefulSetPods returns the pods of the Cluster StatefulSet, without the
+// ones of the broker groups
+func statefulSetPods(pods []corev1.Pod) []corev1.Pod {
+	var selected []corev1.Pod
+
+	for i := range pods {
+		if _, ok := pods[i].Labels[brokerGroupLabel]; !ok {
+			selected = append(selected, pods[i])
+		}
+	}
+
+	return selected
+}
+
+// buildBrokerGroupStatefulSet returns the StatefulSet running the brokers
+// of the group. The pods of every group are still selected by the
+// services, the PodDisruptionBudget and the spreading of the Cluster.
+func buildBrokerGroupStatefulSet(
+	cluster *redpandav1alpha1.Cluster,
+	group *redpandav1alpha1.BrokerGroup,
+	podAnnotations map[string]string,
+	mode string,
+) *appsv1.StatefulSet {
+	ss := buildStatefulSet(brokerGroupCluster(cluster, group), cluster.Name+baseSuffix, podAnnotations, mode)
+
+	ss.Name = brokerGroupName(cluster, group)
+	ss.Spec.Selector = brokerGroupSelector(cluster, group)
+	ss.Spec.Template.Name = ss.Name
+
+	return ss
+}
+
+// reconcileBrokerGroups creates and reconciles the StatefulSet of every
+// broker group, and reports their ready brokers. The groups only follow
+// an upgrade once every Replicas broker runs the new image, so that the
+// brokers are never all restarted at once.
+func (r *ClusterReconciler) reconcileBrokerGroups(
+	ctx context.Context,
+	cluster *redpandav1alpha1.Cluster,
+	sts *appsv1.StatefulSet,
+	status *redpandav1alpha1.ClusterStatus,
+) error {
+	// The StatefulSet controller must have observed the latest pod
+	// template, otherwise its revisions are the ones of the previous one
+	image := containerImage(sts.Spec.Template.Spec.Containers)
+	settled := sts.Status.ObservedGeneration >= sts.Generation && !rolloutInProgress(sts) &&
+		sts.Spec.Replicas != nil && sts.Status.ReadyReplicas >= *sts.Spec.Replicas
+
+	var groups []redpandav1alpha1.BrokerGroupStatus
+
+	conflict := ""
+
+	for i := range cluster.Spec.BrokerGroups {
+		group := &cluster.Spec.BrokerGroups[i]
+		groupCluster := brokerGroupCluster(cluster, group)
+
+		var groupSts appsv1.StatefulSet
+
+		err := r.Get(ctx, types.NamespacedName{Name: brokerGroupName(cluster, group), Namespace: cluster.Namespace}, &groupSts)
+		if apierrors.IsNotFound(err) {
+			if err = r.createBrokerGroupStatefulSet(ctx, cluster, group, image); err != nil {
+				return err
+			}
+
+			groups = append(groups, redpandav1alpha1.BrokerGroupStatus{Name: group.Name})
+
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		// The name of the group can be the one of another Cluster, whose
+		// StatefulSet is never adopted
+		if owner := metav1.GetControllerOf(&groupSts); (owner != nil && owner.UID != cluster.UID) ||
+			!reflect.DeepEqual(groupSts.Spec.Selector, brokerGroupSelector(cluster, group)) {
+			conflict = fmt.Sprintf("StatefulSet %s of broker group %s belongs to another resource", groupSts.Name, group.Name)
+			groups = append(groups, redpandav1alpha1.BrokerGroupStatus{Name: group.Name})
+
+			continue
+		}
+
+		groupImage := image
+		if !settled {
+			groupImage = containerImage(groupSts.Spec.Template.Spec.Containers)
+		}
+
+		replicas := throttleJoins(groupCluster, &groupSts, groupCluster.Spec.Replicas)
+		if err = r.reconcileStatefulSet(ctx, groupCluster, &groupSts, groupImage, replicas); err != nil {
+			return err
+		}
+
+		groups = append(groups, redpandav1alpha1.BrokerGroupStatus{
+			Name:		group.Name,
+			Replicas:	groupSts.Status.ReadyReplicas,
+		})
+	}
+
+	status.BrokerGroups = groups
+
+	if conflict != "" {
+		setDegraded(status, reasonBrokerGroupConflict, conflict)
+	} else {
+		clearDegraded(status, reasonBrokerGroupsOwned, reasonBrokerGroupConflict)
+	}
+
+	return nil
+}
+
+// createBrokerGroupStatefulSet creates the StatefulSet of the group with
+// the image of the Cluster StatefulSet. The brokers of a group always join
+// the cluster formed by the Replicas brokers.
+func (r *ClusterReconciler) createBrokerGroupStatefulSet(
+	ctx context.Context,
+	cluster *redpandav1alpha1.Cluster,
+	group *redpandav1alpha1.BrokerGroup,
+	image string,
+) error {
+	podAnnotations, err := r.podAnnotations(ctx, brokerGroupCluster(cluster, group))
+	if err != nil {
+		return err
+	}
+
+	ss := buildBrokerGroupStatefulSet(cluster, group, podAnnotations, configuratorRejoin)
+
+	if desired := redpandaImage(cluster); image != "" && image != desired {
+		setImage(ss.Spec.Template.Spec.InitContainers, desired, image)
+		setImage(ss.Spec.Template.Spec.Containers, desired, image)
+	}
+
+	if err = controllerutil.SetControllerReference(cluster, ss, r.Scheme); err != nil {
+		return err
+	}
+
+	r.Log.Info("Creating the StatefulSet of the broker group", "StatefulSet.Name", ss.Name)
+
+	return r.Create(ctx, ss)
+}
+
+// brokerCount returns the number of brokers of the cluster, the Replicas
+// brokers and the ones of every group
+func brokerCount(cluster *redpandav1alpha1.Cluster) int32 {
+	var count int32
+	if cluster.Spec.Replicas != nil {
+		count = *cluster.Spec.Replicas
+	}
+
+	for _, g := range cluster.Spec.BrokerGroups {
+		count += g.Replicas
+	}
+
+	return count
+}
+
+// brokerPodNames returns the pod name of every broker, the Replicas
+// brokers followed by the ones of each group
+func brokerPodNames(cluster *redpandav1alpha1.Cluster) []string {
+	names := make([]string, 0, brokerCount(cluster))
+
+	var replicas int32
+	if cluster.Spec.Replicas != nil {
+		replicas = *cluster.Spec.Replicas
+	}
+
+	for i := int32(0); i < replicas; i++ {
+		names = append(names, fmt.Sprintf("%s-%d", cluster.Name, i))
+	}
+
+	for i := range cluster.Spec.BrokerGroups {
+		group := &cluster.Spec.BrokerGroups[i]
+		for j := int32(0); j < group.Replicas; j++ {
+			names = append(names, fmt.Sprintf("%s-%d", brokerGroupName(cluster, group), j))
+		}
+	}
+
+	return names
+}
+
+// brokerNodeID returns the redpanda node id of the broker running in the
+// pod: its ordinal, offset by the first node id of its group, unless the
+// broker was replaced
+func brokerNodeID(cluster *redpandav1alpha1.Cluster, pod *corev1.Pod) (int, bool) {
+	if nodeID, ok := cluster.Status.NodeIDs[pod.Name]; ok {
+		return nodeID, true
+	}
+
+	ordinal, err := strconv.Atoi(pod.Name[strings.LastIndex(pod.Name, "-")+1:])
+	if err != nil {
+		return 0, false
+	}
+
+	name, ok := pod.Labels[brokerGroupLabel]
+	if !ok {
+		return ordinal, true
+	}
+
+	group := findBrokerGroup(cluster, name)
+	if group == nil {
+		return 0, false
+	}
+
+	return int(group.FirstNodeID) + ordinal, true
+}
+
+// replicaNodeID returns the redpanda node id of the Replicas broker with
+// the given ordinal, which is the ordinal unless the broker was replaced
+func replicaNodeID(cluster *redpandav1alpha1.Cluster, sts *appsv1.StatefulSet, ordinal int) int {
+	if nodeID, ok := cluster.Status.NodeIDs[fmt.Sprintf("%s-%d", sts.Name, ordinal)]; ok {
+		return nodeID
+	}
+
+	return ordinal
+}
@@ -0,0 +1,122 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Redpanda broker groups", func() {
+	Context("When the Cluster has a broker group", func() {
+		It("Should run it in its own StatefulSet and report its ready brokers", func() {
+			key := testKey("redpanda-broker-groups")
+			groupKey := testKey("redpanda-broker-groups-hot")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.BrokerGroups = []v1alpha1.BrokerGroup{{
+				Name:		"hot",
+				Replicas:	2,
+				FirstNodeID:	10,
+				Resources: v1alpha1.RedpandaResourceRequirements{
+					ResourceRequirements: corev1.ResourceRequirements{
+						Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("4Gi")},
+					},
+				},
+			}}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			Eventually(func() int32 {
+				return statefulSetReplicas(groupKey)
+			}, timeout, interval).Should(Equal(int32(2)))
+
+			var sts appsv1.StatefulSet
+			Expect(k8sClient.Get(context.Background(), groupKey, &sts)).Should(Succeed())
+			Expect(metav1.IsControlledBy(&sts, redpandaCluster)).Should(BeTrue())
+			Expect(sts.Spec.ServiceName).Should(Equal(key.Name))
+			Expect(sts.Spec.Template.Labels).Should(HaveKeyWithValue("redpanda.vectorized.io/broker-group", "hot"))
+			redpanda := sts.Spec.Template.Spec.Containers[0]
+			Expect(redpanda.Resources.Limits.Memory().String()).Should(Equal("4Gi"))
+			Expect(redpanda.Args).Should(ContainElement("--memory 4G"))
+			Expect(sts.Spec.Template.Spec.InitContainers[0].Env).Should(ContainElement(
+				corev1.EnvVar{Name: "FIRST_NODE_ID", Value: "10"}))
+
+			By("Reporting the ready brokers of the Cluster and of the group")
+			observeStatefulSet(key, "rev-1")
+			observeStatefulSet(groupKey, "rev-1")
+			Eventually(func() []v1alpha1.BrokerGroupStatus {
+				return clusterBrokerGroups(key)
+			}, timeout, interval).Should(Equal([]v1alpha1.BrokerGroupStatus{{Name: "hot", Replicas: 2}}))
+			Eventually(func() int32 {
+				var c v1alpha1.Cluster
+				if err := k8sClient.Get(context.Background(), key, &c); err != nil {
+					return -1
+				}
+				return c.Status.Replicas
+			}, timeout, interval).Should(Equal(int32(replicas)))
+			var c v1alpha1.Cluster
+			Expect(k8sClient.Get(context.Background(), key, &c)).Should(Succeed())
+			Expect(c.Status.Selector).Should(ContainSubstring("!redpanda.vectorized.io/broker-group"))
+
+			By("Upgrading the group once the Replicas brokers are upgraded")
+			previous := statefulSetImage(groupKey)
+			setClusterVersion(key, "y")
+			Eventually(func() string {
+				return statefulSetImage(key)
+			}, timeout, interval).Should(Equal(redpandaContainerImage + ":y"))
+			Consistently(func() string {
+				return statefulSetImage(groupKey)
+			}, time.Second, interval).Should(Equal(previous))
+
+			observeStatefulSet(key, "rev-1")
+			Eventually(func() string {
+				return statefulSetImage(groupKey)
+			}, timeout, interval).Should(Equal(redpandaContainerImage + ":y"))
+		})
+	})
+
+	Context("When the StatefulSet name of a group is taken", func() {
+		It("Should not adopt the StatefulSet of another Cluster", func() {
+			key := testKey("redpanda-broker-groups-taken")
+			groupKey := testKey("redpanda-broker-groups-taken-hot")
+			other := legacyStatefulSet(groupKey, map[string]string{"app": groupKey.Name})
+			Expect(k8sClient.Create(context.Background(), other)).Should(Succeed())
+
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.BrokerGroups = []v1alpha1.BrokerGroup{{Name: "hot", Replicas: 1, FirstNodeID: 10}}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			Eventually(func() string {
+				return clusterConditionReason(key, v1alpha1.ClusterDegraded)
+			}, timeout, interval).Should(Equal("BrokerGroupConflict"))
+
+			var sts appsv1.StatefulSet
+			Expect(k8sClient.Get(context.Background(), groupKey, &sts)).Should(Succeed())
+			Expect(metav1.GetControllerOf(&sts)).Should(BeNil())
+			Expect(sts.Spec.Template.Spec.Containers[0].Image).Should(Equal(other.Spec.Template.Spec.Containers[0].Image))
+		})
+	})
+})
+
+func clusterBrokerGroups(key types.NamespacedName) []v1alpha1.BrokerGroupStatus {
+	var redpandaCluster v1alpha1.Cluster
+	if err := k8sClient.Get(context.Background(), key, &redpandaCluster); err != nil {
+		return nil
+	}
+	return redpandaCluster.Status.BrokerGroups
+}
@@ -0,0 +1,1222 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	"github.com/vectorizedio/redpanda/src/go/k8s/pkg/admin"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/pointer"
+)
+
+func builderCluster() *redpandav1alpha1.Cluster {
+	return &redpandav1alpha1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:		"builder",
+			Namespace:	"default",
+			Labels:		map[string]string{"app": "builder"},
+		},
+		Spec: redpandav1alpha1.ClusterSpec{
+			Image:		"vectorized/redpanda",
+			Version:	"v21.4.13",
+			Configuration: redpandav1alpha1.RedpandaConfig{
+				KafkaAPI:	redpandav1alpha1.SocketAddress{Port: 9092},
+				AdminAPI:	redpandav1alpha1.AdminAPI{Port: 9644},
+				RPCServer: redpandav1alpha1.RPCServer{
+					SocketAddress: redpandav1alpha1.SocketAddress{Port: 33145},
+				},
+			},
+		},
+	}
+}
+
+func TestBuildService(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := builderCluster()
+
+	svc := buildService(cluster)
+	g.Expect(svc.Name).To(Equal("builder"))
+	g.Expect(svc.Spec.ClusterIP).To(Equal(corev1.ClusterIPNone))
+	g.Expect(svc.Spec.Selector).To(Equal(cluster.Labels))
+	g.Expect(svc.Spec.Ports).To(ConsistOf(corev1.ServicePort{
+		Name:		"kafka-tcp",
+		Protocol:	corev1.ProtocolTCP,
+		Port:		9092,
+		TargetPort:	intstr.FromInt(9092),
+	}))
+
+	g.Expect(svc.Spec.PublishNotReadyAddresses).To(BeTrue())
+
+	cluster.Spec.Service.Type = redpandav1alpha1.ServiceTypeClusterIP
+	g.Expect(buildService(cluster).Spec.ClusterIP).To(Equal(corev1.ClusterIPNone))
+
+	client := buildClientService(cluster)
+	g.Expect(client.Name).To(Equal("builder" + clientSuffix))
+	g.Expect(client.Spec.Type).To(Equal(corev1.ServiceTypeClusterIP))
+	g.Expect(client.Spec.Ports).To(Equal(svc.Spec.Ports))
+	g.Expect(client.Spec.PublishNotReadyAddresses).To(BeFalse())
+}
+
+func TestBuildExternalService(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := builderCluster()
+	cluster.Spec.ExternalConnectivity.Annotations = map[string]string{"lb": "internal"}
+
+	svc := buildExternalService(cluster)
+	g.Expect(svc.Name).To(Equal("builder" + externalSuffix))
+	g.Expect(svc.Annotations).To(Equal(map[string]string{"lb": "internal"}))
+	g.Expect(svc.Spec.Type).To(Equal(corev1.ServiceTypeLoadBalancer))
+	g.Expect(svc.Spec.Selector).To(Equal(cluster.Labels))
+}
+
+func TestBuildBrokerService(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := builderCluster()
+	cluster.Spec.ExternalConnectivity.PerBrokerNodePorts = &redpandav1alpha1.PerBrokerNodePorts{BasePort: 30100}
+
+	svc := buildBrokerService(cluster, 2)
+	g.Expect(svc.Name).To(Equal("builder-2" + externalSuffix))
+	g.Expect(svc.Spec.Type).To(Equal(corev1.ServiceTypeNodePort))
+	g.Expect(svc.Spec.Selector).To(Equal(map[string]string{
+		"app":					"builder",
+		"statefulset.kubernetes.io/pod-name":	"builder-2",
+	}))
+	g.Expect(svc.Spec.Ports[0].NodePort).To(Equal(int32(30102)))
+	g.Expect(svc.Spec.Ports[0].TargetPort).To(Equal(intstr.FromInt(9092)))
+	g.Expect(cluster.Labels).To(Equal(map[string]string{"app": "builder"}))
+
+	env := configuratorEnv(cluster, configuratorBootstrap)
+	g.Expect(env).To(ContainElement(corev1.EnvVar{
+		Name:	"HOST_IP",
+		ValueFrom: &corev1.EnvVarSource{
+			FieldRef: &corev1.ObjectFieldSelector{APIVersion: "v1", FieldPath: "status.hostIP"},
+		},
+	}))
+}
+
+func TestBuildAdminService(t *testing.T) {
+	g := NewWithT(t)
+
+	svc := buildAdminService(builderCluster())
+	g.Expect(svc.Name).To(Equal("builder" + adminSuffix))
+	g.Expect(svc.Spec.Type).To(Equal(corev1.ServiceTypeClusterIP))
+	g.Expect(svc.Spec.Ports).To(HaveLen(1))
+	g.Expect(svc.Spec.Ports[0].Port).To(BeEquivalentTo(9644))
+}
+
+func TestBuildConfigMap(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := builderCluster()
+
+	cm, err := buildConfigMap(cluster, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(cm.Name).To(Equal("builder" + baseSuffix))
+	g.Expect(cm.Data).To(HaveKey("configurator.sh"))
+	g.Expect(cm.Data).NotTo(HaveKey(ioPropertiesFile))
+	g.Expect(cm.Data["redpanda.yaml"]).To(ContainSubstring("builder-0.builder.default.svc.cluster.local"))
+
+	cluster.Spec.Storage.IOProperties = &redpandav1alpha1.IOPropertiesSource{Inline: "disks: []"}
+
+	cm, err = buildConfigMap(cluster, []byte("redpanda:\n  auto_create_topics_enabled: false\n"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(cm.Data[ioPropertiesFile]).To(Equal("disks: []"))
+	g.Expect(cm.Data["redpanda.yaml"]).To(ContainSubstring("auto_create_topics_enabled: false"))
+}
+
+func TestPeerList(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := builderCluster()
+	cluster.Spec.Replicas = pointer.Int32Ptr(3)
+
+	deleted := metav1.Now()
+	pods := []corev1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: "builder-0"}, Status: corev1.PodStatus{PodIP: "10.0.0.10"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "builder-1"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "builder-2", DeletionTimestamp: &deleted}, Status: corev1.PodStatus{PodIP: "10.0.0.12"}},
+	}
+
+	g.Expect(peerList(cluster, pods)).To(Equal(
+		"builder-0.builder.default.svc.cluster.local 10.0.0.10\n" +
+			"builder-1.builder.default.svc.cluster.local\n" +
+			"builder-2.builder.default.svc.cluster.local\n"))
+
+	cm, err := buildConfigMap(cluster, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(cm.Data).To(HaveKeyWithValue(peersFile, peerList(cluster, nil)))
+	g.Expect(cm.Data[configuratorScript]).To(ContainSubstring("done < /mnt/operator/peers;"))
+	// The dots of the DNS names are not regular expression wildcards
+	g.Expect(cm.Data[configuratorScript]).To(ContainSubstring(`$2 == host`))
+	g.Expect(cm.Data[configuratorScript]).NotTo(ContainSubstring("sed -i"))
+}
+
+func TestSchemaRegistry(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := builderCluster()
+
+	cm, err := buildConfigMap(cluster, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(cm.Data["redpanda.yaml"]).NotTo(ContainSubstring("schema_registry"))
+	g.Expect(containerPorts(cluster)).To(HaveLen(3))
+	g.Expect(buildService(cluster).Spec.Ports).To(HaveLen(1))
+
+	cluster.Spec.SchemaRegistry.Enabled = true
+
+	cm, err = buildConfigMap(cluster, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(cm.Data["redpanda.yaml"]).To(ContainSubstring(
+		"schema_registry:\n" +
+			"    schema_registry_api:\n" +
+			"        - address: 0.0.0.0\n" +
+			"          name: internal\n" +
+			"          port: 8081\n"))
+	g.Expect(containerPorts(cluster)).To(ContainElement(corev1.ContainerPort{
+		Name:		"schema-registry",
+		ContainerPort:	8081,
+		Protocol:	corev1.ProtocolTCP,
+	}))
+
+	cluster.Spec.SchemaRegistry.Port = 18081
+	g.Expect(buildService(cluster).Spec.Ports).To(ContainElement(corev1.ServicePort{
+		Name:		"schema-registry",
+		Protocol:	corev1.ProtocolTCP,
+		Port:		18081,
+		TargetPort:	intstr.FromInt(18081),
+	}))
+	for _, port := range buildExternalService(cluster).Spec.Ports {
+		g.Expect(port.Name).NotTo(Equal("schema-registry"))
+	}
+}
+
+func TestPandaProxy(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := builderCluster()
+
+	cm, err := buildConfigMap(cluster, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(cm.Data["redpanda.yaml"]).NotTo(ContainSubstring("pandaproxy"))
+	g.Expect(containerPorts(cluster)).To(HaveLen(3))
+
+	cluster.Spec.PandaProxy.Enabled = true
+	cluster.Spec.PandaProxy.Port = 18082
+
+	g.Expect(containerPorts(cluster)).To(ContainElement(corev1.ContainerPort{
+		Name:		"pandaproxy",
+		ContainerPort:	18082,
+		Protocol:	corev1.ProtocolTCP,
+	}))
+	g.Expect(buildService(cluster).Spec.Ports).To(ContainElement(corev1.ServicePort{
+		Name:		"pandaproxy",
+		Protocol:	corev1.ProtocolTCP,
+		Port:		18082,
+		TargetPort:	intstr.FromInt(18082),
+	}))
+	g.Expect(pandaProxySection(cluster)).To(Equal(map[string]interface{}{
+		"pandaproxy_api": []map[string]interface{}{{
+			"name":		"internal",
+			"address":	"0.0.0.0",
+			"port":		18082,
+		}},
+	}))
+
+	cluster.Spec.SASL.Enabled = true
+	cluster.Spec.PandaProxy.TLS = redpandav1alpha1.PandaProxyTLS{
+		Enabled:		true,
+		CertSecretRef:		&corev1.LocalObjectReference{Name: "proxy-tls"},
+		RequireClientAuth:	true,
+	}
+
+	cm, err = buildConfigMap(cluster, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(cm.Data["redpanda.yaml"]).To(ContainSubstring(
+		"pandaproxy:\n" +
+			"    pandaproxy_api:\n" +
+			"        - address: 0.0.0.0\n" +
+			"          authentication_method: http_basic\n" +
+			"          name: internal\n" +
+			"          port: 18082\n" +
+			"    pandaproxy_api_tls:\n" +
+			"        - cert_file: /etc/tls/certs/pandaproxy/tls.crt\n" +
+			"          enabled: true\n" +
+			"          key_file: /etc/tls/certs/pandaproxy/tls.key\n" +
+			"          name: internal\n" +
+			"          require_client_auth: true\n" +
+			"          truststore_file: /etc/tls/certs/pandaproxy/ca.crt\n"))
+
+	ss := buildStatefulSet(cluster, "builder"+baseSuffix, nil, configuratorBootstrap)
+	g.Expect(ss.Spec.Template.Spec.Volumes).To(ContainElement(corev1.Volume{
+		Name:		pandaProxyTLSVolume,
+		VolumeSource:	corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "proxy-tls"}},
+	}))
+	g.Expect(ss.Spec.Template.Spec.Containers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{
+		Name:		pandaProxyTLSVolume,
+		MountPath:	pandaProxyTLSDir,
+		ReadOnly:	true,
+	}))
+	g.Expect(referencedSecrets(cluster)).To(ContainElement("proxy-tls"))
+}
+
+func TestSeedServers(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := builderCluster()
+	seeds := func(replicas int32) []string {
+		cluster.Spec.Replicas = pointer.Int32Ptr(replicas)
+
+		var addresses []string
+		for _, s := range seedServers(cluster, 33145) {
+			g.Expect(s.Host.Port).To(Equal(33145))
+			addresses = append(addresses, s.Host.Address)
+		}
+
+		return addresses
+	}
+
+	g.Expect(seeds(1)).To(Equal([]string{"builder-0.builder.default.svc.cluster.local"}))
+	g.Expect(seeds(3)).To(Equal([]string{
+		"builder-0.builder.default.svc.cluster.local",
+		"builder-1.builder.default.svc.cluster.local",
+		"builder-2.builder.default.svc.cluster.local",
+	}))
+	g.Expect(seeds(7)).To(Equal(seeds(3)))
+}
+
+func TestPerBrokerAddress(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := builderCluster()
+	g.Expect(perBrokerAddress(cluster)).To(BeEmpty())
+
+	cluster.Spec.ExternalConnectivity.PerBrokerAddresses = []string{"203.0.113.10", "broker-1.example.com"}
+	script := configuratorScriptContent(cluster, redpandaConfig(cluster))
+	g.Expect(script).To(ContainSubstring("redpanda.advertised_kafka_api.address $KAFKA_ADDRESS;"))
+	g.Expect(script).To(ContainSubstring("redpanda.advertised_rpc_api.address $SERVICE_NAME;"))
+
+	// The ordinal beyond the list keeps its headless service name
+	for ordinal, expected := range []string{"203.0.113.10", "broker-1.example.com", "builder-2.svc"} {
+		out, err := exec.Command("/bin/bash", "-c", fmt.Sprintf(
+			"ORDINAL_INDEX=%d\nSERVICE_NAME=builder-%d.svc%s\necho -n $KAFKA_ADDRESS",
+			ordinal, ordinal, perBrokerAddress(cluster))).Output()
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(string(out)).To(Equal(expected))
+	}
+}
+
+func TestBindAddress(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := builderCluster()
+	addresses := func() []string {
+		cfg := redpandaConfig(cluster).Redpanda
+		return []string{cfg.RPCServer.Address, cfg.KafkaApi.Address, cfg.AdminApi.Address}
+	}
+
+	g.Expect(addresses()).To(Equal([]string{"0.0.0.0", "0.0.0.0", "0.0.0.0"}))
+	g.Expect(configuratorScriptContent(cluster, redpandaConfig(cluster))).NotTo(ContainSubstring("POD_IP"))
+
+	cluster.Spec.Configuration.BindAddress = "::"
+	g.Expect(addresses()).To(Equal([]string{"::", "::", "::"}))
+
+	// The pod IP is only known to the configurator
+	cluster.Spec.Configuration.BindAddress = redpandav1alpha1.BindAddressPodIP
+	g.Expect(addresses()).To(Equal([]string{"0.0.0.0", "0.0.0.0", "0.0.0.0"}))
+
+	script := configuratorScriptContent(cluster, redpandaConfig(cluster))
+	for _, key := range []string{"rpc_server", "kafka_api", "admin"} {
+		g.Expect(script).To(ContainSubstring("rpk --config $CONFIG config set redpanda." + key + ".address $POD_IP;"))
+	}
+
+	g.Expect(configuratorEnv(cluster, configuratorBootstrap)).To(ContainElement(corev1.EnvVar{
+		Name:	"POD_IP",
+		ValueFrom: &corev1.EnvVarSource{
+			FieldRef: &corev1.ObjectFieldSelector{APIVersion: "v1", FieldPath: "status.podIP"},
+		},
+	}))
+}
+
+func TestAdvertisePodIP(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := builderCluster()
+	script := configuratorScriptContent(cluster, redpandaConfig(cluster))
+	g.Expect(script).To(ContainSubstring("rpk --config $CONFIG config set redpanda.advertised_rpc_api.address $SERVICE_NAME;"))
+	g.Expect(script).To(ContainSubstring("rpk --config $CONFIG config set redpanda.advertised_kafka_api.address $SERVICE_NAME;"))
+	g.Expect(configuratorEnv(cluster, configuratorBootstrap)).To(HaveLen(1))
+
+	cluster.Spec.Configuration.AdvertisePodIP = true
+
+	script = configuratorScriptContent(cluster, redpandaConfig(cluster))
+	g.Expect(script).To(ContainSubstring("rpk --config $CONFIG config set redpanda.advertised_rpc_api.address $POD_IP;"))
+	g.Expect(script).To(ContainSubstring("rpk --config $CONFIG config set redpanda.advertised_kafka_api.address $POD_IP;"))
+	g.Expect(script).To(ContainSubstring("rpk --config $CONFIG config set redpanda.advertised_kafka_api.port 9092;"))
+	// The listeners stay bound to every interface
+	g.Expect(script).NotTo(ContainSubstring("redpanda.kafka_api.address"))
+
+	g.Expect(configuratorEnv(cluster, configuratorBootstrap)).To(ContainElement(corev1.EnvVar{
+		Name:	"POD_IP",
+		ValueFrom: &corev1.EnvVarSource{
+			FieldRef: &corev1.ObjectFieldSelector{APIVersion: "v1", FieldPath: "status.podIP"},
+		},
+	}))
+
+	// The seed servers are still resolved through DNS
+	g.Expect(redpandaConfig(cluster).Redpanda.SeedServers[0].Host.Address).To(Equal("builder-0.builder.default.svc.cluster.local"))
+	g.Expect(advertisedKafkaAddresses(cluster)).To(BeEmpty())
+}
+
+func TestBuildStatefulSet(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := builderCluster()
+	annotations := map[string]string{configuratorHashAnnotation: "hash"}
+
+	ss := buildStatefulSet(cluster, "builder"+baseSuffix, annotations, configuratorBootstrap)
+	g.Expect(ss.Name).To(Equal("builder"))
+	g.Expect(*ss.Spec.Replicas).To(BeEquivalentTo(1))
+	g.Expect(ss.Spec.Template.Annotations).To(Equal(annotations))
+	g.Expect(ss.Spec.Template.Spec.InitContainers).To(HaveLen(1))
+	g.Expect(ss.Spec.Template.Spec.InitContainers[0].Env).To(ConsistOf(
+		corev1.EnvVar{Name: "CONFIGURATOR_MODE", Value: configuratorBootstrap}))
+	g.Expect(ss.Spec.VolumeClaimTemplates[0].Spec.StorageClassName).To(BeNil())
+
+	redpanda := ss.Spec.Template.Spec.Containers[0]
+	g.Expect(redpanda.Image).To(Equal("vectorized/redpanda:v21.4.13"))
+	g.Expect(redpanda.SecurityContext).To(BeNil())
+	g.Expect(redpanda.Args).To(ContainElement("--reserve-memory 0M"))
+}
+
+func TestReadOnlyData(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := builderCluster()
+	cluster.Spec.Storage.VerifyDataDirectory = true
+
+	ss := buildStatefulSet(cluster, "builder"+baseSuffix, nil, configuratorBootstrap)
+	g.Expect(ss.Spec.Template.Spec.Containers[0].VolumeMounts[0]).To(Equal(corev1.VolumeMount{
+		Name:		"datadir",
+		MountPath:	dataDirectory,
+	}))
+	g.Expect(ss.Spec.Template.Spec.InitContainers).To(HaveLen(2))
+
+	cluster.Spec.Debug.ReadOnlyData = true
+
+	ss = buildStatefulSet(cluster, "builder"+baseSuffix, nil, configuratorBootstrap)
+	redpanda := ss.Spec.Template.Spec.Containers[0]
+	g.Expect(redpanda.VolumeMounts[0]).To(Equal(corev1.VolumeMount{
+		Name:		"datadir",
+		MountPath:	dataDirectory,
+		ReadOnly:	true,
+	}))
+	g.Expect(ss.Spec.Template.Spec.InitContainers).To(HaveLen(1))
+	g.Expect(ss.Spec.Template.Spec.InitContainers[0].Name).To(Equal(configuratorContainerName))
+
+	// Redpanda is not started even without the command override
+	g.Expect(redpanda.Command).To(Equal([]string{"/bin/sh", "-c"}))
+	g.Expect(redpanda.Args).To(Equal([]string{debugCommand}))
+}
+
+func TestImageDigest(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := builderCluster()
+	cluster.Spec.Storage.VerifyDataDirectory = true
+	cluster.Spec.DependsOn = []redpandav1alpha1.Dependency{{Name: "minio", Address: "minio", Port: 9000}}
+	cluster.Spec.ImageDigest = "sha256:" + strings.Repeat("0f", 32)
+
+	ss := buildStatefulSet(cluster, "builder"+baseSuffix, nil, configuratorBootstrap)
+	containers := append(ss.Spec.Template.Spec.InitContainers, ss.Spec.Template.Spec.Containers...)
+	g.Expect(containers).To(HaveLen(4))
+
+	for _, c := range containers {
+		g.Expect(c.Image).To(Equal("vectorized/redpanda@sha256:"+strings.Repeat("0f", 32)), c.Name)
+	}
+}
+
+func TestBuildStatefulSetCustomized(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := builderCluster()
+	cluster.Spec.Resources.Limits = corev1.ResourceList{
+		corev1.ResourceMemory: resource.MustParse("4Gi"),
+	}
+	cluster.Spec.Resources.LockMemory = true
+	cluster.Spec.Storage.VerifyDataDirectory = true
+	cluster.Spec.Storage.Selector = metav1.SetAsLabelSelector(map[string]string{"disk": "nvme"})
+	cluster.Spec.Storage.ClaimLabels = map[string]string{"team": "streaming"}
+	cluster.Spec.Storage.ClaimAnnotations = map[string]string{"backup": "daily"}
+	cluster.Spec.Configuration.RPCServer.TLS = redpandav1alpha1.RPCServerTLS{
+		Enabled:	true,
+		CertSecretRef:	&corev1.LocalObjectReference{Name: "rpc-cert"},
+	}
+
+	ss := buildStatefulSet(cluster, "builder"+baseSuffix, nil, configuratorRejoin)
+	g.Expect(ss.Spec.Template.Spec.InitContainers).To(HaveLen(2))
+	g.Expect(ss.Spec.Template.Spec.InitContainers[0].Env[0].Value).To(Equal(configuratorRejoin))
+	g.Expect(*ss.Spec.VolumeClaimTemplates[0].Spec.StorageClassName).To(BeEmpty())
+	g.Expect(ss.Spec.VolumeClaimTemplates[0].Spec.Selector).To(Equal(cluster.Spec.Storage.Selector))
+	g.Expect(ss.Spec.VolumeClaimTemplates[0].Labels).To(HaveKeyWithValue("team", "streaming"))
+	g.Expect(ss.Spec.VolumeClaimTemplates[0].Annotations).To(Equal(cluster.Spec.Storage.ClaimAnnotations))
+
+	cluster.Spec.Storage.StorageClassName = "local-nvme"
+	ss = buildStatefulSet(cluster, "builder"+baseSuffix, nil, configuratorRejoin)
+	g.Expect(ss.Spec.VolumeClaimTemplates[0].Spec.StorageClassName).To(Equal(pointer.StringPtr("local-nvme")))
+
+	redpanda := ss.Spec.Template.Spec.Containers[0]
+	g.Expect(redpanda.Args).To(ContainElements("--memory 4G", "--lock-memory=true"))
+	g.Expect(redpanda.SecurityContext.Capabilities.Add).To(ConsistOf(corev1.Capability("IPC_LOCK")))
+
+	var secrets []string
+	for _, v := range ss.Spec.Template.Spec.Volumes {
+		if v.Secret != nil {
+			secrets = append(secrets, v.Secret.SecretName)
+		}
+	}
+	g.Expect(secrets).To(ConsistOf("rpc-cert"))
+}
+
+func TestPodSecurityContext(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := builderCluster()
+
+	sc := buildStatefulSet(cluster, "builder"+baseSuffix, nil, configuratorBootstrap).Spec.Template.Spec.SecurityContext
+	g.Expect(sc.FSGroup).To(Equal(pointer.Int64Ptr(fsGroup)))
+	g.Expect(*sc.FSGroupChangePolicy).To(Equal(corev1.FSGroupChangeOnRootMismatch))
+
+	always := corev1.FSGroupChangeAlways
+	cluster.Spec.PodSecurityContext = &corev1.PodSecurityContext{FSGroupChangePolicy: &always}
+
+	sc = buildStatefulSet(cluster, "builder"+baseSuffix, nil, configuratorBootstrap).Spec.Template.Spec.SecurityContext
+	g.Expect(*sc.FSGroupChangePolicy).To(Equal(corev1.FSGroupChangeAlways))
+	// The configured security context is not modified
+	g.Expect(cluster.Spec.PodSecurityContext.FSGroup).To(BeNil())
+}
+
+func TestSecurityContextMatches(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := builderCluster()
+	dropped := &corev1.PodSecurityContext{FSGroup: pointer.Int64Ptr(fsGroup)}
+
+	// The default policy dropped by the API server is not restored
+	g.Expect(securityContextMatches(cluster, dropped, podSecurityContext(cluster))).To(BeTrue())
+	g.Expect(securityContextMatches(cluster, nil, podSecurityContext(cluster))).To(BeFalse())
+
+	always := corev1.FSGroupChangeAlways
+	cluster.Spec.PodSecurityContext = &corev1.PodSecurityContext{FSGroupChangePolicy: &always}
+	g.Expect(securityContextMatches(cluster, dropped, podSecurityContext(cluster))).To(BeFalse())
+	g.Expect(securityContextMatches(cluster, podSecurityContext(cluster), podSecurityContext(cluster))).To(BeTrue())
+}
+
+func TestRestoreCertificateVolume(t *testing.T) {
+	g := NewWithT(t)
+
+	ss := buildStatefulSet(builderCluster(), "builder"+baseSuffix, nil, configuratorBootstrap)
+	spec := &ss.Spec.Template.Spec
+	volumes := len(spec.Volumes)
+
+	g.Expect(restoreCertificateVolume(spec, rpcTLSVolume, rpcTLSDir, "")).To(BeFalse())
+	g.Expect(restoreCertificateVolume(spec, rpcTLSVolume, rpcTLSDir, "rpc")).To(BeTrue())
+	g.Expect(spec.Volumes).To(HaveLen(volumes + 1))
+	g.Expect(spec.Containers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{
+		Name:		rpcTLSVolume,
+		MountPath:	rpcTLSDir,
+		ReadOnly:	true,
+	}))
+	g.Expect(restoreCertificateVolume(spec, rpcTLSVolume, rpcTLSDir, "rpc")).To(BeFalse())
+
+	g.Expect(restoreCertificateVolume(spec, rpcTLSVolume, rpcTLSDir, "rotated")).To(BeTrue())
+	g.Expect(spec.Volumes[volumes].Secret.SecretName).To(Equal("rotated"))
+
+	g.Expect(restoreCertificateVolume(spec, rpcTLSVolume, rpcTLSDir, "")).To(BeTrue())
+	g.Expect(spec.Volumes).To(HaveLen(volumes))
+	for _, m := range spec.Containers[0].VolumeMounts {
+		g.Expect(m.Name).NotTo(Equal(rpcTLSVolume))
+	}
+}
+
+func TestDependenciesWaiter(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := builderCluster()
+
+	ss := buildStatefulSet(cluster, "builder"+baseSuffix, nil, configuratorBootstrap)
+	g.Expect(ss.Spec.Template.Spec.InitContainers).To(HaveLen(1))
+
+	cluster.Spec.DependsOn = []redpandav1alpha1.Dependency{
+		{Name: "object-store", Address: "minio.storage.svc.cluster.local", Port: 9000},
+		{Name: "schema-registry", Address: "10.0.0.12", Port: 8081},
+	}
+
+	ss = buildStatefulSet(cluster, "builder"+baseSuffix, nil, configuratorBootstrap)
+	g.Expect(ss.Spec.Template.Spec.InitContainers).To(HaveLen(2))
+
+	waiter := ss.Spec.Template.Spec.InitContainers[1]
+	g.Expect(waiter.Name).To(Equal("redpanda-wait-for-dependencies"))
+	g.Expect(waiter.Image).To(Equal("vectorized/redpanda:v21.4.13"))
+	g.Expect(waiter.Command).To(Equal([]string{"/bin/bash", "-c"}))
+	g.Expect(waiter.Args[0]).To(ContainSubstring("/dev/tcp/"))
+	// A dependency dropping the packets does not block the attempt
+	g.Expect(waiter.Args[0]).To(ContainSubstring("until timeout 2 "))
+	g.Expect(waiter.Args[1:]).To(Equal([]string{
+		"wait-for-dependencies",
+		"object-store=minio.storage.svc.cluster.local:9000",
+		"schema-registry=10.0.0.12:8081",
+	}))
+}
+
+func TestCloudStorage(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := builderCluster()
+	g.Expect(cloudStorageProperties(cluster)).To(BeNil())
+	g.Expect(configuratorScriptContent(cluster, redpandaConfig(cluster))).NotTo(ContainSubstring("cloud_storage"))
+
+	cluster.Spec.CloudStorage = redpandav1alpha1.CloudStorageConfig{
+		Enabled:		true,
+		Bucket:			"segments",
+		Region:			"eu-west-1",
+		CredentialsSecretRef:	&corev1.LocalObjectReference{Name: "s3"},
+	}
+
+	g.Expect(cloudStorageProperties(cluster)).To(Equal(map[string]interface{}{
+		"cloud_storage_enabled":	true,
+		"cloud_storage_bucket":		"segments",
+		"cloud_storage_region":		"eu-west-1",
+	}))
+
+	// The credentials are only written once the configuration is printed,
+	// without tracing
+	script := configuratorScriptContent(cluster, redpandaConfig(cluster))
+	g.Expect(script).To(HaveSuffix(`cat $CONFIG;
+		set +x;
+		rpk --config $CONFIG config set redpanda.cloud_storage_access_key "$CLOUD_STORAGE_ACCESS_KEY";
+		rpk --config $CONFIG config set redpanda.cloud_storage_secret_key "$CLOUD_STORAGE_SECRET_KEY"`))
+
+	ss := buildStatefulSet(cluster, "builder"+baseSuffix, nil, configuratorBootstrap)
+	g.Expect(ss.Spec.Template.Spec.InitContainers[0].Env).To(Equal([]corev1.EnvVar{
+		{Name: "CONFIGURATOR_MODE", Value: configuratorBootstrap},
+		{Name: "CLOUD_STORAGE_ACCESS_KEY", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference:	corev1.LocalObjectReference{Name: "s3"}, Key: "access_key"}}},
+		{Name: "CLOUD_STORAGE_SECRET_KEY", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference:	corev1.LocalObjectReference{Name: "s3"}, Key: "secret_key"}}},
+	}))
+
+	cluster.Spec.CloudStorage.Endpoint = "minio.storage.svc.cluster.local"
+	g.Expect(cloudStorageProperties(cluster)).To(HaveKeyWithValue("cloud_storage_api_endpoint", "minio.storage.svc.cluster.local"))
+}
+
+func TestConfigMapItems(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := builderCluster()
+	g.Expect(configMapItems(cluster)).To(BeNil())
+
+	cluster.Spec.ConfigDir.FileModes = map[string]int32{
+		"redpanda.yaml":	0640,
+		"configurator.sh":	0700,
+		"unknown.yaml":		0600,
+	}
+	cluster.Spec.Storage.IOProperties = &redpandav1alpha1.IOPropertiesSource{Inline: "disks: []"}
+
+	g.Expect(configMapItems(cluster)).To(Equal([]corev1.KeyToPath{
+		{Key: "redpanda.yaml", Path: "redpanda.yaml", Mode: pointer.Int32Ptr(0640)},
+		// The script stays executable by the redpanda group
+		{Key: "configurator.sh", Path: "configurator.sh", Mode: pointer.Int32Ptr(0750)},
+		{Key: peersFile, Path: peersFile},
+		{Key: nodeIDsFile, Path: nodeIDsFile},
+		{Key: ioPropertiesFile, Path: ioPropertiesFile},
+	}))
+
+	ss := buildStatefulSet(cluster, "builder"+baseSuffix, nil, configuratorBootstrap)
+	for _, v := range ss.Spec.Template.Spec.Volumes {
+		if v.Name == "configmap-dir" {
+			g.Expect(v.ConfigMap.Items).To(HaveLen(5))
+			g.Expect(*v.ConfigMap.DefaultMode).To(BeEquivalentTo(0754))
+		}
+	}
+}
+
+func TestBuildPodDisruptionBudget(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := builderCluster()
+
+	for replicas, maxUnavailable := range map[int32]int{0: 1, 1: 1, 2: 1, 3: 1, 4: 1, 5: 2, 7: 3} {
+		cluster.Spec.Replicas = pointer.Int32Ptr(replicas)
+		pdb := buildPodDisruptionBudget(cluster)
+		g.Expect(pdb.Spec.MaxUnavailable.IntValue()).To(Equal(maxUnavailable), "replicas %d", replicas)
+		g.Expect(pdb.Spec.MinAvailable).To(BeNil())
+		g.Expect(pdb.Spec.Selector.MatchLabels).To(Equal(cluster.Labels))
+	}
+}
+
+func TestSplitConfig(t *testing.T) {
+	g := NewWithT(t)
+
+	reloadable := reloadableProperties(map[string]admin.ConfigProperty{
+		"log_segment_size":		{NeedsRestart: false},
+		"auto_create_topics_enabled":	{NeedsRestart: false},
+		"disk_reservation_percent":	{NeedsRestart: true},
+	})
+	g.Expect(reloadable).To(HaveLen(2))
+
+	hot, restartHash, err := splitConfig("redpanda:\n  data_directory: /var/lib/redpanda/data\n"+
+		"  log_segment_size: 1073741824\n  auto_create_topics_enabled: false\n", reloadable)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(hot).To(Equal(map[string]interface{}{
+		"log_segment_size":		1073741824,
+		"auto_create_topics_enabled":	false,
+	}))
+
+	// Only the restart requiring properties make the digest
+	_, sameHash, err := splitConfig("redpanda:\n  log_segment_size: 1024\n  data_directory: /var/lib/redpanda/data\n",
+		reloadable)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(sameHash).To(Equal(restartHash))
+
+	_, otherHash, err := splitConfig("redpanda:\n  data_directory: /data\n", reloadable)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(otherHash).NotTo(Equal(restartHash))
+
+	g.Expect(sameValue(float64(1073741824), 1073741824)).To(BeTrue())
+	g.Expect(sameValue(float64(1024), 1073741824)).To(BeFalse())
+}
+
+func TestInitContainerResources(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := builderCluster()
+	defaults := initContainerResources(cluster)
+	g.Expect(defaults.Requests).To(Equal(defaults.Limits))
+	g.Expect(defaults.Requests.Cpu().String()).To(Equal("100m"))
+	g.Expect(defaults.Requests.Memory().String()).To(Equal("128Mi"))
+
+	cluster.Spec.InitContainerResources = &corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("10m")},
+	}
+	cluster.Spec.Storage.VerifyDataDirectory = true
+	cluster.Spec.DependsOn = []redpandav1alpha1.Dependency{{Name: "minio", Address: "minio", Port: 9000}}
+
+	sts := buildStatefulSet(cluster, "builder-base", nil, configuratorBootstrap)
+	g.Expect(sts.Spec.Template.Spec.InitContainers).To(HaveLen(3))
+	for _, c := range sts.Spec.Template.Spec.InitContainers {
+		g.Expect(c.Resources).To(Equal(*cluster.Spec.InitContainerResources), c.Name)
+	}
+}
+
+func TestPodAntiAffinity(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := builderCluster()
+	antiAffinity := podAntiAffinity(cluster)
+	g.Expect(antiAffinity.RequiredDuringSchedulingIgnoredDuringExecution).To(HaveLen(1))
+	g.Expect(antiAffinity.RequiredDuringSchedulingIgnoredDuringExecution[0].TopologyKey).To(Equal(corev1.LabelHostname))
+	g.Expect(antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution).To(HaveLen(1))
+
+	cluster.Spec.Configuration.DeveloperMode = true
+	antiAffinity = podAntiAffinity(cluster)
+	g.Expect(antiAffinity.RequiredDuringSchedulingIgnoredDuringExecution).To(BeEmpty())
+	g.Expect(antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution).To(HaveLen(1))
+	g.Expect(antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution[0].PodAffinityTerm.TopologyKey).
+		To(Equal(corev1.LabelHostname))
+}
+
+func TestBuildSuperuserSecret(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := builderCluster()
+
+	secret := buildSuperuserSecret(cluster, "secret")
+	g.Expect(secret.Name).To(Equal("builder" + superuserSuffix))
+	g.Expect(secret.Labels).To(Equal(cluster.Labels))
+	g.Expect(secret.Data).To(Equal(map[string][]byte{
+		usernameKey:	[]byte(superuserName(cluster)),
+		passwordKey:	[]byte("secret"),
+	}))
+}
+
+func TestSelfSignedCertificateRenewal(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := builderCluster()
+	now := time.Now()
+
+	ca, err := generateCA(cluster, now)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	cert, err := issueCertificate(ca, selfSignedDNSNames(cluster), nil, now)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(cert.cert.CheckSignatureFrom(ca.cert)).To(Succeed())
+	g.Expect(cert.cert.VerifyHostname("builder-1.builder.default.svc.cluster.local")).To(Succeed())
+
+	g.Expect(needsRenewal(cert.cert, now)).To(BeFalse())
+	g.Expect(needsRenewal(cert.cert, now.Add(certificateValidity-renewBefore-2*time.Hour))).To(BeFalse())
+	g.Expect(needsRenewal(cert.cert, now.Add(certificateValidity-renewBefore))).To(BeTrue())
+
+	// The certificate issued by an expiring CA does not outlive it
+	cert, err = issueCertificate(ca, selfSignedDNSNames(cluster), nil, ca.cert.NotAfter.Add(-24*time.Hour))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(cert.cert.NotAfter).To(Equal(ca.cert.NotAfter))
+
+	// The client certificate can not serve the brokers APIs
+	client, err := issueClientCertificate(ca, "builder client", now)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(client.cert.CheckSignatureFrom(ca.cert)).To(Succeed())
+	g.Expect(client.cert.ExtKeyUsage).To(Equal([]x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}))
+	g.Expect(client.cert.DNSNames).To(BeEmpty())
+}
+
+func TestAdvertisedKafkaAddresses(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := builderCluster()
+	cluster.Spec.Replicas = pointer.Int32Ptr(3)
+
+	g.Expect(advertisedKafkaAddresses(cluster)).To(Equal([]string{
+		"builder-0.builder.default.svc.cluster.local",
+		"builder-1.builder.default.svc.cluster.local",
+		"builder-2.builder.default.svc.cluster.local",
+	}))
+
+	cluster.Spec.ExternalConnectivity.PerBrokerAddresses = []string{"kafka-0.example.com", "203.0.113.1"}
+	addresses := advertisedKafkaAddresses(cluster)
+	g.Expect(addresses).To(Equal([]string{
+		"kafka-0.example.com",
+		"203.0.113.1",
+		"builder-2.builder.default.svc.cluster.local",
+	}))
+
+	// The self-signed certificate covers every advertised address
+	now := time.Now()
+	ca, err := generateCA(cluster, now)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	cert, err := issueCertificate(ca, selfSignedDNSNames(cluster), selfSignedIPAddresses(cluster), now)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(cert.cert.IPAddresses).To(Equal(selfSignedIPAddresses(cluster)))
+
+	for _, address := range addresses {
+		g.Expect(cert.cert.VerifyHostname(address)).To(Succeed())
+	}
+
+	// The node addresses are only known once the brokers are scheduled
+	cluster.Spec.ExternalConnectivity.PerBrokerNodePorts = &redpandav1alpha1.PerBrokerNodePorts{BasePort: 30000}
+	g.Expect(advertisedKafkaAddresses(cluster)).To(Equal([]string{"kafka-0.example.com", "203.0.113.1"}))
+}
+
+func builderConsole() *redpandav1alpha1.Console {
+	return &redpandav1alpha1.Console{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:		"ui",
+			Namespace:	"default",
+		},
+		Spec: redpandav1alpha1.ConsoleSpec{
+			ClusterRef:	corev1.LocalObjectReference{Name: "builder"},
+			Version:	"v2.0.0",
+		},
+	}
+}
+
+func TestConsoleConfig(t *testing.T) {
+	g := NewWithT(t)
+
+	console := builderConsole()
+	cluster := builderCluster()
+	cluster.Spec.Replicas = pointer.Int32Ptr(2)
+
+	cfg := consoleConfig(console, cluster)
+	g.Expect(cfg["kafka"]).To(Equal(map[string]interface{}{
+		"brokers": []string{
+			"builder-0.builder.default.svc.cluster.local:9092",
+			"builder-1.builder.default.svc.cluster.local:9092",
+		},
+	}))
+	g.Expect(cfg["redpanda"]).To(Equal(map[string]interface{}{
+		"adminApi": map[string]interface{}{
+			"enabled":	true,
+			"urls": []string{
+				"http://builder-0.builder.default.svc.cluster.local:9644",
+				"http://builder-1.builder.default.svc.cluster.local:9644",
+			},
+		},
+	}))
+	g.Expect(cfg["server"]).To(Equal(map[string]interface{}{"listenPort": defaultConsolePort}))
+
+	cluster.Spec.SASL.Enabled = true
+	cluster.Spec.SchemaRegistry.Enabled = true
+	cluster.Spec.TLS.SelfSigned = true
+	cluster.Spec.Configuration.KafkaAPITLS = redpandav1alpha1.KafkaAPITLS{Enabled: true, RequireClientAuth: true}
+	cluster.Spec.Configuration.AdminAPI.RequireAuth = true
+	cluster.Spec.Configuration.AdminAPI.TLS.CASecretRef = &corev1.LocalObjectReference{Name: "admin-ca"}
+
+	cfg = consoleConfig(console, cluster)
+	kafka := cfg["kafka"].(map[string]interface{})
+	g.Expect(kafka["tls"]).To(Equal(map[string]interface{}{
+		"enabled":	true,
+		"caFilepath":	"/etc/tls/certs/kafka/ca.crt",
+		"certFilepath":	"/etc/tls/certs/kafka-client/tls.crt",
+		"keyFilepath":	"/etc/tls/certs/kafka-client/tls.key",
+	}))
+	g.Expect(kafka["sasl"]).To(Equal(map[string]interface{}{
+		"enabled":	true,
+		"username":	defaultSuperuserName,
+		"mechanism":	"SCRAM-SHA-256",
+	}))
+
+	// The Console authenticates with the mechanism of the superuser
+	cluster.Spec.SASL.Mechanisms = []redpandav1alpha1.SASLMechanism{redpandav1alpha1.SASLMechanismScramSha512}
+	sasl := consoleConfig(console, cluster)["kafka"].(map[string]interface{})["sasl"].(map[string]interface{})
+	g.Expect(sasl["mechanism"]).To(Equal("SCRAM-SHA-512"))
+
+	// An existing superuser keeps the mechanism it was created with
+	cluster.Spec.SASL.Mechanisms = []redpandav1alpha1.SASLMechanism{
+		redpandav1alpha1.SASLMechanismScramSha256, redpandav1alpha1.SASLMechanismScramSha512,
+	}
+	cluster.Status.SuperuserMechanism = string(redpandav1alpha1.SASLMechanismScramSha512)
+	sasl = consoleConfig(console, cluster)["kafka"].(map[string]interface{})["sasl"].(map[string]interface{})
+	g.Expect(sasl["mechanism"]).To(Equal("SCRAM-SHA-512"))
+	cluster.Status.SuperuserMechanism = ""
+	g.Expect(kafka["schemaRegistry"]).To(Equal(map[string]interface{}{
+		"enabled":	true,
+		"urls": []string{
+			"http://builder-0.builder.default.svc.cluster.local:8081",
+			"http://builder-1.builder.default.svc.cluster.local:8081",
+		},
+	}))
+
+	adminAPI := cfg["redpanda"].(map[string]interface{})["adminApi"].(map[string]interface{})
+	g.Expect(adminAPI["urls"]).To(ContainElement("https://builder-0.builder.default.svc.cluster.local:9644"))
+	g.Expect(adminAPI["username"]).To(Equal(defaultSuperuserName))
+	g.Expect(adminAPI["tls"]).To(Equal(map[string]interface{}{
+		"enabled":	true,
+		"caFilepath":	"/etc/tls/certs/admin-api-ca/ca.crt",
+	}))
+
+	// The Console reaches the Admin API at the URL of the operator
+	cluster.Spec.Configuration.AdminAPI.URL = "https://admin.example.com:9644"
+	adminAPI = consoleConfig(console, cluster)["redpanda"].(map[string]interface{})["adminApi"].(map[string]interface{})
+	g.Expect(adminAPI["urls"]).To(Equal([]string{"https://admin.example.com:9644"}))
+	g.Expect(adminAPI["tls"]).NotTo(BeNil())
+
+	// A user kafka certificate requires a client certificate of the Console
+	cluster.Spec.Configuration.KafkaAPITLS.CertSecretRef = &corev1.LocalObjectReference{Name: "kafka-tls"}
+	kafkaTLS := consoleConfig(console, cluster)["kafka"].(map[string]interface{})["tls"].(map[string]interface{})
+	g.Expect(kafkaTLS).NotTo(HaveKey("certFilepath"))
+	console.Spec.KafkaClientCertSecretRef = &corev1.LocalObjectReference{Name: "ui-client"}
+	kafkaTLS = consoleConfig(console, cluster)["kafka"].(map[string]interface{})["tls"].(map[string]interface{})
+	g.Expect(kafkaTLS["certFilepath"]).To(Equal("/etc/tls/certs/kafka-client/tls.crt"))
+
+	// The passwords are never written to the ConfigMap
+	cm, err := buildConsoleConfigMap(console, cluster)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(cm.Name).To(Equal("ui" + consoleSuffix))
+	g.Expect(cm.Data[consoleConfigFile]).To(ContainSubstring("listenPort: 8080"))
+	g.Expect(cm.Data[consoleConfigFile]).NotTo(ContainSubstring("password"))
+}
+
+func TestBuildConsoleDeployment(t *testing.T) {
+	g := NewWithT(t)
+
+	console := builderConsole()
+	cluster := builderCluster()
+
+	deploy := buildConsoleDeployment(console, cluster, "digest")
+	g.Expect(deploy.Name).To(Equal("ui" + consoleSuffix))
+	g.Expect(*deploy.Spec.Replicas).To(Equal(int32(1)))
+	g.Expect(deploy.Spec.Selector.MatchLabels).To(Equal(consoleLabels(console)))
+	g.Expect(deploy.Spec.Template.Labels).To(Equal(consoleLabels(console)))
+	g.Expect(deploy.Spec.Template.Annotations[consoleConfigHashAnnotation]).To(Equal("digest"))
+
+	container := deploy.Spec.Template.Spec.Containers[0]
+	g.Expect(container.Image).To(Equal("vectorized/console:v2.0.0"))
+	g.Expect(container.Args).To(Equal([]string{"--config.filepath=/etc/console/config.yaml"}))
+	g.Expect(container.Env).To(BeEmpty())
+	g.Expect(deploy.Spec.Template.Spec.Volumes).To(HaveLen(1))
+
+	// A new configuration changes the desired spec
+	hash := deploy.Annotations[consoleTemplateHashAnnotation]
+	g.Expect(hash).NotTo(BeEmpty())
+	g.Expect(buildConsoleDeployment(console, cluster, "digest").Annotations).
+		To(HaveKeyWithValue(consoleTemplateHashAnnotation, hash))
+	g.Expect(buildConsoleDeployment(console, cluster, "other").Annotations).
+		NotTo(HaveKeyWithValue(consoleTemplateHashAnnotation, hash))
+
+	cluster.Spec.SASL.Enabled = true
+	cluster.Spec.SASL.SuperuserSecretRef = &corev1.LocalObjectReference{Name: "credentials"}
+	cluster.Spec.TLS.SelfSigned = true
+	cluster.Spec.Configuration.KafkaAPITLS.Enabled = true
+
+	deploy = buildConsoleDeployment(console, cluster, "digest")
+	container = deploy.Spec.Template.Spec.Containers[0]
+	g.Expect(container.Env).To(HaveLen(1))
+	g.Expect(container.Env[0].Name).To(Equal(kafkaSASLPasswordEnv))
+	g.Expect(container.Env[0].ValueFrom.SecretKeyRef.Name).To(Equal("credentials"))
+	g.Expect(container.Env[0].ValueFrom.SecretKeyRef.Key).To(Equal(passwordKey))
+
+	// Only the CA of the brokers certificate is mounted without client
+	// authentication
+	volumes := deploy.Spec.Template.Spec.Volumes
+	g.Expect(volumes).To(HaveLen(2))
+	g.Expect(volumes[1].Secret.SecretName).To(Equal("builder" + selfSignedTLSSuffix))
+	g.Expect(volumes[1].Secret.Items).To(Equal([]corev1.KeyToPath{{Key: caCertKey, Path: caCertKey}}))
+	g.Expect(container.VolumeMounts).To(ContainElement(corev1.VolumeMount{
+		Name:		consoleKafkaTLSVolume,
+		MountPath:	consoleKafkaTLSDir,
+		ReadOnly:	true,
+	}))
+
+	// The client certificate is issued for the Console, the key of the
+	// brokers certificate is never mounted
+	cluster.Spec.Configuration.KafkaAPITLS.RequireClientAuth = true
+	volumes = buildConsoleDeployment(console, cluster, "digest").Spec.Template.Spec.Volumes
+	g.Expect(volumes).To(HaveLen(3))
+	g.Expect(volumes[1].Secret.Items).To(Equal([]corev1.KeyToPath{{Key: caCertKey, Path: caCertKey}}))
+	g.Expect(volumes[2].Name).To(Equal(consoleKafkaClientVolume))
+	g.Expect(volumes[2].Secret.SecretName).To(Equal("builder" + selfSignedClientSuffix))
+
+	svc := buildConsoleService(console)
+	g.Expect(svc.Spec.Selector).To(Equal(consoleLabels(console)))
+	g.Expect(svc.Spec.Ports).To(HaveLen(1))
+	g.Expect(svc.Spec.Ports[0].Port).To(Equal(int32(defaultConsolePort)))
+}
+
+func TestConsoleClusterCondition(t *testing.T) {
+	g := NewWithT(t)
+
+	console := builderConsole()
+	g.Expect(consoleClusterCondition(console, nil).Reason).To(Equal(reasonConsoleClusterNotFound))
+
+	cluster := builderCluster()
+	g.Expect(consoleClusterCondition(console, cluster).Reason).To(Equal(reasonConsoleClusterNotReady))
+
+	cluster.Status.Replicas = 1
+	condition := consoleClusterCondition(console, cluster)
+	g.Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(condition.Reason).To(Equal(reasonConsoleClusterAvailable))
+
+	cluster.Spec.Configuration.KafkaAPITLS = redpandav1alpha1.KafkaAPITLS{
+		Enabled:		true,
+		CertSecretRef:		&corev1.LocalObjectReference{Name: "kafka-tls"},
+		RequireClientAuth:	true,
+	}
+	g.Expect(consoleClusterCondition(console, cluster).Reason).To(Equal(reasonConsoleClientCertRequired))
+
+	console.Spec.KafkaClientCertSecretRef = &corev1.LocalObjectReference{Name: "ui-client"}
+	g.Expect(consoleClusterCondition(console, cluster).Reason).To(Equal(reasonConsoleClusterAvailable))
+
+	setDegraded(&cluster.Status, "Reason", "broken")
+	condition = consoleClusterCondition(console, cluster)
+	g.Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+	g.Expect(condition.Reason).To(Equal(reasonConsoleClusterDegraded))
+	g.Expect(condition.Message).To(ContainSubstring("broken"))
+}
+
+func builderBrokerGroup() *redpandav1alpha1.BrokerGroup {
+	return &redpandav1alpha1.BrokerGroup{
+		Name:		"hot",
+		Replicas:	2,
+		FirstNodeID:	10,
+		Resources: redpandav1alpha1.RedpandaResourceRequirements{
+			ResourceRequirements: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{
+					corev1.ResourceCPU:	resource.MustParse("4"),
+					corev1.ResourceMemory:	resource.MustParse("8Gi"),
+				},
+			},
+		},
+	}
+}
+
+func TestBuildBrokerGroupStatefulSet(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := builderCluster()
+	cluster.Spec.Replicas = pointer.Int32Ptr(3)
+	cluster.Spec.BrokerGroups = []redpandav1alpha1.BrokerGroup{*builderBrokerGroup()}
+	group := &cluster.Spec.BrokerGroups[0]
+
+	ss := buildBrokerGroupStatefulSet(cluster, group, map[string]string{}, configuratorRejoin)
+	g.Expect(ss.Name).To(Equal("builder-hot"))
+	g.Expect(ss.Spec.ServiceName).To(Equal("builder"))
+	g.Expect(*ss.Spec.Replicas).To(BeEquivalentTo(1))
+	g.Expect(ss.Spec.Selector.MatchLabels).To(Equal(map[string]string{"app": "builder", brokerGroupLabel: "hot"}))
+	g.Expect(ss.Spec.Template.Labels).To(Equal(ss.Spec.Selector.MatchLabels))
+	g.Expect(ss.Spec.Template.Spec.Volumes[1].ConfigMap.Name).To(Equal("builder" + baseSuffix))
+	g.Expect(ss.Spec.Template.Spec.InitContainers[0].Env).To(ConsistOf(
+		corev1.EnvVar{Name: configuratorModeEnv, Value: configuratorRejoin},
+		corev1.EnvVar{Name: firstNodeIDEnv, Value: "10"}))
+
+	// The brokers of the group are spread with every broker of the Cluster
+	g.Expect(ss.Spec.Template.Spec.Affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution[0].
+		LabelSelector.MatchLabels).To(Equal(cluster.Labels))
+
+	redpanda := ss.Spec.Template.Spec.Containers[0]
+	g.Expect(redpanda.Resources.Limits).To(Equal(group.Resources.Limits))
+	g.Expect(redpanda.Args).To(ContainElement("--memory 8G"))
+
+	// The Cluster definition is left untouched
+	g.Expect(cluster.Spec.PodTemplate.Labels).To(BeEmpty())
+	g.Expect(*cluster.Spec.Replicas).To(BeEquivalentTo(3))
+
+	main := buildStatefulSet(cluster, "builder"+baseSuffix, map[string]string{}, configuratorBootstrap)
+	g.Expect(main.Spec.Template.Spec.InitContainers[0].Env).To(ConsistOf(
+		corev1.EnvVar{Name: configuratorModeEnv, Value: configuratorBootstrap}))
+
+	// The pods of the group are not selected by the Cluster StatefulSet
+	selector, err := metav1.LabelSelectorAsSelector(main.Spec.Selector)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(selector.Matches(labels.Set(main.Spec.Template.Labels))).To(BeTrue())
+	g.Expect(selector.Matches(labels.Set(ss.Spec.Template.Labels))).To(BeFalse())
+
+	// The scale subresource selects the same pods
+	scale, err := labels.Parse(scaleSelector(cluster))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(scale.Matches(labels.Set(main.Spec.Template.Labels))).To(BeTrue())
+	g.Expect(scale.Matches(labels.Set(ss.Spec.Template.Labels))).To(BeFalse())
+}
+
+func TestBrokerGroupNodeID(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := builderCluster()
+	script := configuratorScriptContent(cluster, redpandaConfig(cluster))
+	g.Expect(script).To(ContainSubstring("redpanda.node_id $ORDINAL_INDEX;"))
+	g.Expect(script).NotTo(ContainSubstring("NODE_ID="))
+
+	cluster.Spec.Replicas = pointer.Int32Ptr(3)
+	cluster.Spec.BrokerGroups = []redpandav1alpha1.BrokerGroup{*builderBrokerGroup()}
+	script = configuratorScriptContent(cluster, redpandaConfig(cluster))
+	g.Expect(script).To(ContainSubstring("redpanda.node_id $NODE_ID;"))
+	g.Expect(script).To(ContainSubstring(`if [ "$NODE_ID" = "0" ]`))
+
+	var offset string
+	for _, line := range strings.Split(script, "\n") {
+		if strings.Contains(line, "NODE_ID=") {
+			offset = line
+		}
+	}
+
+	for _, c := range []struct {
+		hostname, env	string
+		nodeID		string
+	}{
+		{"builder-2", "", "2"},
+		{"builder-hot-0", "FIRST_NODE_ID=10", "10"},
+		{"builder-hot-1", "FIRST_NODE_ID=10", "11"},
+	} {
+		out, err := exec.Command("/bin/sh", "-c", fmt.Sprintf(
+			"%s HOSTNAME=%s; ORDINAL_INDEX=${HOSTNAME##*-};%s\necho -n $NODE_ID",
+			c.env, c.hostname, offset)).Output()
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(string(out)).To(Equal(c.nodeID), c.hostname)
+	}
+
+	pod := func(name string, labels map[string]string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+
+	nodeID, ok := brokerNodeID(cluster, pod("builder-2", nil))
+	g.Expect(ok).To(BeTrue())
+	g.Expect(nodeID).To(Equal(2))
+
+	nodeID, ok = brokerNodeID(cluster, pod("builder-hot-1", map[string]string{brokerGroupLabel: "hot"}))
+	g.Expect(ok).To(BeTrue())
+	g.Expect(nodeID).To(Equal(11))
+
+	_, ok = brokerNodeID(cluster, pod("builder-cold-0", map[string]string{brokerGroupLabel: "cold"}))
+	g.Expect(ok).To(BeFalse())
+
+	g.Expect(brokerPodNames(cluster)).To(Equal([]string{
+		"builder-0", "builder-1", "builder-2", "builder-hot-0", "builder-hot-1",
+	}))
+	g.Expect(buildPodDisruptionBudget(cluster).Spec.MaxUnavailable.IntValue()).To(Equal(2))
+}
+
+func TestReplacedNodeID(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := builderCluster()
+	cluster.Spec.Replicas = pointer.Int32Ptr(3)
+	script := configuratorScriptContent(cluster, redpandaConfig(cluster))
+	g.Expect(script).NotTo(ContainSubstring(nodeIDsPath))
+
+	cluster.Spec.BrokerReplacement.Enabled = true
+	cluster.Status.NodeIDs = map[string]int{"builder-2": 1002, "builder-0": 2000}
+	g.Expect(nodeIDList(cluster)).To(Equal("builder-0 2000\nbuilder-2 1002\n"))
+
+	cm, err := buildConfigMap(cluster, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(cm.Data).To(HaveKeyWithValue(nodeIDsFile, nodeIDList(cluster)))
+
+	script = cm.Data[configuratorScript]
+	g.Expect(script).To(ContainSubstring("redpanda.node_id $NODE_ID;"))
+	g.Expect(script).To(ContainSubstring(`if [ "$NODE_ID" = "0" ]`))
+
+	start := strings.Index(script, "ORDINAL_INDEX=")
+	end := strings.Index(script, "done < "+nodeIDsPath+";")
+	g.Expect(start).To(BeNumerically(">=", 0))
+	g.Expect(end).To(BeNumerically(">", start))
+
+	dir, err := ioutil.TempDir("", "node-ids")
+	g.Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, nodeIDsFile)
+	g.Expect(ioutil.WriteFile(file, []byte(cm.Data[nodeIDsFile]), 0600)).To(Succeed())
+
+	for hostname, nodeID := range map[string]string{"builder-0": "2000", "builder-1": "1", "builder-2": "1002"} {
+		out, err := exec.Command("/bin/sh", "-c", fmt.Sprintf("HOSTNAME=%s; %sdone < %s;\necho -n $NODE_ID",
+			hostname, script[start:end], file)).Output()
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(string(out)).To(Equal(nodeID), hostname)
+	}
+
+	nodeID, ok := brokerNodeID(cluster, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "builder-2"}})
+	g.Expect(ok).To(BeTrue())
+	g.Expect(nodeID).To(Equal(1002))
+
+	sts := &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "builder"}}
+	g.Expect(replicaNodeID(cluster, sts, 1)).To(Equal(1))
+	g.Expect(replicaNodeID(cluster, sts, 2)).To(Equal(1002))
+
+	// The replaced brokers keep their node id once the replacement is
+	// disabled
+	cluster.Spec.BrokerReplacement.Enabled = false
+	script = configuratorScriptContent(cluster, redpandaConfig(cluster))
+	g.Expect(script).To(ContainSubstring("done < " + nodeIDsPath + ";"))
+
+	// The node id listed for the pod overrides the one of its group
+	cluster.Spec.BrokerGroups = []redpandav1alpha1.BrokerGroup{*builderBrokerGroup()}
+	script = configuratorScriptContent(cluster, redpandaConfig(cluster))
+	g.Expect(strings.Index(script, firstNodeIDEnv)).To(BeNumerically("<", strings.Index(script, nodeIDsPath)))
+}
@@ -0,0 +1,175 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	"github.com/vectorizedio/redpanda/src/go/k8s/pkg/admin"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// defaultFreeThresholdPercent is the redpanda default of
+// storage_space_alert_free_threshold_percent
+const defaultFreeThresholdPercent = 5
+
+const (
+	reasonDiskSpaceLow	= "FreeSpaceBelowThreshold"
+	reasonDiskSpaceOK	= "FreeSpaceAboveThreshold"
+)
+
+// updateBrokerUsage reports the resource usage of the brokers in the
+// status, so that scaling decisions can be made on the Cluster. The disk
+// usage and the partition counts change continuously, they are refreshed
+// at most once per lastReconcileTimeResolution so that they do not write
+// the status on every reconciliation.
+func (r *ClusterReconciler) updateBrokerUsage(
+	ctx context.Context,
+	cluster *redpandav1alpha1.Cluster,
+	adminAPIConfig *AdminAPIConfig,
+	status *redpandav1alpha1.ClusterStatus,
+) error {
+	adminAPI, err := r.adminAPIClient(cluster, adminAPIConfig)
+	if err != nil {
+		return err
+	}
+
+	brokers, err := adminAPI.Brokers(ctx)
+	if err != nil {
+		return err
+	}
+
+	previous := make(map[int]redpandav1alpha1.BrokerStatus, len(cluster.Status.Brokers))
+	for _, b := range cluster.Status.Brokers {
+		previous[b.NodeID] = b
+	}
+
+	// The new brokers are reported right away
+	refresh := usageRefreshDue(cluster)
+	for _, b := range brokers {
+		if _, reported := previous[b.NodeID]; !reported {
+			refresh = true
+		}
+	}
+
+	// The partition counts are informative only, failing to fetch them,
+	// e.g. from the brokers not serving the cluster partitions, keeps the
+	// previously reported ones
+	var counts map[int]int
+	if refresh {
+		counts, _ = partitionCounts(ctx, adminAPI)
+	}
+
+	usage := make([]redpandav1alpha1.BrokerStatus, 0, len(brokers))
+
+	for _, b := range brokers {
+		broker := redpandav1alpha1.BrokerStatus{
+			NodeID:		b.NodeID,
+			InMaintenance:	b.Maintenance != nil && b.Maintenance.Draining,
+		}
+
+		prev := previous[b.NodeID]
+
+		if refresh {
+			for _, d := range b.DiskSpace {
+				broker.DiskUsedBytes += d.Total - d.Free
+				broker.DiskTotalBytes += d.Total
+			}
+		} else {
+			broker.DiskUsedBytes, broker.DiskTotalBytes = prev.DiskUsedBytes, prev.DiskTotalBytes
+		}
+
+		broker.PartitionCount = prev.PartitionCount
+		if counts != nil {
+			broker.PartitionCount = counts[b.NodeID]
+		}
+
+		usage = append(usage, broker)
+	}
+
+	// Sorted so that the status does not change with the response order
+	sort.Slice(usage, func(i, j int) bool {
+		return usage[i].NodeID < usage[j].NodeID
+	})
+
+	status.Brokers = usage
+	setDiskSpaceLow(status, freeThresholdPercent(cluster))
+
+	return nil
+}
+
+// partitionCounts returns the number of partition replicas hosted by each
+// broker, by node id
+func partitionCounts(
+	ctx context.Context, adminAPI admin.AdminAPIClient,
+) (map[int]int, error) {
+	partitions, err := adminAPI.ClusterPartitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[int]int)
+
+	for _, p := range partitions {
+		for _, replica := range p.Replicas {
+			counts[replica.NodeID]++
+		}
+	}
+
+	return counts, nil
+}
+
+func freeThresholdPercent(cluster *redpandav1alpha1.Cluster) int {
+	if t := cluster.Spec.Configuration.DiskAlerts.FreeThresholdPercent; t != 0 {
+		return t
+	}
+
+	return defaultFreeThresholdPercent
+}
+
+// setDiskSpaceLow reports the brokers whose free disk space is below the
+// threshold, giving an early warning before the writes start failing
+func setDiskSpaceLow(status *redpandav1alpha1.ClusterStatus, threshold int) {
+	var low []string
+
+	for _, b := range status.Brokers {
+		if b.DiskTotalBytes == 0 {
+			continue
+		}
+
+		free := b.DiskTotalBytes - b.DiskUsedBytes
+		if free*100 < int64(threshold)*b.DiskTotalBytes {
+			low = append(low, fmt.Sprint(b.NodeID))
+		}
+	}
+
+	if len(low) == 0 {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:	redpandav1alpha1.ClusterDiskSpaceLow,
+			Status:	metav1.ConditionFalse,
+			Reason:	reasonDiskSpaceOK,
+		})
+
+		return
+	}
+
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:	redpandav1alpha1.ClusterDiskSpaceLow,
+		Status:	metav1.ConditionTrue,
+		Reason:	reasonDiskSpaceLow,
+		Message: fmt.Sprintf("brokers %s have less than %d%% of free disk space",
+			strings.Join(low, ", "), threshold),
+	})
+}
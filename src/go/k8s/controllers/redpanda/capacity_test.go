@@ -0,0 +1,97 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Redpanda broker usage", func() {
+	Context("When the brokers are ready", func() {
+		It("Should report the usage of every broker in the status", func() {
+			key := testKey("redpanda-broker-usage")
+			Expect(k8sClient.Create(context.Background(), testCluster(key.Name))).Should(Succeed())
+			setReadyReplicas(key, 1)
+
+			Eventually(func() []v1alpha1.BrokerStatus {
+				var redpandaCluster v1alpha1.Cluster
+				if err := k8sClient.Get(context.Background(), key, &redpandaCluster); err != nil {
+					return nil
+				}
+				return redpandaCluster.Status.Brokers
+			}, timeout, interval).Should(Equal([]v1alpha1.BrokerStatus{
+				{NodeID: 0, DiskUsedBytes: 90, DiskTotalBytes: 100, PartitionCount: 5},
+				{NodeID: 1, DiskUsedBytes: 60, DiskTotalBytes: 100, PartitionCount: 3},
+			}))
+		})
+	})
+
+	Context("When a broker is low on disk space", func() {
+		It("Should report the Cluster disk space as low", func() {
+			key := testKey("redpanda-disk-space-low")
+			redpandaCluster := testCluster(key.Name)
+			// The mock broker 0 has 10% of free disk space
+			redpandaCluster.Spec.Configuration.DiskAlerts.FreeThresholdPercent = 20
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+			setReadyReplicas(key, 1)
+
+			Eventually(func() metav1.ConditionStatus {
+				return clusterCondition(key, v1alpha1.ClusterDiskSpaceLow)
+			}, timeout, interval).Should(Equal(metav1.ConditionTrue))
+		})
+
+		It("Should not report the disk space as low above the default threshold", func() {
+			key := testKey("redpanda-disk-space-ok")
+			Expect(k8sClient.Create(context.Background(), testCluster(key.Name))).Should(Succeed())
+			setReadyReplicas(key, 1)
+
+			Eventually(func() metav1.ConditionStatus {
+				return clusterCondition(key, v1alpha1.ClusterDiskSpaceLow)
+			}, timeout, interval).Should(Equal(metav1.ConditionFalse))
+		})
+	})
+
+	Context("When no broker is ready", func() {
+		It("Should not report any usage", func() {
+			key := testKey("redpanda-broker-usage-not-ready")
+			Expect(k8sClient.Create(context.Background(), testCluster(key.Name))).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+
+			var redpandaCluster v1alpha1.Cluster
+			Expect(k8sClient.Get(context.Background(), key, &redpandaCluster)).Should(Succeed())
+			Expect(redpandaCluster.Status.Brokers).Should(BeEmpty())
+		})
+	})
+})
+
+// setReadyReplicas reports the replicas of the Cluster StatefulSet as
+// ready, as no pod ever runs in the test environment
+func setReadyReplicas(key types.NamespacedName, replicas int32) {
+	Eventually(func() error {
+		var sts appsv1.StatefulSet
+		if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+			return err
+		}
+		sts.Status.Replicas = replicas
+		sts.Status.ReadyReplicas = replicas
+		return k8sClient.Status().Update(context.Background(), &sts)
+	}, timeout, interval).Should(Succeed())
+}
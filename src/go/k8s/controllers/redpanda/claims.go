@@ -0,0 +1,246 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// dataVolumeName is the name of the claim template of the data
+	// directory, the StatefulSet names its claims datadir-<sts>-<ordinal>
+	dataVolumeName	= "datadir"
+
+	// claimOrdinalAnnotation records the ordinal of the broker whose data
+	// a claim holds. A claim restored or renamed from the volume of
+	// another broker keeps it, so its data is never handed to the broker
+	// of a different ordinal.
+	claimOrdinalAnnotation	= "redpanda.vectorized.io/ordinal"
+	// claimDecommissionedAnnotation marks the claim left behind by a
+	// decommissioned broker, a new broker of the same ordinal must not
+	// start on its data
+	claimDecommissionedAnnotation	= "redpanda.vectorized.io/decommissioned"
+
+	// Reasons of the Degraded condition set by the data claims check
+	reasonDataClaimMismatch		= "DataClaimMismatch"
+	reasonDataClaimsConsistent	= "DataClaimsConsistent"
+)
+
+// dataClaimName returns the name of the data claim of the broker ordinal
+func dataClaimName(sts *appsv1.StatefulSet, ordinal int) string {
+	return fmt.Sprintf("%s-%s-%d", dataVolumeName, sts.Name, ordinal)
+}
+
+// dataClaimOrdinal returns the ordinal of the broker the StatefulSet binds
+// the claim to, or -1 when it is not a data claim of the StatefulSet
+func dataClaimOrdinal(stsName, name string) int {
+	prefix := dataVolumeName + "-" + stsName + "-"
+	if !strings.HasPrefix(name, prefix) {
+		return -1
+	}
+
+	ordinal, err := strconv.Atoi(strings.TrimPrefix(name, prefix))
+	if err != nil || ordinal < 0 {
+		return -1
+	}
+
+	return ordinal
+}
+
+// claimMismatch explains why the data of the claim must not be used by the
+// broker of the given ordinal, it returns an empty string when it can be
+func claimMismatch(pvc *corev1.PersistentVolumeClaim, ordinal int) string {
+	if pvc.Annotations[claimDecommissionedAnnotation] == "true" {
+		return fmt.Sprintf("claim %s holds the data of decommissioned broker %d", pvc.Name, ordinal)
+	}
+
+	if recorded, ok := pvc.Annotations[claimOrdinalAnnotation]; ok && recorded != strconv.Itoa(ordinal) {
+		return fmt.Sprintf("claim %s holds the data of broker %s, not of broker %d", pvc.Name, recorded, ordinal)
+	}
+
+	return ""
+}
+
+// reconcileDataClaims verifies that every data claim the StatefulSet binds
+// holds the data of the broker of its ordinal, and returns the replicas the
+// StatefulSet may be scaled to. The scale up stops before the first broker
+// which would start on mismatched data, and the Cluster is reported as
+// degraded until the claim is deleted. The claims of the running brokers
+// are annotated with their ordinal.
+func (r *ClusterReconciler) reconcileDataClaims(
+	ctx context.Context,
+	cluster *redpandav1alpha1.Cluster,
+	sts *appsv1.StatefulSet,
+	replicas *int32,
+	status *redpandav1alpha1.ClusterStatus,
+) (*int32, error) {
+	var pvcs corev1.PersistentVolumeClaimList
+	if err := r.List(ctx, &pvcs, client.InNamespace(cluster.Namespace)); err != nil {
+		return replicas, err
+	}
+
+	var current int32
+	if sts.Spec.Replicas != nil {
+		current = *sts.Spec.Replicas
+	}
+
+	allowed := replicas
+	var mismatches []string
+
+	for i := range pvcs.Items {
+		pvc := &pvcs.Items[i]
+
+		// A deleted claim is replaced by an empty one once it is released
+		ordinal := dataClaimOrdinal(sts.Name, pvc.Name)
+		if ordinal < 0 || replicas == nil || int32(ordinal) >= *replicas || pvc.DeletionTimestamp != nil {
+			continue
+		}
+
+		if reason := claimMismatch(pvc, ordinal); reason != "" {
+			mismatches = append(mismatches, reason)
+
+			// A running broker already uses its claim
+			if int32(ordinal) >= current && int32(ordinal) < *allowed {
+				allowed = pointer.Int32Ptr(int32(ordinal))
+			}
+
+			continue
+		}
+
+		if _, ok := pvc.Annotations[claimOrdinalAnnotation]; ok || int32(ordinal) >= current {
+			continue
+		}
+
+		if pvc.Annotations == nil {
+			pvc.Annotations = map[string]string{}
+		}
+
+		pvc.Annotations[claimOrdinalAnnotation] = strconv.Itoa(ordinal)
+		if err := r.Update(ctx, pvc); err != nil {
+			return replicas, err
+		}
+	}
+
+	if len(mismatches) == 0 {
+		clearDegraded(status, reasonDataClaimsConsistent, reasonDataClaimMismatch)
+
+		return replicas, nil
+	}
+
+	message := fmt.Sprintf("The data claims do not match their brokers: %s. Delete the claims to start the "+
+		"brokers on empty volumes", strings.Join(mismatches, ", "))
+
+	if !dataClaimsMismatched(status) {
+		r.event(cluster, corev1.EventTypeWarning, reasonDataClaimMismatch, message)
+	}
+
+	setDegraded(status, reasonDataClaimMismatch, message)
+
+	return allowed, nil
+}
+
+// dataClaimsMismatched reports whether the Cluster is degraded by a data
+// claim mismatch
+func dataClaimsMismatched(status *redpandav1alpha1.ClusterStatus) bool {
+	degraded := meta.FindStatusCondition(status.Conditions, redpandav1alpha1.ClusterDegraded)
+
+	return degraded != nil && degraded.Status == metav1.ConditionTrue && degraded.Reason == reasonDataClaimMismatch
+}
+
+// markDecommissioned annotates the data claim of a decommissioned broker,
+// which stays behind when the StatefulSet is scaled down
+func (r *ClusterReconciler) markDecommissioned(
+	ctx context.Context, sts *appsv1.StatefulSet, ordinal int,
+) error {
+	var pvc corev1.PersistentVolumeClaim
+
+	err := r.Get(ctx, types.NamespacedName{Name: dataClaimName(sts, ordinal), Namespace: sts.Namespace}, &pvc)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if pvc.Annotations[claimDecommissionedAnnotation] == "true" {
+		return nil
+	}
+
+	if pvc.Annotations == nil {
+		pvc.Annotations = map[string]string{}
+	}
+
+	pvc.Annotations[claimDecommissionedAnnotation] = "true"
+
+	return r.Update(ctx, &pvc)
+}
+
+// reconcileClaimMetadata adds the labels and annotations of the data claims
+// to the existing claims of every broker, including the ones of the broker
+// groups. The claim templates of the StatefulSets can not be updated, the
+// claims created from an earlier template would otherwise never get them.
+func (r *ClusterReconciler) reconcileClaimMetadata(
+	ctx context.Context, cluster *redpandav1alpha1.Cluster,
+) error {
+	var pvcs corev1.PersistentVolumeClaimList
+	if err := r.List(ctx, &pvcs, client.InNamespace(cluster.Namespace)); err != nil {
+		return err
+	}
+
+	stsNames := []string{cluster.Name}
+	for i := range cluster.Spec.BrokerGroups {
+		stsNames = append(stsNames, brokerGroupName(cluster, &cluster.Spec.BrokerGroups[i]))
+	}
+
+	labels := claimLabels(cluster)
+
+	for i := range pvcs.Items {
+		pvc := &pvcs.Items[i]
+		if pvc.DeletionTimestamp != nil || !brokerDataClaim(stsNames, pvc.Name) {
+			continue
+		}
+
+		if !restoreManagedMetadata(&pvc.ObjectMeta, labels, cluster.Spec.Storage.ClaimAnnotations) {
+			continue
+		}
+
+		if err := r.Update(ctx, pvc); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// brokerDataClaim reports whether the claim is a data claim of one of the
+// StatefulSets
+func brokerDataClaim(stsNames []string, name string) bool {
+	for _, n := range stsNames {
+		if dataClaimOrdinal(n, name) >= 0 {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,142 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Redpanda data claims", func() {
+	Context("When a decommissioned broker left its claim behind", func() {
+		It("Should hold the scale up until the claim is deleted", func() {
+			key := createScaledCluster("redpanda-stale-claim", time.Minute)
+			createDataClaim(key, 0, nil)
+			createDataClaim(key, 1, nil)
+
+			By("Marking the claim of the decommissioned broker")
+			scaleCluster(key, 1)
+			Eventually(func() int32 {
+				return statefulSetReplicas(key)
+			}, timeout, interval).Should(Equal(int32(1)))
+			Expect(dataClaimAnnotation(key, 1, "redpanda.vectorized.io/decommissioned")).Should(Equal("true"))
+			Expect(dataClaimAnnotation(key, 0, "redpanda.vectorized.io/ordinal")).Should(Equal("0"))
+
+			By("Refusing to start a new broker on its data")
+			scaleCluster(key, 2)
+			Eventually(func() string {
+				return clusterConditionReason(key, v1alpha1.ClusterDegraded)
+			}, timeout, interval).Should(Equal("DataClaimMismatch"))
+			Consistently(func() int32 {
+				return statefulSetReplicas(key)
+			}, "1s", interval).Should(Equal(int32(1)))
+
+			By("Scaling up once the claim is deleted")
+			Expect(k8sClient.Delete(context.Background(), &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: "datadir-" + key.Name + "-1", Namespace: key.Namespace},
+			})).Should(Succeed())
+			Eventually(func() int32 {
+				return statefulSetReplicas(key)
+			}, timeout, interval).Should(Equal(int32(2)))
+			Eventually(func() metav1.ConditionStatus {
+				return clusterCondition(key, v1alpha1.ClusterDegraded)
+			}, timeout, interval).Should(Equal(metav1.ConditionFalse))
+		})
+	})
+
+	Context("When a claim holds the data of another broker", func() {
+		It("Should not bind it to a new broker", func() {
+			key := createScaledCluster("redpanda-mismatched-claim", time.Minute)
+			createDataClaim(key, 2, map[string]string{"redpanda.vectorized.io/ordinal": "0"})
+
+			scaleCluster(key, 3)
+			Eventually(func() string {
+				return clusterConditionReason(key, v1alpha1.ClusterDegraded)
+			}, timeout, interval).Should(Equal("DataClaimMismatch"))
+			Consistently(func() int32 {
+				return statefulSetReplicas(key)
+			}, "1s", interval).Should(Equal(int32(2)))
+		})
+	})
+
+	Context("When labels and annotations are added to the claims", func() {
+		It("Should update the existing claims of the brokers only", func() {
+			key := createScaledCluster("redpanda-claim-metadata", time.Minute)
+			createDataClaim(key, 0, nil)
+			createDataClaim(key, 1, nil)
+			createDataClaim(testKey(key.Name+"-other"), 0, nil)
+
+			Eventually(func() error {
+				var redpandaCluster v1alpha1.Cluster
+				if err := k8sClient.Get(context.Background(), key, &redpandaCluster); err != nil {
+					return err
+				}
+				redpandaCluster.Spec.Storage.ClaimLabels = map[string]string{"team": "streaming", "app": "other"}
+				redpandaCluster.Spec.Storage.ClaimAnnotations = map[string]string{"backup": "daily"}
+				return k8sClient.Update(context.Background(), &redpandaCluster)
+			}, timeout, interval).Should(Succeed())
+
+			for _, ordinal := range []int{0, 1} {
+				Eventually(func() map[string]string {
+					return dataClaim(key, ordinal).Labels
+				}, timeout, interval).Should(Equal(map[string]string{"app": key.Name, "team": "streaming"}))
+				Expect(dataClaimAnnotation(key, ordinal, "backup")).Should(Equal("daily"))
+			}
+
+			// The claims of other StatefulSets are left untouched
+			Consistently(func() map[string]string {
+				return dataClaim(testKey(key.Name+"-other"), 0).Labels
+			}, "1s", interval).Should(Equal(map[string]string{"app": key.Name + "-other"}))
+		})
+	})
+})
+
+// createDataClaim creates the data claim of the broker ordinal, like the
+// StatefulSet controller
+func createDataClaim(key types.NamespacedName, ordinal int, annotations map[string]string) {
+	Expect(k8sClient.Create(context.Background(), &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:		fmt.Sprintf("datadir-%s-%d", key.Name, ordinal),
+			Namespace:	key.Namespace,
+			Labels:		map[string]string{"app": key.Name},
+			Annotations:	annotations,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:	[]corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: resource.MustParse("100Gi"),
+				},
+			},
+		},
+	})).Should(Succeed())
+}
+
+func dataClaimAnnotation(key types.NamespacedName, ordinal int, annotation string) string {
+	return dataClaim(key, ordinal).Annotations[annotation]
+}
+
+func dataClaim(key types.NamespacedName, ordinal int) *corev1.PersistentVolumeClaim {
+	var pvc corev1.PersistentVolumeClaim
+	name := fmt.Sprintf("datadir-%s-%d", key.Name, ordinal)
+	if err := k8sClient.Get(context.Background(), testKey(name), &pvc); err != nil {
+		return &corev1.PersistentVolumeClaim{}
+	}
+	return &pvc
+}
@@ -0,0 +1,183 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// Keys of the Secret holding the object store credentials
+	cloudStorageAccessKeyKey	= "access_key"
+	cloudStorageSecretKeyKey	= "secret_key"
+
+	cloudStorageAccessKeyEnv	= "CLOUD_STORAGE_ACCESS_KEY"
+	cloudStorageSecretKeyEnv	= "CLOUD_STORAGE_SECRET_KEY"
+
+	reasonUploadLag	= "UploadLagAboveThreshold"
+)
+
+// cloudStorageProperties returns the cloud_storage_* keys of the shared
+// redpanda.yaml, the credentials are set by the configurator
+func cloudStorageProperties(
+	cluster *redpandav1alpha1.Cluster,
+) map[string]interface{} {
+	cloudStorage := cluster.Spec.CloudStorage
+	if !cloudStorage.Enabled {
+		return nil
+	}
+
+	props := map[string]interface{}{
+		"cloud_storage_enabled":	true,
+		"cloud_storage_bucket":		cloudStorage.Bucket,
+		"cloud_storage_region":		cloudStorage.Region,
+	}
+
+	if cloudStorage.Endpoint != "" {
+		props["cloud_storage_api_endpoint"] = cloudStorage.Endpoint
+	}
+
+	return props
+}
+
+// cloudStorageEnv returns the environment of the configurator holding the
+// object store credentials
+func cloudStorageEnv(cluster *redpandav1alpha1.Cluster) []corev1.EnvVar {
+	cloudStorage := cluster.Spec.CloudStorage
+	if !cloudStorage.Enabled || cloudStorage.CredentialsSecretRef == nil {
+		return nil
+	}
+
+	secretKey := func(key string) *corev1.EnvVarSource {
+		return &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference:	*cloudStorage.CredentialsSecretRef,
+				Key:			key,
+			},
+		}
+	}
+
+	return []corev1.EnvVar{
+		{Name: cloudStorageAccessKeyEnv, ValueFrom: secretKey(cloudStorageAccessKeyKey)},
+		{Name: cloudStorageSecretKeyEnv, ValueFrom: secretKey(cloudStorageSecretKeyKey)},
+	}
+}
+
+// cloudStorageCredentials returns the end of the configurator script
+// writing the object store credentials in the redpanda.yaml of the broker.
+// It runs once the configuration is printed, with the tracing disabled, so
+// that the credentials never show in the logs.
+func cloudStorageCredentials(cluster *redpandav1alpha1.Cluster) string {
+	if cloudStorageEnv(cluster) == nil {
+		return ""
+	}
+
+	return `;
+		set +x;
+		rpk --config $CONFIG config set redpanda.cloud_storage_access_key "$` + cloudStorageAccessKeyEnv + `";
+		rpk --config $CONFIG config set redpanda.cloud_storage_secret_key "$` + cloudStorageSecretKeyEnv + `"`
+}
+
+// updateCloudStorageStatus reports the progress of the tiered storage
+// uploads in the status, when the decommissioning is gated on it. A
+// partition is behind when its broker wrote offsets which are not
+// uploaded yet, and its lag is the time since it last uploaded a segment.
+// Querying every partition is expensive, the progress is refreshed at
+// most once per lastReconcileTimeResolution.
+func (r *ClusterReconciler) updateCloudStorageStatus(
+	ctx context.Context,
+	cluster *redpandav1alpha1.Cluster,
+	adminAPIConfig *AdminAPIConfig,
+	status *redpandav1alpha1.ClusterStatus,
+) error {
+	if !cluster.Spec.CloudStorage.Enabled || cluster.Spec.CloudStorage.MaxUploadLag == nil {
+		status.CloudStorage = nil
+
+		return nil
+	}
+
+	if status.CloudStorage != nil && !usageRefreshDue(cluster) {
+		return nil
+	}
+
+	// An unknown progress does not hold the decommissioning
+	status.CloudStorage = nil
+
+	adminAPI, err := r.adminAPIClient(cluster, adminAPIConfig)
+	if err != nil {
+		return err
+	}
+
+	partitions, err := adminAPI.ClusterPartitions(ctx)
+	if err != nil {
+		return err
+	}
+
+	progress := redpandav1alpha1.CloudStorageStatus{}
+
+	for i := range partitions {
+		p := &partitions[i]
+		if !p.KafkaTopic() {
+			continue
+		}
+
+		uploads, err := adminAPI.CloudStorageStatus(ctx, p.Topic, p.PartitionID)
+		if err != nil {
+			return err
+		}
+
+		if uploads.LocalLogLastOffset == nil ||
+			(uploads.CloudLogLastOffset != nil && *uploads.CloudLogLastOffset >= *uploads.LocalLogLastOffset) {
+			continue
+		}
+
+		progress.PartitionsBehind++
+
+		// A partition which never uploaded a segment is behind since it
+		// was created, which the brokers do not report
+		if uploads.MsSinceLastSegmentUpload == nil {
+			continue
+		}
+
+		if lag := time.Duration(*uploads.MsSinceLastSegmentUpload) * time.Millisecond; lag > progress.UploadLag.Duration {
+			progress.UploadLag = metav1.Duration{Duration: lag}
+		}
+	}
+
+	status.CloudStorage = &progress
+
+	return nil
+}
+
+// uploadsBehind returns why the brokers may not be decommissioned while
+// their data is not uploaded, or an empty string. The decommissioning is
+// only gated with spec.cloudStorage.maxUploadLag set, and an unknown
+// upload progress does not hold it.
+func uploadsBehind(
+	cluster *redpandav1alpha1.Cluster, status *redpandav1alpha1.ClusterStatus,
+) string {
+	maxLag := cluster.Spec.CloudStorage.MaxUploadLag
+	if !cluster.Spec.CloudStorage.Enabled || maxLag == nil || status.CloudStorage == nil {
+		return ""
+	}
+
+	if lag := status.CloudStorage.UploadLag.Duration; lag > maxLag.Duration {
+		return fmt.Sprintf("The tiered storage upload lag of %s is above %s, %d partitions are behind",
+			lag.Round(time.Second), maxLag.Duration, status.CloudStorage.PartitionsBehind)
+	}
+
+	return ""
+}
@@ -0,0 +1,166 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	"github.com/vectorizedio/redpanda/src/go/k8s/pkg/admin"
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+// reconcileClusterProperties applies the cluster properties which differ
+// from the ones reported by the Admin API. With the hot reload, they
+// include the reloadable properties of the redpanda.yaml, unless they are
+// cluster properties too, and the reloadable properties removed from the
+// redpanda.yaml are reset. It waits for every broker to be ready, so the
+// properties are not applied to a partially formed cluster.
+func (r *ClusterReconciler) reconcileClusterProperties(
+	ctx context.Context,
+	cluster *redpandav1alpha1.Cluster,
+	sts *appsv1.StatefulSet,
+	adminAPIConfig *AdminAPIConfig,
+	status *redpandav1alpha1.ClusterStatus,
+) error {
+	hotReload := cluster.Spec.HotReload.Enabled
+	if !hotReload {
+		status.HotReload = nil
+	}
+
+	if (len(cluster.Spec.ClusterProperties) == 0 && !hotReload) || cluster.Spec.Replicas == nil ||
+		sts.Status.ReadyReplicas == 0 || sts.Status.ReadyReplicas < *cluster.Spec.Replicas {
+		return nil
+	}
+
+	adminAPI, err := r.adminAPIClient(cluster, adminAPIConfig)
+	if err != nil {
+		return err
+	}
+
+	if err = adminAPI.Ready(ctx); err != nil {
+		return err
+	}
+
+	var hot map[string]interface{}
+
+	if hotReload {
+		if hot, err = r.reconcileHotReload(ctx, cluster, adminAPI, status); err != nil {
+			return err
+		}
+	}
+
+	current, err := adminAPI.ClusterConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	upsert := make(map[string]interface{})
+
+	for k, v := range hot {
+		if value, ok := current[k]; !ok || !sameValue(value, v) {
+			upsert[k] = v
+		}
+	}
+
+	for k, v := range cluster.Spec.ClusterProperties {
+		if value, ok := current[k]; !ok || fmt.Sprint(value) != v {
+			upsert[k] = v
+		}
+	}
+
+	var remove []string
+
+	if hotReload {
+		for _, k := range status.HotReload.Properties {
+			_, set := cluster.Spec.ClusterProperties[k]
+			if _, ok := hot[k]; !ok && !set {
+				remove = append(remove, k)
+			}
+		}
+	}
+
+	if len(upsert) > 0 || len(remove) > 0 {
+		if err = adminAPI.PatchClusterConfig(ctx, upsert, remove); err != nil {
+			return err
+		}
+	}
+
+	if hotReload {
+		status.HotReload.Properties = sortedKeys(hot)
+	}
+
+	return nil
+}
+
+// reconcileHotReload returns the reloadable properties of the redpanda.yaml
+// which are not cluster properties, and records the digest of the other
+// ones. The reloadable properties are the ones of the schema reported by
+// the brokers, so they follow the redpanda version. The brokers are only
+// rolled out once the digest changes after it is first recorded, as they
+// already run with the recorded properties.
+func (r *ClusterReconciler) reconcileHotReload(
+	ctx context.Context,
+	cluster *redpandav1alpha1.Cluster,
+	adminAPI admin.AdminAPIClient,
+	status *redpandav1alpha1.ClusterStatus,
+) (map[string]interface{}, error) {
+	data, err := r.renderedConfig(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	schema, err := adminAPI.ClusterConfigSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	hot, hash, err := splitConfig(data, reloadableProperties(schema))
+	if err != nil {
+		return nil, err
+	}
+
+	for k := range hot {
+		if _, ok := cluster.Spec.ClusterProperties[k]; ok {
+			delete(hot, k)
+		}
+	}
+
+	if status.HotReload == nil {
+		status.HotReload = &redpandav1alpha1.HotReloadStatus{}
+	}
+
+	if hr := status.HotReload; hr.ConfigHash != "" && hr.ConfigHash != hash {
+		hr.RestartHash = hash
+	}
+
+	status.HotReload.ConfigHash = hash
+
+	return hot, nil
+}
+
+// sortedKeys returns the keys of the map in increasing order, or nil when
+// it is empty
+func sortedKeys(m map[string]interface{}) []string {
+	if len(m) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,164 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Redpanda cluster properties", func() {
+	Context("When cluster properties are configured", func() {
+		It("Should apply them once the brokers are ready and revert drift", func() {
+			key := testKey("redpanda-cluster-properties")
+			adminAPI := testAdminAPIs.get(key.Name)
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.ClusterProperties = map[string]string{
+				"auto_create_topics_enabled": "false",
+			}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			By("Waiting for the brokers to be ready")
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+			Consistently(func() interface{} {
+				return adminAPI.clusterProperty("auto_create_topics_enabled")
+			}, 2*time.Second, interval).Should(BeNil())
+
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return err
+				}
+				sts.Status.Replicas = 1
+				sts.Status.ReadyReplicas = 1
+				return k8sClient.Status().Update(context.Background(), &sts)
+			}, timeout, interval).Should(Succeed())
+			Eventually(func() interface{} {
+				return adminAPI.clusterProperty("auto_create_topics_enabled")
+			}, timeout, interval).Should(Equal("false"))
+
+			By("Reverting a property changed outside of the operator")
+			adminAPI.setClusterProperty("auto_create_topics_enabled", true)
+			Eventually(func() error {
+				var c v1alpha1.Cluster
+				if err := k8sClient.Get(context.Background(), key, &c); err != nil {
+					return err
+				}
+				c.Annotations = map[string]string{"resync": time.Now().String()}
+				return k8sClient.Update(context.Background(), &c)
+			}, timeout, interval).Should(Succeed())
+			Eventually(func() interface{} {
+				return adminAPI.clusterProperty("auto_create_topics_enabled")
+			}, timeout, interval).Should(Equal("false"))
+		})
+	})
+
+	Context("When the hot reload is enabled", func() {
+		It("Should apply the hot reloadable properties without rolling the brokers out", func() {
+			key := testKey("redpanda-hot-reload")
+			adminAPI := testAdminAPIs.get(key.Name)
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.HotReload.Enabled = true
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+			setReadyReplicas(key, 1)
+
+			By("Recording the digest of the properties without rolling the brokers out")
+			Eventually(func() string {
+				hr := clusterHotReload(key)
+				if hr == nil {
+					return ""
+				}
+				return hr.ConfigHash
+			}, timeout, interval).ShouldNot(BeEmpty())
+			Expect(configHash(key)).Should(BeEmpty())
+
+			By("Changing a hot reloadable property")
+			updateCluster(key, func(c *v1alpha1.Cluster) {
+				c.Spec.Configuration.KafkaConnectionLimits.MaxConnections = 1000
+			})
+			Eventually(func() interface{} {
+				return adminAPI.clusterProperty("kafka_connections_max")
+			}, timeout, interval).Should(BeEquivalentTo(1000))
+			Expect(eventuallyRedpandaConfig(key)).Should(HaveKeyWithValue("kafka_connections_max", BeEquivalentTo(1000)))
+			Eventually(func() []string {
+				if hr := clusterHotReload(key); hr != nil {
+					return hr.Properties
+				}
+				return nil
+			}, timeout, interval).Should(ContainElement("kafka_connections_max"))
+			Expect(configHash(key)).Should(BeEmpty())
+
+			By("Resetting a hot reloadable property removed from the redpanda.yaml")
+			updateCluster(key, func(c *v1alpha1.Cluster) {
+				c.Spec.Configuration.KafkaConnectionLimits.MaxConnections = 0
+			})
+			Eventually(func() interface{} {
+				return adminAPI.clusterProperty("kafka_connections_max")
+			}, timeout, interval).Should(BeNil())
+			Expect(configHash(key)).Should(BeEmpty())
+
+			By("Changing a property which requires a restart")
+			updateCluster(key, func(c *v1alpha1.Cluster) {
+				c.Spec.Configuration.DiskAlerts.ReservationPercent = 10
+			})
+			Eventually(func() string {
+				return configHash(key)
+			}, timeout, interval).ShouldNot(BeEmpty())
+			Expect(configHash(key)).Should(Equal(clusterHotReload(key).RestartHash))
+			Expect(adminAPI.clusterProperty("disk_reservation_percent")).Should(BeNil())
+		})
+	})
+})
+
+// configHash returns the digest of the restart requiring configuration in
+// the pod template of the brokers
+func configHash(key types.NamespacedName) string {
+	var sts appsv1.StatefulSet
+	if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+		return ""
+	}
+
+	return sts.Spec.Template.Annotations["redpanda.vectorized.io/config-hash"]
+}
+
+// clusterHotReload returns the hot reload status of the Cluster
+func clusterHotReload(key types.NamespacedName) *v1alpha1.HotReloadStatus {
+	var c v1alpha1.Cluster
+	if err := k8sClient.Get(context.Background(), key, &c); err != nil {
+		return nil
+	}
+
+	return c.Status.HotReload
+}
+
+// updateCluster applies the change to the Cluster, retrying on conflicts
+func updateCluster(key types.NamespacedName, change func(*v1alpha1.Cluster)) {
+	Eventually(func() error {
+		var c v1alpha1.Cluster
+		if err := k8sClient.Get(context.Background(), key, &c); err != nil {
+			return err
+		}
+		change(&c)
+		return k8sClient.Update(context.Background(), &c)
+	}, timeout, interval).Should(Succeed())
+}
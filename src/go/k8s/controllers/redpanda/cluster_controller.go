@@ -12,18 +12,22 @@ package redpanda
 
 import (
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
 	"github.com/vectorizedio/redpanda/src/go/rpk/pkg/config"
-	"gopkg.in/yaml.v3"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	storagev1 "k8s.io/api/storage/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -31,27 +35,57 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/pointer"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 const (
 	baseSuffix	= "-base"
 	dataDirectory	= "/var/lib/redpanda/data"
 	fsGroup		= 101
+	// redpandaUser is the uid of the redpanda user in the container image
+	redpandaUser	= 101
 
 	configDir		= "/etc/redpanda"
+	configFile		= "redpanda.yaml"
+	ioPropertiesVolume	= "io-properties"
+	ioPropertiesDir		= "/mnt/io-properties"
+	ioPropertiesFile	= "io-properties.yaml"
 	configuratorDir		= "/mnt/operator"
 	configuratorScript	= "configurator.sh"
+	// peersFile lists the DNS name of every broker with the IP address of
+	// its pod, the configurator falls back to the IP addresses of the seed
+	// servers whose DNS names do not resolve yet
+	peersFile	= "peers"
+	// nodeIDsFile lists the pod name and the node id of every replaced
+	// broker, which can not reuse the node id derived from its ordinal
+	nodeIDsFile	= "node-ids"
 
 	debugLevel	= 2
+
+	// debugCommand keeps the redpanda container running without starting
+	// the broker, and stops as soon as the pod is terminated
+	debugCommand	= `trap "exit 0" TERM; while true; do sleep 5; done`
+
+	// lockFile is created by redpanda in the data directory and is not
+	// removed after an ungraceful shutdown
+	lockFile	= "pid.lock"
 )
 
 var (
-	configPath		= filepath.Join(configDir, "redpanda.yaml")
+	configPath		= filepath.Join(configDir, configFile)
 	configuratorPath	= filepath.Join(configuratorDir, configuratorScript)
+	peersPath		= filepath.Join(configuratorDir, peersFile)
+	nodeIDsPath		= filepath.Join(configuratorDir, nodeIDsFile)
+	ioPropertiesPath	= filepath.Join(ioPropertiesDir, ioPropertiesFile)
 )
 
 // ClusterReconciler reconciles a Cluster object
@@ -59,15 +93,52 @@ type ClusterReconciler struct {
 	client.Client
 	Log	logr.Logger
 	Scheme	*runtime.Scheme
+	// ResyncPeriod is the interval after which a successfully reconciled
+	// Cluster is reconciled again, even without any change to the watched
+	// resources. Zero disables the periodic resync.
+	ResyncPeriod	time.Duration
+	// AdminAPIClientFactory creates the Admin API clients, NewAdminAPIClient
+	// is used when it is not set
+	AdminAPIClientFactory	AdminAPIClientFactory
+	// MaxConcurrentReconciles is the number of Clusters reconciled in
+	// parallel, defaults to 1. A single Cluster is never reconciled
+	// concurrently, the work queue hands each Cluster to one worker at a
+	// time, so its StatefulSet is always updated by one reconciliation.
+	MaxConcurrentReconciles	int
+	// MaxBackoff caps the exponential delay before retrying the failed
+	// reconciliation of a Cluster. Zero keeps the default rate limiter of
+	// controller-runtime, without jitter.
+	MaxBackoff	time.Duration
+	// BackoffJitter shortens every retry delay by a random share of at
+	// most this value, between 0 and 1, so that the Clusters failing at
+	// the same time are not retried at the same time
+	BackoffJitter	float64
+	// QuorumLossGracePeriod is how long the brokers may be without an
+	// elected controller leader before the Cluster is reported as
+	// degraded, defaults to one minute
+	QuorumLossGracePeriod	time.Duration
+	// Recorder emits the events of the Clusters, no event is emitted when
+	// it is not set
+	Recorder	record.EventRecorder
+	// ClusterSelector restricts the reconciled Clusters to the ones whose
+	// labels match, so that the Clusters can be sharded across several
+	// operators. Every Cluster is reconciled when it is not set.
+	ClusterSelector	labels.Selector
 }
 
 //+kubebuilder:rbac:groups=redpanda.vectorized.io,resources=clusters,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=redpanda.vectorized.io,resources=clusters/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=redpanda.vectorized.io,resources=clusters/finalizers,verbs=update
-//+kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;create;update;patch;
-//+kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch;create;update;patch;
+//+kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;create;update;patch;delete;
+//+kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch;create;update;patch;delete;
 //+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update;patch;
-//+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;
+//+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;update;delete;
+//+kubebuilder:rbac:groups=core,resources=pods/status,verbs=update;
+//+kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=get;list;watch;update;delete;
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;
+//+kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;delete;
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch;
+//+kubebuilder:rbac:groups=storage.k8s.io,resources=storageclasses,verbs=get;list;watch;
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -95,45 +166,152 @@ func (r *ClusterReconciler) Reconcile(
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	var svc corev1.Service
+	// The owned resources of the Clusters of another shard are watched too
+	if !r.manages(&redpandaCluster) {
+		log.V(debugLevel).Info("Cluster is not selected by this operator")
+
+		return ctrl.Result{}, nil
+	}
+
+	if paused(&redpandaCluster) {
+		log.Info("Reconciliation is paused", "annotation", redpandav1alpha1.ManagedAnnotation)
+
+		status := redpandaCluster.Status.DeepCopy()
+		setPaused(status, true)
+
+		return r.updateStatus(ctx, &redpandaCluster, status, log)
+	}
+
+	// Fail fast when the Admin API CA or credentials can not be loaded, as
+	// the operator would not be able to reach the brokers it manages
+	adminAPIConfig, err := r.adminAPIConfig(ctx, &redpandaCluster)
+	if err != nil {
+		log.Error(err, "Unable to load the Admin API connection settings")
 
-	err := r.Get(ctx, types.NamespacedName{Name: redpandaCluster.Name, Namespace: redpandaCluster.Namespace}, &svc)
-	if err != nil && !errors.IsNotFound(err) {
-		log.V(debugLevel).Info("Unable to fetch Service resource")
 		return ctrl.Result{}, err
 	}
 
-	if errors.IsNotFound(err) {
-		log.V(debugLevel).Info("Creating headless service")
+	// All status mutations are applied to a copy and written with a single
+	// update, which limits the API calls and the chance of conflicts
+	status := redpandaCluster.Status.DeepCopy()
+	setPaused(status, false)
 
-		if err = r.createHeadlessService(ctx, &redpandaCluster, r.Scheme); err != nil {
-			log.Error(err, "Failed to create new service",
-				"Service.Namespace", redpandaCluster.Namespace,
-				"Service.Name", redpandaCluster.Name)
+	held, err := r.migrateLegacyResources(ctx, &redpandaCluster, status)
+	if err != nil {
+		log.Error(err, "Failed to adopt the legacy resources")
+
+		return ctrl.Result{}, err
+	}
+
+	if !held {
+		if held, err = r.reconcileServiceName(ctx, &redpandaCluster, status); err != nil {
+			log.Error(err, "Failed to recreate the StatefulSet with the headless service name")
 
 			return ctrl.Result{}, err
 		}
 	}
 
-	var baseConfigMap corev1.ConfigMap
+	if held {
+		log.Info("The reconciliation is held until the StatefulSet is recreated")
+		setLastReconcileTime(&redpandaCluster, status)
 
-	err = r.Get(ctx, types.NamespacedName{Name: redpandaCluster.Name + baseSuffix, Namespace: redpandaCluster.Namespace}, &baseConfigMap)
-	if err != nil && !errors.IsNotFound(err) {
-		log.V(debugLevel).Info("Unable to fetch base redpanda ConfigMap resource")
+		result, err := r.updateStatus(ctx, &redpandaCluster, status, log)
+		if err == nil && (result.RequeueAfter == 0 || result.RequeueAfter > legacyPollInterval) {
+			result.RequeueAfter = legacyPollInterval
+		}
+
+		return result, err
+	}
+
+	if err = r.reconcileService(ctx, &redpandaCluster, status); err != nil {
+		log.Error(err, "Failed to reconcile service",
+			"Service.Namespace", redpandaCluster.Namespace,
+			"Service.Name", redpandaCluster.Name)
 
 		return ctrl.Result{}, err
 	}
 
-	if errors.IsNotFound(err) {
-		log.V(debugLevel).Info("Creating base redpanda ConfigMap")
+	if err = r.reconcileAdminService(ctx, &redpandaCluster); err != nil {
+		log.Error(err, "Failed to reconcile Admin API service",
+			"Service.Namespace", redpandaCluster.Namespace,
+			"Service.Name", redpandaCluster.Name+adminSuffix)
 
-		if err = r.createBootstrapConfigMap(ctx, &redpandaCluster, r.Scheme); err != nil {
-			log.Error(err, "Failed to create new base redpanda ConfigMap",
-				"Configmap.Namespace", redpandaCluster.Namespace,
-				"Configmap.Name", redpandaCluster.Name+baseSuffix)
+		return ctrl.Result{}, err
+	}
 
-			return ctrl.Result{}, err
-		}
+	if err = r.reconcileExternalService(ctx, &redpandaCluster); err != nil {
+		log.Error(err, "Failed to reconcile external service",
+			"Service.Namespace", redpandaCluster.Namespace,
+			"Service.Name", redpandaCluster.Name+externalSuffix)
+
+		return ctrl.Result{}, err
+	}
+
+	if err = r.reconcileBrokerServices(ctx, &redpandaCluster); err != nil {
+		log.Error(err, "Failed to reconcile the broker NodePort services",
+			"Service.Namespace", redpandaCluster.Namespace)
+
+		return ctrl.Result{}, err
+	}
+
+	if err = r.reconcilePodDisruptionBudget(ctx, &redpandaCluster); err != nil {
+		log.Error(err, "Failed to reconcile the PodDisruptionBudget",
+			"PodDisruptionBudget.Namespace", redpandaCluster.Namespace,
+			"PodDisruptionBudget.Name", redpandaCluster.Name)
+
+		return ctrl.Result{}, err
+	}
+
+	// The pods of every broker, the ones of the Cluster StatefulSet are
+	// told apart from the ones of the broker groups by the group label
+	var observedPods corev1.PodList
+
+	err = r.List(ctx, &observedPods, &client.ListOptions{
+		LabelSelector:	labels.SelectorFromSet(redpandaCluster.Labels),
+		Namespace:	redpandaCluster.Namespace,
+	})
+	if err != nil {
+		log.Error(err, "Unable to fetch PodList resource")
+
+		return ctrl.Result{}, err
+	}
+
+	if err = r.reconcileConfigMap(ctx, &redpandaCluster, observedPods.Items); err != nil {
+		log.Error(err, "Failed to reconcile base redpanda ConfigMap",
+			"Configmap.Namespace", redpandaCluster.Namespace,
+			"Configmap.Name", redpandaCluster.Name+baseSuffix)
+
+		return ctrl.Result{}, err
+	}
+
+	if err = r.reconcileSelfSignedTLS(ctx, &redpandaCluster); err != nil {
+		log.Error(err, "Failed to reconcile the self-signed certificates")
+
+		return ctrl.Result{}, err
+	}
+
+	if err = r.reconcileRPCCertificate(ctx, &redpandaCluster, status); err != nil {
+		log.Error(err, "Failed to verify the RPC certificate")
+
+		return ctrl.Result{}, err
+	}
+
+	if err = r.reconcileKafkaCertificate(ctx, &redpandaCluster, status); err != nil {
+		log.Error(err, "Failed to verify the kafka API certificate")
+
+		return ctrl.Result{}, err
+	}
+
+	if err = r.reconcileStorageClass(ctx, &redpandaCluster, status); err != nil {
+		log.Error(err, "Failed to verify the StorageClass of the data volumes")
+
+		return ctrl.Result{}, err
+	}
+
+	if debugCommandOverridden(&redpandaCluster) {
+		setDegraded(status, reasonDebugCommand, "The redpanda command is overridden, the brokers are not serving")
+	} else {
+		clearDegraded(status, reasonDebugCommandDisabled, reasonDebugCommand, reasonDebugCommandDisabled)
 	}
 
 	var sts appsv1.StatefulSet
@@ -154,150 +332,611 @@ func (r *ClusterReconciler) Reconcile(
 
 			return ctrl.Result{}, err
 		}
-	}
+	} else {
+		// The brokers may not be ready while the controller has no leader,
+		// its quorum is checked as soon as they run. Failing to reach the
+		// Admin API keeps the previously observed quorum.
+		if sts.Status.Replicas > 0 {
+			if err = r.checkControllerQuorum(ctx, &redpandaCluster, adminAPIConfig, status); err != nil {
+				log.Error(err, "Unable to fetch the controller leader from the Admin API")
+			}
+		}
+
+		// Failing to fetch the upload progress leaves it unknown, which
+		// does not hold the decommissioning
+		if sts.Status.ReadyReplicas > 0 {
+			if err = r.updateCloudStorageStatus(ctx, &redpandaCluster, adminAPIConfig, status); err != nil {
+				log.Error(err, "Unable to fetch the tiered storage upload status from the Admin API")
+			}
+		}
+
+		replicas, decommissionErr := r.decommissionReplicas(ctx, &redpandaCluster, &sts, adminAPIConfig, status)
+		if decommissionErr != nil {
+			log.Error(decommissionErr, "Failed to decommission broker")
+
+			return ctrl.Result{}, decommissionErr
+		}
+
+		if replicas, err = r.reconcileDataClaims(ctx, &redpandaCluster, &sts, replicas, status); err != nil {
+			log.Error(err, "Failed to verify the data claims of the brokers")
 
-	// Ensure StatefulSet #replicas equals cluster requirement.
-	if sts.Spec.Replicas != redpandaCluster.Spec.Replicas {
-		sts.Spec.Replicas = redpandaCluster.Spec.Replicas
-		if err = r.Update(ctx, &sts); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if err = r.reconcileClaimMetadata(ctx, &redpandaCluster); err != nil {
+			log.Error(err, "Failed to update the labels and annotations of the data claims")
+
+			return ctrl.Result{}, err
+		}
+
+		replicas = throttleJoins(&redpandaCluster, &sts, replicas)
+
+		image := r.upgradeImage(&redpandaCluster, &sts, observedPods.Items, status)
+		if err = r.reconcileStatefulSet(ctx, &redpandaCluster, &sts, image, replicas); err != nil {
 			log.Error(err, "Failed to update StatefulSet", "StatefulSet.Namespace", redpandaCluster.Namespace, "StatefulSet.Name", redpandaCluster.Name)
+
 			return ctrl.Result{}, err
 		}
+
+		err = r.reconcileMaintenance(ctx, &redpandaCluster, &sts, statefulSetPods(observedPods.Items), adminAPIConfig, status)
+		if err != nil {
+			log.Error(err, "Failed to restart a broker in maintenance mode")
+
+			return ctrl.Result{}, err
+		}
+
+		err = r.reconcileReplacement(ctx, &redpandaCluster, &sts, observedPods.Items, adminAPIConfig, status)
+		if err != nil {
+			log.Error(err, "Failed to replace a failed broker")
+
+			return ctrl.Result{}, err
+		}
+
+		if err = r.reconcileBrokerGroups(ctx, &redpandaCluster, &sts, status); err != nil {
+			log.Error(err, "Failed to reconcile the StatefulSets of the broker groups")
+
+			return ctrl.Result{}, err
+		}
+
+		// Failing to reach the Admin API keeps the upgrade in progress
+		err = r.verifyUpgrade(ctx, &redpandaCluster, &sts, observedPods.Items, adminAPIConfig, status)
+		if err != nil {
+			log.Error(err, "Unable to verify the upgraded brokers through the Admin API")
+		}
 	}
 
-	var observedPods corev1.PodList
+	setRollingOut(status, rolloutInProgress(&sts))
 
-	err = r.List(ctx, &observedPods, &client.ListOptions{
-		LabelSelector:	labels.SelectorFromSet(redpandaCluster.Labels),
-		Namespace:	redpandaCluster.Namespace,
-	})
-	if err != nil {
-		log.Error(err, "Unable to fetch PodList resource")
+	if err = r.reconcileSuperuser(ctx, &redpandaCluster, &sts, adminAPIConfig, status); err != nil {
+		log.Error(err, "Failed to reconcile the bootstrap superuser")
+
+		// The rejected credentials are reported, no other Admin API call
+		// can succeed with them
+		if credentialsRejected(err) {
+			if _, statusErr := r.updateStatus(ctx, &redpandaCluster, status, log); statusErr != nil {
+				return ctrl.Result{}, statusErr
+			}
+		}
+
+		return ctrl.Result{}, err
+	}
+
+	if err = r.reconcileAdminAPIAuth(ctx, &redpandaCluster, &sts, adminAPIConfig, status); err != nil {
+		log.Error(err, "Failed to set the Admin API authentication")
+
+		return ctrl.Result{}, err
+	}
+
+	if err = r.reconcileClusterProperties(ctx, &redpandaCluster, &sts, adminAPIConfig, status); err != nil {
+		log.Error(err, "Failed to reconcile the cluster properties")
+
+		return ctrl.Result{}, err
+	}
+
+	if err = r.reconcileLicense(ctx, &redpandaCluster, &sts, adminAPIConfig, status); err != nil {
+		log.Error(err, "Failed to reconcile the enterprise license")
 
 		return ctrl.Result{}, err
 	}
 
-	observedNodes := make([]string, 0, len(observedPods.Items))
+	// The usage is informative only, failing to fetch it keeps the
+	// previously reported one
+	if sts.Status.ReadyReplicas > 0 {
+		if err = r.updateBrokerUsage(ctx, &redpandaCluster, adminAPIConfig, status); err != nil {
+			log.Error(err, "Unable to fetch the broker usage from the Admin API")
+		}
+	}
+
+	// Without the membership condition the gated brokers stay unready
+	gatePending, err := r.reconcileReadinessGates(ctx, &redpandaCluster, observedPods.Items, adminAPIConfig)
+	if err != nil {
+		log.Error(err, "Failed to set the cluster membership readiness gate")
+	}
+
+	var observedNodes []string
 	// nolint:gocritic // the copies are necessary for further redpandacluster updates
 	for _, item := range observedPods.Items {
 		observedNodes = append(observedNodes, item.Name)
 	}
 
-	if !reflect.DeepEqual(observedNodes, redpandaCluster.Status.Nodes) {
-		redpandaCluster.Status.Nodes = observedNodes
-		if err := r.Status().Update(ctx, &redpandaCluster); err != nil {
-			log.Error(err, "Failed to update RedpandaClusterStatus")
+	status.Nodes = observedNodes
+	status.Replicas = sts.Status.ReadyReplicas
+	status.Selector = scaleSelector(&redpandaCluster)
+	setLastReconcileTime(&redpandaCluster, status)
 
-			return ctrl.Result{}, err
-		}
+	result, err := r.updateStatus(ctx, &redpandaCluster, status, log)
+
+	// The drain progress is polled until the broker can be removed, the
+	// upload progress until it can be decommissioned, the controller
+	// until it elects a leader, the leadership transfer until the broker
+	// in maintenance mode can be restarted, the membership until every
+	// broker joined, the mismatched data claims until they are deleted,
+	// the replaced broker until its claim is released and the upgraded
+	// brokers until they report the new version
+	polled := status.Decommission != nil || decommissionHeld(status) || status.ControllerLeaderLostTime != nil ||
+		status.Maintenance != nil || gatePending || dataClaimsMismatched(status) || status.Replacement != nil ||
+		verifyingUpgrade(status)
+	if err == nil && polled &&
+		(result.RequeueAfter == 0 || result.RequeueAfter > decommissionPollInterval) {
+		result.RequeueAfter = decommissionPollInterval
 	}
 
-	if !reflect.DeepEqual(sts.Status.ReadyReplicas, redpandaCluster.Status.Replicas) {
-		redpandaCluster.Status.Replicas = sts.Status.ReadyReplicas
-		if err := r.Status().Update(ctx, &redpandaCluster); err != nil {
-			log.Error(err, "Failed to update RedpandaClusterStatus")
+	return result, err
+}
 
-			return ctrl.Result{}, err
+// lastReconcileTimeResolution is the minimal interval between two updates
+// of the last reconcile time. Updating it on every reconciliation would
+// trigger a new reconciliation through the Cluster watch, in a loop.
+const lastReconcileTimeResolution = time.Minute
+
+// setLastReconcileTime records the successful reconciliation, whenever the
+// status is written anyway or the recorded time is too old
+func setLastReconcileTime(
+	cluster *redpandav1alpha1.Cluster, status *redpandav1alpha1.ClusterStatus,
+) {
+	now := metav1.Now()
+	status.LastReconcileTime = cluster.Status.LastReconcileTime
+
+	if !reflect.DeepEqual(cluster.Status, *status) ||
+		now.Sub(status.LastReconcileTime.Time) >= lastReconcileTimeResolution {
+		status.LastReconcileTime = now
+	}
+}
+
+// usageRefreshDue returns whether the observed values changing
+// continuously, like the disk usage, are refreshed by this reconciliation.
+// They are refreshed along with the last reconcile time, otherwise each of
+// their changes would write the status and trigger a new reconciliation.
+func usageRefreshDue(cluster *redpandav1alpha1.Cluster) bool {
+	return metav1.Now().Sub(cluster.Status.LastReconcileTime.Time) >= lastReconcileTimeResolution
+}
+
+// updateStatus writes the desired status when it differs from the observed
+// one. Conflicts are expected when the Cluster changed during the
+// reconciliation and are resolved by requeueing with the fresh object.
+func (r *ClusterReconciler) updateStatus(
+	ctx context.Context,
+	cluster *redpandav1alpha1.Cluster,
+	status *redpandav1alpha1.ClusterStatus,
+	log logr.Logger,
+) (ctrl.Result, error) {
+	if reflect.DeepEqual(cluster.Status, *status) {
+		return ctrl.Result{RequeueAfter: r.ResyncPeriod}, nil
+	}
+
+	cluster.Status = *status
+	if err := r.Status().Update(ctx, cluster); err != nil {
+		if errors.IsConflict(err) {
+			log.V(debugLevel).Info("Conflict while updating RedpandaClusterStatus, requeueing")
+
+			return ctrl.Result{Requeue: true}, nil
 		}
+
+		log.Error(err, "Failed to update RedpandaClusterStatus")
+
+		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, nil
+	return ctrl.Result{RequeueAfter: r.ResyncPeriod}, nil
 }
 
-func (r *ClusterReconciler) createHeadlessService(
+func (r *ClusterReconciler) createService(
 	ctx context.Context,
 	clusterSpec *redpandav1alpha1.Cluster,
 	scheme *runtime.Scheme,
 ) error {
-	svc := &corev1.Service{
+	svc := buildService(clusterSpec)
+
+	err := controllerutil.SetControllerReference(clusterSpec, svc, scheme)
+	if err != nil {
+		return err
+	}
+
+	return r.Create(ctx, svc)
+}
+
+// buildService returns the headless service governing the StatefulSet.
+// It publishes the addresses of the brokers which are not ready yet, so
+// that the starting brokers can resolve each other and form the cluster.
+func buildService(clusterSpec *redpandav1alpha1.Cluster) *corev1.Service {
+	ports := []corev1.ServicePort{
+		{
+			Name:		"kafka-tcp",
+			Protocol:	corev1.ProtocolTCP,
+			Port:		int32(kafkaAPIPort(clusterSpec)),
+			TargetPort:	intstr.FromInt(kafkaAPIPort(clusterSpec)),
+		},
+	}
+
+	if clusterSpec.Spec.SchemaRegistry.Enabled {
+		ports = append(ports, corev1.ServicePort{
+			Name:		"schema-registry",
+			Protocol:	corev1.ProtocolTCP,
+			Port:		int32(schemaRegistryPort(clusterSpec)),
+			TargetPort:	intstr.FromInt(schemaRegistryPort(clusterSpec)),
+		})
+	}
+
+	if clusterSpec.Spec.PandaProxy.Enabled {
+		ports = append(ports, corev1.ServicePort{
+			Name:		"pandaproxy",
+			Protocol:	corev1.ProtocolTCP,
+			Port:		int32(pandaProxyPort(clusterSpec)),
+			TargetPort:	intstr.FromInt(pandaProxyPort(clusterSpec)),
+		})
+	}
+
+	return &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace:	clusterSpec.Namespace,
 			Name:		clusterSpec.Name,
 			Labels:		clusterSpec.Labels,
 		},
 		Spec: corev1.ServiceSpec{
-			ClusterIP:	corev1.ClusterIPNone,
-			Ports: []corev1.ServicePort{
-				{
-					Name:		"kafka-tcp",
-					Protocol:	corev1.ProtocolTCP,
-					Port:		int32(clusterSpec.Spec.Configuration.KafkaAPI.Port),
-					TargetPort:	intstr.FromInt(clusterSpec.Spec.Configuration.KafkaAPI.Port),
-				},
-			},
-			Selector:	clusterSpec.Labels,
+			ClusterIP:			corev1.ClusterIPNone,
+			PublishNotReadyAddresses:	true,
+			Ports:				ports,
+			Selector:			clusterSpec.Labels,
 		},
 	}
+}
 
-	err := controllerutil.SetControllerReference(clusterSpec, svc, scheme)
+// bootstrapConfigMap returns the base ConfigMap holding the redpanda.yaml
+// shared by all brokers, the script configuring it for each broker and the
+// peers of the brokers
+func (r *ClusterReconciler) bootstrapConfigMap(
+	ctx context.Context,
+	cluster *redpandav1alpha1.Cluster,
+	pods []corev1.Pod,
+	scheme *runtime.Scheme,
+) (*corev1.ConfigMap, error) {
+	fragment, err := r.userConfig(ctx, cluster)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return r.Create(ctx, svc)
+	cm, err := buildConfigMap(cluster, fragment)
+	if err != nil {
+		return nil, err
+	}
+
+	cm.Data[peersFile] = peerList(cluster, pods)
+
+	err = controllerutil.SetControllerReference(cluster, cm, scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	return cm, nil
 }
 
-func (r *ClusterReconciler) createBootstrapConfigMap(
-	ctx context.Context,
-	cluster *redpandav1alpha1.Cluster,
-	scheme *runtime.Scheme,
-) error {
-	serviceAddress := cluster.Name + "." + cluster.Namespace + ".svc.cluster.local"
+// buildConfigMap returns the base ConfigMap with the user configuration
+// fragment merged into the generated redpanda.yaml
+func buildConfigMap(
+	cluster *redpandav1alpha1.Cluster, fragment []byte,
+) (*corev1.ConfigMap, error) {
+	cfg := redpandaConfig(cluster)
+
+	cfgBytes, err := renderConfig(cfg, redpandaProperties(cluster), configSections(cluster), fragment)
+	if err != nil {
+		return nil, err
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:	cluster.Namespace,
+			Name:		cluster.Name + baseSuffix,
+			Labels:		cluster.Labels,
+		},
+		Data: map[string]string{
+			configFile:		string(cfgBytes),
+			configuratorScript:	configuratorScriptContent(cluster, cfg),
+			peersFile:		peerList(cluster, nil),
+			nodeIDsFile:		nodeIDList(cluster),
+		},
+	}
+
+	if io := cluster.Spec.Storage.IOProperties; io != nil && io.ConfigMapRef == nil {
+		cm.Data[ioPropertiesFile] = io.Inline
+	}
+
+	return cm, nil
+}
+
+// redpandaConfig returns the rpk configuration shared by all brokers, the
+// broker specific values are set by the configurator script
+func redpandaConfig(cluster *redpandav1alpha1.Cluster) *config.Config {
 	cfg := config.Default()
 	cfg.Redpanda = copyConfig(&cluster.Spec.Configuration, &cfg.Redpanda)
 	cfg.Redpanda.Id = 0
 	cfg.Redpanda.AdvertisedKafkaApi.Port = cfg.Redpanda.KafkaApi.Port
 	cfg.Redpanda.AdvertisedRPCAPI.Port = cfg.Redpanda.RPCServer.Port
+
+	if port := cluster.Spec.Configuration.AdvertisedKafkaAPI.Port; port != 0 {
+		cfg.Redpanda.AdvertisedKafkaApi.Port = port
+	}
+
+	if port := cluster.Spec.Configuration.AdvertisedRPCAPI.Port; port != 0 {
+		cfg.Redpanda.AdvertisedRPCAPI.Port = port
+	}
+
 	cfg.Redpanda.Directory = dataDirectory
-	cfg.Redpanda.SeedServers = []config.SeedServer{
-		{
+	cfg.Redpanda.SeedServers = seedServers(cluster, cfg.Redpanda.AdvertisedRPCAPI.Port)
+
+	if external := cluster.Spec.Configuration.ExternalSeedServers; len(external) > 0 {
+		cfg.Redpanda.SeedServers = make([]config.SeedServer, 0, len(external))
+
+		for _, seed := range external {
+			port := seed.Port
+			if port == 0 {
+				port = config.Default().Redpanda.RPCServer.Port
+			}
+
+			cfg.Redpanda.SeedServers = append(cfg.Redpanda.SeedServers, config.SeedServer{
+				Host: config.SocketAddress{Address: seed.Address, Port: port},
+			})
+		}
+	}
+
+	return cfg
+}
+
+// seedServerCount is the number of seed servers of the clusters with at
+// least that many brokers, smaller clusters are seeded by the broker with
+// ordinal 0 only
+const seedServerCount = 3
+
+// seedServers returns the brokers the others join through. Large clusters
+// are seeded by the first brokers, so that they can form the cluster when
+// one of the seeds is unavailable, while the seeds do not change when the
+// cluster is scaled further.
+func seedServers(cluster *redpandav1alpha1.Cluster, port int) []config.SeedServer {
+	count := int32(1)
+	if cluster.Spec.Replicas != nil && *cluster.Spec.Replicas >= seedServerCount {
+		count = seedServerCount
+	}
+
+	seeds := make([]config.SeedServer, 0, count)
+
+	for i := int32(0); i < count; i++ {
+		seeds = append(seeds, config.SeedServer{
 			Host: config.SocketAddress{
 				// Example address: cluster-sample-0.cluster-sample.default.svc.cluster.local
-				Address:	cluster.Name + "-0." + serviceAddress,
-				Port:		cfg.Redpanda.AdvertisedRPCAPI.Port,
+				Address:	fmt.Sprintf("%s-%d.%s", cluster.Name, i, serviceAddress(cluster)),
+				Port:		port,
 			},
-		},
+		})
 	}
 
-	cfgBytes, err := yaml.Marshal(cfg)
-	if err != nil {
-		return err
+	return seeds
+}
+
+// configuratorHashAnnotation is the pod template annotation holding the
+// digest of the configurator script. The script is only run when a broker
+// starts, so changing it, e.g. with new advertised ports, rolls the
+// brokers out. The StatefulSets created without the annotation only get
+// it once the script their brokers were started with is replaced, or along
+// with another rollout, so that upgrading the operator does not roll out
+// every Cluster.
+const configuratorHashAnnotation = "redpanda.vectorized.io/configurator-hash"
+
+// configuratorHash returns the digest of the configurator script
+func configuratorHash(cluster *redpandav1alpha1.Cluster) string {
+	return scriptHash(configuratorScriptContent(cluster, redpandaConfig(cluster)))
+}
+
+// scriptHash returns the digest of a configurator script content
+func scriptHash(script string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(script)))
+}
+
+// configuratorScriptContent returns the script configuring the redpanda.yaml
+// of each broker. The advertised ports are embedded in the script, so it
+// has to be regenerated whenever they change.
+func configuratorScriptContent(
+	cluster *redpandav1alpha1.Cluster, cfg *config.Config,
+) string {
+	// The node id of the brokers of a group is offset by its first node id
+	nodeID, setNodeID := "$ORDINAL_INDEX", ""
+	if len(cluster.Spec.BrokerGroups) > 0 {
+		nodeID = "$NODE_ID"
+		setNodeID = `
+		NODE_ID=$((${` + firstNodeIDEnv + `:-0} + ORDINAL_INDEX));`
 	}
 
-	script :=
-		`set -xe;
+	// A replaced broker takes the node id listed for its pod, the removed
+	// node id of the broker it replaces can not join the cluster again
+	if cluster.Spec.BrokerReplacement.Enabled || len(cluster.Status.NodeIDs) > 0 {
+		if setNodeID == "" {
+			nodeID = "$NODE_ID"
+			setNodeID = `
+		NODE_ID=$ORDINAL_INDEX;`
+		}
+
+		setNodeID += `
+		while read -r NODE_POD NODE_POD_ID; do
+			if [ "$NODE_POD" = "$HOSTNAME" ]; then
+				NODE_ID=$NODE_POD_ID;
+			fi;
+		done < ` + nodeIDsPath + `;`
+	}
+
+	// The brokers joining an external cluster must keep their seeds, none
+	// of them bootstraps a new cluster
+	bootstrap := `
+		if [ "` + nodeID + `" = "0" ] && [ "$CONFIGURATOR_MODE" = "` + configuratorBootstrap + `" ]; then
+			rpk --config $CONFIG config set redpanda.seed_servers '[]' --format yaml;
+		fi;`
+	if len(cluster.Spec.Configuration.ExternalSeedServers) > 0 {
+		bootstrap = ""
+	}
+
+	kafkaAddress, kafkaPort := "$SERVICE_NAME", strconv.Itoa(cfg.Redpanda.AdvertisedKafkaApi.Port)
+	if nodePorts := cluster.Spec.ExternalConnectivity.PerBrokerNodePorts; nodePorts != nil {
+		kafkaAddress = "$" + hostIPEnv
+		kafkaPort = fmt.Sprintf("$((%d + ORDINAL_INDEX))", nodePorts.BasePort)
+	}
+
+	selectAddress := perBrokerAddress(cluster)
+	if selectAddress != "" {
+		kafkaAddress = "$KAFKA_ADDRESS"
+	}
+
+	rpcAddress := "$SERVICE_NAME"
+	if cluster.Spec.Configuration.AdvertisePodIP {
+		kafkaAddress, rpcAddress = "$"+podIPEnv, "$"+podIPEnv
+	}
+
+	bind := ""
+	if cluster.Spec.Configuration.BindAddress == redpandav1alpha1.BindAddressPodIP {
+		bind = `
+		rpk --config $CONFIG config set redpanda.rpc_server.address $` + podIPEnv + `;
+		rpk --config $CONFIG config set redpanda.kafka_api.address $` + podIPEnv + `;
+		rpk --config $CONFIG config set redpanda.admin.address $` + podIPEnv + `;`
+	}
+
+	return `set -xe;
 		CONFIG=` + configPath + `;
-		ORDINAL_INDEX=${HOSTNAME##*-};
-		SERVICE_NAME=${HOSTNAME}.` + serviceAddress + `
+		ORDINAL_INDEX=${HOSTNAME##*-};` + setNodeID + `
+		SERVICE_NAME=${HOSTNAME}.` + serviceAddress(cluster) + selectAddress + `
 		cp /mnt/operator/redpanda.yaml $CONFIG;
-		rpk --config $CONFIG config set redpanda.node_id $ORDINAL_INDEX;
-		if [ "$ORDINAL_INDEX" = "0" ]; then
-			rpk --config $CONFIG config set redpanda.seed_servers '[]' --format yaml;
-		fi;
-		rpk --config $CONFIG config set redpanda.advertised_rpc_api.address $SERVICE_NAME;
+		while read -r PEER_HOST PEER_IP; do
+			if [ -n "$PEER_IP" ] && ! getent hosts $PEER_HOST > /dev/null; then
+				awk -v host="$PEER_HOST" -v ip="$PEER_IP" '$1 == "address:" && $2 == host && NF == 2 { sub(/address: .*/, "address: " ip) } { print }' $CONFIG > $CONFIG.peers;
+				mv $CONFIG.peers $CONFIG;
+			fi;
+		done < ` + peersPath + `;
+		rpk --config $CONFIG config set redpanda.node_id ` + nodeID + `;` + bootstrap + `
+		rpk --config $CONFIG config set redpanda.advertised_rpc_api.address ` + rpcAddress + `;
 		rpk --config $CONFIG config set redpanda.advertised_rpc_api.port ` + strconv.Itoa(cfg.Redpanda.AdvertisedRPCAPI.Port) + `;
-		rpk --config $CONFIG config set redpanda.advertised_kafka_api.address $SERVICE_NAME;
-		rpk --config $CONFIG config set redpanda.advertised_kafka_api.port ` + strconv.Itoa(cfg.Redpanda.AdvertisedKafkaApi.Port) + `;
-		cat $CONFIG`
+		rpk --config $CONFIG config set redpanda.advertised_kafka_api.address ` + kafkaAddress + `;
+		rpk --config $CONFIG config set redpanda.advertised_kafka_api.port ` + kafkaPort + `;` + bind + `
+		cat $CONFIG` + cloudStorageCredentials(cluster)
+}
+
+// perBrokerAddress returns the part of the configurator script setting
+// KAFKA_ADDRESS to the advertised address of the broker ordinal, or an
+// empty string without per broker addresses. A broker without an address
+// advertises its headless service name.
+func perBrokerAddress(cluster *redpandav1alpha1.Cluster) string {
+	addresses := cluster.Spec.ExternalConnectivity.PerBrokerAddresses
+	if len(addresses) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString(`
+		KAFKA_ADDRESS=$SERVICE_NAME;
+		case $ORDINAL_INDEX in`)
+
+	for i, address := range addresses {
+		fmt.Fprintf(&b, "\n\t\t\t%d) KAFKA_ADDRESS=%s;;", i, address)
+	}
+
+	b.WriteString(`
+		esac;`)
+
+	return b.String()
+}
+
+// peerList returns the headless service DNS name of every broker, one per
+// line, followed by the IP address of its pod when it has one. The pods
+// being deleted are left out, as their IP address is about to change. The
+// base ConfigMap is thus updated whenever a pod restarts with a new IP
+// address, which does not roll the brokers out: the configurator only
+// reads the peers when a broker starts.
+func peerList(cluster *redpandav1alpha1.Cluster, pods []corev1.Pod) string {
+	ips := make(map[string]string, len(pods))
+
+	for i := range pods {
+		if pods[i].DeletionTimestamp == nil {
+			ips[pods[i].Name] = pods[i].Status.PodIP
+		}
+	}
+
+	var b strings.Builder
+
+	for _, name := range brokerPodNames(cluster) {
+		b.WriteString(name + "." + serviceAddress(cluster))
+
+		if ip := ips[name]; ip != "" {
+			b.WriteString(" " + ip)
+		}
+
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// nodeIDList returns the pod name and the node id of every replaced
+// broker, one per line, for the configurator to set the node id of the
+// brokers restarted on an empty volume
+func nodeIDList(cluster *redpandav1alpha1.Cluster) string {
+	pods := make([]string, 0, len(cluster.Status.NodeIDs))
+	for pod := range cluster.Status.NodeIDs {
+		pods = append(pods, pod)
+	}
+
+	sort.Strings(pods)
+
+	var b strings.Builder
+	for _, pod := range pods {
+		fmt.Fprintf(&b, "%s %d\n", pod, cluster.Status.NodeIDs[pod])
+	}
 
-	cm := &corev1.ConfigMap{
-		ObjectMeta: metav1.ObjectMeta{
-			Namespace:	cluster.Namespace,
-			Name:		cluster.Name + baseSuffix,
-			Labels:		cluster.Labels,
-		},
-		Data: map[string]string{
-			"redpanda.yaml":	string(cfgBytes),
-			"configurator.sh":	script,
-		},
+	return b.String()
+}
+
+// serviceAddress returns the domain name of the headless service
+func serviceAddress(cluster *redpandav1alpha1.Cluster) string {
+	return cluster.Name + "." + cluster.Namespace + ".svc.cluster.local"
+}
+
+// brokerHosts returns the DNS name of every broker in the headless service
+func brokerHosts(cluster *redpandav1alpha1.Cluster) []string {
+	names := brokerPodNames(cluster)
+
+	hosts := make([]string, 0, len(names))
+	for _, name := range names {
+		hosts = append(hosts, name+"."+serviceAddress(cluster))
 	}
 
-	err = controllerutil.SetControllerReference(cluster, cm, scheme)
-	if err != nil {
-		return err
+	return hosts
+}
+
+// defaultBindAddress binds the listeners to every interface of the pod
+const defaultBindAddress = "0.0.0.0"
+
+// listenerAddress returns the address of the listeners in the base
+// redpanda.yaml, the brokers bound to their pod IP get it from the
+// configurator
+func listenerAddress(c *redpandav1alpha1.RedpandaConfig) string {
+	if c.BindAddress == "" || c.BindAddress == redpandav1alpha1.BindAddressPodIP {
+		return defaultBindAddress
 	}
 
-	return r.Create(ctx, cm)
+	return c.BindAddress
 }
 
 func copyConfig(
@@ -318,40 +957,79 @@ func copyConfig(
 		AdminAPIPort = cfgDefaults.AdminApi.Port
 	}
 
+	bindAddress := listenerAddress(c)
+
 	return config.RedpandaConfig{
 		RPCServer: config.SocketAddress{
-			Address:	"0.0.0.0",
+			Address:	bindAddress,
 			Port:		rpcServerPort,
 		},
 		AdvertisedRPCAPI:	&config.SocketAddress{},
 		KafkaApi: config.SocketAddress{
-			Address:	"0.0.0.0",
+			Address:	bindAddress,
 			Port:		kafkaAPIPort,
 		},
 		AdvertisedKafkaApi:	&config.SocketAddress{},
 		AdminApi: config.SocketAddress{
-			Address:	"0.0.0.0",
+			Address:	bindAddress,
 			Port:		AdminAPIPort,
 		},
 		DeveloperMode:	c.DeveloperMode,
 	}
 }
 
-// nolint:funlen // The definition needs further refinement
 func (r *ClusterReconciler) createBootstrapStatefulSet(
 	ctx context.Context,
 	cluster *redpandav1alpha1.Cluster,
 	scheme *runtime.Scheme,
 	configMapName string,
 ) error {
+	podAnnotations, err := r.podAnnotations(ctx, cluster)
+	if err != nil {
+		return err
+	}
+
+	mode, err := r.configuratorMode(ctx, cluster)
+	if err != nil {
+		return err
+	}
+
+	ss := buildStatefulSet(cluster, configMapName, podAnnotations, mode)
+
+	err = controllerutil.SetControllerReference(cluster, ss, scheme)
+	if err != nil {
+		return err
+	}
+
+	return r.Create(ctx, ss)
+}
+
+// buildStatefulSet returns the StatefulSet running the brokers with the
+// given pod template annotations and configurator mode, see
+// podAnnotations and configuratorMode
+// nolint:funlen // The definition needs further refinement
+func buildStatefulSet(
+	cluster *redpandav1alpha1.Cluster,
+	configMapName string,
+	podAnnotations map[string]string,
+	mode string,
+) *appsv1.StatefulSet {
 	// Default configMap mode is 0644. Adding og+x to execute configurator script.
 	var configMapDefaultMode int32 = 0754
 
-	memory, exist := cluster.Spec.Resources.Limits["memory"]
-	if !exist {
-		memory = resource.MustParse("2Gi")
+	var securityContext *corev1.SecurityContext
+
+	if cluster.Spec.Resources.LockMemory {
+		// Locking memory beyond RLIMIT_MEMLOCK requires IPC_LOCK
+		securityContext = &corev1.SecurityContext{
+			Capabilities: &corev1.Capabilities{
+				Add: []corev1.Capability{"IPC_LOCK"},
+			},
+		}
 	}
 
+	command, args := redpandaCommand(cluster)
+
 	ss := &appsv1.StatefulSet{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace:	cluster.Namespace,
@@ -361,7 +1039,7 @@ func (r *ClusterReconciler) createBootstrapStatefulSet(
 		Spec: appsv1.StatefulSetSpec{
 			Replicas:		pointer.Int32Ptr(1),
 			PodManagementPolicy:	appsv1.ParallelPodManagement,
-			Selector:		metav1.SetAsLabelSelector(cluster.Labels),
+			Selector:		statefulSetSelector(cluster),
 			UpdateStrategy: appsv1.StatefulSetUpdateStrategy{
 				Type: appsv1.RollingUpdateStatefulSetStrategyType,
 			},
@@ -370,12 +1048,11 @@ func (r *ClusterReconciler) createBootstrapStatefulSet(
 				ObjectMeta: metav1.ObjectMeta{
 					Name:		cluster.Name,
 					Namespace:	cluster.Namespace,
-					Labels:		cluster.Labels,
+					Labels:		podLabels(cluster),
+					Annotations:	podAnnotations,
 				},
 				Spec: corev1.PodSpec{
-					SecurityContext: &corev1.PodSecurityContext{
-						FSGroup: pointer.Int64Ptr(fsGroup),
-					},
+					SecurityContext:	podSecurityContext(cluster),
 					Volumes: []corev1.Volume{
 						{
 							Name:	"datadir",
@@ -392,6 +1069,7 @@ func (r *ClusterReconciler) createBootstrapStatefulSet(
 									LocalObjectReference: corev1.LocalObjectReference{
 										Name: configMapName,
 									},
+									Items:		configMapItems(cluster),
 									DefaultMode:	&configMapDefaultMode,
 								},
 							},
@@ -399,16 +1077,24 @@ func (r *ClusterReconciler) createBootstrapStatefulSet(
 						{
 							Name:	"config-dir",
 							VolumeSource: corev1.VolumeSource{
-								EmptyDir: &corev1.EmptyDirVolumeSource{},
+								EmptyDir: &corev1.EmptyDirVolumeSource{
+									Medium:		cluster.Spec.ConfigDir.Medium,
+									SizeLimit:	cluster.Spec.ConfigDir.SizeLimit,
+								},
 							},
 						},
 					},
 					InitContainers: []corev1.Container{
 						{
-							Name:		"redpanda-configurator",
-							Image:		cluster.Spec.Image + ":" + cluster.Spec.Version,
+							Name:		configuratorContainerName,
+							Image:		redpandaImage(cluster),
 							Command:	[]string{"/bin/sh", "-c"},
 							Args:		[]string{configuratorPath},
+							Env:		configuratorEnv(cluster, mode),
+							Resources:	initContainerResources(cluster),
+							// The configurator only writes to the config-dir
+							// emptyDir, so it can run in restricted namespaces.
+							SecurityContext:	restrictedSecurityContext(),
 							VolumeMounts: []corev1.VolumeMount{
 								{
 									Name:		"config-dir",
@@ -423,31 +1109,14 @@ func (r *ClusterReconciler) createBootstrapStatefulSet(
 					},
 					Containers: []corev1.Container{
 						{
-							Name:	"redpanda",
-							Image:	cluster.Spec.Image + ":" + cluster.Spec.Version,
-							Args: []string{
-								"--check=false",
-								"--smp 1",
-								"--memory " + strings.ReplaceAll(memory.String(), "Gi", "G"),
-								"start",
-								"--",
-								"--default-log-level=debug",
-								"--reserve-memory 0M",
-							},
-							Ports: []corev1.ContainerPort{
-								{
-									Name:		"admin",
-									ContainerPort:	int32(cluster.Spec.Configuration.AdminAPI.Port),
-								},
-								{
-									Name:		"kafka",
-									ContainerPort:	int32(cluster.Spec.Configuration.KafkaAPI.Port),
-								},
-								{
-									Name:		"rpc",
-									ContainerPort:	int32(cluster.Spec.Configuration.RPCServer.Port),
-								},
-							},
+							Name:			"redpanda",
+							Image:			redpandaImage(cluster),
+							Command:		command,
+							Args:			args,
+							SecurityContext:	securityContext,
+							StartupProbe:		startupProbe(cluster),
+							LivenessProbe:		livenessProbe(cluster, false),
+							Ports:			containerPorts(cluster),
 							Resources: corev1.ResourceRequirements{
 								Limits:		cluster.Spec.Resources.Limits,
 								Requests:	cluster.Spec.Resources.Requests,
@@ -456,6 +1125,7 @@ func (r *ClusterReconciler) createBootstrapStatefulSet(
 								{
 									Name:		"datadir",
 									MountPath:	dataDirectory,
+									ReadOnly:	cluster.Spec.Debug.ReadOnlyData,
 								},
 								{
 									Name:		"config-dir",
@@ -465,33 +1135,10 @@ func (r *ClusterReconciler) createBootstrapStatefulSet(
 						},
 					},
 					Affinity: &corev1.Affinity{
-						PodAntiAffinity: &corev1.PodAntiAffinity{
-							RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{
-								{
-									LabelSelector:	metav1.SetAsLabelSelector(cluster.Labels),
-									Namespaces:	[]string{cluster.Namespace},
-									TopologyKey:	corev1.LabelHostname},
-							},
-							PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{
-								{
-									Weight:	100,
-									PodAffinityTerm: corev1.PodAffinityTerm{
-										LabelSelector:	metav1.SetAsLabelSelector(cluster.Labels),
-										Namespaces:	[]string{cluster.Namespace},
-										TopologyKey:	corev1.LabelHostname,
-									},
-								},
-							},
-						},
-					},
-					TopologySpreadConstraints: []corev1.TopologySpreadConstraint{
-						{
-							MaxSkew:		1,
-							TopologyKey:		corev1.LabelZoneFailureDomainStable,
-							WhenUnsatisfiable:	corev1.ScheduleAnyway,
-							LabelSelector:		metav1.SetAsLabelSelector(cluster.Labels),
-						},
+						PodAntiAffinity: podAntiAffinity(cluster),
 					},
+					TopologySpreadConstraints:	topologySpreadConstraints(cluster),
+					ReadinessGates:			readinessGates(cluster),
 				},
 			},
 			VolumeClaimTemplates: []corev1.PersistentVolumeClaim{
@@ -499,7 +1146,8 @@ func (r *ClusterReconciler) createBootstrapStatefulSet(
 					ObjectMeta: metav1.ObjectMeta{
 						Namespace:	cluster.Namespace,
 						Name:		"datadir",
-						Labels:		cluster.Labels,
+						Labels:		claimLabels(cluster),
+						Annotations:	cluster.Spec.Storage.ClaimAnnotations,
 					},
 					Spec: corev1.PersistentVolumeClaimSpec{
 						AccessModes:	[]corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
@@ -514,18 +1162,542 @@ func (r *ClusterReconciler) createBootstrapStatefulSet(
 		},
 	}
 
-	err := controllerutil.SetControllerReference(cluster, ss, scheme)
-	if err != nil {
-		return err
+	pvc := &ss.Spec.VolumeClaimTemplates[0].Spec
+	if selector := cluster.Spec.Storage.Selector; selector != nil {
+		pvc.Selector = selector
+		// An empty storage class disables the dynamic provisioning
+		pvc.StorageClassName = pointer.StringPtr("")
 	}
 
-	return r.Create(ctx, ss)
+	// The selected volumes can be statically provisioned with a class too
+	if name := cluster.Spec.Storage.StorageClassName; name != "" {
+		pvc.StorageClassName = pointer.StringPtr(name)
+	}
+
+	if io := cluster.Spec.Storage.IOProperties; io != nil {
+		addIOProperties(&ss.Spec.Template.Spec, io, configMapName)
+	}
+
+	if name := rpcCertSecretName(cluster); name != "" {
+		addRPCTLS(&ss.Spec.Template.Spec, name)
+	}
+
+	if name := kafkaCertSecretName(cluster); name != "" {
+		addCertificateVolume(&ss.Spec.Template.Spec, kafkaTLSVolume, kafkaTLSDir, name)
+	}
+
+	if name := pandaProxyCertSecretName(cluster); name != "" {
+		addCertificateVolume(&ss.Spec.Template.Spec, pandaProxyTLSVolume, pandaProxyTLSDir, name)
+	}
+
+	// The verifier writes to the data directory
+	if cluster.Spec.Storage.VerifyDataDirectory && !cluster.Spec.Debug.ReadOnlyData {
+		ss.Spec.Template.Spec.InitContainers = append(ss.Spec.Template.Spec.InitContainers, dataDirectoryVerifier(cluster))
+	}
+
+	if len(cluster.Spec.DependsOn) > 0 {
+		ss.Spec.Template.Spec.InitContainers = append(ss.Spec.Template.Spec.InitContainers, dependenciesWaiter(cluster))
+	}
+
+	return ss
+}
+
+// Reasons of the Degraded condition set by the debug command override
+const (
+	reasonDebugCommand		= "DebugCommandOverride"
+	reasonDebugCommandDisabled	= "DebugCommandDisabled"
+)
+
+// debugCommandOverridden returns true when the redpanda container idles
+// instead of starting the broker. It is never started on a read-only data
+// directory, even when the override was not requested.
+func debugCommandOverridden(cluster *redpandav1alpha1.Cluster) bool {
+	return cluster.Spec.Debug.OverrideCommand || cluster.Spec.Debug.ReadOnlyData
+}
+
+// redpandaCommand returns the command and arguments of the redpanda
+// container. In debug mode the container idles instead of starting the
+// broker, so the mounted configuration and data can be inspected.
+func redpandaCommand(cluster *redpandav1alpha1.Cluster) (command, args []string) {
+	if debugCommandOverridden(cluster) {
+		return []string{"/bin/sh", "-c"}, []string{debugCommand}
+	}
+
+	memory, exist := cluster.Spec.Resources.Limits["memory"]
+	if !exist {
+		memory = resource.MustParse("2Gi")
+	}
+
+	args = []string{
+		"--check=false",
+		"--smp 1",
+		"--memory " + strings.ReplaceAll(memory.String(), "Gi", "G"),
+	}
+
+	if cluster.Spec.Resources.LockMemory {
+		args = append(args, "--lock-memory=true")
+	}
+
+	if cluster.Spec.Resources.CPUSet != "" {
+		args = append(args, "--cpuset="+cluster.Spec.Resources.CPUSet)
+	}
+
+	if cluster.Spec.Storage.IOProperties != nil {
+		args = append(args, "--io-properties-file="+ioPropertiesPath)
+	}
+
+	args = append(args,
+		"start",
+		"--",
+		"--default-log-level=debug",
+		fmt.Sprintf("--reserve-memory %dM", reserveMemoryBytes(memory, cluster.Spec.Resources.ReserveMemoryPercent)>>20))
+
+	// The text format is the redpanda default, so the args of the existing
+	// brokers stay the same
+	if cluster.Spec.LogFormat == redpandav1alpha1.LogFormatJSON {
+		args = append(args, "--logger-format=json")
+	}
+
+	return nil, append(args, additionalArguments(cluster)...)
+}
+
+// additionalArguments returns the user supplied redpanda arguments, sorted
+// so that the pod template stays the same between reconciliations
+func additionalArguments(cluster *redpandav1alpha1.Cluster) []string {
+	args := make([]string, 0, len(cluster.Spec.AdditionalCommandlineArguments))
+
+	for k, v := range cluster.Spec.AdditionalCommandlineArguments {
+		arg := "--" + k
+		if v != "" {
+			arg += "=" + v
+		}
+
+		args = append(args, arg)
+	}
+
+	sort.Strings(args)
+
+	return args
+}
+
+// podLabels returns the labels of the pod template, the extra labels of
+// the Cluster along with the ones matched by the StatefulSet selector
+func podLabels(cluster *redpandav1alpha1.Cluster) map[string]string {
+	merged := make(map[string]string, len(cluster.Spec.PodTemplate.Labels)+len(cluster.Labels))
+	for k, v := range cluster.Spec.PodTemplate.Labels {
+		merged[k] = v
+	}
+
+	for k, v := range cluster.Labels {
+		merged[k] = v
+	}
+
+	return merged
+}
+
+// claimLabels returns the labels of the data claims, the extra labels of
+// the Cluster along with its own
+func claimLabels(cluster *redpandav1alpha1.Cluster) map[string]string {
+	merged := make(map[string]string, len(cluster.Spec.Storage.ClaimLabels)+len(cluster.Labels))
+	for k, v := range cluster.Spec.Storage.ClaimLabels {
+		merged[k] = v
+	}
+
+	for k, v := range cluster.Labels {
+		merged[k] = v
+	}
+
+	return merged
+}
+
+// configuratorScriptMode lets the configurator, running in the redpanda
+// group, read and execute its script whatever the configured mode
+const configuratorScriptMode int32 = 0050
+
+// configMapItems returns the files of the base ConfigMap volume with their
+// configured modes, or nil when no mode is configured so that every file
+// gets the default mode. Once items are set only the listed keys are
+// mounted, so all of them are listed.
+func configMapItems(cluster *redpandav1alpha1.Cluster) []corev1.KeyToPath {
+	modes := cluster.Spec.ConfigDir.FileModes
+	if len(modes) == 0 {
+		return nil
+	}
+
+	keys := []string{configFile, configuratorScript, peersFile, nodeIDsFile}
+	if io := cluster.Spec.Storage.IOProperties; io != nil && io.ConfigMapRef == nil {
+		keys = append(keys, ioPropertiesFile)
+	}
+
+	items := make([]corev1.KeyToPath, 0, len(keys))
+
+	for _, key := range keys {
+		item := corev1.KeyToPath{Key: key, Path: key}
+
+		if mode, ok := modes[key]; ok {
+			if key == configuratorScript {
+				mode |= configuratorScriptMode
+			}
+
+			item.Mode = pointer.Int32Ptr(mode)
+		}
+
+		items = append(items, item)
+	}
+
+	return items
+}
+
+// reserveMemoryBytes returns the share of the memory limit which
+// redpanda leaves to the operating system and the sidecars
+func reserveMemoryBytes(limit resource.Quantity, percent int) int64 {
+	return limit.Value() * int64(percent) / 100
+}
+
+// addIOProperties mounts the io-properties.yaml file in the redpanda
+// container. The inline content is stored in the base ConfigMap.
+func addIOProperties(
+	spec *corev1.PodSpec, io *redpandav1alpha1.IOPropertiesSource, baseConfigMap string,
+) {
+	spec.Volumes = append(spec.Volumes, corev1.Volume{
+		Name:		ioPropertiesVolume,
+		VolumeSource:	ioPropertiesSource(ioPropertiesConfigMap(io, baseConfigMap)),
+	})
+
+	for i := range spec.Containers {
+		if spec.Containers[i].Name == redpandaContainerName {
+			spec.Containers[i].VolumeMounts = append(spec.Containers[i].VolumeMounts, ioPropertiesMount())
+		}
+	}
+}
+
+// ioPropertiesConfigMap returns the name of the ConfigMap holding the
+// io-properties.yaml file
+func ioPropertiesConfigMap(io *redpandav1alpha1.IOPropertiesSource, baseConfigMap string) string {
+	if io.ConfigMapRef != nil {
+		return io.ConfigMapRef.Name
+	}
+
+	return baseConfigMap
+}
+
+func ioPropertiesSource(configMap string) corev1.VolumeSource {
+	return corev1.VolumeSource{
+		ConfigMap: &corev1.ConfigMapVolumeSource{
+			LocalObjectReference:	corev1.LocalObjectReference{Name: configMap},
+			Items: []corev1.KeyToPath{
+				{Key: ioPropertiesFile, Path: ioPropertiesFile},
+			},
+		},
+	}
+}
+
+func ioPropertiesMount() corev1.VolumeMount {
+	return corev1.VolumeMount{Name: ioPropertiesVolume, MountPath: ioPropertiesDir, ReadOnly: true}
+}
+
+// restoreIOProperties adds, updates or removes the io-properties volume of
+// an existing StatefulSet, so that its mount follows the
+// --io-properties-file argument restored with the command. Only the
+// ConfigMap name and the items of the volume are compared, its mode is
+// defaulted by the API server. It returns true when the pod spec changed.
+func restoreIOProperties(spec *corev1.PodSpec, cluster *redpandav1alpha1.Cluster, baseConfigMap string) bool {
+	io := cluster.Spec.Storage.IOProperties
+	modified := false
+	index := -1
+
+	for i := range spec.Volumes {
+		if spec.Volumes[i].Name == ioPropertiesVolume {
+			index = i
+		}
+	}
+
+	switch {
+	case io == nil && index >= 0:
+		spec.Volumes = append(spec.Volumes[:index], spec.Volumes[index+1:]...)
+		modified = true
+	case io == nil:
+	case index < 0:
+		spec.Volumes = append(spec.Volumes, corev1.Volume{
+			Name:		ioPropertiesVolume,
+			VolumeSource:	ioPropertiesSource(ioPropertiesConfigMap(io, baseConfigMap)),
+		})
+		modified = true
+	default:
+		desired := ioPropertiesSource(ioPropertiesConfigMap(io, baseConfigMap))
+
+		cm := spec.Volumes[index].ConfigMap
+		if cm == nil || cm.Name != desired.ConfigMap.Name || !reflect.DeepEqual(cm.Items, desired.ConfigMap.Items) {
+			spec.Volumes[index].VolumeSource = desired
+			modified = true
+		}
+	}
+
+	if restoreVolumeMount(spec, ioPropertiesMount(), io != nil) {
+		modified = true
+	}
+
+	return modified
+}
+
+// podSecurityContext returns the security context of the pods, the fsGroup
+// of the redpanda user is kept unless another one is configured. The
+// volumes are only chowned recursively when their root does not match the
+// fsGroup, which would slow down every restart on large data volumes.
+func podSecurityContext(cluster *redpandav1alpha1.Cluster) *corev1.PodSecurityContext {
+	sc := &corev1.PodSecurityContext{}
+	if cluster.Spec.PodSecurityContext != nil {
+		sc = cluster.Spec.PodSecurityContext.DeepCopy()
+	}
+
+	if sc.FSGroup == nil {
+		sc.FSGroup = pointer.Int64Ptr(fsGroup)
+	}
+
+	if sc.FSGroupChangePolicy == nil {
+		policy := corev1.FSGroupChangeOnRootMismatch
+		sc.FSGroupChangePolicy = &policy
+	}
+
+	return sc
+}
+
+// topologySpreadConstraints returns the spread constraints of the pods,
+// the constraints without selector select the pods of the Cluster
+func topologySpreadConstraints(
+	cluster *redpandav1alpha1.Cluster,
+) []corev1.TopologySpreadConstraint {
+	configured := cluster.Spec.Scheduling.TopologySpreadConstraints
+	if len(configured) == 0 {
+		return []corev1.TopologySpreadConstraint{
+			{
+				MaxSkew:		1,
+				TopologyKey:		corev1.LabelZoneFailureDomainStable,
+				WhenUnsatisfiable:	corev1.ScheduleAnyway,
+				LabelSelector:		metav1.SetAsLabelSelector(cluster.Labels),
+			},
+		}
+	}
+
+	constraints := make([]corev1.TopologySpreadConstraint, 0, len(configured))
+
+	for i := range configured {
+		c := *configured[i].DeepCopy()
+		if c.LabelSelector == nil {
+			c.LabelSelector = metav1.SetAsLabelSelector(cluster.Labels)
+		}
+
+		constraints = append(constraints, c)
+	}
+
+	return constraints
+}
+
+// podAntiAffinity spreads the brokers across the topology domains. In
+// developer mode the spreading is only preferred, so that several brokers
+// can run on a single node, e.g. on kind or minikube.
+func podAntiAffinity(cluster *redpandav1alpha1.Cluster) *corev1.PodAntiAffinity {
+	term := corev1.PodAffinityTerm{
+		LabelSelector:	metav1.SetAsLabelSelector(cluster.Labels),
+		Namespaces:	[]string{cluster.Namespace},
+		TopologyKey:	antiAffinityTopologyKey(cluster),
+	}
+
+	antiAffinity := &corev1.PodAntiAffinity{
+		PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{
+			{
+				Weight:			100,
+				PodAffinityTerm:	term,
+			},
+		},
+	}
+
+	if !cluster.Spec.Configuration.DeveloperMode {
+		antiAffinity.RequiredDuringSchedulingIgnoredDuringExecution = []corev1.PodAffinityTerm{term}
+	}
+
+	return antiAffinity
+}
+
+// antiAffinityTopologyKey returns the node label spreading the brokers,
+// by default only one broker is scheduled on each node
+func antiAffinityTopologyKey(cluster *redpandav1alpha1.Cluster) string {
+	if key := cluster.Spec.Scheduling.AntiAffinityTopologyKey; key != "" {
+		return key
+	}
+
+	return corev1.LabelHostname
+}
+
+const dataDirectoryVerifierName = "redpanda-data-verifier"
+
+// Default resources of the init containers
+var (
+	defaultInitContainerCPU		= resource.MustParse("100m")
+	defaultInitContainerMemory	= resource.MustParse("128Mi")
+)
+
+// initContainerResources returns the resources of the init containers
+func initContainerResources(
+	cluster *redpandav1alpha1.Cluster,
+) corev1.ResourceRequirements {
+	if r := cluster.Spec.InitContainerResources; r != nil {
+		return *r.DeepCopy()
+	}
+
+	resources := corev1.ResourceList{
+		corev1.ResourceCPU:	defaultInitContainerCPU,
+		corev1.ResourceMemory:	defaultInitContainerMemory,
+	}
+
+	return corev1.ResourceRequirements{Requests: resources, Limits: resources.DeepCopy()}
+}
+
+// restrictedSecurityContext returns the security context of the init
+// containers, which run as the redpanda user without any privilege
+func restrictedSecurityContext() *corev1.SecurityContext {
+	return &corev1.SecurityContext{
+		RunAsUser:			pointer.Int64Ptr(redpandaUser),
+		RunAsGroup:			pointer.Int64Ptr(fsGroup),
+		RunAsNonRoot:			pointer.BoolPtr(true),
+		ReadOnlyRootFilesystem:		pointer.BoolPtr(true),
+		AllowPrivilegeEscalation:	pointer.BoolPtr(false),
+		Capabilities: &corev1.Capabilities{
+			Drop: []corev1.Capability{"ALL"},
+		},
+	}
+}
+
+// dataDirectoryVerifier returns the init container that prepares a reused
+// data volume. It removes the lock file left by a broker that was not shut
+// down gracefully and fails the pod early when the volume is not writable
+// by the redpanda group. Only the group is checked: the kubelet applies the
+// fsGroup to the volume root, whose owner is usually root. The write check
+// runs as the redpanda user, like the broker.
+func dataDirectoryVerifier(cluster *redpandav1alpha1.Cluster) corev1.Container {
+	script :=
+		`set -xe;
+		DATA_DIR=` + dataDirectory + `;
+		rm -f $DATA_DIR/` + lockFile + `;
+		if [ "$(stat -c %g $DATA_DIR)" != "` + strconv.Itoa(fsGroup) + `" ]; then
+			echo "$DATA_DIR is not owned by group ` + strconv.Itoa(fsGroup) + `";
+			exit 1;
+		fi;
+		touch $DATA_DIR/.write-check;
+		rm $DATA_DIR/.write-check`
+
+	return corev1.Container{
+		Name:		dataDirectoryVerifierName,
+		Image:		redpandaImage(cluster),
+		Command:	[]string{"/bin/sh", "-c"},
+		Args:			[]string{script},
+		Resources:		initContainerResources(cluster),
+		SecurityContext:	restrictedSecurityContext(),
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:		"datadir",
+				MountPath:	dataDirectory,
+			},
+		},
+	}
+}
+
+const (
+	configuratorContainerName	= "redpanda-configurator"
+	configuratorModeEnv		= "CONFIGURATOR_MODE"
+	hostIPEnv			= "HOST_IP"
+	podIPEnv			= "POD_IP"
+)
+
+// configuratorEnv returns the environment of the configurator init
+// container
+func configuratorEnv(
+	cluster *redpandav1alpha1.Cluster, mode string,
+) []corev1.EnvVar {
+	env := append([]corev1.EnvVar{{Name: configuratorModeEnv, Value: mode}}, cloudStorageEnv(cluster)...)
+
+	if group := brokerGroupOf(cluster); group != nil {
+		env = append(env, corev1.EnvVar{Name: firstNodeIDEnv, Value: strconv.Itoa(int(group.FirstNodeID))})
+	}
+
+	// The brokers behind a node port advertise the IP address of their node
+	if cluster.Spec.ExternalConnectivity.PerBrokerNodePorts != nil {
+		env = append(env, corev1.EnvVar{
+			Name:	hostIPEnv,
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{APIVersion: "v1", FieldPath: "status.hostIP"},
+			},
+		})
+	}
+
+	// The pod IP is used by the listeners bound to it and by the brokers
+	// advertising it
+	if c := cluster.Spec.Configuration; c.BindAddress == redpandav1alpha1.BindAddressPodIP || c.AdvertisePodIP {
+		env = append(env, corev1.EnvVar{
+			Name:	podIPEnv,
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{APIVersion: "v1", FieldPath: "status.podIP"},
+			},
+		})
+	}
+
+	return env
+}
+
+const dependenciesWaiterName = "redpanda-wait-for-dependencies"
+
+// dependenciesWaiter returns the init container waiting for every
+// dependency to accept TCP connections. The dependencies are passed as
+// name=address:port arguments rather than written in the script, so that
+// they are never interpreted by the shell. Each connection attempt times
+// out after 2 seconds, a dependency dropping the packets would otherwise
+// block until the kernel gives up.
+func dependenciesWaiter(cluster *redpandav1alpha1.Cluster) corev1.Container {
+	script :=
+		`for dependency in "$@"; do
+			name=${dependency%%=*};
+			target=${dependency#*=};
+			until timeout 2 bash -c 'exec 3<>"/dev/tcp/$1/$2"' connect "${target%:*}" "${target##*:}" 2>/dev/null; do
+				echo "Waiting for $name at $target";
+				sleep 2;
+			done;
+			echo "$name is reachable at $target";
+		done`
+
+	args := []string{script, "wait-for-dependencies"}
+	for _, d := range cluster.Spec.DependsOn {
+		args = append(args, fmt.Sprintf("%s=%s:%d", d.Name, d.Address, d.Port))
+	}
+
+	return corev1.Container{
+		Name:		dependenciesWaiterName,
+		Image:		redpandaImage(cluster),
+		Command:	[]string{"/bin/bash", "-c"},
+		Args:		args,
+		Resources:	initContainerResources(cluster),
+	}
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *ClusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	options := controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}
+	if r.MaxBackoff > 0 {
+		options.RateLimiter = newJitteredRateLimiter(r.MaxBackoff, r.BackoffJitter)
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&redpandav1alpha1.Cluster{}).
+		For(&redpandav1alpha1.Cluster{}, builder.WithPredicates(predicate.NewPredicateFuncs(r.manages))).
+		WithOptions(options).
 		Owns(&appsv1.StatefulSet{}).
+		Owns(&corev1.Service{}).
+		Owns(&corev1.ConfigMap{}).
+		Owns(&corev1.Secret{}).
+		Owns(&policyv1beta1.PodDisruptionBudget{}).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}},
+			handler.EnqueueRequestsFromMapFunc(r.referencingClusters(referencedConfigMaps))).
+		Watches(&source.Kind{Type: &corev1.Secret{}},
+			handler.EnqueueRequestsFromMapFunc(r.referencingClusters(watchedSecrets))).
+		Watches(&source.Kind{Type: &storagev1.StorageClass{}},
+			handler.EnqueueRequestsFromMapFunc(r.referencingClusters(referencedStorageClasses))).
 		Complete(r)
 }
@@ -16,12 +16,16 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	redpandacontrollers "github.com/vectorizedio/redpanda/src/go/k8s/controllers/redpanda"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 var _ = Describe("RedPandaCluster controller", func() {
@@ -71,9 +75,11 @@ var _ = Describe("RedPandaCluster controller", func() {
 					Configuration: v1alpha1.RedpandaConfig{
 						KafkaAPI: v1alpha1.SocketAddress{Port: kafkaPort},
 					},
-					Resources: corev1.ResourceRequirements{
-						Limits:		resources,
-						Requests:	resources,
+					Resources: v1alpha1.RedpandaResourceRequirements{
+						ResourceRequirements: corev1.ResourceRequirements{
+							Limits:		resources,
+							Requests:	resources,
+						},
 					},
 				},
 			}
@@ -113,10 +119,141 @@ var _ = Describe("RedPandaCluster controller", func() {
 
 			Expect(sts.Spec.Template.Spec.Containers[0].Resources.Requests).Should(Equal(resources))
 			Expect(sts.Spec.Template.Spec.Containers[0].Resources.Limits).Should(Equal(resources))
+			Expect(sts.Spec.Template.Spec.InitContainers).Should(HaveLen(1))
+		})
+	})
+
+	Context("When the status of several fields is stale", func() {
+		It("Should update the status at most once per reconcile", func() {
+			key := testKey("redpanda-status-batch")
+			redpandaCluster := testCluster(key.Name)
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			Eventually(func() error {
+				var sts appsv1.StatefulSet
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+
+			By("Making both the nodes and the replicas stale")
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), key, redpandaCluster); err != nil {
+					return err
+				}
+				redpandaCluster.Status.Nodes = []string{"stale-0"}
+				redpandaCluster.Status.Replicas = 3
+				return k8sClient.Status().Update(context.Background(), redpandaCluster)
+			}, timeout, interval).Should(Succeed())
+
+			c := &statusCountingClient{Client: k8sClient}
+			r := &redpandacontrollers.ClusterReconciler{
+				Client:	c,
+				Log:	ctrl.Log.WithName("controllers").WithName("core").WithName("RedpandaCluster"),
+				Scheme:	scheme.Scheme,
+			}
+			_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(c.updates).Should(BeNumerically("<=", 1))
+
+			Eventually(func() bool {
+				if err := k8sClient.Get(context.Background(), key, redpandaCluster); err != nil {
+					return false
+				}
+				return len(redpandaCluster.Status.Nodes) == 0 && redpandaCluster.Status.Replicas == 0
+			}, timeout, interval).Should(BeTrue())
+		})
+	})
+
+	Context("When the Cluster is reconciled successfully", func() {
+		It("Should record the last reconcile time", func() {
+			key := testKey("redpanda-last-reconcile")
+			redpandaCluster := testCluster(key.Name)
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			Eventually(func() bool {
+				if err := k8sClient.Get(context.Background(), key, redpandaCluster); err != nil {
+					return false
+				}
+				return !redpandaCluster.Status.LastReconcileTime.IsZero()
+			}, timeout, interval).Should(BeTrue())
+
+			By("Updating it once it is older than its resolution")
+			stale := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), key, redpandaCluster); err != nil {
+					return err
+				}
+				redpandaCluster.Status.LastReconcileTime = stale
+				return k8sClient.Status().Update(context.Background(), redpandaCluster)
+			}, timeout, interval).Should(Succeed())
+
+			r := &redpandacontrollers.ClusterReconciler{
+				Client:	k8sClient,
+				Log:	ctrl.Log.WithName("controllers").WithName("core").WithName("RedpandaCluster"),
+				Scheme:	scheme.Scheme,
+			}
+			Eventually(func() bool {
+				if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key}); err != nil {
+					return false
+				}
+				if err := k8sClient.Get(context.Background(), key, redpandaCluster); err != nil {
+					return false
+				}
+				return redpandaCluster.Status.LastReconcileTime.After(stale.Time)
+			}, timeout, interval).Should(BeTrue())
+		})
+	})
+
+	Context("When the periodic resync is enabled", func() {
+		It("Should requeue the Cluster after the resync period", func() {
+			key := testKey("redpanda-resync")
+			Expect(k8sClient.Create(context.Background(), testCluster(key.Name))).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+
+			r := &redpandacontrollers.ClusterReconciler{
+				Client:		k8sClient,
+				Log:		ctrl.Log.WithName("controllers").WithName("core").WithName("RedpandaCluster"),
+				Scheme:		scheme.Scheme,
+				ResyncPeriod:	time.Minute,
+			}
+			Eventually(func() (ctrl.Result, error) {
+				return r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+			}, timeout, interval).Should(Equal(ctrl.Result{RequeueAfter: time.Minute}))
+
+			r.ResyncPeriod = 0
+			Eventually(func() (ctrl.Result, error) {
+				return r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+			}, timeout, interval).Should(Equal(ctrl.Result{}))
 		})
 	})
 })
 
+// statusCountingClient records the number of status updates issued
+// through it
+type statusCountingClient struct {
+	client.Client
+	updates	int
+}
+
+func (c *statusCountingClient) Status() client.StatusWriter {
+	return &countingStatusWriter{StatusWriter: c.Client.Status(), updates: &c.updates}
+}
+
+type countingStatusWriter struct {
+	client.StatusWriter
+	updates	*int
+}
+
+func (w *countingStatusWriter) Update(
+	ctx context.Context, obj client.Object, opts ...client.UpdateOption,
+) error {
+	*w.updates++
+	return w.StatusWriter.Update(ctx, obj, opts...)
+}
+
 func validOwner(
 	cluster *v1alpha1.Cluster, owners []metav1.OwnerReference,
 ) bool {
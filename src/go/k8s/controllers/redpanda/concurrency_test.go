@@ -0,0 +1,118 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	redpandacontrollers "github.com/vectorizedio/redpanda/src/go/k8s/controllers/redpanda"
+	"github.com/vectorizedio/redpanda/src/go/k8s/pkg/admin"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Redpanda concurrent reconciliation", func() {
+	Context("When several reconciles may run concurrently", func() {
+		It("Should reconcile Clusters in parallel and each Cluster serially", func() {
+			for i := 0; i < 3; i++ {
+				Expect(k8sClient.Create(context.Background(), testCluster(fmt.Sprintf("redpanda-concurrent-%d", i)))).Should(Succeed())
+			}
+
+			mgr, err := ctrl.NewManager(testEnv.Config, ctrl.Options{
+				Scheme:			scheme.Scheme,
+				MetricsBindAddress:	"0",
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			c := &concurrencyTrackingClient{Client: mgr.GetClient(), inFlight: map[client.ObjectKey]int{}}
+			err = (&redpandacontrollers.ClusterReconciler{
+				Client:		c,
+				Log:		ctrl.Log.WithName("controllers").WithName("core").WithName("RedpandaCluster"),
+				Scheme:		mgr.GetScheme(),
+				ResyncPeriod:	100 * time.Millisecond,
+				AdminAPIClientFactory: func(cluster *v1alpha1.Cluster, cfg *redpandacontrollers.AdminAPIConfig) (admin.AdminAPIClient, error) {
+					return testAdminAPIs.connect(cluster.Name, cfg), nil
+				},
+				MaxConcurrentReconciles:	3,
+			}).SetupWithManager(mgr)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			go func() {
+				defer GinkgoRecover()
+				Expect(mgr.Start(ctx)).Should(Succeed())
+			}()
+
+			Eventually(c.maxTotal, timeout, interval).Should(BeNumerically(">", 1))
+			Expect(c.maxPerCluster()).Should(Equal(1))
+		})
+	})
+})
+
+// concurrencyTrackingClient measures how many reconciles overlap, by
+// slowing down the fetch of the Cluster starting every reconciliation
+type concurrencyTrackingClient struct {
+	client.Client
+
+	mu		sync.Mutex
+	inFlight	map[client.ObjectKey]int
+	total		int
+	peakTotal	int
+	peakCluster	int
+}
+
+func (c *concurrencyTrackingClient) Get(
+	ctx context.Context, key client.ObjectKey, obj client.Object,
+) error {
+	if _, ok := obj.(*v1alpha1.Cluster); !ok {
+		return c.Client.Get(ctx, key, obj)
+	}
+
+	c.mu.Lock()
+	c.inFlight[key]++
+	c.total++
+	if c.inFlight[key] > c.peakCluster {
+		c.peakCluster = c.inFlight[key]
+	}
+	if c.total > c.peakTotal {
+		c.peakTotal = c.total
+	}
+	c.mu.Unlock()
+
+	time.Sleep(50 * time.Millisecond)
+
+	c.mu.Lock()
+	c.inFlight[key]--
+	c.total--
+	c.mu.Unlock()
+
+	return c.Client.Get(ctx, key, obj)
+}
+
+func (c *concurrencyTrackingClient) maxTotal() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.peakTotal
+}
+
+func (c *concurrencyTrackingClient) maxPerCluster() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.peakCluster
+}
@@ -0,0 +1,56 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// setDegraded reports the Cluster as degraded for the given reason
+func setDegraded(
+	status *redpandav1alpha1.ClusterStatus, reason, message string,
+) {
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:		redpandav1alpha1.ClusterDegraded,
+		Status:		metav1.ConditionTrue,
+		Reason:		reason,
+		Message:	message,
+	})
+}
+
+// clearDegraded reports the Cluster as not degraded, unless it is degraded
+// for a reason other than the owned ones. Several checks share the
+// Degraded condition and each one only resolves its own reasons.
+func clearDegraded(
+	status *redpandav1alpha1.ClusterStatus, reason string, owned ...string,
+) {
+	current := meta.FindStatusCondition(status.Conditions, redpandav1alpha1.ClusterDegraded)
+	if current != nil && current.Status == metav1.ConditionTrue && !contains(owned, current.Reason) {
+		return
+	}
+
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:	redpandav1alpha1.ClusterDegraded,
+		Status:	metav1.ConditionFalse,
+		Reason:	reason,
+	})
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
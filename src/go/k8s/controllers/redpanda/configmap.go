@@ -0,0 +1,164 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"reflect"
+
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// userConfigKey is the key of the user ConfigMap holding the configuration
+// fragment
+const userConfigKey = "redpanda.yaml"
+
+// ioPropertiesHashAnnotation is the pod template annotation holding the
+// digest of the IO properties
+const ioPropertiesHashAnnotation = "redpanda.vectorized.io/io-properties-hash"
+
+// startedConfiguratorHashAnnotation is the base ConfigMap annotation
+// holding the digest of the configurator script found in the ConfigMap
+// when the operator first replaced it. The brokers of a StatefulSet created
+// without configuratorHashAnnotation were started with this script.
+const startedConfiguratorHashAnnotation = "redpanda.vectorized.io/started-configurator-hash"
+
+// reconcileConfigMap creates the base ConfigMap, or updates its content
+// when the Cluster, the referenced user ConfigMap or the pods of the
+// brokers changed
+func (r *ClusterReconciler) reconcileConfigMap(
+	ctx context.Context, cluster *redpandav1alpha1.Cluster, pods []corev1.Pod,
+) error {
+	desired, err := r.bootstrapConfigMap(ctx, cluster, pods, r.Scheme)
+	if err != nil {
+		return err
+	}
+
+	var cm corev1.ConfigMap
+
+	err = r.Get(ctx, types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, &cm)
+	if errors.IsNotFound(err) {
+		return r.Create(ctx, desired)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err = r.ensureOwner(ctx, cluster, &cm); err != nil {
+		return err
+	}
+
+	if reflect.DeepEqual(cm.Data, desired.Data) {
+		return nil
+	}
+
+	// The replaced script is recorded in the same update, so that a
+	// StatefulSet without the configurator digest is rolled out even when
+	// the update of the StatefulSet fails
+	script := cm.Data[configuratorScript]
+	if _, ok := cm.Annotations[startedConfiguratorHashAnnotation]; !ok && script != desired.Data[configuratorScript] {
+		if cm.Annotations == nil {
+			cm.Annotations = make(map[string]string, 1)
+		}
+
+		cm.Annotations[startedConfiguratorHashAnnotation] = scriptHash(script)
+	}
+
+	cm.Data = desired.Data
+
+	return r.Update(ctx, &cm)
+}
+
+// configuratorReplaced reports whether the configurator script of the base
+// ConfigMap, whose digest is given, differs from the one the brokers of a
+// StatefulSet created without the configurator digest were started with
+func (r *ClusterReconciler) configuratorReplaced(
+	ctx context.Context, cluster *redpandav1alpha1.Cluster, digest string,
+) (bool, error) {
+	var cm corev1.ConfigMap
+
+	err := r.Get(ctx, types.NamespacedName{Name: cluster.Name + baseSuffix, Namespace: cluster.Namespace}, &cm)
+	if err != nil {
+		return false, fmt.Errorf("unable to fetch base ConfigMap %s/%s: %w", cluster.Namespace, cluster.Name+baseSuffix, err)
+	}
+
+	started, ok := cm.Annotations[startedConfiguratorHashAnnotation]
+
+	return ok && started != digest, nil
+}
+
+// userConfig returns the configuration fragment of the ConfigMap referenced
+// by the Cluster, or nil when no ConfigMap is referenced
+func (r *ClusterReconciler) userConfig(
+	ctx context.Context, cluster *redpandav1alpha1.Cluster,
+) ([]byte, error) {
+	ref := cluster.Spec.ConfigMapRef
+	if ref == nil {
+		return nil, nil
+	}
+
+	var cm corev1.ConfigMap
+
+	err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: cluster.Namespace}, &cm)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch configuration ConfigMap %s/%s: %w", cluster.Namespace, ref.Name, err)
+	}
+
+	return []byte(cm.Data[userConfigKey]), nil
+}
+
+// ioPropertiesHash returns the digest of the io-properties.yaml file, so
+// that the brokers restart with the changed properties, or an empty string
+// when no IO properties are configured
+func (r *ClusterReconciler) ioPropertiesHash(
+	ctx context.Context, cluster *redpandav1alpha1.Cluster,
+) (string, error) {
+	io := cluster.Spec.Storage.IOProperties
+	if io == nil {
+		return "", nil
+	}
+
+	content := io.Inline
+
+	if ref := io.ConfigMapRef; ref != nil {
+		var cm corev1.ConfigMap
+
+		err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: cluster.Namespace}, &cm)
+		if err != nil {
+			return "", fmt.Errorf("unable to fetch IO properties ConfigMap %s/%s: %w", cluster.Namespace, ref.Name, err)
+		}
+
+		content = cm.Data[ioPropertiesFile]
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(content))), nil
+}
+
+// referencedConfigMaps returns the names of the user ConfigMaps a Cluster
+// depends on
+func referencedConfigMaps(cluster *redpandav1alpha1.Cluster) []string {
+	var names []string
+
+	if cluster.Spec.ConfigMapRef != nil {
+		names = append(names, cluster.Spec.ConfigMapRef.Name)
+	}
+
+	if io := cluster.Spec.Storage.IOProperties; io != nil && io.ConfigMapRef != nil {
+		names = append(names, io.ConfigMapRef.Name)
+	}
+
+	return names
+}
@@ -0,0 +1,263 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	"github.com/vectorizedio/redpanda/src/go/rpk/pkg/config"
+	"gopkg.in/yaml.v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const redpandaSection = "redpanda"
+
+var errMissingRedpandaSection = errors.New("rendered configuration has no " + redpandaSection + " section")
+
+// redpandaProperties returns the redpanda node configuration keys that are
+// not part of the rpk configuration schema. Unset values are omitted so
+// redpanda applies its own defaults.
+func redpandaProperties(
+	cluster *redpandav1alpha1.Cluster,
+) map[string]interface{} {
+	props := make(map[string]interface{})
+
+	// A Cluster rendered without being created has no UID yet
+	if id := clusterID(cluster); id != "" {
+		props["cluster_id"] = id
+	}
+
+	limits := cluster.Spec.Configuration.KafkaConnectionLimits
+	setIfNotZero(props, "kafka_connections_max", limits.MaxConnections)
+	setIfNotZero(props, "kafka_connections_max_per_ip", limits.MaxConnectionsPerIP)
+	setIfNotZero(props, "kafka_connection_rate_limit", limits.ConnectionRateLimit)
+	setIfNotZero(props, "kafka_request_max_bytes", limits.MaxRequestBytes)
+
+	if timeout := limits.IdleTimeout; timeout != nil {
+		props["kafka_connection_idle_timeout_ms"] = timeout.Milliseconds()
+	}
+
+	disk := cluster.Spec.Configuration.DiskAlerts
+	setIfNotZero(props, "disk_reservation_percent", disk.ReservationPercent)
+	setIfNotZero(props, "storage_space_alert_free_threshold_percent", disk.FreeThresholdPercent)
+
+	retention := cluster.Spec.Configuration.Retention
+	setIfNotNil(props, "log_retention_ms", retention.RetentionMs)
+	setIfNotNil(props, "retention_bytes", retention.RetentionBytes)
+	setIfNotNil(props, "log_segment_size", retention.SegmentSize)
+
+	raft := cluster.Spec.Configuration.Raft
+	setMillisecondsIfNotNil(props, "raft_heartbeat_interval_ms", raft.HeartbeatInterval)
+	setMillisecondsIfNotNil(props, "raft_heartbeat_timeout_ms", raft.HeartbeatTimeout)
+	setMillisecondsIfNotNil(props, "election_timeout_ms", raft.ElectionTimeout)
+
+	if rpcTLS := rpcServerTLS(cluster); rpcTLS != nil {
+		props["rpc_server_tls"] = rpcTLS
+	}
+
+	if kafkaTLS := kafkaAPITLS(cluster); kafkaTLS != nil {
+		props["kafka_api_tls"] = kafkaTLS
+	}
+
+	if cluster.Spec.SASL.Enabled {
+		props["enable_sasl"] = true
+		props["superusers"] = []string{superuserName(cluster)}
+		props["sasl_mechanisms"] = saslMechanisms(cluster)
+	}
+
+	for k, v := range cloudStorageProperties(cluster) {
+		props[k] = v
+	}
+
+	return props
+}
+
+// saslMechanisms returns the SASL mechanisms offered to the kafka clients,
+// SCRAM-SHA-256 unless others are configured
+func saslMechanisms(cluster *redpandav1alpha1.Cluster) []string {
+	if len(cluster.Spec.SASL.Mechanisms) == 0 {
+		return []string{string(redpandav1alpha1.SASLMechanismScramSha256)}
+	}
+
+	mechanisms := make([]string, 0, len(cluster.Spec.SASL.Mechanisms))
+	for _, m := range cluster.Spec.SASL.Mechanisms {
+		mechanisms = append(mechanisms, string(m))
+	}
+
+	return mechanisms
+}
+
+// configSections returns the top level sections of the redpanda.yaml which
+// are not part of the rpk configuration schema
+func configSections(
+	cluster *redpandav1alpha1.Cluster,
+) map[string]interface{} {
+	sections := make(map[string]interface{})
+
+	if cluster.Spec.SchemaRegistry.Enabled {
+		sections["schema_registry"] = map[string]interface{}{
+			"schema_registry_api": []map[string]interface{}{{
+				"name":		"internal",
+				"address":	listenerAddress(&cluster.Spec.Configuration),
+				"port":		schemaRegistryPort(cluster),
+			}},
+		}
+	}
+
+	if cluster.Spec.PandaProxy.Enabled {
+		sections["pandaproxy"] = pandaProxySection(cluster)
+	}
+
+	return sections
+}
+
+// clusterID returns the identifier of the cluster, defaulted to the UID of
+// the Cluster
+func clusterID(cluster *redpandav1alpha1.Cluster) string {
+	if cluster.Spec.ClusterID != "" {
+		return cluster.Spec.ClusterID
+	}
+
+	return string(cluster.UID)
+}
+
+func setIfNotZero(props map[string]interface{}, key string, value int) {
+	if value != 0 {
+		props[key] = value
+	}
+}
+
+// setIfNotNil sets the properties whose -1 or zero value is meaningful
+func setIfNotNil(props map[string]interface{}, key string, value *int64) {
+	if value != nil {
+		props[key] = *value
+	}
+}
+
+// setMillisecondsIfNotNil sets the duration properties, in milliseconds
+func setMillisecondsIfNotNil(props map[string]interface{}, key string, value *metav1.Duration) {
+	if value != nil {
+		props[key] = value.Milliseconds()
+	}
+}
+
+// renderConfig marshals the rpk configuration, appends the additional
+// properties to its redpanda section and the additional sections to its
+// root. The keys are sorted to keep the rendered file stable between
+// reconciliations. The user fragment is then merged in, without overriding
+// any key set by the operator.
+func renderConfig(
+	cfg *config.Config,
+	properties map[string]interface{},
+	sections map[string]interface{},
+	fragment []byte,
+) ([]byte, error) {
+	var root yaml.Node
+	if err := root.Encode(cfg); err != nil {
+		return nil, err
+	}
+
+	section := mappingValue(&root, redpandaSection)
+	if section == nil {
+		return nil, errMissingRedpandaSection
+	}
+
+	if err := setMappingValues(section, properties); err != nil {
+		return nil, err
+	}
+
+	if err := setMappingValues(&root, sections); err != nil {
+		return nil, err
+	}
+
+	if len(fragment) > 0 {
+		var user yaml.Node
+		if err := yaml.Unmarshal(fragment, &user); err != nil {
+			return nil, fmt.Errorf("invalid configuration fragment: %w", err)
+		}
+
+		if len(user.Content) > 0 {
+			mergeMissing(&root, user.Content[0])
+		}
+	}
+
+	return yaml.Marshal(&root)
+}
+
+// setMappingValues sets the values in the yaml mapping node, in the order
+// of their keys
+func setMappingValues(node *yaml.Node, values map[string]interface{}) error {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if existing := mappingValue(node, k); existing != nil {
+			if err := existing.Encode(values[k]); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		var value yaml.Node
+		if err := value.Encode(values[k]); err != nil {
+			return err
+		}
+
+		node.Content = append(node.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: k},
+			&value)
+	}
+
+	return nil
+}
+
+// mergeMissing deep merges the src mapping into the dst mapping. Keys
+// already present in dst are kept unless both values are mappings, which
+// are then merged recursively.
+func mergeMissing(dst, src *yaml.Node) {
+	if dst.Kind != yaml.MappingNode || src.Kind != yaml.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		existing := mappingValue(dst, src.Content[i].Value)
+		if existing == nil {
+			dst.Content = append(dst.Content, src.Content[i], src.Content[i+1])
+
+			continue
+		}
+
+		mergeMissing(existing, src.Content[i+1])
+	}
+}
+
+// mappingValue returns the value node stored under key in a yaml mapping
+// node, or nil when the key is not present
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
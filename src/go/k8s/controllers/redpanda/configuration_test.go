@@ -0,0 +1,441 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	"gopkg.in/yaml.v3"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+)
+
+const configuratorHashAnnotation = "redpanda.vectorized.io/configurator-hash"
+
+var _ = Describe("Redpanda configuration", func() {
+	Context("When kafka connection limits are configured", func() {
+		It("Should render only the configured limits", func() {
+			key := testKey("redpanda-connection-limits")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.Configuration.KafkaConnectionLimits = v1alpha1.KafkaConnectionLimits{
+				MaxConnections:		1000,
+				ConnectionRateLimit:	50,
+			}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			cfg := eventuallyRedpandaConfig(key)
+			Expect(cfg).Should(HaveKeyWithValue("kafka_connections_max", 1000))
+			Expect(cfg).Should(HaveKeyWithValue("kafka_connection_rate_limit", 50))
+			Expect(cfg).ShouldNot(HaveKey("kafka_connections_max_per_ip"))
+			Expect(cfg).ShouldNot(HaveKey("kafka_request_max_bytes"))
+			Expect(cfg).ShouldNot(HaveKey("kafka_connection_idle_timeout_ms"))
+		})
+
+		It("Should render the request size limit and the idle timeout", func() {
+			key := testKey("redpanda-request-limits")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.Configuration.KafkaConnectionLimits = v1alpha1.KafkaConnectionLimits{
+				MaxRequestBytes:	16 << 20,
+				IdleTimeout:		&metav1.Duration{Duration: 10 * time.Minute},
+			}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			cfg := eventuallyRedpandaConfig(key)
+			Expect(cfg).Should(HaveKeyWithValue("kafka_request_max_bytes", 16<<20))
+			Expect(cfg).Should(HaveKeyWithValue("kafka_connection_idle_timeout_ms", 600000))
+		})
+	})
+
+	Context("When disk alerts are configured", func() {
+		It("Should render the disk usage thresholds", func() {
+			key := testKey("redpanda-disk-alerts")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.Configuration.DiskAlerts = v1alpha1.DiskAlerts{
+				ReservationPercent:	10,
+				FreeThresholdPercent:	15,
+			}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			cfg := eventuallyRedpandaConfig(key)
+			Expect(cfg).Should(HaveKeyWithValue("disk_reservation_percent", 10))
+			Expect(cfg).Should(HaveKeyWithValue("storage_space_alert_free_threshold_percent", 15))
+		})
+
+		It("Should leave the redpanda defaults by default", func() {
+			key := testKey("redpanda-no-disk-alerts")
+			Expect(k8sClient.Create(context.Background(), testCluster(key.Name))).Should(Succeed())
+
+			cfg := eventuallyRedpandaConfig(key)
+			Expect(cfg).ShouldNot(HaveKey("disk_reservation_percent"))
+			Expect(cfg).ShouldNot(HaveKey("storage_space_alert_free_threshold_percent"))
+		})
+	})
+
+	Context("When the retention is configured", func() {
+		It("Should render only the configured retention", func() {
+			key := testKey("redpanda-retention")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.Configuration.Retention = v1alpha1.TopicRetention{
+				RetentionMs:	pointer.Int64Ptr(-1),
+				SegmentSize:	pointer.Int64Ptr(64 << 20),
+			}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			cfg := eventuallyRedpandaConfig(key)
+			Expect(cfg).Should(HaveKeyWithValue("log_retention_ms", -1))
+			Expect(cfg).Should(HaveKeyWithValue("log_segment_size", 64<<20))
+			Expect(cfg).ShouldNot(HaveKey("retention_bytes"))
+		})
+	})
+
+	Context("When the raft timeouts are configured", func() {
+		It("Should render only the configured timeouts in milliseconds", func() {
+			key := testKey("redpanda-raft")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.Configuration.Raft = v1alpha1.RaftConfig{
+				HeartbeatInterval:	&metav1.Duration{Duration: 500 * time.Millisecond},
+				ElectionTimeout:	&metav1.Duration{Duration: 5 * time.Second},
+			}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			cfg := eventuallyRedpandaConfig(key)
+			Expect(cfg).Should(HaveKeyWithValue("raft_heartbeat_interval_ms", 500))
+			Expect(cfg).Should(HaveKeyWithValue("election_timeout_ms", 5000))
+			Expect(cfg).ShouldNot(HaveKey("raft_heartbeat_timeout_ms"))
+		})
+	})
+
+	Context("When SASL is enabled", func() {
+		It("Should offer SCRAM-SHA-256 by default", func() {
+			key := testKey("redpanda-sasl-default")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.SASL.Enabled = true
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			cfg := eventuallyRedpandaConfig(key)
+			Expect(cfg).Should(HaveKeyWithValue("enable_sasl", true))
+			Expect(cfg).Should(HaveKeyWithValue("sasl_mechanisms", ConsistOf("SCRAM-SHA-256")))
+		})
+
+		It("Should render the configured mechanisms", func() {
+			key := testKey("redpanda-sasl-mechanisms")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.SASL.Enabled = true
+			redpandaCluster.Spec.SASL.Mechanisms = []v1alpha1.SASLMechanism{
+				v1alpha1.SASLMechanismScramSha512, v1alpha1.SASLMechanismScramSha256,
+			}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			cfg := eventuallyRedpandaConfig(key)
+			Expect(cfg["sasl_mechanisms"]).Should(Equal([]interface{}{"SCRAM-SHA-512", "SCRAM-SHA-256"}))
+		})
+	})
+
+	Context("When rendering the cluster_id", func() {
+		It("Should default to the Cluster UID and stay stable across reconciles", func() {
+			key := testKey("redpanda-default-cluster-id")
+			redpandaCluster := testCluster(key.Name)
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			Expect(eventuallyRedpandaConfig(key)).Should(HaveKeyWithValue("cluster_id", string(redpandaCluster.UID)))
+
+			By("Reconciling the Cluster again")
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), key, redpandaCluster); err != nil {
+					return err
+				}
+				redpandaCluster.Spec.Configuration.DiskAlerts.ReservationPercent = 10
+				return k8sClient.Update(context.Background(), redpandaCluster)
+			}, timeout, interval).Should(Succeed())
+			Eventually(func() map[string]interface{} {
+				return eventuallyRedpandaConfig(key)
+			}, timeout, interval).Should(HaveKeyWithValue("disk_reservation_percent", 10))
+			Expect(eventuallyRedpandaConfig(key)).Should(HaveKeyWithValue("cluster_id", string(redpandaCluster.UID)))
+		})
+
+		It("Should render the configured cluster_id", func() {
+			key := testKey("redpanda-cluster-id")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.ClusterID = "production-eu"
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			Expect(eventuallyRedpandaConfig(key)).Should(HaveKeyWithValue("cluster_id", "production-eu"))
+		})
+	})
+
+	Context("When the advertised ports change", func() {
+		It("Should regenerate the configurator script and roll the brokers out", func() {
+			key := testKey("redpanda-advertised-ports")
+			redpandaCluster := testCluster(key.Name)
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+			firstHash := sts.Spec.Template.Annotations[configuratorHashAnnotation]
+			Expect(firstHash).ShouldNot(BeEmpty())
+
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), key, redpandaCluster); err != nil {
+					return err
+				}
+				redpandaCluster.Spec.Configuration.AdvertisedKafkaAPI.Port = 30092
+				return k8sClient.Update(context.Background(), redpandaCluster)
+			}, timeout, interval).Should(Succeed())
+
+			Eventually(func() string {
+				return configuratorScript(key)
+			}, timeout, interval).Should(ContainSubstring("redpanda.advertised_kafka_api.port 30092;"))
+			Eventually(func() string {
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return firstHash
+				}
+				return sts.Spec.Template.Annotations[configuratorHashAnnotation]
+			}, timeout, interval).ShouldNot(Equal(firstHash))
+		})
+
+		It("Should only roll out a StatefulSet created without the digest when the script changes", func() {
+			key := testKey("redpanda-configurator-upgrade")
+			Expect(k8sClient.Create(context.Background(), testCluster(key.Name))).Should(Succeed())
+
+			By("Removing the digest like a StatefulSet created by an older operator")
+			hasDigest := func() bool {
+				var sts appsv1.StatefulSet
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return false
+				}
+				_, ok := sts.Spec.Template.Annotations[configuratorHashAnnotation]
+				return ok
+			}
+			Eventually(func() error {
+				var sts appsv1.StatefulSet
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return err
+				}
+				delete(sts.Spec.Template.Annotations, configuratorHashAnnotation)
+				return k8sClient.Update(context.Background(), &sts)
+			}, timeout, interval).Should(Succeed())
+			Consistently(hasDigest, 2*time.Second, interval).Should(BeFalse())
+
+			By("Rolling the brokers out once the advertised ports change the script")
+			updateCluster(key, func(c *v1alpha1.Cluster) {
+				c.Spec.Configuration.AdvertisedKafkaAPI.Port = 30092
+			})
+			Eventually(func() string {
+				return configuratorScript(key)
+			}, timeout, interval).Should(ContainSubstring("redpanda.advertised_kafka_api.port 30092;"))
+			Eventually(hasDigest, timeout, interval).Should(BeTrue())
+		})
+	})
+
+	Context("When external seed servers are configured", func() {
+		It("Should join the external cluster from every broker", func() {
+			key := testKey("redpanda-external-seeds")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.Configuration.ExternalSeedServers = []v1alpha1.ServerAddress{
+				{Address: "seed-0.example.com", Port: 33146},
+				{Address: "seed-1.example.com"},
+			}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			cfg := eventuallyRedpandaConfig(key)
+			Expect(cfg["seed_servers"]).Should(ConsistOf(
+				HaveKeyWithValue("host", And(
+					HaveKeyWithValue("address", "seed-0.example.com"),
+					HaveKeyWithValue("port", 33146))),
+				HaveKeyWithValue("host", And(
+					HaveKeyWithValue("address", "seed-1.example.com"),
+					HaveKeyWithValue("port", 33145))),
+			))
+			Expect(configuratorScript(key)).ShouldNot(ContainSubstring("seed_servers"))
+		})
+
+		It("Should clear the seeds of the first broker by default", func() {
+			key := testKey("redpanda-internal-seeds")
+			Expect(k8sClient.Create(context.Background(), testCluster(key.Name))).Should(Succeed())
+
+			cfg := eventuallyRedpandaConfig(key)
+			Expect(cfg["seed_servers"]).Should(ConsistOf(
+				HaveKeyWithValue("host", HaveKeyWithValue("address", key.Name+"-0."+key.Name+".default.svc.cluster.local")),
+			))
+			Expect(configuratorScript(key)).Should(ContainSubstring("redpanda.seed_servers '[]'"))
+		})
+	})
+
+	Context("When the tiered storage is enabled", func() {
+		It("Should render the cloud storage keys and inject the credentials", func() {
+			key := testKey("redpanda-cloud-storage")
+			credentials := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:		key.Name + "-s3",
+					Namespace:	key.Namespace,
+				},
+				StringData:	map[string]string{"access_key": "access", "secret_key": "secret"},
+			}
+			Expect(k8sClient.Create(context.Background(), credentials)).Should(Succeed())
+
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.CloudStorage = v1alpha1.CloudStorageConfig{
+				Enabled:		true,
+				Bucket:			"segments",
+				Region:			"eu-west-1",
+				Endpoint:		"minio.storage.svc.cluster.local",
+				CredentialsSecretRef:	&corev1.LocalObjectReference{Name: credentials.Name},
+			}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			cfg := eventuallyRedpandaConfig(key)
+			Expect(cfg).Should(HaveKeyWithValue("cloud_storage_enabled", true))
+			Expect(cfg).Should(HaveKeyWithValue("cloud_storage_bucket", "segments"))
+			Expect(cfg).Should(HaveKeyWithValue("cloud_storage_region", "eu-west-1"))
+			Expect(cfg).Should(HaveKeyWithValue("cloud_storage_api_endpoint", "minio.storage.svc.cluster.local"))
+			Expect(cfg).ShouldNot(HaveKey("cloud_storage_secret_key"))
+			Expect(configuratorScript(key)).Should(ContainSubstring(`"$CLOUD_STORAGE_SECRET_KEY"`))
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+			Expect(sts.Spec.Template.Spec.InitContainers[0].Env).Should(ContainElement(corev1.EnvVar{
+				Name:	"CLOUD_STORAGE_SECRET_KEY",
+				ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference:	corev1.LocalObjectReference{Name: credentials.Name},
+					Key:			"secret_key",
+				}},
+			}))
+			Expect(sts.Spec.Template.Annotations).Should(HaveKey("redpanda.vectorized.io/secrets-hash"))
+		})
+	})
+
+	Context("When the cluster is scaled", func() {
+		It("Should list every broker and its pod IP in the peers file", func() {
+			key := testKey("redpanda-peers")
+			Expect(k8sClient.Create(context.Background(), testCluster(key.Name))).Should(Succeed())
+
+			address := func(ordinal string) string {
+				return key.Name + "-" + ordinal + "." + key.Name + ".default.svc.cluster.local"
+			}
+			Eventually(func() string {
+				return baseConfigMapEntry(key, "peers")
+			}, timeout, interval).Should(Equal(address("0") + "\n"))
+
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:		key.Name + "-0",
+					Namespace:	key.Namespace,
+					Labels:		map[string]string{"app": key.Name},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "redpanda", Image: "vectorized/redpanda"}},
+				},
+			}
+			Expect(k8sClient.Create(context.Background(), pod)).Should(Succeed())
+			pod.Status.PodIP = "10.0.0.10"
+			Expect(k8sClient.Status().Update(context.Background(), pod)).Should(Succeed())
+
+			updateCluster(key, func(c *v1alpha1.Cluster) {
+				c.Spec.Replicas = pointer.Int32Ptr(3)
+			})
+			Eventually(func() string {
+				return baseConfigMapEntry(key, "peers")
+			}, timeout, interval).Should(Equal(
+				address("0") + " 10.0.0.10\n" + address("1") + "\n" + address("2") + "\n"))
+		})
+	})
+
+	Context("When a user ConfigMap is referenced", func() {
+		It("Should merge the fragment without overriding managed keys", func() {
+			key := testKey("redpanda-user-config")
+			userConfig := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:		key.Name + "-user",
+					Namespace:	key.Namespace,
+				},
+				Data: map[string]string{
+					"redpanda.yaml": "redpanda:\n" +
+						"  data_directory: /tmp/ignored\n" +
+						"  kafka_api:\n" +
+						"    port: 1234\n" +
+						"    extra: kept\n" +
+						"  custom_key: first\n",
+				},
+			}
+			Expect(k8sClient.Create(context.Background(), userConfig)).Should(Succeed())
+
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.ConfigMapRef = &corev1.LocalObjectReference{Name: userConfig.Name}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			cfg := eventuallyRedpandaConfig(key)
+			Expect(cfg).Should(HaveKeyWithValue("custom_key", "first"))
+			Expect(cfg).Should(HaveKeyWithValue("data_directory", "/var/lib/redpanda/data"))
+			Expect(cfg).Should(HaveKeyWithValue("kafka_api", And(
+				HaveKeyWithValue("port", kafkaPort),
+				HaveKeyWithValue("extra", "kept"),
+			)))
+
+			By("Re-rendering the configuration when the user ConfigMap changes")
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), types.NamespacedName{Name: userConfig.Name, Namespace: key.Namespace}, userConfig); err != nil {
+					return err
+				}
+				userConfig.Data["redpanda.yaml"] = "redpanda:\n  custom_key: second\n"
+				return k8sClient.Update(context.Background(), userConfig)
+			}, timeout, interval).Should(Succeed())
+			Eventually(func() interface{} {
+				return eventuallyRedpandaConfig(key)["custom_key"]
+			}, timeout, interval).Should(Equal("second"))
+		})
+	})
+})
+
+// eventuallyRedpandaConfig waits for the base ConfigMap of the Cluster and
+// returns the redpanda section of the rendered redpanda.yaml
+func eventuallyRedpandaConfig(key types.NamespacedName) map[string]interface{} {
+	var cm corev1.ConfigMap
+	Eventually(func() error {
+		return k8sClient.Get(context.Background(), types.NamespacedName{
+			Name:		key.Name + "-base",
+			Namespace:	key.Namespace,
+		}, &cm)
+	}, timeout, interval).Should(Succeed())
+
+	var cfg struct {
+		Redpanda map[string]interface{} `yaml:"redpanda"`
+	}
+	Expect(yaml.Unmarshal([]byte(cm.Data[redpandaConfigurationFile]), &cfg)).Should(Succeed())
+	Expect(cfg.Redpanda).ShouldNot(BeEmpty())
+
+	return cfg.Redpanda
+}
+
+// configuratorScript returns the configurator script of the base ConfigMap
+// of the Cluster, or an empty string when it does not exist yet
+func configuratorScript(key types.NamespacedName) string {
+	return baseConfigMapEntry(key, "configurator.sh")
+}
+
+// baseConfigMapEntry returns the given entry of the base ConfigMap of the
+// Cluster, or an empty string when it does not exist yet
+func baseConfigMapEntry(key types.NamespacedName, entry string) string {
+	var cm corev1.ConfigMap
+	if err := k8sClient.Get(context.Background(), testKey(key.Name+"-base"), &cm); err != nil {
+		return ""
+	}
+	return cm.Data[entry]
+}
@@ -0,0 +1,690 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/go-logr/logr"
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	"gopkg.in/yaml.v3"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	consoleSuffix		= "-console"
+	consoleContainerName	= "console"
+	defaultConsoleImage	= "vectorized/console"
+	defaultConsolePort	= 8080
+
+	consoleConfigVolume	= "config"
+	consoleConfigDir	= "/etc/console"
+	consoleConfigFile	= "config.yaml"
+
+	consoleKafkaTLSVolume		= "kafka-tls"
+	consoleKafkaTLSDir		= "/etc/tls/certs/kafka"
+	consoleKafkaClientVolume	= "kafka-client"
+	consoleKafkaClientDir		= "/etc/tls/certs/kafka-client"
+	consoleAdminAPICAVolume		= "admin-api-ca"
+	consoleAdminAPICADir		= "/etc/tls/certs/admin-api-ca"
+	consoleAdminAPIClientVolume	= "admin-api-client"
+	consoleAdminAPIClientDir	= "/etc/tls/certs/admin-api-client"
+
+	// The Console reads the passwords from the environment, so that they
+	// are not written to its ConfigMap
+	kafkaSASLPasswordEnv	= "KAFKA_SASL_PASSWORD"
+	adminAPIPasswordEnv	= "REDPANDA_ADMINAPI_PASSWORD"
+
+	// consoleConfigHashAnnotation restarts the Console pods when their
+	// configuration changes
+	consoleConfigHashAnnotation	= "redpanda.vectorized.io/config-hash"
+	// consoleTemplateHashAnnotation is the digest of the desired
+	// Deployment spec, which is replaced when the digest changes
+	consoleTemplateHashAnnotation	= "redpanda.vectorized.io/template-hash"
+
+	// Reasons of the ClusterAvailable condition of the Console
+	reasonConsoleClusterNotFound	= "ClusterNotFound"
+	reasonConsoleClusterDegraded	= "ClusterDegraded"
+	reasonConsoleClusterNotReady	= "ClusterNotReady"
+	reasonConsoleClusterAvailable	= "ClusterAvailable"
+	reasonConsoleClientCertRequired	= "ClientCertificateRequired"
+)
+
+// ConsoleReconciler reconciles a Console object
+type ConsoleReconciler struct {
+	client.Client
+	Log	logr.Logger
+	Scheme	*runtime.Scheme
+	// ClusterSelector restricts the deployed Consoles to the ones of the
+	// Clusters reconciled by the operator, see ClusterReconciler
+	ClusterSelector	labels.Selector
+}
+
+//+kubebuilder:rbac:groups=redpanda.vectorized.io,resources=consoles,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=redpanda.vectorized.io,resources=consoles/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=redpanda.vectorized.io,resources=consoles/finalizers,verbs=update
+//+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete;
+
+// Reconcile deploys the Console of the referenced Cluster. The Console is
+// left untouched while the Cluster does not exist, its configuration is
+// derived from the Cluster definition.
+func (r *ConsoleReconciler) Reconcile(
+	ctx context.Context, req ctrl.Request,
+) (ctrl.Result, error) {
+	log := r.Log.WithValues("redpandaconsole", req.NamespacedName)
+
+	var console redpandav1alpha1.Console
+	if err := r.Get(ctx, req.NamespacedName, &console); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	status := console.Status.DeepCopy()
+
+	var cluster redpandav1alpha1.Cluster
+
+	err := r.Get(ctx, types.NamespacedName{Name: console.Spec.ClusterRef.Name, Namespace: console.Namespace}, &cluster)
+	if errors.IsNotFound(err) {
+		log.Info("Referenced Cluster not found", "cluster", console.Spec.ClusterRef.Name)
+		meta.SetStatusCondition(&status.Conditions, consoleClusterCondition(&console, nil))
+
+		return ctrl.Result{}, r.updateConsoleStatus(ctx, &console, status)
+	}
+
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// The Console of a Cluster of another shard is deployed by its operator
+	if !selected(r.ClusterSelector, &cluster) {
+		log.V(debugLevel).Info("Referenced Cluster is not selected by this operator")
+
+		return ctrl.Result{}, nil
+	}
+
+	meta.SetStatusCondition(&status.Conditions, consoleClusterCondition(&console, &cluster))
+
+	cm, err := buildConsoleConfigMap(&console, &cluster)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err = r.reconcileConsoleConfigMap(ctx, &console, cm); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	deploy := buildConsoleDeployment(&console, &cluster, configDigest(cm.Data[consoleConfigFile]))
+	if err = r.reconcileConsoleDeployment(ctx, &console, deploy); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err = r.reconcileConsoleService(ctx, &console); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	status.ReadyReplicas = deploy.Status.ReadyReplicas
+
+	return ctrl.Result{}, r.updateConsoleStatus(ctx, &console, status)
+}
+
+// consoleClusterCondition reports whether the Console can reach the
+// referenced Cluster, cluster is nil when it does not exist
+func consoleClusterCondition(
+	console *redpandav1alpha1.Console, cluster *redpandav1alpha1.Cluster,
+) metav1.Condition {
+	condition := metav1.Condition{
+		Type:	redpandav1alpha1.ConsoleClusterAvailable,
+		Status:	metav1.ConditionFalse,
+	}
+
+	switch {
+	case cluster == nil:
+		condition.Reason = reasonConsoleClusterNotFound
+		condition.Message = "The referenced Cluster does not exist"
+	case meta.IsStatusConditionTrue(cluster.Status.Conditions, redpandav1alpha1.ClusterDegraded):
+		degraded := meta.FindStatusCondition(cluster.Status.Conditions, redpandav1alpha1.ClusterDegraded)
+		condition.Reason = reasonConsoleClusterDegraded
+		condition.Message = fmt.Sprintf("The Cluster is degraded: %s", degraded.Message)
+	case kafkaClientAuthRequired(cluster) && consoleKafkaClientCertSecretName(console, cluster) == "":
+		condition.Reason = reasonConsoleClientCertRequired
+		condition.Message = "The kafka API requires a client certificate, set kafkaClientCertSecretRef"
+	case cluster.Status.Replicas == 0:
+		condition.Reason = reasonConsoleClusterNotReady
+		condition.Message = "The Cluster has no running broker"
+	default:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = reasonConsoleClusterAvailable
+		condition.Message = fmt.Sprintf("The Cluster has %d running brokers", cluster.Status.Replicas)
+	}
+
+	return condition
+}
+
+func (r *ConsoleReconciler) updateConsoleStatus(
+	ctx context.Context, console *redpandav1alpha1.Console, status *redpandav1alpha1.ConsoleStatus,
+) error {
+	if reflect.DeepEqual(&console.Status, status) {
+		return nil
+	}
+
+	console.Status = *status
+
+	return r.Status().Update(ctx, console)
+}
+
+// ensureConsoleOwner restores the controller reference of a resource
+// managed for the Console
+func (r *ConsoleReconciler) ensureConsoleOwner(
+	ctx context.Context, console *redpandav1alpha1.Console, obj client.Object,
+) error {
+	if metav1.IsControlledBy(obj, console) {
+		return nil
+	}
+
+	if err := controllerutil.SetControllerReference(console, obj, r.Scheme); err != nil {
+		return err
+	}
+
+	return r.Update(ctx, obj)
+}
+
+func (r *ConsoleReconciler) reconcileConsoleConfigMap(
+	ctx context.Context, console *redpandav1alpha1.Console, desired *corev1.ConfigMap,
+) error {
+	var cm corev1.ConfigMap
+
+	err := r.Get(ctx, types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, &cm)
+	if errors.IsNotFound(err) {
+		if err = controllerutil.SetControllerReference(console, desired, r.Scheme); err != nil {
+			return err
+		}
+
+		return r.Create(ctx, desired)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err = r.ensureConsoleOwner(ctx, console, &cm); err != nil {
+		return err
+	}
+
+	if reflect.DeepEqual(cm.Data, desired.Data) {
+		return nil
+	}
+
+	cm.Data = desired.Data
+
+	return r.Update(ctx, &cm)
+}
+
+// reconcileConsoleDeployment creates the Deployment, or replaces its spec
+// when the desired one changed. The status of the current Deployment is
+// copied to desired.
+func (r *ConsoleReconciler) reconcileConsoleDeployment(
+	ctx context.Context, console *redpandav1alpha1.Console, desired *appsv1.Deployment,
+) error {
+	var deploy appsv1.Deployment
+
+	err := r.Get(ctx, types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, &deploy)
+	if errors.IsNotFound(err) {
+		if err = controllerutil.SetControllerReference(console, desired, r.Scheme); err != nil {
+			return err
+		}
+
+		return r.Create(ctx, desired)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	desired.Status = deploy.Status
+
+	if err = r.ensureConsoleOwner(ctx, console, &deploy); err != nil {
+		return err
+	}
+
+	hash := desired.Annotations[consoleTemplateHashAnnotation]
+	if deploy.Annotations[consoleTemplateHashAnnotation] == hash {
+		return nil
+	}
+
+	if deploy.Annotations == nil {
+		deploy.Annotations = map[string]string{}
+	}
+
+	deploy.Annotations[consoleTemplateHashAnnotation] = hash
+	deploy.Spec.Replicas = desired.Spec.Replicas
+	deploy.Spec.Template = desired.Spec.Template
+
+	return r.Update(ctx, &deploy)
+}
+
+func (r *ConsoleReconciler) reconcileConsoleService(
+	ctx context.Context, console *redpandav1alpha1.Console,
+) error {
+	desired := buildConsoleService(console)
+
+	var svc corev1.Service
+
+	err := r.Get(ctx, types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, &svc)
+	if errors.IsNotFound(err) {
+		if err = controllerutil.SetControllerReference(console, desired, r.Scheme); err != nil {
+			return err
+		}
+
+		return r.Create(ctx, desired)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err = r.ensureConsoleOwner(ctx, console, &svc); err != nil {
+		return err
+	}
+
+	if !restoreServicePorts(&svc, desired.Spec.Ports) && labels.Equals(svc.Spec.Selector, desired.Spec.Selector) {
+		return nil
+	}
+
+	svc.Spec.Selector = desired.Spec.Selector
+
+	return r.Update(ctx, &svc)
+}
+
+// consoleName returns the name of the resources of the Console. The
+// suffix keeps them apart from the resources of a Cluster of the same name.
+func consoleName(console *redpandav1alpha1.Console) string {
+	return console.Name + consoleSuffix
+}
+
+func consoleLabels(console *redpandav1alpha1.Console) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":	"redpanda-console",
+		"app.kubernetes.io/instance":	console.Name,
+	}
+}
+
+func consolePort(console *redpandav1alpha1.Console) int {
+	if console.Spec.Port != 0 {
+		return console.Spec.Port
+	}
+
+	return defaultConsolePort
+}
+
+func consoleImage(console *redpandav1alpha1.Console) string {
+	image := console.Spec.Image
+	if image == "" {
+		image = defaultConsoleImage
+	}
+
+	return image + ":" + console.Spec.Version
+}
+
+// kafkaClientAuthRequired reports whether the kafka API only accepts the
+// clients presenting a certificate
+func kafkaClientAuthRequired(cluster *redpandav1alpha1.Cluster) bool {
+	return kafkaCertSecretName(cluster) != "" && cluster.Spec.Configuration.KafkaAPITLS.RequireClientAuth
+}
+
+// consoleKafkaClientCertSecretName returns the name of the Secret holding
+// the client certificate of the Console, or an empty string when there is
+// none. The certificate of the brokers is never shared with the Console.
+// The self-signed CA only issues the client certificate when it also signs
+// the kafka API certificate.
+func consoleKafkaClientCertSecretName(
+	console *redpandav1alpha1.Console, cluster *redpandav1alpha1.Cluster,
+) string {
+	if ref := console.Spec.KafkaClientCertSecretRef; ref != nil {
+		return ref.Name
+	}
+
+	if kafkaCertSecretName(cluster) == cluster.Name+selfSignedTLSSuffix {
+		return cluster.Name + selfSignedClientSuffix
+	}
+
+	return ""
+}
+
+// consoleConfig returns the Console configuration connecting it to the
+// brokers, the Admin API and the Schema Registry of the Cluster
+func consoleConfig(
+	console *redpandav1alpha1.Console, cluster *redpandav1alpha1.Cluster,
+) map[string]interface{} {
+	hosts := brokerHosts(cluster)
+
+	brokers := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		brokers = append(brokers, fmt.Sprintf("%s:%d", h, kafkaAPIPort(cluster)))
+	}
+
+	kafka := map[string]interface{}{"brokers": brokers}
+
+	if kafkaCertSecretName(cluster) != "" {
+		kafkaTLS := map[string]interface{}{
+			"enabled":	true,
+			"caFilepath":	filepath.Join(consoleKafkaTLSDir, caCertKey),
+		}
+
+		if kafkaClientAuthRequired(cluster) && consoleKafkaClientCertSecretName(console, cluster) != "" {
+			kafkaTLS["certFilepath"] = filepath.Join(consoleKafkaClientDir, corev1.TLSCertKey)
+			kafkaTLS["keyFilepath"] = filepath.Join(consoleKafkaClientDir, corev1.TLSPrivateKeyKey)
+		}
+
+		kafka["tls"] = kafkaTLS
+	}
+
+	if cluster.Spec.SASL.Enabled {
+		kafka["sasl"] = map[string]interface{}{
+			"enabled":	true,
+			"username":	superuserName(cluster),
+			"mechanism":	superuserMechanism(cluster, &cluster.Status),
+		}
+	}
+
+	if cluster.Spec.SchemaRegistry.Enabled {
+		urls := make([]string, 0, len(hosts))
+		for _, h := range hosts {
+			urls = append(urls, fmt.Sprintf("http://%s:%d", h, schemaRegistryPort(cluster)))
+		}
+
+		kafka["schemaRegistry"] = map[string]interface{}{"enabled": true, "urls": urls}
+	}
+
+	scheme := strings.ToLower(string(healthCheckScheme(cluster)))
+
+	urls := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		urls = append(urls, fmt.Sprintf("%s://%s:%d", scheme, h, adminAPIPort(cluster)))
+	}
+
+	// The Console reaches the Admin API the same way as the operator
+	if u := cluster.Spec.Configuration.AdminAPI.URL; u != "" {
+		urls = []string{u}
+		scheme = strings.SplitN(u, ":", 2)[0]
+	}
+
+	adminAPI := map[string]interface{}{"enabled": true, "urls": urls}
+
+	if scheme == "https" {
+		adminTLS := map[string]interface{}{"enabled": true}
+
+		if cluster.Spec.Configuration.AdminAPI.TLS.CASecretRef != nil {
+			adminTLS["caFilepath"] = filepath.Join(consoleAdminAPICADir, caCertKey)
+		}
+
+		if cluster.Spec.Configuration.AdminAPI.TLS.ClientCertSecretRef != nil {
+			adminTLS["certFilepath"] = filepath.Join(consoleAdminAPIClientDir, corev1.TLSCertKey)
+			adminTLS["keyFilepath"] = filepath.Join(consoleAdminAPIClientDir, corev1.TLSPrivateKeyKey)
+		}
+
+		adminAPI["tls"] = adminTLS
+	}
+
+	if cluster.Spec.Configuration.AdminAPI.RequireAuth {
+		adminAPI["username"] = superuserName(cluster)
+	}
+
+	return map[string]interface{}{
+		"kafka":	kafka,
+		"redpanda":	map[string]interface{}{"adminApi": adminAPI},
+		"server":	map[string]interface{}{"listenPort": consolePort(console)},
+	}
+}
+
+// buildConsoleConfigMap returns the ConfigMap holding the Console
+// configuration file
+func buildConsoleConfigMap(
+	console *redpandav1alpha1.Console, cluster *redpandav1alpha1.Cluster,
+) (*corev1.ConfigMap, error) {
+	content, err := yaml.Marshal(consoleConfig(console, cluster))
+	if err != nil {
+		return nil, err
+	}
+
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:	console.Namespace,
+			Name:		consoleName(console),
+			Labels:		consoleLabels(console),
+		},
+		Data:	map[string]string{consoleConfigFile: string(content)},
+	}, nil
+}
+
+func configDigest(content string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+}
+
+// superuserPasswordEnv returns the environment variable reading the
+// bootstrap superuser password from its Secret
+func superuserPasswordEnv(name string, cluster *redpandav1alpha1.Cluster) corev1.EnvVar {
+	secretName := cluster.Name + superuserSuffix
+	if ref := cluster.Spec.SASL.SuperuserSecretRef; ref != nil {
+		secretName = ref.Name
+	}
+
+	return corev1.EnvVar{
+		Name:	name,
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference:	corev1.LocalObjectReference{Name: secretName},
+				Key:			passwordKey,
+			},
+		},
+	}
+}
+
+// addConsoleSecretVolume mounts the given keys of the Secret in the
+// directory of the Console container
+func addConsoleSecretVolume(spec *corev1.PodSpec, volume, dir, secretName string, keys ...string) {
+	items := make([]corev1.KeyToPath, 0, len(keys))
+	for _, k := range keys {
+		items = append(items, corev1.KeyToPath{Key: k, Path: k})
+	}
+
+	spec.Volumes = append(spec.Volumes, corev1.Volume{
+		Name:	volume,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{SecretName: secretName, Items: items},
+		},
+	})
+
+	spec.Containers[0].VolumeMounts = append(spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+		Name:		volume,
+		MountPath:	dir,
+		ReadOnly:	true,
+	})
+}
+
+// buildConsoleDeployment returns the Deployment of the Console, its pods
+// are restarted when the digest of their configuration changes
+func buildConsoleDeployment(
+	console *redpandav1alpha1.Console, cluster *redpandav1alpha1.Cluster, configHash string,
+) *appsv1.Deployment {
+	replicas := console.Spec.Replicas
+	if replicas == nil {
+		replicas = pointer.Int32Ptr(1)
+	}
+
+	port := consolePort(console)
+
+	container := corev1.Container{
+		Name:		consoleContainerName,
+		Image:		consoleImage(console),
+		Args:		[]string{"--config.filepath=" + filepath.Join(consoleConfigDir, consoleConfigFile)},
+		Ports:		[]corev1.ContainerPort{{Name: "http", ContainerPort: int32(port), Protocol: corev1.ProtocolTCP}},
+		Resources:	console.Spec.Resources,
+		ReadinessProbe: &corev1.Probe{
+			Handler: corev1.Handler{
+				HTTPGet: &corev1.HTTPGetAction{Path: "/admin/health", Port: intstr.FromInt(port)},
+			},
+		},
+		VolumeMounts: []corev1.VolumeMount{{
+			Name:		consoleConfigVolume,
+			MountPath:	consoleConfigDir,
+			ReadOnly:	true,
+		}},
+	}
+
+	if cluster.Spec.SASL.Enabled {
+		container.Env = append(container.Env, superuserPasswordEnv(kafkaSASLPasswordEnv, cluster))
+	}
+
+	if cluster.Spec.Configuration.AdminAPI.RequireAuth {
+		container.Env = append(container.Env, superuserPasswordEnv(adminAPIPasswordEnv, cluster))
+	}
+
+	spec := corev1.PodSpec{
+		Containers:	[]corev1.Container{container},
+		Volumes: []corev1.Volume{{
+			Name:	consoleConfigVolume,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: consoleName(console)},
+				},
+			},
+		}},
+	}
+
+	if secretName := kafkaCertSecretName(cluster); secretName != "" {
+		addConsoleSecretVolume(&spec, consoleKafkaTLSVolume, consoleKafkaTLSDir, secretName, caCertKey)
+	}
+
+	if secretName := consoleKafkaClientCertSecretName(console, cluster); kafkaClientAuthRequired(cluster) && secretName != "" {
+		addConsoleSecretVolume(&spec, consoleKafkaClientVolume, consoleKafkaClientDir, secretName,
+			corev1.TLSCertKey, corev1.TLSPrivateKeyKey)
+	}
+
+	adminTLS := cluster.Spec.Configuration.AdminAPI.TLS
+	if ref := adminTLS.CASecretRef; ref != nil {
+		addConsoleSecretVolume(&spec, consoleAdminAPICAVolume, consoleAdminAPICADir, ref.Name, caCertKey)
+	}
+
+	if ref := adminTLS.ClientCertSecretRef; ref != nil {
+		addConsoleSecretVolume(&spec, consoleAdminAPIClientVolume, consoleAdminAPIClientDir, ref.Name,
+			corev1.TLSCertKey, corev1.TLSPrivateKeyKey)
+	}
+
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:	console.Namespace,
+			Name:		consoleName(console),
+			Labels:		consoleLabels(console),
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas:	replicas,
+			Selector:	&metav1.LabelSelector{MatchLabels: consoleLabels(console)},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:		consoleLabels(console),
+					Annotations:	map[string]string{consoleConfigHashAnnotation: configHash},
+				},
+				Spec:	spec,
+			},
+		},
+	}
+
+	// The spec defaulted by the API server can not be compared with the
+	// desired one, the digest of the desired spec is compared instead
+	content, _ := json.Marshal(&deploy.Spec)
+	deploy.Annotations = map[string]string{consoleTemplateHashAnnotation: configDigest(string(content))}
+
+	return deploy
+}
+
+// buildConsoleService returns the ClusterIP Service exposing the Console
+// web UI
+func buildConsoleService(console *redpandav1alpha1.Console) *corev1.Service {
+	port := consolePort(console)
+
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:	console.Namespace,
+			Name:		consoleName(console),
+			Labels:		consoleLabels(console),
+		},
+		Spec: corev1.ServiceSpec{
+			Type:		corev1.ServiceTypeClusterIP,
+			Selector:	consoleLabels(console),
+			Ports: []corev1.ServicePort{{
+				Name:		"http",
+				Protocol:	corev1.ProtocolTCP,
+				Port:		int32(port),
+				TargetPort:	intstr.FromInt(port),
+			}},
+		},
+	}
+}
+
+// referencingConsoles enqueues the Consoles referencing the changed
+// Cluster, so that they follow its definition and connection status
+func (r *ConsoleReconciler) referencingConsoles(obj client.Object) []reconcile.Request {
+	if !selected(r.ClusterSelector, obj) {
+		return nil
+	}
+
+	var consoles redpandav1alpha1.ConsoleList
+	if err := r.List(context.Background(), &consoles, client.InNamespace(obj.GetNamespace())); err != nil {
+		r.Log.Error(err, "Unable to list Consoles referencing Cluster",
+			"Namespace", obj.GetNamespace(), "Name", obj.GetName())
+
+		return nil
+	}
+
+	var requests []reconcile.Request
+
+	for i := range consoles.Items {
+		if consoles.Items[i].Spec.ClusterRef.Name == obj.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+				Name:		consoles.Items[i].Name,
+				Namespace:	consoles.Items[i].Namespace,
+			}})
+		}
+	}
+
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ConsoleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&redpandav1alpha1.Console{}).
+		Owns(&appsv1.Deployment{}).
+		Owns(&corev1.Service{}).
+		Owns(&corev1.ConfigMap{}).
+		Watches(&source.Kind{Type: &redpandav1alpha1.Cluster{}},
+			handler.EnqueueRequestsFromMapFunc(r.referencingConsoles)).
+		Complete(r)
+}
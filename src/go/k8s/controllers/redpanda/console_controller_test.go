@@ -0,0 +1,103 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Redpanda Console", func() {
+	Context("When a Console references a Cluster", func() {
+		It("Should deploy the Console configured from the Cluster", func() {
+			key := testKey("redpanda-console")
+			consoleKey := testKey("ui")
+			resourceKey := testKey("ui-console")
+
+			console := &v1alpha1.Console{
+				ObjectMeta:	metav1.ObjectMeta{Name: consoleKey.Name, Namespace: consoleKey.Namespace},
+				Spec: v1alpha1.ConsoleSpec{
+					ClusterRef:	corev1.LocalObjectReference{Name: key.Name},
+					Version:	"v2.0.0",
+				},
+			}
+			Expect(k8sClient.Create(context.Background(), console)).Should(Succeed())
+
+			By("Waiting for the Cluster")
+			Eventually(func() string {
+				return consoleClusterReason(consoleKey)
+			}, timeout, interval).Should(Equal("ClusterNotFound"))
+			Consistently(func() error {
+				return k8sClient.Get(context.Background(), resourceKey, &appsv1.Deployment{})
+			}, "1s", interval).ShouldNot(Succeed())
+
+			By("Deploying the Console once the Cluster exists")
+			Expect(k8sClient.Create(context.Background(), testCluster(key.Name))).Should(Succeed())
+
+			var deploy appsv1.Deployment
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), resourceKey, &deploy)
+			}, timeout, interval).Should(Succeed())
+			Expect(metav1.IsControlledBy(&deploy, console)).Should(BeTrue())
+			Expect(deploy.Spec.Template.Spec.Containers[0].Image).Should(Equal("vectorized/console:v2.0.0"))
+			Expect(k8sClient.Get(context.Background(), resourceKey, &corev1.Service{})).Should(Succeed())
+			Expect(consoleConfig(resourceKey)).Should(ContainSubstring(
+				"redpanda-console-0.redpanda-console.default.svc.cluster.local:9092"))
+			Expect(consoleClusterReason(consoleKey)).Should(Equal("ClusterNotReady"))
+			configHash := deploy.Spec.Template.Annotations["redpanda.vectorized.io/config-hash"]
+
+			By("Following the changes of the Cluster")
+			updateCluster(key, func(c *v1alpha1.Cluster) {
+				c.Spec.SASL.Enabled = true
+			})
+			Eventually(func() string {
+				return consoleConfig(resourceKey)
+			}, timeout, interval).Should(ContainSubstring("SCRAM-SHA-256"))
+			Eventually(func() string {
+				if err := k8sClient.Get(context.Background(), resourceKey, &deploy); err != nil {
+					return configHash
+				}
+				return deploy.Spec.Template.Annotations["redpanda.vectorized.io/config-hash"]
+			}, timeout, interval).ShouldNot(Equal(configHash))
+
+			env := deploy.Spec.Template.Spec.Containers[0].Env
+			Expect(env).Should(HaveLen(1))
+			Expect(env[0].Name).Should(Equal("KAFKA_SASL_PASSWORD"))
+			Expect(env[0].ValueFrom.SecretKeyRef.Name).Should(Equal(key.Name + "-superuser"))
+		})
+	})
+})
+
+func consoleClusterReason(key types.NamespacedName) string {
+	var console v1alpha1.Console
+	if err := k8sClient.Get(context.Background(), key, &console); err != nil {
+		return ""
+	}
+	if c := meta.FindStatusCondition(console.Status.Conditions, v1alpha1.ConsoleClusterAvailable); c != nil {
+		return c.Reason
+	}
+	return ""
+}
+
+func consoleConfig(key types.NamespacedName) string {
+	var cm corev1.ConfigMap
+	if err := k8sClient.Get(context.Background(), key, &cm); err != nil {
+		return ""
+	}
+	return cm.Data["config.yaml"]
+}
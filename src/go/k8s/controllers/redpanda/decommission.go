@@ -0,0 +1,179 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	"github.com/vectorizedio/redpanda/src/go/k8s/pkg/admin"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+)
+
+const (
+	defaultDecommissionTimeout	= 30 * time.Minute
+	// decommissionPollInterval is how often the drain progress is checked
+	decommissionPollInterval	= 10 * time.Second
+
+	reasonDecommissioning		= "Decommissioning"
+	reasonDecommissionTimeout	= "DecommissionTimeout"
+)
+
+// decommissionReasons are the Degraded reasons resolved by the broker
+// decommissioning
+var decommissionReasons = []string{reasonDecommissioning, reasonDecommissionTimeout, reasonUploadLag}
+
+// decommissionTimeout returns how long a broker may take to drain
+func decommissionTimeout(cluster *redpandav1alpha1.Cluster) time.Duration {
+	if t := cluster.Spec.Scaling.DecommissionTimeout; t != nil {
+		return t.Duration
+	}
+
+	return defaultDecommissionTimeout
+}
+
+// decommissionReplicas returns the replicas the StatefulSet may be scaled
+// to. Brokers are removed one at a time starting from the highest ordinal,
+// and only once they drained their partitions. The decommissioning is
+// tracked in the status and reports the Cluster as degraded when the
+// broker does not drain within the timeout. With the tiered storage, a
+// broker is only decommissioned once the uploads caught up. The scale
+// down is held while the controller quorum is lost or a failed broker is
+// replaced.
+func (r *ClusterReconciler) decommissionReplicas(
+	ctx context.Context,
+	cluster *redpandav1alpha1.Cluster,
+	sts *appsv1.StatefulSet,
+	adminAPIConfig *AdminAPIConfig,
+	status *redpandav1alpha1.ClusterStatus,
+) (*int32, error) {
+	desired := cluster.Spec.Replicas
+	if desired == nil || sts.Spec.Replicas == nil || *desired >= *sts.Spec.Replicas {
+		status.Decommission = nil
+		// The scale down held by the uploads was reverted
+		if decommissionHeld(status) {
+			clearDegraded(status, reasonDecommissioning, decommissionReasons...)
+		}
+
+		return desired, nil
+	}
+
+	current := sts.Spec.Replicas
+
+	// Removing a broker could prevent the controller from electing a
+	// leader again
+	if r.quorumLost(status) || status.Replacement != nil {
+		return current, nil
+	}
+
+	ordinal := int(*current - 1)
+	nodeID := replicaNodeID(cluster, sts, ordinal)
+
+	adminAPI, err := r.adminAPIClient(cluster, adminAPIConfig)
+	if err != nil {
+		return current, err
+	}
+
+	if status.Decommission == nil || status.Decommission.NodeID != nodeID {
+		if reason := uploadsBehind(cluster, status); reason != "" {
+			setDegraded(status, reasonUploadLag, reason)
+
+			return current, nil
+		}
+
+		if err = adminAPI.DecommissionBroker(ctx, nodeID); err != nil {
+			return current, err
+		}
+
+		status.Decommission = &redpandav1alpha1.DecommissionStatus{
+			NodeID:		nodeID,
+			StartTime:	metav1.Now(),
+		}
+	}
+
+	drained, err := brokerDrained(ctx, adminAPI, nodeID)
+	if err != nil {
+		return current, err
+	}
+
+	if drained {
+		// The claim of the broker is kept by the StatefulSet
+		if err = r.markDecommissioned(ctx, sts, ordinal); err != nil {
+			return current, err
+		}
+
+		status.Decommission = nil
+		clearDegraded(status, reasonDecommissioning, decommissionReasons...)
+
+		return pointer.Int32Ptr(int32(ordinal)), nil
+	}
+
+	if elapsed := time.Since(status.Decommission.StartTime.Time); elapsed > decommissionTimeout(cluster) {
+		setDegraded(status, reasonDecommissionTimeout,
+			fmt.Sprintf("Broker %d did not drain its partitions after %s", nodeID, elapsed.Round(time.Second)))
+	}
+
+	return current, nil
+}
+
+// decommissionHeld reports whether a broker waits for the tiered storage
+// uploads before its decommissioning
+func decommissionHeld(status *redpandav1alpha1.ClusterStatus) bool {
+	degraded := meta.FindStatusCondition(status.Conditions, redpandav1alpha1.ClusterDegraded)
+
+	return degraded != nil && degraded.Status == metav1.ConditionTrue && degraded.Reason == reasonUploadLag
+}
+
+// brokerDrained reports whether the broker completed its decommissioning
+func brokerDrained(
+	ctx context.Context, adminAPI admin.AdminAPIClient, nodeID int,
+) (bool, error) {
+	brokers, err := adminAPI.Brokers(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	for _, b := range brokers {
+		if b.NodeID == nodeID {
+			return b.MembershipStatus == admin.MembershipRemoved, nil
+		}
+	}
+
+	return true, nil
+}
+
+// throttleJoins returns the replicas the StatefulSet may be scaled up to.
+// With a join throttle, at most JoinThrottle new brokers start at once and
+// the next ones are only added once the StatefulSet controller observed
+// the previous scale up and every broker is ready.
+func throttleJoins(
+	cluster *redpandav1alpha1.Cluster, sts *appsv1.StatefulSet, replicas *int32,
+) *int32 {
+	throttle := cluster.Spec.Scaling.JoinThrottle
+	if throttle <= 0 || replicas == nil || sts.Spec.Replicas == nil || *replicas <= *sts.Spec.Replicas {
+		return replicas
+	}
+
+	current := *sts.Spec.Replicas
+	if sts.Status.ObservedGeneration < sts.Generation || sts.Status.ReadyReplicas < current {
+		return pointer.Int32Ptr(current)
+	}
+
+	if *replicas-current > throttle {
+		return pointer.Int32Ptr(current + throttle)
+	}
+
+	return replicas
+}
@@ -0,0 +1,342 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+)
+
+var _ = Describe("Redpanda broker joins", func() {
+	Context("When the joins are throttled", func() {
+		It("Should start the new brokers one at a time", func() {
+			key := testKey("redpanda-join-throttle")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.Scaling.JoinThrottle = 1
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			Eventually(func() int32 {
+				return statefulSetReplicas(key)
+			}, timeout, interval).Should(Equal(int32(1)))
+
+			By("Waiting for the running broker to be ready")
+			scaleCluster(key, 3)
+			Consistently(func() int32 {
+				return statefulSetReplicas(key)
+			}, "1s", interval).Should(Equal(int32(1)))
+
+			By("Starting a single new broker at a time")
+			observeStatefulSet(key, "rev-1")
+			Eventually(func() int32 {
+				return statefulSetReplicas(key)
+			}, timeout, interval).Should(Equal(int32(2)))
+			Consistently(func() int32 {
+				return statefulSetReplicas(key)
+			}, "1s", interval).Should(Equal(int32(2)))
+
+			observeStatefulSet(key, "rev-1")
+			Eventually(func() int32 {
+				return statefulSetReplicas(key)
+			}, timeout, interval).Should(Equal(int32(3)))
+		})
+	})
+})
+
+var _ = Describe("Redpanda broker decommissioning", func() {
+	Context("When the broker drains within the timeout", func() {
+		It("Should scale the StatefulSet down", func() {
+			key := createScaledCluster("redpanda-decommission", time.Minute)
+
+			scaleCluster(key, 1)
+			Eventually(func() int32 {
+				return statefulSetReplicas(key)
+			}, timeout, interval).Should(Equal(int32(1)))
+			Expect(testAdminAPIs.get(key.Name).isDecommissioned(1)).Should(BeTrue())
+
+			Eventually(func() *v1alpha1.DecommissionStatus {
+				var redpandaCluster v1alpha1.Cluster
+				Expect(k8sClient.Get(context.Background(), key, &redpandaCluster)).Should(Succeed())
+				return redpandaCluster.Status.Decommission
+			}, timeout, interval).Should(BeNil())
+		})
+	})
+
+	Context("When the broker does not drain within the timeout", func() {
+		It("Should keep the broker and report the Cluster as degraded", func() {
+			testAdminAPIs.get("redpanda-decommission-timeout").setDrain(false)
+			key := createScaledCluster("redpanda-decommission-timeout", time.Second)
+
+			scaleCluster(key, 1)
+			Eventually(func() string {
+				return clusterConditionReason(key, v1alpha1.ClusterDegraded)
+			}, timeout, interval).Should(Equal("DecommissionTimeout"))
+			Expect(clusterCondition(key, v1alpha1.ClusterDegraded)).Should(Equal(metav1.ConditionTrue))
+			Expect(statefulSetReplicas(key)).Should(Equal(int32(2)))
+
+			By("Removing the broker once it drained")
+			testAdminAPIs.get(key.Name).setDrain(true)
+			Eventually(func() int32 {
+				return statefulSetReplicas(key)
+			}, timeout, interval).Should(Equal(int32(1)))
+			Eventually(func() metav1.ConditionStatus {
+				return clusterCondition(key, v1alpha1.ClusterDegraded)
+			}, timeout, interval).Should(Equal(metav1.ConditionFalse))
+		})
+	})
+
+	Context("When the tiered storage uploads are behind", func() {
+		It("Should hold the decommissioning until the uploads caught up", func() {
+			key := testKey("redpanda-decommission-upload-lag")
+			testAdminAPIs.get(key.Name).setUploadLag(time.Hour, 3)
+
+			credentials := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:		key.Name + "-s3",
+					Namespace:	key.Namespace,
+				},
+				StringData:	map[string]string{"access_key": "access", "secret_key": "secret"},
+			}
+			Expect(k8sClient.Create(context.Background(), credentials)).Should(Succeed())
+
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.Replicas = pointer.Int32Ptr(2)
+			redpandaCluster.Spec.CloudStorage = v1alpha1.CloudStorageConfig{
+				Enabled:		true,
+				Bucket:			"segments",
+				Region:			"eu-west-1",
+				CredentialsSecretRef:	&corev1.LocalObjectReference{Name: credentials.Name},
+				MaxUploadLag:		&metav1.Duration{Duration: time.Minute},
+			}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+			Eventually(func() int32 {
+				return statefulSetReplicas(key)
+			}, timeout, interval).Should(Equal(int32(2)))
+			setReadyReplicas(key, 2)
+
+			Eventually(func() *v1alpha1.CloudStorageStatus {
+				var cluster v1alpha1.Cluster
+				Expect(k8sClient.Get(context.Background(), key, &cluster)).Should(Succeed())
+				return cluster.Status.CloudStorage
+			}, timeout, interval).Should(Equal(&v1alpha1.CloudStorageStatus{
+				PartitionsBehind:	3,
+				UploadLag:		metav1.Duration{Duration: time.Hour},
+			}))
+
+			scaleCluster(key, 1)
+			Eventually(func() string {
+				return clusterConditionReason(key, v1alpha1.ClusterDegraded)
+			}, timeout, interval).Should(Equal("UploadLagAboveThreshold"))
+			Expect(statefulSetReplicas(key)).Should(Equal(int32(2)))
+			Expect(testAdminAPIs.get(key.Name).isDecommissioned(1)).Should(BeFalse())
+
+			By("Decommissioning the broker once the uploads caught up")
+			testAdminAPIs.get(key.Name).setUploadLag(time.Second, 0)
+			expireLastReconcileTime(key)
+			Eventually(func() int32 {
+				return statefulSetReplicas(key)
+			}, timeout, interval).Should(Equal(int32(1)))
+			Expect(testAdminAPIs.get(key.Name).isDecommissioned(1)).Should(BeTrue())
+			Eventually(func() metav1.ConditionStatus {
+				return clusterCondition(key, v1alpha1.ClusterDegraded)
+			}, timeout, interval).Should(Equal(metav1.ConditionFalse))
+		})
+	})
+})
+
+var _ = Describe("Redpanda broker replacement", func() {
+	Context("When a broker keeps failing", func() {
+		It("Should replace it on a new data claim with a new node id", func() {
+			key := testKey("redpanda-replacement")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.Replicas = pointer.Int32Ptr(2)
+			redpandaCluster.Spec.BrokerReplacement.Enabled = true
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+			Eventually(func() int32 {
+				return statefulSetReplicas(key)
+			}, timeout, interval).Should(Equal(int32(2)))
+
+			// The protection finalizer of the claims in use is removed by
+			// the test, once the pod releasing the claim is gone
+			createDataClaim(key, 0, nil)
+			createDataClaim(key, 1, nil)
+			claimKey := testKey("datadir-" + key.Name + "-1")
+			Eventually(func() error {
+				var pvc corev1.PersistentVolumeClaim
+				if err := k8sClient.Get(context.Background(), claimKey, &pvc); err != nil {
+					return err
+				}
+				pvc.Finalizers = []string{"kubernetes.io/pvc-protection"}
+				return k8sClient.Update(context.Background(), &pvc)
+			}, timeout, interval).Should(Succeed())
+
+			observeStatefulSet(key, "rev-1")
+			brokerPod(key, 0, "rev-1")
+			brokerPod(key, 1, "rev-1")
+
+			By("Failing the broker for longer than the threshold")
+			testAdminAPIs.get(key.Name).setAlive(1, false)
+			failBrokerPod(testKey(key.Name + "-1"))
+			// The pods are not watched, the Cluster change triggers the
+			// reconciliation
+			updateCluster(key, func(c *v1alpha1.Cluster) {
+				c.Spec.BrokerReplacement.MinRestarts = 3
+			})
+			Eventually(func() bool {
+				return testAdminAPIs.get(key.Name).isDecommissioned(1)
+			}, timeout, interval).Should(BeTrue())
+			Eventually(func() bool {
+				return clusterEvent(key, corev1.EventTypeWarning, "ReplacingBroker")
+			}, timeout, interval).Should(BeTrue())
+
+			By("Deleting the data claim and the pod of the broker")
+			Eventually(func() bool {
+				return dataClaim(key, 1).DeletionTimestamp != nil
+			}, timeout, interval).Should(BeTrue())
+			// The configurator reads the new node id before the broker
+			// restarts
+			Expect(baseConfigMapEntry(key, "node-ids")).Should(Equal(key.Name + "-1 1001\n"))
+			Eventually(func() bool {
+				return podExists(testKey(key.Name + "-1"))
+			}, timeout, interval).Should(BeFalse())
+
+			By("Deleting the pod recreated on the terminating claim once more")
+			brokerPod(key, 1, "rev-1")
+			Eventually(func() error {
+				var pvc corev1.PersistentVolumeClaim
+				if err := k8sClient.Get(context.Background(), claimKey, &pvc); err != nil {
+					return err
+				}
+				pvc.Finalizers = nil
+				return k8sClient.Update(context.Background(), &pvc)
+			}, timeout, interval).Should(Succeed())
+			Eventually(func() bool {
+				return podExists(testKey(key.Name + "-1"))
+			}, timeout, interval).Should(BeFalse())
+
+			By("Completing once the broker is recreated with a new claim")
+			createDataClaim(key, 1, nil)
+			brokerPod(key, 1, "rev-1")
+			Eventually(func() *v1alpha1.ReplacementStatus {
+				return clusterReplacement(key)
+			}, timeout, interval).Should(BeNil())
+			Eventually(func() bool {
+				return clusterEvent(key, corev1.EventTypeNormal, "BrokerReplaced")
+			}, timeout, interval).Should(BeTrue())
+			Expect(podExists(testKey(key.Name + "-1"))).Should(BeTrue())
+
+			By("Keeping the new node id of the broker")
+			var cluster v1alpha1.Cluster
+			Expect(k8sClient.Get(context.Background(), key, &cluster)).Should(Succeed())
+			Expect(cluster.Status.NodeIDs).Should(Equal(map[string]int{key.Name + "-1": 1001}))
+			Consistently(func() *v1alpha1.ReplacementStatus {
+				return clusterReplacement(key)
+			}, "2s", interval).Should(BeNil())
+			Expect(testAdminAPIs.get(key.Name).isDecommissioned(1)).Should(BeTrue())
+			Expect(dataClaim(key, 1).DeletionTimestamp).Should(BeNil())
+		})
+	})
+})
+
+// failBrokerPod makes the redpanda container of the pod crash loop, with
+// the pod unready for an hour
+func failBrokerPod(key types.NamespacedName) {
+	Eventually(func() error {
+		var pod corev1.Pod
+		if err := k8sClient.Get(context.Background(), key, &pod); err != nil {
+			return err
+		}
+		pod.Status.Conditions = []corev1.PodCondition{{
+			Type:			corev1.PodReady,
+			Status:			corev1.ConditionFalse,
+			LastTransitionTime:	metav1.NewTime(time.Now().Add(-time.Hour)),
+		}}
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+			Name:		"redpanda",
+			Image:		pod.Spec.Containers[0].Image,
+			RestartCount:	8,
+			State: corev1.ContainerState{
+				Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"},
+			},
+		}}
+		return k8sClient.Status().Update(context.Background(), &pod)
+	}, timeout, interval).Should(Succeed())
+}
+
+func podExists(key types.NamespacedName) bool {
+	return k8sClient.Get(context.Background(), key, &corev1.Pod{}) == nil
+}
+
+func clusterReplacement(key types.NamespacedName) *v1alpha1.ReplacementStatus {
+	var cluster v1alpha1.Cluster
+	if err := k8sClient.Get(context.Background(), key, &cluster); err != nil {
+		return nil
+	}
+
+	return cluster.Status.Replacement
+}
+
+// createScaledCluster creates a Cluster with two brokers and waits for its
+// StatefulSet to be scaled up
+func createScaledCluster(
+	name string, decommissionTimeout time.Duration,
+) types.NamespacedName {
+	key := testKey(name)
+	redpandaCluster := testCluster(key.Name)
+	redpandaCluster.Spec.Replicas = pointer.Int32Ptr(2)
+	redpandaCluster.Spec.Scaling.DecommissionTimeout = &metav1.Duration{Duration: decommissionTimeout}
+	Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+	Eventually(func() int32 {
+		return statefulSetReplicas(key)
+	}, timeout, interval).Should(Equal(int32(2)))
+
+	return key
+}
+
+// expireLastReconcileTime makes the next reconciliation refresh the values
+// observed at most once per resolution of the last reconcile time
+func expireLastReconcileTime(key types.NamespacedName) {
+	Eventually(func() error {
+		var cluster v1alpha1.Cluster
+		if err := k8sClient.Get(context.Background(), key, &cluster); err != nil {
+			return err
+		}
+		cluster.Status.LastReconcileTime = metav1.NewTime(time.Now().Add(-time.Hour))
+		return k8sClient.Status().Update(context.Background(), &cluster)
+	}, timeout, interval).Should(Succeed())
+}
+
+func scaleCluster(key types.NamespacedName, replicas int32) {
+	Eventually(func() error {
+		var redpandaCluster v1alpha1.Cluster
+		if err := k8sClient.Get(context.Background(), key, &redpandaCluster); err != nil {
+			return err
+		}
+		redpandaCluster.Spec.Replicas = pointer.Int32Ptr(replicas)
+		return k8sClient.Update(context.Background(), &redpandaCluster)
+	}, timeout, interval).Should(Succeed())
+}
+
+func statefulSetReplicas(key types.NamespacedName) int32 {
+	var sts appsv1.StatefulSet
+	if err := k8sClient.Get(context.Background(), key, &sts); err != nil || sts.Spec.Replicas == nil {
+		return -1
+	}
+	return *sts.Spec.Replicas
+}
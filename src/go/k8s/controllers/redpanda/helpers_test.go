@@ -0,0 +1,62 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda_test
+
+import (
+	"time"
+
+	"github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+)
+
+const (
+	timeout		= time.Second * 30
+	interval	= time.Second * 1
+
+	kafkaPort			= 9092
+	redpandaConfigurationFile	= "redpanda.yaml"
+	replicas			= 1
+	redpandaContainerTag		= "x"
+	redpandaContainerImage		= "vectorized/redpanda"
+)
+
+// testKey returns the key of a Cluster created by testCluster
+func testKey(name string) types.NamespacedName {
+	return types.NamespacedName{
+		Name:		name,
+		Namespace:	"default",
+	}
+}
+
+// testCluster returns a minimal Cluster, labeled with its own name so the
+// resources of different test Clusters never select each other
+func testCluster(name string) *v1alpha1.Cluster {
+	key := testKey(name)
+
+	return &v1alpha1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:		key.Name,
+			Namespace:	key.Namespace,
+			Labels: map[string]string{
+				"app": name,
+			},
+		},
+		Spec: v1alpha1.ClusterSpec{
+			Image:		redpandaContainerImage,
+			Version:	redpandaContainerTag,
+			Replicas:	pointer.Int32Ptr(replicas),
+			Configuration: v1alpha1.RedpandaConfig{
+				KafkaAPI: v1alpha1.SocketAddress{Port: kafkaPort},
+			},
+		},
+	}
+}
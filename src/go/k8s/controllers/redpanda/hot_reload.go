@@ -0,0 +1,100 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	"github.com/vectorizedio/redpanda/src/go/k8s/pkg/admin"
+	"gopkg.in/yaml.v3"
+)
+
+// configHashAnnotation is the pod template annotation holding the digest
+// of the redpanda.yaml properties which require a restart, set when the
+// hot reload is enabled and such a property changed
+const configHashAnnotation = "redpanda.vectorized.io/config-hash"
+
+// reloadableProperties returns the cluster properties of the schema that
+// redpanda applies at runtime when set through the Admin API
+func reloadableProperties(schema map[string]admin.ConfigProperty) map[string]bool {
+	reloadable := make(map[string]bool, len(schema))
+
+	for name, property := range schema {
+		if !property.NeedsRestart {
+			reloadable[name] = true
+		}
+	}
+
+	return reloadable
+}
+
+// splitConfig returns the reloadable properties of the redpanda section of
+// the redpanda.yaml, and the digest of the rest of it, which requires a
+// restart
+func splitConfig(data string, reloadable map[string]bool) (map[string]interface{}, string, error) {
+	var cfg map[string]interface{}
+	if err := yaml.Unmarshal([]byte(data), &cfg); err != nil {
+		return nil, "", err
+	}
+
+	hot := make(map[string]interface{})
+
+	if section, ok := cfg[redpandaSection].(map[string]interface{}); ok {
+		for k, v := range section {
+			if reloadable[k] {
+				hot[k] = v
+				delete(section, k)
+			}
+		}
+	}
+
+	// The keys of the maps are sorted, so the digest is stable
+	restart, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return hot, fmt.Sprintf("%x", sha256.Sum256(restart)), nil
+}
+
+// renderedConfig returns the redpanda.yaml of the base ConfigMap
+func (r *ClusterReconciler) renderedConfig(
+	ctx context.Context, cluster *redpandav1alpha1.Cluster,
+) (string, error) {
+	fragment, err := r.userConfig(ctx, cluster)
+	if err != nil {
+		return "", err
+	}
+
+	cm, err := buildConfigMap(cluster, fragment)
+	if err != nil {
+		return "", err
+	}
+
+	return cm.Data[configFile], nil
+}
+
+// sameValue compares a property reported by the Admin API with a property
+// of the redpanda.yaml through their JSON encoding, as the Admin API
+// numbers are decoded as floats
+func sameValue(current, desired interface{}) bool {
+	c, err := json.Marshal(current)
+	if err != nil {
+		return false
+	}
+
+	d, err := json.Marshal(desired)
+
+	return err == nil && string(c) == string(d)
+}
@@ -0,0 +1,133 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	kafkaTLSVolume	= "kafka-tls"
+	kafkaTLSDir	= "/etc/tls/certs/kafka"
+
+	// Reasons of the Degraded condition set by the kafka certificate check
+	reasonKafkaCertificateNames	= "KafkaCertificateNamesMismatch"
+	reasonKafkaCertificateValid	= "KafkaCertificateValid"
+)
+
+// kafkaAPITLS returns the kafka_api_tls configuration, or nil when the
+// kafka API TLS is disabled
+func kafkaAPITLS(cluster *redpandav1alpha1.Cluster) map[string]interface{} {
+	if kafkaCertSecretName(cluster) == "" {
+		return nil
+	}
+
+	return map[string]interface{}{
+		"enabled":		true,
+		"require_client_auth":	cluster.Spec.Configuration.KafkaAPITLS.RequireClientAuth,
+		"cert_file":		filepath.Join(kafkaTLSDir, corev1.TLSCertKey),
+		"key_file":		filepath.Join(kafkaTLSDir, corev1.TLSPrivateKeyKey),
+		"truststore_file":	filepath.Join(kafkaTLSDir, caCertKey),
+	}
+}
+
+// kafkaCertSecretName returns the name of the Secret holding the kafka API
+// certificate, or an empty string when the kafka API TLS is disabled. A
+// user certificate takes precedence over the self-signed one.
+func kafkaCertSecretName(cluster *redpandav1alpha1.Cluster) string {
+	kafkaTLS := cluster.Spec.Configuration.KafkaAPITLS
+	if !kafkaTLS.Enabled {
+		return ""
+	}
+
+	if kafkaTLS.CertSecretRef != nil {
+		return kafkaTLS.CertSecretRef.Name
+	}
+
+	if cluster.Spec.TLS.SelfSigned {
+		return cluster.Name + selfSignedTLSSuffix
+	}
+
+	return ""
+}
+
+// advertisedKafkaAddresses returns the kafka API address advertised by
+// every broker, indexed by ordinal. The brokers advertising the IP address
+// of their node or pod are left out, as it is only known once they are
+// scheduled.
+func advertisedKafkaAddresses(cluster *redpandav1alpha1.Cluster) []string {
+	if cluster.Spec.Configuration.AdvertisePodIP {
+		return nil
+	}
+
+	var replicas int32
+	if cluster.Spec.Replicas != nil {
+		replicas = *cluster.Spec.Replicas
+	}
+
+	external := cluster.Spec.ExternalConnectivity
+
+	var addresses []string
+
+	for i := int32(0); i < replicas; i++ {
+		switch {
+		case int(i) < len(external.PerBrokerAddresses):
+			addresses = append(addresses, external.PerBrokerAddresses[i])
+		case external.PerBrokerNodePorts == nil:
+			addresses = append(addresses, fmt.Sprintf("%s-%d.%s", cluster.Name, i, serviceAddress(cluster)))
+		}
+	}
+
+	// The brokers of the groups advertise their headless service name
+	for _, name := range brokerPodNames(cluster)[replicas:] {
+		addresses = append(addresses, name+"."+serviceAddress(cluster))
+	}
+
+	return addresses
+}
+
+// reconcileKafkaCertificate reports the Cluster as degraded when the kafka
+// API certificate does not cover the address advertised by every broker,
+// as the clients would then fail to verify them
+func (r *ClusterReconciler) reconcileKafkaCertificate(
+	ctx context.Context,
+	cluster *redpandav1alpha1.Cluster,
+	status *redpandav1alpha1.ClusterStatus,
+) error {
+	name := kafkaCertSecretName(cluster)
+	if name == "" {
+		clearDegraded(status, reasonKafkaCertificateValid, reasonKafkaCertificateNames)
+
+		return nil
+	}
+
+	cert, err := r.fetchCertificate(ctx, cluster.Namespace, name, "kafka API")
+	if err != nil {
+		return err
+	}
+
+	for _, address := range advertisedKafkaAddresses(cluster) {
+		if err := cert.VerifyHostname(address); err != nil {
+			setDegraded(status, reasonKafkaCertificateNames,
+				fmt.Sprintf("The kafka API certificate of secret %s does not cover the advertised address %s", name, address))
+
+			return nil
+		}
+	}
+
+	clearDegraded(status, reasonKafkaCertificateValid, reasonKafkaCertificateNames)
+
+	return nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Redpanda kafka API TLS", func() {
+	Context("When the kafka API TLS is enabled", func() {
+		It("Should render the TLS configuration and mount the certificate", func() {
+			key := testKey("redpanda-kafka-tls")
+			createRPCCertificate(key.Name+"-kafka", "*."+key.Name+".default.svc.cluster.local")
+
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.Configuration.KafkaAPITLS = v1alpha1.KafkaAPITLS{
+				Enabled:	true,
+				CertSecretRef:	&corev1.LocalObjectReference{Name: key.Name + "-kafka"},
+			}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			cfg := eventuallyRedpandaConfig(key)
+			Expect(cfg).Should(HaveKeyWithValue("kafka_api_tls", And(
+				HaveKeyWithValue("enabled", true),
+				HaveKeyWithValue("require_client_auth", false),
+				HaveKeyWithValue("cert_file", "/etc/tls/certs/kafka/tls.crt"),
+				HaveKeyWithValue("key_file", "/etc/tls/certs/kafka/tls.key"),
+				HaveKeyWithValue("truststore_file", "/etc/tls/certs/kafka/ca.crt"),
+			)))
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+			Expect(sts.Spec.Template.Spec.Containers[0].VolumeMounts).Should(ContainElement(corev1.VolumeMount{
+				Name:		"kafka-tls",
+				MountPath:	"/etc/tls/certs/kafka",
+				ReadOnly:	true,
+			}))
+			Eventually(func() metav1.ConditionStatus {
+				return clusterCondition(key, v1alpha1.ClusterDegraded)
+			}, timeout, interval).Should(Equal(metav1.ConditionFalse))
+		})
+
+		It("Should mount the certificate when enabled on an existing Cluster", func() {
+			key := testKey("redpanda-kafka-tls-existing")
+			createRPCCertificate(key.Name+"-kafka", "*."+key.Name+".default.svc.cluster.local")
+			redpandaCluster := testCluster(key.Name)
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &appsv1.StatefulSet{})
+			}, timeout, interval).Should(Succeed())
+			Expect(statefulSetVolumeNames(key)).ShouldNot(ContainElement("kafka-tls"))
+
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), key, redpandaCluster); err != nil {
+					return err
+				}
+				redpandaCluster.Spec.Configuration.KafkaAPITLS = v1alpha1.KafkaAPITLS{
+					Enabled:	true,
+					CertSecretRef:	&corev1.LocalObjectReference{Name: key.Name + "-kafka"},
+				}
+				return k8sClient.Update(context.Background(), redpandaCluster)
+			}, timeout, interval).Should(Succeed())
+			Eventually(func() []corev1.VolumeMount {
+				return redpandaVolumeMounts(key)
+			}, timeout, interval).Should(ContainElement(corev1.VolumeMount{
+				Name:		"kafka-tls",
+				MountPath:	"/etc/tls/certs/kafka",
+				ReadOnly:	true,
+			}))
+			Expect(statefulSetVolumeNames(key)).Should(ContainElement("kafka-tls"))
+		})
+
+		It("Should report a certificate not covering the advertised addresses", func() {
+			key := testKey("redpanda-kafka-tls-names")
+			createRPCCertificate(key.Name+"-kafka", "*."+key.Name+".default.svc.cluster.local")
+
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.ExternalConnectivity.PerBrokerAddresses = []string{"kafka-0.example.com"}
+			redpandaCluster.Spec.Configuration.KafkaAPITLS = v1alpha1.KafkaAPITLS{
+				Enabled:	true,
+				CertSecretRef:	&corev1.LocalObjectReference{Name: key.Name + "-kafka"},
+			}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			Eventually(func() string {
+				return clusterConditionReason(key, v1alpha1.ClusterDegraded)
+			}, timeout, interval).Should(Equal("KafkaCertificateNamesMismatch"))
+
+			By("Clearing the condition once they are covered")
+			updateCluster(key, func(cluster *v1alpha1.Cluster) {
+				cluster.Spec.ExternalConnectivity.PerBrokerAddresses = nil
+			})
+			Eventually(func() metav1.ConditionStatus {
+				return clusterCondition(key, v1alpha1.ClusterDegraded)
+			}, timeout, interval).Should(Equal(metav1.ConditionFalse))
+		})
+	})
+})
@@ -0,0 +1,278 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// legacyPollInterval is how often the legacy StatefulSet is checked
+	// while it holds the reconciliation
+	legacyPollInterval	= 5 * time.Second
+
+	reasonLegacyStatefulSetName	= "LegacyStatefulSetName"
+	reasonLegacyStatefulSetAdopted	= "LegacyStatefulSetAdopted"
+	reasonServiceNameMismatch	= "StatefulSetServiceNameMismatch"
+	reasonServiceNameMatches	= "StatefulSetServiceNameMatches"
+)
+
+// migrateLegacyResources adopts the resources created for the Cluster by an
+// older operator version, named after the legacy name annotation. The
+// Services and the ConfigMap are relabeled and owned by the Cluster, so
+// that the clients of their names keep working until the Cluster is
+// deleted. The StatefulSet is recreated when its selector does not match
+// the Cluster labels, the selector being immutable. It returns
+// true while the legacy StatefulSet holds the reconciliation, either while
+// it is deleted or when it can not be adopted.
+func (r *ClusterReconciler) migrateLegacyResources(
+	ctx context.Context,
+	cluster *redpandav1alpha1.Cluster,
+	status *redpandav1alpha1.ClusterStatus,
+) (bool, error) {
+	clearDegraded(status, reasonLegacyStatefulSetAdopted, reasonLegacyStatefulSetName)
+
+	legacyName := cluster.Annotations[redpandav1alpha1.LegacyNameAnnotation]
+	if legacyName == "" {
+		return false, nil
+	}
+
+	if legacyName != cluster.Name {
+		for _, name := range []string{legacyName, legacyName + adminSuffix, legacyName + externalSuffix} {
+			if err := r.adoptLegacy(ctx, cluster, name, &corev1.Service{}); err != nil {
+				return false, err
+			}
+		}
+
+		if err := r.adoptLegacy(ctx, cluster, legacyName+baseSuffix, &corev1.ConfigMap{}); err != nil {
+			return false, err
+		}
+	}
+
+	var sts appsv1.StatefulSet
+
+	err := r.Get(ctx, types.NamespacedName{Name: legacyName, Namespace: cluster.Namespace}, &sts)
+	if apierrors.IsNotFound(err) || (err == nil && metav1.IsControlledBy(&sts, cluster)) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	// The claims are named after the StatefulSet, a renamed StatefulSet
+	// would start the brokers on new empty volumes, and creating it would
+	// start a second set of brokers
+	if legacyName != cluster.Name {
+		setDegraded(status, reasonLegacyStatefulSetName,
+			fmt.Sprintf("The legacy StatefulSet %s can not be renamed to %s without losing its data volumes",
+				legacyName, cluster.Name))
+
+		return true, nil
+	}
+
+	if sts.DeletionTimestamp != nil {
+		return true, nil
+	}
+
+	// A selector of the Cluster labels only, as set before the broker
+	// groups, still selects the brokers of the StatefulSet
+	if reflect.DeepEqual(sts.Spec.Selector, statefulSetSelector(cluster)) ||
+		reflect.DeepEqual(sts.Spec.Selector, metav1.SetAsLabelSelector(cluster.Labels)) {
+		return false, nil
+	}
+
+	return true, r.recreateStatefulSet(ctx, cluster, &sts)
+}
+
+// recreateStatefulSet deletes the StatefulSet without its pods, so that it
+// is created again with its immutable fields set to their desired value.
+// The pods and claims are relabeled first, the new StatefulSet then adopts
+// the running brokers and rejoins the cluster stored on their volumes.
+func (r *ClusterReconciler) recreateStatefulSet(
+	ctx context.Context, cluster *redpandav1alpha1.Cluster, sts *appsv1.StatefulSet,
+) error {
+	selector, err := metav1.LabelSelectorAsSelector(sts.Spec.Selector)
+	if err != nil {
+		return err
+	}
+
+	var pods corev1.PodList
+
+	err = r.List(ctx, &pods, &client.ListOptions{LabelSelector: selector, Namespace: cluster.Namespace})
+	if err != nil {
+		return err
+	}
+
+	for i := range pods.Items {
+		if err = r.relabel(ctx, cluster, &pods.Items[i]); err != nil {
+			return err
+		}
+	}
+
+	// The claims are labeled after their template, not the selector, and
+	// are found by name
+	var pvcs corev1.PersistentVolumeClaimList
+	if err = r.List(ctx, &pvcs, client.InNamespace(cluster.Namespace)); err != nil {
+		return err
+	}
+
+	for i := range pvcs.Items {
+		if !claimOf(sts, pvcs.Items[i].Name) {
+			continue
+		}
+
+		if err = r.relabel(ctx, cluster, &pvcs.Items[i]); err != nil {
+			return err
+		}
+	}
+
+	r.Log.Info("Recreating the StatefulSet", "StatefulSet.Namespace", sts.Namespace, "StatefulSet.Name", sts.Name)
+
+	err = r.Delete(ctx, sts, client.PropagationPolicy(metav1.DeletePropagationOrphan))
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	return nil
+}
+
+// adoptLegacy relabels a legacy resource and makes the Cluster its
+// controller, the resources controlled by another owner are left untouched
+func (r *ClusterReconciler) adoptLegacy(
+	ctx context.Context, cluster *redpandav1alpha1.Cluster, name string, obj client.Object,
+) error {
+	err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: cluster.Namespace}, obj)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if metav1.GetControllerOf(obj) != nil && !metav1.IsControlledBy(obj, cluster) {
+		r.Log.Info("Not adopting the legacy resource controlled by another owner",
+			"Namespace", cluster.Namespace, "Name", name)
+
+		return nil
+	}
+
+	if metav1.IsControlledBy(obj, cluster) && hasLabels(obj, cluster.Labels) {
+		return nil
+	}
+
+	if err = controllerutil.SetControllerReference(cluster, obj, r.Scheme); err != nil {
+		return err
+	}
+
+	addLabels(obj, cluster.Labels)
+
+	return r.Update(ctx, obj)
+}
+
+// relabel adds the labels of the Cluster to a legacy resource
+func (r *ClusterReconciler) relabel(
+	ctx context.Context, cluster *redpandav1alpha1.Cluster, obj client.Object,
+) error {
+	if hasLabels(obj, cluster.Labels) {
+		return nil
+	}
+
+	addLabels(obj, cluster.Labels)
+
+	return r.Update(ctx, obj)
+}
+
+func addLabels(obj client.Object, add map[string]string) {
+	objLabels := obj.GetLabels()
+	if objLabels == nil {
+		objLabels = make(map[string]string, len(add))
+	}
+
+	for k, v := range add {
+		objLabels[k] = v
+	}
+
+	obj.SetLabels(objLabels)
+}
+
+// claimOf reports whether the claim was created from one of the volume
+// claim templates of the StatefulSet, whatever the ordinal
+func claimOf(sts *appsv1.StatefulSet, claim string) bool {
+	for i := range sts.Spec.VolumeClaimTemplates {
+		if strings.HasPrefix(claim, sts.Spec.VolumeClaimTemplates[i].Name+"-"+sts.Name+"-") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasLabels(obj client.Object, want map[string]string) bool {
+	for k, v := range want {
+		if obj.GetLabels()[k] != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// reconcileServiceName detects a StatefulSet governed by another service
+// than the headless one, e.g. after it was adopted. The serviceName being
+// immutable, the StatefulSet is only recreated when the Cluster opts in
+// with the recreate annotation, otherwise the Cluster is reported as
+// degraded. It returns true while the StatefulSet is being recreated.
+func (r *ClusterReconciler) reconcileServiceName(
+	ctx context.Context,
+	cluster *redpandav1alpha1.Cluster,
+	status *redpandav1alpha1.ClusterStatus,
+) (bool, error) {
+	var sts appsv1.StatefulSet
+
+	err := r.Get(ctx, types.NamespacedName{Name: cluster.Name, Namespace: cluster.Namespace}, &sts)
+	if apierrors.IsNotFound(err) || (err == nil && sts.Spec.ServiceName == cluster.Name) {
+		clearDegraded(status, reasonServiceNameMatches, reasonServiceNameMismatch)
+
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	if sts.DeletionTimestamp != nil {
+		return true, nil
+	}
+
+	if cluster.Annotations[redpandav1alpha1.RecreateStatefulSetAnnotation] != "true" {
+		setDegraded(status, reasonServiceNameMismatch,
+			fmt.Sprintf("StatefulSet %s is governed by service %s instead of %s, set the %s annotation to true to recreate it",
+				sts.Name, sts.Spec.ServiceName, cluster.Name, redpandav1alpha1.RecreateStatefulSetAnnotation))
+
+		return false, nil
+	}
+
+	return true, r.recreateStatefulSet(ctx, cluster, &sts)
+}
@@ -0,0 +1,238 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+)
+
+var _ = Describe("Redpanda legacy resources", func() {
+	Context("When the legacy resources have another name", func() {
+		It("Should adopt the Services and the ConfigMap", func() {
+			key := testKey("redpanda-legacy-adopt")
+			legacyLabels := map[string]string{"app": "redpanda-legacy-old"}
+
+			legacySvc := &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:		"redpanda-legacy-old",
+					Namespace:	key.Namespace,
+					Labels:		legacyLabels,
+				},
+				Spec: corev1.ServiceSpec{
+					Selector:	legacyLabels,
+					Ports:		[]corev1.ServicePort{{Name: "kafka-tcp", Port: 9092}},
+				},
+			}
+			Expect(k8sClient.Create(context.Background(), legacySvc)).Should(Succeed())
+
+			legacyCM := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:		"redpanda-legacy-old-base",
+					Namespace:	key.Namespace,
+					Labels:		legacyLabels,
+				},
+			}
+			Expect(k8sClient.Create(context.Background(), legacyCM)).Should(Succeed())
+
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Annotations = map[string]string{v1alpha1.LegacyNameAnnotation: "redpanda-legacy-old"}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			Eventually(func() bool {
+				var svc corev1.Service
+				if err := k8sClient.Get(context.Background(), testKey(legacySvc.Name), &svc); err != nil {
+					return false
+				}
+				return validOwner(redpandaCluster, svc.OwnerReferences) && svc.Labels["app"] == key.Name
+			}, timeout, interval).Should(BeTrue())
+
+			Eventually(func() bool {
+				var cm corev1.ConfigMap
+				if err := k8sClient.Get(context.Background(), testKey(legacyCM.Name), &cm); err != nil {
+					return false
+				}
+				return validOwner(redpandaCluster, cm.OwnerReferences) && cm.Labels["app"] == key.Name
+			}, timeout, interval).Should(BeTrue())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+		})
+
+		It("Should not rename the StatefulSet", func() {
+			key := testKey("redpanda-legacy-rename")
+			Expect(k8sClient.Create(context.Background(),
+				legacyStatefulSet(testKey("redpanda-legacy-renamed"), map[string]string{"app": "redpanda-legacy-renamed"}),
+			)).Should(Succeed())
+
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Annotations = map[string]string{v1alpha1.LegacyNameAnnotation: "redpanda-legacy-renamed"}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			Eventually(func() string {
+				return clusterConditionReason(key, v1alpha1.ClusterDegraded)
+			}, timeout, interval).Should(Equal("LegacyStatefulSetName"))
+			Expect(errors.IsNotFound(k8sClient.Get(context.Background(), key, &appsv1.StatefulSet{}))).Should(BeTrue())
+		})
+	})
+
+	Context("When the legacy StatefulSet has another selector", func() {
+		It("Should recreate it and keep the data volumes", func() {
+			key := testKey("redpanda-legacy-selector")
+			legacyLabels := map[string]string{"app.kubernetes.io/name": "redpanda-legacy"}
+			Expect(k8sClient.Create(context.Background(), legacyStatefulSet(key, legacyLabels))).Should(Succeed())
+
+			pvc := &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:		"datadir-" + key.Name + "-0",
+					Namespace:	key.Namespace,
+				},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					AccessModes:	[]corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+					},
+				},
+			}
+			Expect(k8sClient.Create(context.Background(), pvc)).Should(Succeed())
+
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Annotations = map[string]string{v1alpha1.LegacyNameAnnotation: key.Name}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			Eventually(func() string {
+				var claim corev1.PersistentVolumeClaim
+				if err := k8sClient.Get(context.Background(), testKey(pvc.Name), &claim); err != nil {
+					return ""
+				}
+				return claim.Labels["app"]
+			}, timeout, interval).Should(Equal(key.Name))
+
+			By("Removing the orphan finalizer, like the garbage collector")
+			Eventually(func() error {
+				var sts appsv1.StatefulSet
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return err
+				}
+				if sts.DeletionTimestamp == nil {
+					return errors.NewConflict(appsv1.Resource("statefulsets"), key.Name, nil)
+				}
+				sts.Finalizers = nil
+				return k8sClient.Update(context.Background(), &sts)
+			}, timeout, interval).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() bool {
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return false
+				}
+				return validOwner(redpandaCluster, sts.OwnerReferences) &&
+					sts.Spec.Selector.MatchLabels["app"] == key.Name
+			}, timeout, interval).Should(BeTrue())
+			Expect(sts.Spec.Template.Spec.InitContainers[0].Env).Should(ContainElement(corev1.EnvVar{
+				Name:	"CONFIGURATOR_MODE",
+				Value:	"rejoin",
+			}))
+		})
+	})
+
+	Context("When the StatefulSet is governed by another service", func() {
+		It("Should only recreate it once annotated", func() {
+			key := testKey("redpanda-service-name")
+			sts := legacyStatefulSet(key, map[string]string{"app": key.Name})
+			sts.Spec.ServiceName = "redpanda-headless"
+			Expect(k8sClient.Create(context.Background(), sts)).Should(Succeed())
+
+			redpandaCluster := testCluster(key.Name)
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			Eventually(func() string {
+				return clusterConditionReason(key, v1alpha1.ClusterDegraded)
+			}, timeout, interval).Should(Equal("StatefulSetServiceNameMismatch"))
+			Consistently(func() bool {
+				var current appsv1.StatefulSet
+				return k8sClient.Get(context.Background(), key, &current) == nil && current.DeletionTimestamp == nil
+			}, "1s", interval).Should(BeTrue())
+
+			updateCluster(key, func(cluster *v1alpha1.Cluster) {
+				cluster.Annotations = map[string]string{v1alpha1.RecreateStatefulSetAnnotation: "true"}
+			})
+
+			By("Removing the orphan finalizer, like the garbage collector")
+			Eventually(func() error {
+				var current appsv1.StatefulSet
+				if err := k8sClient.Get(context.Background(), key, &current); err != nil {
+					return err
+				}
+				if current.DeletionTimestamp == nil {
+					return errors.NewConflict(appsv1.Resource("statefulsets"), key.Name, nil)
+				}
+				current.Finalizers = nil
+				return k8sClient.Update(context.Background(), &current)
+			}, timeout, interval).Should(Succeed())
+
+			Eventually(func() string {
+				var current appsv1.StatefulSet
+				if err := k8sClient.Get(context.Background(), key, &current); err != nil {
+					return ""
+				}
+				return current.Spec.ServiceName
+			}, timeout, interval).Should(Equal(key.Name))
+			Eventually(func() string {
+				return clusterConditionReason(key, v1alpha1.ClusterDegraded)
+			}, timeout, interval).ShouldNot(Equal("StatefulSetServiceNameMismatch"))
+		})
+	})
+})
+
+// legacyStatefulSet returns a StatefulSet created by an older operator
+// version, without owner and with its own labels
+func legacyStatefulSet(key types.NamespacedName, labels map[string]string) *appsv1.StatefulSet {
+	return &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:		key.Name,
+			Namespace:	key.Namespace,
+			Labels:		labels,
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas:	pointer.Int32Ptr(1),
+			Selector:	metav1.SetAsLabelSelector(labels),
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta:	metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "redpanda", Image: "vectorized/redpanda:v21.4.1"}},
+				},
+			},
+			VolumeClaimTemplates: []corev1.PersistentVolumeClaim{
+				{
+					ObjectMeta:	metav1.ObjectMeta{Name: "datadir"},
+					Spec: corev1.PersistentVolumeClaimSpec{
+						AccessModes:	[]corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+						},
+					},
+				},
+			},
+		},
+	}
+}
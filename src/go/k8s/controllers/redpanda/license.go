@@ -0,0 +1,108 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// licenseKey is the Secret key holding the enterprise license
+const licenseKey = "license"
+
+var errMissingLicense = errors.New("secret has no " + licenseKey + " key")
+
+// reconcileLicense loads the license of the referenced Secret when the
+// cluster does not run it yet, and reports the loaded license in the
+// status. Like the cluster properties it waits for every broker to be
+// ready.
+func (r *ClusterReconciler) reconcileLicense(
+	ctx context.Context,
+	cluster *redpandav1alpha1.Cluster,
+	sts *appsv1.StatefulSet,
+	adminAPIConfig *AdminAPIConfig,
+	status *redpandav1alpha1.ClusterStatus,
+) error {
+	ref := cluster.Spec.LicenseSecretRef
+	if ref == nil {
+		status.License = nil
+
+		return nil
+	}
+
+	if cluster.Spec.Replicas == nil || sts.Status.ReadyReplicas == 0 ||
+		sts.Status.ReadyReplicas < *cluster.Spec.Replicas {
+		return nil
+	}
+
+	var secret corev1.Secret
+
+	err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: cluster.Namespace}, &secret)
+	if err != nil {
+		return fmt.Errorf("unable to fetch license secret %s/%s: %w", cluster.Namespace, ref.Name, err)
+	}
+
+	license := bytes.TrimSpace(secret.Data[licenseKey])
+	if len(license) == 0 {
+		return fmt.Errorf("invalid license secret %s/%s: %w", cluster.Namespace, ref.Name, errMissingLicense)
+	}
+
+	adminAPI, err := r.adminAPIClient(cluster, adminAPIConfig)
+	if err != nil {
+		return err
+	}
+
+	current, err := adminAPI.License(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !current.Loaded || current.Properties.Checksum != fmt.Sprintf("%x", sha256.Sum256(license)) {
+		if err = adminAPI.SetLicense(ctx, license); err != nil {
+			return err
+		}
+
+		if current, err = adminAPI.License(ctx); err != nil {
+			return err
+		}
+	}
+
+	expires := time.Unix(current.Properties.Expires, 0)
+	status.License = &redpandav1alpha1.LicenseStatus{
+		Organization:	current.Properties.Organization,
+		Type:		current.Properties.Type,
+		Expires:	metav1.NewTime(expires),
+		Valid:		current.Loaded && time.Now().Before(expires),
+	}
+
+	return nil
+}
+
+// watchedSecrets returns the names of the Secrets whose changes trigger a
+// reconciliation. Unlike the referenced Secrets, a new license does not
+// require the brokers to restart.
+func watchedSecrets(cluster *redpandav1alpha1.Cluster) []string {
+	names := referencedSecrets(cluster)
+	if ref := cluster.Spec.LicenseSecretRef; ref != nil {
+		names = append(names, ref.Name)
+	}
+
+	return names
+}
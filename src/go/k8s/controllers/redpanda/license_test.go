@@ -0,0 +1,88 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Redpanda enterprise license", func() {
+	Context("When a license Secret is referenced", func() {
+		It("Should load the license and report it in the status", func() {
+			key := testKey("redpanda-license")
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:		key.Name + "-license",
+					Namespace:	key.Namespace,
+				},
+				Data:	map[string][]byte{"license": []byte("first-license\n")},
+			}
+			Expect(k8sClient.Create(context.Background(), secret)).Should(Succeed())
+
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.LicenseSecretRef = &corev1.LocalObjectReference{Name: secret.Name}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+			setReadyReplicas(key, 1)
+
+			Eventually(func() string {
+				return testAdminAPIs.get(key.Name).loadedLicense()
+			}, timeout, interval).Should(Equal("first-license"))
+			Eventually(func() *v1alpha1.LicenseStatus {
+				if err := k8sClient.Get(context.Background(), key, redpandaCluster); err != nil {
+					return nil
+				}
+				return redpandaCluster.Status.License
+			}, timeout, interval).ShouldNot(BeNil())
+			Expect(redpandaCluster.Status.License.Organization).Should(Equal("vectorized"))
+			Expect(redpandaCluster.Status.License.Valid).Should(BeTrue())
+			Expect(redpandaCluster.Status.License.Expires.Time.Equal(testLicenseExpiry)).Should(BeTrue())
+
+			By("Loading the license again when the Secret changes")
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), types.NamespacedName{Name: secret.Name, Namespace: key.Namespace}, secret); err != nil {
+					return err
+				}
+				secret.Data["license"] = []byte("second-license")
+				return k8sClient.Update(context.Background(), secret)
+			}, timeout, interval).Should(Succeed())
+			Eventually(func() string {
+				return testAdminAPIs.get(key.Name).loadedLicense()
+			}, timeout, interval).Should(Equal("second-license"))
+		})
+	})
+
+	Context("When no license Secret is referenced", func() {
+		It("Should run the community edition", func() {
+			key := testKey("redpanda-community")
+			Expect(k8sClient.Create(context.Background(), testCluster(key.Name))).Should(Succeed())
+			setReadyReplicas(key, 1)
+
+			Eventually(func() []v1alpha1.BrokerStatus {
+				var redpandaCluster v1alpha1.Cluster
+				if err := k8sClient.Get(context.Background(), key, &redpandaCluster); err != nil {
+					return nil
+				}
+				return redpandaCluster.Status.Brokers
+			}, timeout, interval).ShouldNot(BeEmpty())
+
+			var redpandaCluster v1alpha1.Cluster
+			Expect(k8sClient.Get(context.Background(), key, &redpandaCluster)).Should(Succeed())
+			Expect(redpandaCluster.Status.License).Should(BeNil())
+			Expect(testAdminAPIs.get(key.Name).loadedLicense()).Should(BeEmpty())
+		})
+	})
+})
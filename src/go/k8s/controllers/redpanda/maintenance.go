@@ -0,0 +1,243 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	"context"
+	"fmt"
+
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	"github.com/vectorizedio/redpanda/src/go/k8s/pkg/admin"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+)
+
+// holdRollout makes the StatefulSet keep every broker on its current pod
+// template, they are then released one at a time by reconcileMaintenance
+func holdRollout(sts *appsv1.StatefulSet, replicas int32) {
+	if sts.Spec.UpdateStrategy.RollingUpdate == nil {
+		sts.Spec.UpdateStrategy.RollingUpdate = &appsv1.RollingUpdateStatefulSetStrategy{}
+	}
+
+	sts.Spec.UpdateStrategy.RollingUpdate.Partition = pointer.Int32Ptr(replicas)
+}
+
+// rolloutPartition returns the lowest ordinal the StatefulSet restarts on
+// a new pod template
+func rolloutPartition(sts *appsv1.StatefulSet) int32 {
+	if ru := sts.Spec.UpdateStrategy.RollingUpdate; ru != nil && ru.Partition != nil {
+		return *ru.Partition
+	}
+
+	return 0
+}
+
+// reconcileMaintenance releases the brokers held by the rollout one at a
+// time, from the highest ordinal down like the StatefulSet controller. A
+// broker is put in maintenance mode once every broker is ready, released
+// once it transferred the leadership of its partitions, and taken out of
+// maintenance mode once it runs the new pod template and is ready again.
+// Without the maintenance mode, a rollout held by a previous Cluster
+// definition is released at once.
+func (r *ClusterReconciler) reconcileMaintenance(
+	ctx context.Context,
+	cluster *redpandav1alpha1.Cluster,
+	sts *appsv1.StatefulSet,
+	pods []corev1.Pod,
+	adminAPIConfig *AdminAPIConfig,
+	status *redpandav1alpha1.ClusterStatus,
+) error {
+	partition := rolloutPartition(sts)
+
+	if !cluster.Spec.MaintenanceMode.Enabled {
+		if status.Maintenance != nil {
+			if err := r.disableMaintenanceMode(ctx, cluster, sts, adminAPIConfig, status); err != nil {
+				return err
+			}
+		}
+
+		if partition == 0 {
+			return nil
+		}
+
+		sts.Spec.UpdateStrategy.RollingUpdate.Partition = pointer.Int32Ptr(0)
+
+		return r.Update(ctx, sts)
+	}
+
+	// The revisions of the pods are only comparable once the StatefulSet
+	// controller observed the latest pod template
+	if sts.Status.ObservedGeneration < sts.Generation {
+		return nil
+	}
+
+	var replicas int32
+	if sts.Spec.Replicas != nil {
+		replicas = *sts.Spec.Replicas
+	}
+
+	if m := status.Maintenance; m != nil {
+		if int32(m.NodeID) >= replicas {
+			// The broker was decommissioned in the meantime
+			status.Maintenance = nil
+
+			return nil
+		}
+
+		pod := findPod(pods, fmt.Sprintf("%s-%d", sts.Name, m.NodeID))
+
+		switch {
+		case m.Restarting:
+			// A new rollout may hold the broker again before it restarted,
+			// it is then only waited for to be ready
+			updated := partition > int32(m.NodeID) ||
+				(pod != nil && pod.Labels[appsv1.ControllerRevisionHashLabelKey] == sts.Status.UpdateRevision)
+			if pod == nil || !updated || !podReady(pod) {
+				return nil
+			}
+
+			return r.disableMaintenanceMode(ctx, cluster, sts, adminAPIConfig, status)
+		case partition == int32(m.NodeID)+1:
+			drained, err := r.leadershipTransferred(ctx, cluster, adminAPIConfig, replicaNodeID(cluster, sts, m.NodeID))
+			if err != nil || !drained {
+				return err
+			}
+
+			m.Restarting = true
+			sts.Spec.UpdateStrategy.RollingUpdate.Partition = pointer.Int32Ptr(int32(m.NodeID))
+
+			return r.Update(ctx, sts)
+		default:
+			// The broker is no longer the next one to restart
+			return r.disableMaintenanceMode(ctx, cluster, sts, adminAPIConfig, status)
+		}
+	}
+
+	next := partition - 1
+	if replicas < partition {
+		next = replicas - 1
+	}
+
+	if next < 0 {
+		return nil
+	}
+
+	pod := findPod(pods, fmt.Sprintf("%s-%d", sts.Name, next))
+	if pod == nil {
+		return nil
+	}
+
+	// The brokers created by the rollout already run the new pod template
+	if pod.Labels[appsv1.ControllerRevisionHashLabelKey] == sts.Status.UpdateRevision {
+		sts.Spec.UpdateStrategy.RollingUpdate.Partition = pointer.Int32Ptr(next)
+
+		return r.Update(ctx, sts)
+	}
+
+	// A broker is only restarted while the others can take over its
+	// leadership
+	if sts.Status.ReadyReplicas < replicas || r.quorumLost(status) {
+		return nil
+	}
+
+	adminAPI, err := r.adminAPIClient(cluster, adminAPIConfig)
+	if err != nil {
+		return err
+	}
+
+	nodeID := replicaNodeID(cluster, sts, int(next))
+	if err = adminAPI.EnableMaintenanceMode(ctx, nodeID); err != nil {
+		return err
+	}
+
+	r.Log.Info("Broker put in maintenance mode before its restart", "NodeID", nodeID)
+	status.Maintenance = &redpandav1alpha1.MaintenanceStatus{NodeID: int(next), StartTime: metav1.Now()}
+
+	return nil
+}
+
+// leadershipTransferred reports whether the broker in maintenance mode
+// can be restarted. A broker failing to transfer some leadership is
+// restarted anyway, like without the maintenance mode.
+func (r *ClusterReconciler) leadershipTransferred(
+	ctx context.Context,
+	cluster *redpandav1alpha1.Cluster,
+	adminAPIConfig *AdminAPIConfig,
+	nodeID int,
+) (bool, error) {
+	adminAPI, err := r.adminAPIClient(cluster, adminAPIConfig)
+	if err != nil {
+		return false, err
+	}
+
+	brokers, err := adminAPI.Brokers(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	for _, b := range brokers {
+		if b.NodeID == nodeID {
+			return maintenanceDone(b.Maintenance), nil
+		}
+	}
+
+	return false, nil
+}
+
+func maintenanceDone(m *admin.MaintenanceStatus) bool {
+	return m != nil && (m.Finished || m.Errors)
+}
+
+// disableMaintenanceMode takes the tracked broker out of maintenance mode
+func (r *ClusterReconciler) disableMaintenanceMode(
+	ctx context.Context,
+	cluster *redpandav1alpha1.Cluster,
+	sts *appsv1.StatefulSet,
+	adminAPIConfig *AdminAPIConfig,
+	status *redpandav1alpha1.ClusterStatus,
+) error {
+	adminAPI, err := r.adminAPIClient(cluster, adminAPIConfig)
+	if err != nil {
+		return err
+	}
+
+	nodeID := replicaNodeID(cluster, sts, status.Maintenance.NodeID)
+	if err = adminAPI.DisableMaintenanceMode(ctx, nodeID); err != nil {
+		return err
+	}
+
+	r.Log.Info("Broker taken out of maintenance mode", "NodeID", nodeID)
+	status.Maintenance = nil
+
+	return nil
+}
+
+// findPod returns the named pod, or nil when it is not in the list
+func findPod(pods []corev1.Pod, name string) *corev1.Pod {
+	for i := range pods {
+		if pods[i].Name == name {
+			return &pods[i]
+		}
+	}
+
+	return nil
+}
+
+func podReady(pod *corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+
+	return false
+}
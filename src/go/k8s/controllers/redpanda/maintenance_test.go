@@ -0,0 +1,152 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda_test
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+)
+
+var _ = Describe("Redpanda maintenance mode", func() {
+	Context("When the brokers are rolled out", func() {
+		It("Should restart them one at a time in maintenance mode", func() {
+			key := testKey("redpanda-maintenance")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.Replicas = pointer.Int32Ptr(2)
+			redpandaCluster.Spec.MaintenanceMode.Enabled = true
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &appsv1.StatefulSet{})
+			}, timeout, interval).Should(Succeed())
+			brokerPod(key, 0, "rev-1")
+			brokerPod(key, 1, "rev-1")
+			observeStatefulSet(key, "rev-1")
+
+			By("Holding every broker on the new pod template")
+			setClusterVersion(key, "y")
+			Eventually(func() int32 {
+				return statefulSetPartition(key)
+			}, timeout, interval).Should(Equal(int32(2)))
+			Expect(statefulSetImage(key)).Should(Equal(redpandaContainerImage + ":y"))
+
+			By("Releasing the highest ordinal once in maintenance mode")
+			observeStatefulSet(key, "rev-2")
+			Eventually(func() int32 {
+				observeStatefulSet(key, "rev-2")
+				return statefulSetPartition(key)
+			}, timeout, interval).Should(Equal(int32(1)))
+			Expect(testAdminAPIs.get(key.Name).inMaintenance(1)).Should(BeTrue())
+			Expect(testAdminAPIs.get(key.Name).inMaintenance(0)).Should(BeFalse())
+			Eventually(func() *v1alpha1.MaintenanceStatus {
+				return clusterMaintenance(key)
+			}, timeout, interval).Should(Equal(&v1alpha1.MaintenanceStatus{NodeID: 1, Restarting: true}))
+
+			By("Taking the restarted broker out of maintenance mode")
+			brokerPod(key, 1, "rev-2")
+			Eventually(func() int32 {
+				observeStatefulSet(key, "rev-2")
+				return statefulSetPartition(key)
+			}, timeout, interval).Should(Equal(int32(0)))
+			Expect(testAdminAPIs.get(key.Name).inMaintenance(1)).Should(BeFalse())
+			Expect(testAdminAPIs.get(key.Name).inMaintenance(0)).Should(BeTrue())
+
+			brokerPod(key, 0, "rev-2")
+			Eventually(func() *v1alpha1.MaintenanceStatus {
+				observeStatefulSet(key, "rev-2")
+				return clusterMaintenance(key)
+			}, timeout, interval).Should(BeNil())
+			Expect(testAdminAPIs.get(key.Name).inMaintenance(0)).Should(BeFalse())
+		})
+	})
+})
+
+// brokerPod creates or updates the ready pod of the broker ordinal on the
+// given revision, like the StatefulSet controller restarting it
+func brokerPod(key types.NamespacedName, ordinal int, revision string) {
+	podKey := testKey(fmt.Sprintf("%s-%d", key.Name, ordinal))
+
+	var pod corev1.Pod
+	if err := k8sClient.Get(context.Background(), podKey, &pod); err != nil {
+		pod = corev1.Pod{
+			ObjectMeta:	metav1.ObjectMeta{Name: podKey.Name, Namespace: podKey.Namespace},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "redpanda", Image: statefulSetImage(key)}},
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), &pod)).Should(Succeed())
+	}
+
+	Eventually(func() error {
+		if err := k8sClient.Get(context.Background(), podKey, &pod); err != nil {
+			return err
+		}
+		pod.Labels = map[string]string{"app": key.Name, appsv1.ControllerRevisionHashLabelKey: revision}
+		return k8sClient.Update(context.Background(), &pod)
+	}, timeout, interval).Should(Succeed())
+
+	Eventually(func() error {
+		if err := k8sClient.Get(context.Background(), podKey, &pod); err != nil {
+			return err
+		}
+		pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+		return k8sClient.Status().Update(context.Background(), &pod)
+	}, timeout, interval).Should(Succeed())
+}
+
+// observeStatefulSet reports the latest generation as observed with the
+// given update revision and every broker ready
+func observeStatefulSet(key types.NamespacedName, revision string) {
+	Eventually(func() error {
+		var sts appsv1.StatefulSet
+		if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+			return err
+		}
+		sts.Status.ObservedGeneration = sts.Generation
+		sts.Status.Replicas = *sts.Spec.Replicas
+		sts.Status.ReadyReplicas = *sts.Spec.Replicas
+		sts.Status.CurrentRevision = "rev-1"
+		sts.Status.UpdateRevision = revision
+		return k8sClient.Status().Update(context.Background(), &sts)
+	}, timeout, interval).Should(Succeed())
+}
+
+func statefulSetPartition(key types.NamespacedName) int32 {
+	var sts appsv1.StatefulSet
+	if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+		return -1
+	}
+	if ru := sts.Spec.UpdateStrategy.RollingUpdate; ru != nil && ru.Partition != nil {
+		return *ru.Partition
+	}
+	return 0
+}
+
+// clusterMaintenance returns the maintenance status without its start time
+func clusterMaintenance(key types.NamespacedName) *v1alpha1.MaintenanceStatus {
+	var redpandaCluster v1alpha1.Cluster
+	if err := k8sClient.Get(context.Background(), key, &redpandaCluster); err != nil {
+		return nil
+	}
+	if m := redpandaCluster.Status.Maintenance; m != nil {
+		m.StartTime = metav1.Time{}
+		return m
+	}
+	return nil
+}
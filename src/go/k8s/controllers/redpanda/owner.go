@@ -0,0 +1,37 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	"context"
+
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// ensureOwner restores the controller reference of a resource managed for
+// the Cluster, e.g. after a backup restore tool stripped it. Without it the
+// resource would not be garbage collected with the Cluster, nor trigger
+// its reconciliation.
+func (r *ClusterReconciler) ensureOwner(
+	ctx context.Context, cluster *redpandav1alpha1.Cluster, obj client.Object,
+) error {
+	if metav1.IsControlledBy(obj, cluster) {
+		return nil
+	}
+
+	if err := controllerutil.SetControllerReference(cluster, obj, r.Scheme); err != nil {
+		return err
+	}
+
+	return r.Update(ctx, obj)
+}
@@ -0,0 +1,62 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Redpanda owner references", func() {
+	Context("When the owner reference of a managed resource is stripped", func() {
+		It("Should restore it", func() {
+			key := testKey("redpanda-stripped-owner")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.SASL.Enabled = true
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			resources := []struct {
+				kind	string
+				key	types.NamespacedName
+				obj	client.Object
+			}{
+				{"Service", key, &corev1.Service{}},
+				{"ConfigMap", testKey(key.Name + "-base"), &corev1.ConfigMap{}},
+				{"Secret", testKey(key.Name + "-superuser"), &corev1.Secret{}},
+				{"StatefulSet", key, &appsv1.StatefulSet{}},
+			}
+
+			for _, res := range resources {
+				res := res
+				By("Stripping the owner reference of the " + res.kind)
+				Eventually(func() error {
+					if err := k8sClient.Get(context.Background(), res.key, res.obj); err != nil {
+						return err
+					}
+					res.obj.SetOwnerReferences(nil)
+					return k8sClient.Update(context.Background(), res.obj)
+				}, timeout, interval).Should(Succeed())
+
+				Eventually(func() bool {
+					if err := k8sClient.Get(context.Background(), res.key, res.obj); err != nil {
+						return false
+					}
+					return validOwner(redpandaCluster, res.obj.GetOwnerReferences())
+				}, timeout, interval).Should(BeTrue())
+			}
+		})
+	})
+})
@@ -0,0 +1,79 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	"path/filepath"
+
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	pandaProxyTLSVolume	= "pandaproxy-tls"
+	pandaProxyTLSDir	= "/etc/tls/certs/pandaproxy"
+
+	// defaultPandaProxyPort is the redpanda default port of the Pandaproxy
+	defaultPandaProxyPort	= 8082
+
+	pandaProxyListener	= "internal"
+)
+
+// pandaProxyPort returns the port of the Pandaproxy listener
+func pandaProxyPort(cluster *redpandav1alpha1.Cluster) int {
+	if port := cluster.Spec.PandaProxy.Port; port != 0 {
+		return port
+	}
+
+	return defaultPandaProxyPort
+}
+
+// pandaProxySection returns the pandaproxy section of the redpanda.yaml.
+// The listener authenticates the clients like the kafka API, with their
+// SCRAM credentials over HTTP basic authentication.
+func pandaProxySection(cluster *redpandav1alpha1.Cluster) map[string]interface{} {
+	listener := map[string]interface{}{
+		"name":		pandaProxyListener,
+		"address":	listenerAddress(&cluster.Spec.Configuration),
+		"port":		pandaProxyPort(cluster),
+	}
+
+	if cluster.Spec.SASL.Enabled {
+		listener["authentication_method"] = "http_basic"
+	}
+
+	section := map[string]interface{}{
+		"pandaproxy_api": []map[string]interface{}{listener},
+	}
+
+	if pandaProxyCertSecretName(cluster) != "" {
+		section["pandaproxy_api_tls"] = []map[string]interface{}{{
+			"name":			pandaProxyListener,
+			"enabled":		true,
+			"require_client_auth":	cluster.Spec.PandaProxy.TLS.RequireClientAuth,
+			"cert_file":		filepath.Join(pandaProxyTLSDir, corev1.TLSCertKey),
+			"key_file":		filepath.Join(pandaProxyTLSDir, corev1.TLSPrivateKeyKey),
+			"truststore_file":	filepath.Join(pandaProxyTLSDir, caCertKey),
+		}}
+	}
+
+	return section
+}
+
+// pandaProxyCertSecretName returns the name of the Secret holding the
+// Pandaproxy certificate, or an empty string when it is served over http
+func pandaProxyCertSecretName(cluster *redpandav1alpha1.Cluster) string {
+	proxy := cluster.Spec.PandaProxy
+	if !proxy.Enabled || !proxy.TLS.Enabled || proxy.TLS.CertSecretRef == nil {
+		return ""
+	}
+
+	return proxy.TLS.CertSecretRef.Name
+}
@@ -0,0 +1,52 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	reasonPaused	= "ManagedAnnotationFalse"
+	reasonResumed	= "Resumed"
+)
+
+// paused reports whether the reconciliation of the Cluster is paused, e.g.
+// during a maintenance window
+func paused(cluster *redpandav1alpha1.Cluster) bool {
+	return cluster.Annotations[redpandav1alpha1.ManagedAnnotation] == "false"
+}
+
+// setPaused reports the Cluster as paused, or as resumed when it was
+// paused before. Clusters that were never paused get no condition.
+func setPaused(status *redpandav1alpha1.ClusterStatus, isPaused bool) {
+	if isPaused {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:		redpandav1alpha1.ClusterPaused,
+			Status:		metav1.ConditionTrue,
+			Reason:		reasonPaused,
+			Message:	"The " + redpandav1alpha1.ManagedAnnotation + " annotation is false, the Cluster is not reconciled",
+		})
+
+		return
+	}
+
+	if meta.FindStatusCondition(status.Conditions, redpandav1alpha1.ClusterPaused) == nil {
+		return
+	}
+
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:	redpandav1alpha1.ClusterPaused,
+		Status:	metav1.ConditionFalse,
+		Reason:	reasonResumed,
+	})
+}
@@ -0,0 +1,79 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Redpanda paused reconciliation", func() {
+	Context("When the Cluster is not managed", func() {
+		It("Should leave the resources untouched until it is resumed", func() {
+			key := createScaledCluster("redpanda-paused", 0)
+
+			setManaged(key, "false")
+			Eventually(func() metav1.ConditionStatus {
+				return clusterCondition(key, v1alpha1.ClusterPaused)
+			}, timeout, interval).Should(Equal(metav1.ConditionTrue))
+
+			By("Skipping the scale up while paused")
+			scaleCluster(key, 3)
+			Consistently(func() int32 {
+				return statefulSetReplicas(key)
+			}, "3s", interval).Should(Equal(int32(2)))
+
+			By("Reconciling again once resumed")
+			setManaged(key, "")
+			Eventually(func() int32 {
+				return statefulSetReplicas(key)
+			}, timeout, interval).Should(Equal(int32(3)))
+			Eventually(func() string {
+				return clusterConditionReason(key, v1alpha1.ClusterPaused)
+			}, timeout, interval).Should(Equal("Resumed"))
+			Expect(clusterCondition(key, v1alpha1.ClusterPaused)).Should(Equal(metav1.ConditionFalse))
+		})
+	})
+
+	Context("When the Cluster was never paused", func() {
+		It("Should not report the Paused condition", func() {
+			key := createScaledCluster("redpanda-never-paused", 0)
+
+			Consistently(func() metav1.ConditionStatus {
+				return clusterCondition(key, v1alpha1.ClusterPaused)
+			}, "2s", interval).Should(Equal(metav1.ConditionUnknown))
+		})
+	})
+})
+
+// setManaged sets the managed annotation of the Cluster, an empty value
+// removes it
+func setManaged(key types.NamespacedName, value string) {
+	Eventually(func() error {
+		var redpandaCluster v1alpha1.Cluster
+		if err := k8sClient.Get(context.Background(), key, &redpandaCluster); err != nil {
+			return err
+		}
+		if value == "" {
+			delete(redpandaCluster.Annotations, v1alpha1.ManagedAnnotation)
+		} else {
+			if redpandaCluster.Annotations == nil {
+				redpandaCluster.Annotations = map[string]string{}
+			}
+			redpandaCluster.Annotations[v1alpha1.ManagedAnnotation] = value
+		}
+		return k8sClient.Update(context.Background(), &redpandaCluster)
+	}, timeout, interval).Should(Succeed())
+}
@@ -0,0 +1,104 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	"context"
+	"reflect"
+
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// maxUnavailableBrokers returns the brokers which may be evicted at once:
+// those beyond a majority of the replicas, which keeps the quorum of the
+// controller, and at least one
+func maxUnavailableBrokers(cluster *redpandav1alpha1.Cluster) int {
+	replicas := int(brokerCount(cluster))
+
+	if beyondMajority := replicas - (replicas/2 + 1); beyondMajority > 1 {
+		return beyondMajority
+	}
+
+	return 1
+}
+
+// buildPodDisruptionBudget returns the PodDisruptionBudget of the brokers
+func buildPodDisruptionBudget(
+	cluster *redpandav1alpha1.Cluster,
+) *policyv1beta1.PodDisruptionBudget {
+	maxUnavailable := intstr.FromInt(maxUnavailableBrokers(cluster))
+
+	return &policyv1beta1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:	cluster.Namespace,
+			Name:		cluster.Name,
+			Labels:		cluster.Labels,
+		},
+		Spec: policyv1beta1.PodDisruptionBudgetSpec{
+			MaxUnavailable:	&maxUnavailable,
+			Selector:	&metav1.LabelSelector{MatchLabels: cluster.Labels},
+		},
+	}
+}
+
+// reconcilePodDisruptionBudget keeps the budget in line with the replicas
+// and the selector of the Cluster, and deletes it once disabled
+func (r *ClusterReconciler) reconcilePodDisruptionBudget(
+	ctx context.Context, cluster *redpandav1alpha1.Cluster,
+) error {
+	var pdb policyv1beta1.PodDisruptionBudget
+
+	err := r.Get(ctx, types.NamespacedName{Name: cluster.Name, Namespace: cluster.Namespace}, &pdb)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+
+	exists := err == nil
+
+	if !cluster.Spec.PodDisruptionBudget.Enabled {
+		if !exists || !metav1.IsControlledBy(&pdb, cluster) {
+			return nil
+		}
+
+		if err = r.Delete(ctx, &pdb); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+
+		return nil
+	}
+
+	desired := buildPodDisruptionBudget(cluster)
+
+	if !exists {
+		if err = controllerutil.SetControllerReference(cluster, desired, r.Scheme); err != nil {
+			return err
+		}
+
+		return r.Create(ctx, desired)
+	}
+
+	if err = r.ensureOwner(ctx, cluster, &pdb); err != nil {
+		return err
+	}
+
+	if reflect.DeepEqual(pdb.Spec, desired.Spec) {
+		return nil
+	}
+
+	pdb.Spec = desired.Spec
+
+	return r.Update(ctx, &pdb)
+}
@@ -0,0 +1,72 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+)
+
+var _ = Describe("Redpanda PodDisruptionBudget", func() {
+	Context("When the PodDisruptionBudget is enabled", func() {
+		It("Should follow the replicas and be deleted once disabled", func() {
+			key := testKey("redpanda-pdb")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.Replicas = pointer.Int32Ptr(3)
+			redpandaCluster.Spec.PodDisruptionBudget.Enabled = true
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			Eventually(func() int {
+				return pdbMaxUnavailable(key)
+			}, timeout, interval).Should(Equal(1))
+
+			var pdb policyv1beta1.PodDisruptionBudget
+			Expect(k8sClient.Get(context.Background(), key, &pdb)).Should(Succeed())
+			Expect(pdb.Spec.Selector).Should(Equal(&metav1.LabelSelector{MatchLabels: redpandaCluster.Labels}))
+			Expect(metav1.IsControlledBy(&pdb, redpandaCluster)).Should(BeTrue())
+
+			By("Allowing more evictions once scaled up")
+			scaleCluster(key, 5)
+			Eventually(func() int {
+				return pdbMaxUnavailable(key)
+			}, timeout, interval).Should(Equal(2))
+
+			By("Deleting the PodDisruptionBudget once disabled")
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), key, redpandaCluster); err != nil {
+					return err
+				}
+				redpandaCluster.Spec.PodDisruptionBudget.Enabled = false
+				return k8sClient.Update(context.Background(), redpandaCluster)
+			}, timeout, interval).Should(Succeed())
+			Eventually(func() bool {
+				return apierrors.IsNotFound(k8sClient.Get(context.Background(), key, &pdb))
+			}, timeout, interval).Should(BeTrue())
+		})
+	})
+})
+
+// pdbMaxUnavailable returns the brokers the PodDisruptionBudget lets evict
+// at once, or -1 when it does not exist
+func pdbMaxUnavailable(key types.NamespacedName) int {
+	var pdb policyv1beta1.PodDisruptionBudget
+	if err := k8sClient.Get(context.Background(), key, &pdb); err != nil || pdb.Spec.MaxUnavailable == nil {
+		return -1
+	}
+
+	return pdb.Spec.MaxUnavailable.IntValue()
+}
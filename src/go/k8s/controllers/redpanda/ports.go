@@ -0,0 +1,119 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	"github.com/vectorizedio/redpanda/src/go/rpk/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// kafkaAPIPort returns the port of the kafka API, defaulted like in the
+// rendered redpanda.yaml
+func kafkaAPIPort(cluster *redpandav1alpha1.Cluster) int {
+	if port := cluster.Spec.Configuration.KafkaAPI.Port; port != 0 {
+		return port
+	}
+
+	return config.Default().Redpanda.KafkaApi.Port
+}
+
+// rpcServerPort returns the port of the internal RPC server, defaulted
+// like in the rendered redpanda.yaml
+func rpcServerPort(cluster *redpandav1alpha1.Cluster) int {
+	if port := cluster.Spec.Configuration.RPCServer.Port; port != 0 {
+		return port
+	}
+
+	return config.Default().Redpanda.RPCServer.Port
+}
+
+// defaultSchemaRegistryPort is the redpanda default port of the Schema
+// Registry
+const defaultSchemaRegistryPort = 8081
+
+// schemaRegistryPort returns the port of the Schema Registry listener
+func schemaRegistryPort(cluster *redpandav1alpha1.Cluster) int {
+	if port := cluster.Spec.SchemaRegistry.Port; port != 0 {
+		return port
+	}
+
+	return defaultSchemaRegistryPort
+}
+
+// containerPorts returns the ports of the redpanda container. The protocol
+// is set explicitly, so that the ports defaulted by the API server can be
+// compared with the desired ones.
+func containerPorts(cluster *redpandav1alpha1.Cluster) []corev1.ContainerPort {
+	ports := []corev1.ContainerPort{
+		{
+			Name:		"admin",
+			ContainerPort:	int32(adminAPIPort(cluster)),
+			Protocol:	corev1.ProtocolTCP,
+		},
+		{
+			Name:		"kafka",
+			ContainerPort:	int32(kafkaAPIPort(cluster)),
+			Protocol:	corev1.ProtocolTCP,
+		},
+		{
+			Name:		"rpc",
+			ContainerPort:	int32(rpcServerPort(cluster)),
+			Protocol:	corev1.ProtocolTCP,
+		},
+	}
+
+	if cluster.Spec.SchemaRegistry.Enabled {
+		ports = append(ports, corev1.ContainerPort{
+			Name:		"schema-registry",
+			ContainerPort:	int32(schemaRegistryPort(cluster)),
+			Protocol:	corev1.ProtocolTCP,
+		})
+	}
+
+	if cluster.Spec.PandaProxy.Enabled {
+		ports = append(ports, corev1.ContainerPort{
+			Name:		"pandaproxy",
+			ContainerPort:	int32(pandaProxyPort(cluster)),
+			Protocol:	corev1.ProtocolTCP,
+		})
+	}
+
+	return ports
+}
+
+// restoreServicePorts sets the ports of the service back to the desired
+// ones, keeping the node ports allocated by the API server. It reports
+// whether the ports were changed.
+func restoreServicePorts(svc *corev1.Service, desired []corev1.ServicePort) bool {
+	current := make(map[string]corev1.ServicePort, len(svc.Spec.Ports))
+	for _, p := range svc.Spec.Ports {
+		current[p.Name] = p
+	}
+
+	modified := len(svc.Spec.Ports) != len(desired)
+	ports := make([]corev1.ServicePort, 0, len(desired))
+
+	for _, p := range desired {
+		c, ok := current[p.Name]
+		if !ok || c.Protocol != p.Protocol || c.Port != p.Port || c.TargetPort != p.TargetPort {
+			modified = true
+		}
+
+		p.NodePort = c.NodePort
+		ports = append(ports, p)
+	}
+
+	if modified {
+		svc.Spec.Ports = ports
+	}
+
+	return modified
+}
@@ -0,0 +1,86 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Redpanda ports", func() {
+	Context("When the ports of the Cluster change", func() {
+		It("Should keep the config, the container and the services consistent", func() {
+			key := testKey("redpanda-ports")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.Configuration.AdminAPI.ServiceEnabled = true
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+			Expect(containerPort(&sts, "kafka")).Should(Equal(int32(9092)))
+
+			By("Changing the kafka and Admin API ports")
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), key, redpandaCluster); err != nil {
+					return err
+				}
+				redpandaCluster.Spec.Configuration.KafkaAPI.Port = 9093
+				redpandaCluster.Spec.Configuration.AdminAPI.Port = 9645
+				return k8sClient.Update(context.Background(), redpandaCluster)
+			}, timeout, interval).Should(Succeed())
+
+			Eventually(func() []int32 {
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return nil
+				}
+				return []int32{containerPort(&sts, "kafka"), containerPort(&sts, "admin")}
+			}, timeout, interval).Should(Equal([]int32{9093, 9645}))
+			Expect(sts.Spec.Template.Spec.Containers[0].StartupProbe.HTTPGet.Port.IntValue()).Should(Equal(9645))
+
+			Eventually(func() bool {
+				return servicePort(key, 9093)
+			}, timeout, interval).Should(BeTrue())
+			Eventually(func() bool {
+				return servicePort(types.NamespacedName{Name: key.Name + "-admin", Namespace: key.Namespace}, 9645)
+			}, timeout, interval).Should(BeTrue())
+
+			Eventually(func() interface{} {
+				kafka, _ := eventuallyRedpandaConfig(key)["kafka_api"].(map[string]interface{})
+				return kafka["port"]
+			}, timeout, interval).Should(Equal(9093))
+		})
+	})
+})
+
+// containerPort returns the named port of the redpanda container
+func containerPort(sts *appsv1.StatefulSet, name string) int32 {
+	for _, p := range sts.Spec.Template.Spec.Containers[0].Ports {
+		if p.Name == name {
+			return p.ContainerPort
+		}
+	}
+	return 0
+}
+
+// servicePort reports whether the service exposes and targets the port
+func servicePort(key types.NamespacedName, port int32) bool {
+	var svc corev1.Service
+	if err := k8sClient.Get(context.Background(), key, &svc); err != nil || len(svc.Spec.Ports) != 1 {
+		return false
+	}
+	return svc.Spec.Ports[0].Port == port && svc.Spec.Ports[0].TargetPort.IntVal == port
+}
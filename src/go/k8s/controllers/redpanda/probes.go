@@ -0,0 +1,143 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// The defaults give a broker ten minutes to start
+	defaultStartupFailureThreshold	= 60
+	defaultStartupPeriodSeconds	= 10
+
+	defaultLivenessFailureThreshold	= 3
+	defaultLivenessPeriodSeconds	= 10
+
+	readinessPath	= "/v1/status/ready"
+)
+
+// healthCheckScheme returns the scheme of the Admin API health check
+func healthCheckScheme(cluster *redpandav1alpha1.Cluster) corev1.URIScheme {
+	adminAPI := cluster.Spec.Configuration.AdminAPI
+	if adminAPI.HealthCheck.Scheme != "" {
+		return adminAPI.HealthCheck.Scheme
+	}
+
+	if adminAPI.TLS.CASecretRef != nil {
+		return corev1.URISchemeHTTPS
+	}
+
+	return corev1.URISchemeHTTP
+}
+
+// healthCheckPath returns the path of the Admin API health check
+func healthCheckPath(cluster *redpandav1alpha1.Cluster) string {
+	if path := cluster.Spec.Configuration.AdminAPI.HealthCheck.Path; path != "" {
+		return path
+	}
+
+	return readinessPath
+}
+
+// startupProbe returns the probe reporting a broker as started once its
+// Admin API is ready
+func startupProbe(cluster *redpandav1alpha1.Cluster) *corev1.Probe {
+	failureThreshold := cluster.Spec.StartupProbe.FailureThreshold
+	if failureThreshold == 0 {
+		failureThreshold = defaultStartupFailureThreshold
+	}
+
+	periodSeconds := cluster.Spec.StartupProbe.PeriodSeconds
+	if periodSeconds == 0 {
+		periodSeconds = defaultStartupPeriodSeconds
+	}
+
+	return adminAPIProbe(cluster, failureThreshold, periodSeconds)
+}
+
+// livenessProbe returns the probe restarting a broker whose Admin API
+// stopped answering. While rollingOut, the failure threshold is relaxed
+// when the Cluster asks for it.
+func livenessProbe(
+	cluster *redpandav1alpha1.Cluster, rollingOut bool,
+) *corev1.Probe {
+	spec := cluster.Spec.LivenessProbe
+
+	failureThreshold := spec.FailureThreshold
+	if failureThreshold == 0 {
+		failureThreshold = defaultLivenessFailureThreshold
+	}
+
+	if rollingOut && spec.RolloutFailureThreshold != 0 {
+		failureThreshold = spec.RolloutFailureThreshold
+	}
+
+	periodSeconds := spec.PeriodSeconds
+	if periodSeconds == 0 {
+		periodSeconds = defaultLivenessPeriodSeconds
+	}
+
+	return adminAPIProbe(cluster, failureThreshold, periodSeconds)
+}
+
+// adminAPIProbe returns a probe of the Admin API health check. All
+// fields are set explicitly, so that the probe defaulted by the API server
+// can be compared with the desired one.
+func adminAPIProbe(
+	cluster *redpandav1alpha1.Cluster, failureThreshold, periodSeconds int32,
+) *corev1.Probe {
+	return &corev1.Probe{
+		Handler: corev1.Handler{
+			HTTPGet: &corev1.HTTPGetAction{
+				Path:	healthCheckPath(cluster),
+				Port:	intstr.FromInt(adminAPIPort(cluster)),
+				Scheme:	healthCheckScheme(cluster),
+			},
+		},
+		FailureThreshold:	failureThreshold,
+		PeriodSeconds:		periodSeconds,
+		SuccessThreshold:	1,
+		TimeoutSeconds:		1,
+	}
+}
+
+// Reasons of the RollingOut condition
+const (
+	reasonRolloutInProgress	= "RolloutInProgress"
+	reasonRolloutComplete	= "RolloutComplete"
+)
+
+// rolloutInProgress returns true until the StatefulSet controller updated
+// every pod to the latest revision of the pod template
+func rolloutInProgress(sts *appsv1.StatefulSet) bool {
+	return sts.Status.UpdateRevision != "" && sts.Status.CurrentRevision != sts.Status.UpdateRevision
+}
+
+func setRollingOut(status *redpandav1alpha1.ClusterStatus, rollingOut bool) {
+	condition := metav1.Condition{
+		Type:		redpandav1alpha1.ClusterRollingOut,
+		Status:		metav1.ConditionFalse,
+		Reason:		reasonRolloutComplete,
+		Message:	"Every broker runs the latest pod template",
+	}
+	if rollingOut {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = reasonRolloutInProgress
+		condition.Message = "The brokers are rolled out to a new pod template"
+	}
+
+	meta.SetStatusCondition(&status.Conditions, condition)
+}
@@ -0,0 +1,127 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	"github.com/vectorizedio/redpanda/src/go/k8s/pkg/admin"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	defaultQuorumLossGracePeriod	= time.Minute
+
+	reasonQuorumLost	= "ControllerQuorumLost"
+	reasonLeaderElected	= "ControllerLeaderElected"
+)
+
+// quorumLossGracePeriod returns how long the controller may be without a
+// leader, e.g. during an election, before its quorum is considered lost
+func (r *ClusterReconciler) quorumLossGracePeriod() time.Duration {
+	if r.QuorumLossGracePeriod > 0 {
+		return r.QuorumLossGracePeriod
+	}
+
+	return defaultQuorumLossGracePeriod
+}
+
+// checkControllerQuorum tracks since when the controller of the brokers
+// has no elected leader. Past the grace period the Cluster is reported as
+// degraded and a Warning event is emitted, which holds the decommissioning
+// and the upgrades until a leader is elected again.
+func (r *ClusterReconciler) checkControllerQuorum(
+	ctx context.Context,
+	cluster *redpandav1alpha1.Cluster,
+	adminAPIConfig *AdminAPIConfig,
+	status *redpandav1alpha1.ClusterStatus,
+) error {
+	adminAPI, err := r.adminAPIClient(cluster, adminAPIConfig)
+	if err != nil {
+		return err
+	}
+
+	leader, err := adminAPI.ControllerLeader(ctx)
+	if err != nil {
+		return err
+	}
+
+	if leader != admin.NoLeader {
+		status.ControllerLeaderLostTime = nil
+
+		if status.ControllerQuorumLossReported {
+			status.ControllerQuorumLossReported = false
+			clearDegraded(status, reasonLeaderElected, reasonQuorumLost)
+			r.event(cluster, corev1.EventTypeNormal, reasonLeaderElected,
+				fmt.Sprintf("Broker %d was elected controller leader", leader))
+		}
+
+		return nil
+	}
+
+	if status.ControllerLeaderLostTime == nil {
+		now := metav1.Now()
+		status.ControllerLeaderLostTime = &now
+	}
+
+	if !r.quorumLost(status) {
+		return nil
+	}
+
+	brokers, err := adminAPI.Brokers(ctx)
+	if err != nil {
+		return err
+	}
+
+	members := 0
+
+	for _, b := range brokers {
+		if b.MembershipStatus != admin.MembershipRemoved {
+			members++
+		}
+	}
+
+	// The message does not change while the quorum stays lost, so that the
+	// status is not written on every reconciliation
+	message := fmt.Sprintf("No controller leader was elected for more than %s among the %d member brokers, "+
+		"the decommissioning and upgrades are held", r.quorumLossGracePeriod(), members)
+
+	// The Degraded condition is shared with the other checks, the event is
+	// emitted once per loss whatever its reason
+	if !status.ControllerQuorumLossReported {
+		status.ControllerQuorumLossReported = true
+		r.event(cluster, corev1.EventTypeWarning, reasonQuorumLost, message)
+	}
+
+	setDegraded(status, reasonQuorumLost, message)
+
+	return nil
+}
+
+// quorumLost reports whether the controller of the brokers lost its
+// quorum, i.e. it has no leader for longer than the grace period
+func (r *ClusterReconciler) quorumLost(status *redpandav1alpha1.ClusterStatus) bool {
+	lost := status.ControllerLeaderLostTime
+
+	return lost != nil && time.Since(lost.Time) > r.quorumLossGracePeriod()
+}
+
+// event emits an event on the Cluster when the reconciler has a recorder
+func (r *ClusterReconciler) event(
+	cluster *redpandav1alpha1.Cluster, eventType, reason, message string,
+) {
+	if r.Recorder != nil {
+		r.Recorder.Event(cluster, eventType, reason, message)
+	}
+}
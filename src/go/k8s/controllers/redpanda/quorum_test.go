@@ -0,0 +1,78 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	"github.com/vectorizedio/redpanda/src/go/k8s/pkg/admin"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Redpanda controller quorum", func() {
+	Context("When the controller has no leader", func() {
+		It("Should report the Cluster as degraded and hold the decommissioning", func() {
+			testAdminAPIs.get("redpanda-quorum-loss").setControllerLeader(admin.NoLeader)
+			key := createScaledCluster("redpanda-quorum-loss", time.Minute)
+			setReadyReplicas(key, 2)
+
+			Eventually(func() string {
+				return clusterConditionReason(key, v1alpha1.ClusterDegraded)
+			}, timeout, interval).Should(Equal("ControllerQuorumLost"))
+			Expect(clusterCondition(key, v1alpha1.ClusterDegraded)).Should(Equal(metav1.ConditionTrue))
+			Eventually(func() bool {
+				return clusterEvent(key, corev1.EventTypeWarning, "ControllerQuorumLost")
+			}, timeout, interval).Should(BeTrue())
+
+			scaleCluster(key, 1)
+			Consistently(func() int32 {
+				return statefulSetReplicas(key)
+			}, 3*time.Second, interval).Should(Equal(int32(2)))
+			Expect(testAdminAPIs.get(key.Name).isDecommissioned(1)).Should(BeFalse())
+
+			By("Decommissioning the broker once a leader is elected")
+			testAdminAPIs.get(key.Name).setControllerLeader(0)
+			Eventually(func() int32 {
+				return statefulSetReplicas(key)
+			}, timeout, interval).Should(Equal(int32(1)))
+			Expect(testAdminAPIs.get(key.Name).isDecommissioned(1)).Should(BeTrue())
+			Eventually(func() *metav1.Time {
+				var redpandaCluster v1alpha1.Cluster
+				Expect(k8sClient.Get(context.Background(), key, &redpandaCluster)).Should(Succeed())
+				return redpandaCluster.Status.ControllerLeaderLostTime
+			}, timeout, interval).Should(BeNil())
+			Eventually(func() bool {
+				return clusterEvent(key, corev1.EventTypeNormal, "ControllerLeaderElected")
+			}, timeout, interval).Should(BeTrue())
+		})
+	})
+})
+
+// clusterEvent reports whether an event was emitted on the Cluster
+func clusterEvent(key types.NamespacedName, eventType, reason string) bool {
+	var events corev1.EventList
+	Expect(k8sClient.List(context.Background(), &events, client.InNamespace(key.Namespace))).Should(Succeed())
+
+	for _, e := range events.Items {
+		if e.InvolvedObject.Kind == "Cluster" && e.InvolvedObject.Name == key.Name &&
+			e.Type == eventType && e.Reason == reason {
+			return true
+		}
+	}
+
+	return false
+}
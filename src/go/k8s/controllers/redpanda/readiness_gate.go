@@ -0,0 +1,132 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	"context"
+
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	"github.com/vectorizedio/redpanda/src/go/k8s/pkg/admin"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Reasons of the cluster membership readiness gate condition
+const (
+	reasonBrokerJoined	= "BrokerJoined"
+	reasonBrokerNotJoined	= "BrokerNotJoined"
+)
+
+// readinessGates returns the readiness gates of the broker pods
+func readinessGates(cluster *redpandav1alpha1.Cluster) []corev1.PodReadinessGate {
+	if !cluster.Spec.ReadinessGate.Enabled {
+		return nil
+	}
+
+	return []corev1.PodReadinessGate{{ConditionType: redpandav1alpha1.ClusterMemberReadinessGate}}
+}
+
+// reconcileReadinessGates sets the cluster membership condition of the
+// pods with the readiness gate, to true once their broker is a member of
+// the cluster according to the Admin API. It returns true while some
+// broker did not join yet, so that the membership is polled.
+func (r *ClusterReconciler) reconcileReadinessGates(
+	ctx context.Context,
+	cluster *redpandav1alpha1.Cluster,
+	pods []corev1.Pod,
+	adminAPIConfig *AdminAPIConfig,
+) (bool, error) {
+	var gated []*corev1.Pod
+
+	for i := range pods {
+		for _, gate := range pods[i].Spec.ReadinessGates {
+			if gate.ConditionType == redpandav1alpha1.ClusterMemberReadinessGate {
+				gated = append(gated, &pods[i])
+			}
+		}
+	}
+
+	if len(gated) == 0 {
+		return false, nil
+	}
+
+	adminAPI, err := r.adminAPIClient(cluster, adminAPIConfig)
+	if err != nil {
+		return true, err
+	}
+
+	brokers, err := adminAPI.Brokers(ctx)
+	if err != nil {
+		return true, err
+	}
+
+	members := make(map[int]bool, len(brokers))
+	for _, b := range brokers {
+		members[b.NodeID] = b.MembershipStatus != admin.MembershipRemoved
+	}
+
+	pending := false
+
+	for _, pod := range gated {
+		nodeID, ok := brokerNodeID(cluster, pod)
+		if !ok {
+			continue
+		}
+
+		member := members[nodeID]
+		if !member {
+			pending = true
+		}
+
+		if err = r.setMembershipCondition(ctx, pod, member); err != nil {
+			return true, err
+		}
+	}
+
+	return pending, nil
+}
+
+// setMembershipCondition updates the readiness gate condition of the pod
+// when it changed
+func (r *ClusterReconciler) setMembershipCondition(
+	ctx context.Context, pod *corev1.Pod, member bool,
+) error {
+	condition := corev1.PodCondition{
+		Type:			redpandav1alpha1.ClusterMemberReadinessGate,
+		Status:			corev1.ConditionFalse,
+		Reason:			reasonBrokerNotJoined,
+		Message:		"The broker is not a member of the cluster",
+		LastTransitionTime:	metav1.Now(),
+	}
+	if member {
+		condition.Status = corev1.ConditionTrue
+		condition.Reason = reasonBrokerJoined
+		condition.Message = "The broker is a member of the cluster"
+	}
+
+	for i := range pod.Status.Conditions {
+		c := &pod.Status.Conditions[i]
+		if c.Type != condition.Type {
+			continue
+		}
+
+		if c.Status == condition.Status {
+			return nil
+		}
+
+		*c = condition
+
+		return r.Status().Update(ctx, pod)
+	}
+
+	pod.Status.Conditions = append(pod.Status.Conditions, condition)
+
+	return r.Status().Update(ctx, pod)
+}
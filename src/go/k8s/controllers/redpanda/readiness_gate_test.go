@@ -0,0 +1,96 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda_test
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+)
+
+var _ = Describe("Redpanda readiness gate", func() {
+	Context("When the cluster membership readiness gate is enabled", func() {
+		It("Should follow the membership of the brokers", func() {
+			key := testKey("redpanda-readiness-gate")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.Replicas = pointer.Int32Ptr(2)
+			redpandaCluster.Spec.ReadinessGate.Enabled = true
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			api := testAdminAPIs.get(key.Name)
+			Expect(api.DecommissionBroker(context.Background(), 1)).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+			Expect(sts.Spec.Template.Spec.ReadinessGates).Should(Equal([]corev1.PodReadinessGate{
+				{ConditionType: v1alpha1.ClusterMemberReadinessGate},
+			}))
+
+			for ordinal := 0; ordinal < 2; ordinal++ {
+				Expect(k8sClient.Create(context.Background(), &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:		fmt.Sprintf("%s-%d", key.Name, ordinal),
+						Namespace:	key.Namespace,
+						Labels:		map[string]string{"app": key.Name},
+					},
+					Spec: corev1.PodSpec{
+						Containers:	[]corev1.Container{{Name: "redpanda", Image: statefulSetImage(key)}},
+						ReadinessGates:	sts.Spec.Template.Spec.ReadinessGates,
+					},
+				})).Should(Succeed())
+			}
+			setReadyReplicas(key, 2)
+
+			Eventually(func() corev1.ConditionStatus {
+				return membershipCondition(testKey(key.Name + "-0"))
+			}, timeout, interval).Should(Equal(corev1.ConditionTrue))
+			Eventually(func() corev1.ConditionStatus {
+				return membershipCondition(testKey(key.Name + "-1"))
+			}, timeout, interval).Should(Equal(corev1.ConditionFalse))
+
+			By("Setting the condition once the broker joined")
+			api.recommission(1)
+			Eventually(func() corev1.ConditionStatus {
+				return membershipCondition(testKey(key.Name + "-1"))
+			}, timeout, interval).Should(Equal(corev1.ConditionTrue))
+
+			By("Clearing the condition once the broker left")
+			Expect(api.DecommissionBroker(context.Background(), 0)).Should(Succeed())
+			setReadyReplicas(key, 1)
+			Eventually(func() corev1.ConditionStatus {
+				return membershipCondition(testKey(key.Name + "-0"))
+			}, timeout, interval).Should(Equal(corev1.ConditionFalse))
+		})
+	})
+})
+
+// membershipCondition returns the status of the readiness gate condition
+func membershipCondition(key types.NamespacedName) corev1.ConditionStatus {
+	var pod corev1.Pod
+	if err := k8sClient.Get(context.Background(), key, &pod); err != nil {
+		return corev1.ConditionUnknown
+	}
+	for _, c := range pod.Status.Conditions {
+		if c.Type == v1alpha1.ClusterMemberReadinessGate {
+			return c.Status
+		}
+	}
+	return corev1.ConditionUnknown
+}
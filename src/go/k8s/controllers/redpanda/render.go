@@ -0,0 +1,71 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Render returns the base ConfigMap, the Services and the StatefulSets the
+// operator creates for a new Cluster, without talking to the API server.
+// As the objects referenced by the Cluster are not read, fragment is the
+// content of the user configuration ConfigMap, if any, and the pod
+// template misses the digest of the referenced Secrets.
+func Render(
+	cluster *redpandav1alpha1.Cluster, fragment []byte,
+) ([]runtime.Object, error) {
+	cm, err := buildConfigMap(cluster, fragment)
+	if err != nil {
+		return nil, err
+	}
+
+	cm.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("ConfigMap"))
+
+	svc := buildService(cluster)
+	svc.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Service"))
+
+	annotations := map[string]string{
+		configuratorHashAnnotation: configuratorHash(cluster),
+	}
+
+	if restartedAt := cluster.Annotations[redpandav1alpha1.RestartedAtAnnotation]; restartedAt != "" {
+		annotations[redpandav1alpha1.RestartedAtAnnotation] = restartedAt
+	}
+
+	ss := buildStatefulSet(cluster, cm.Name, annotations, configuratorBootstrap)
+	ss.SetGroupVersionKind(appsv1.SchemeGroupVersion.WithKind("StatefulSet"))
+
+	objects := []runtime.Object{cm, svc}
+
+	if serviceType(cluster) == redpandav1alpha1.ServiceTypeClusterIP {
+		clientSvc := buildClientService(cluster)
+		clientSvc.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Service"))
+		objects = append(objects, clientSvc)
+	}
+
+	objects = append(objects, ss)
+
+	// The StatefulSets of the broker groups follow the one of the Cluster
+	for i := range cluster.Spec.BrokerGroups {
+		groupAnnotations := make(map[string]string, len(annotations))
+		for k, v := range annotations {
+			groupAnnotations[k] = v
+		}
+
+		groupSts := buildBrokerGroupStatefulSet(cluster, &cluster.Spec.BrokerGroups[i], groupAnnotations, configuratorRejoin)
+		groupSts.SetGroupVersionKind(appsv1.SchemeGroupVersion.WithKind("StatefulSet"))
+		objects = append(objects, groupSts)
+	}
+
+	return objects, nil
+}
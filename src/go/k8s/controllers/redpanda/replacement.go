@@ -0,0 +1,307 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	"github.com/vectorizedio/redpanda/src/go/k8s/pkg/admin"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	defaultReplacementThreshold	= 15 * time.Minute
+	defaultReplacementRestarts	= 5
+
+	// crashLoopBackOff is the waiting reason of a container restarted
+	// after repeated failures
+	crashLoopBackOff	= "CrashLoopBackOff"
+
+	reasonReplacingBroker		= "ReplacingBroker"
+	reasonBrokerReplaced		= "BrokerReplaced"
+	reasonReplacementTimeout	= "ReplacementTimeout"
+)
+
+// replacementThreshold returns how long a broker must be unready before
+// it is replaced
+func replacementThreshold(cluster *redpandav1alpha1.Cluster) time.Duration {
+	if t := cluster.Spec.BrokerReplacement.FailureThreshold; t != nil {
+		return t.Duration
+	}
+
+	return defaultReplacementThreshold
+}
+
+// replacementRestarts returns the restarts of the redpanda container
+// before its broker is replaced
+func replacementRestarts(cluster *redpandav1alpha1.Cluster) int32 {
+	if r := cluster.Spec.BrokerReplacement.MinRestarts; r > 0 {
+		return r
+	}
+
+	return defaultReplacementRestarts
+}
+
+// replacementCandidate returns the pod of the broker to replace, or nil.
+// Every broker of the Cluster, including the ones of the broker groups, is
+// considered. A broker is only replaced while it is the only unavailable
+// one: several failing brokers are more likely caused by their
+// configuration or their environment than by their data, and replacing
+// them would lose the replicas of their partitions.
+func replacementCandidate(
+	cluster *redpandav1alpha1.Cluster, pods []corev1.Pod, now time.Time,
+) *corev1.Pod {
+	var candidate *corev1.Pod
+
+	unavailable := 0
+
+	for _, name := range brokerPodNames(cluster) {
+		pod := findPod(pods, name)
+		if pod != nil && podReady(pod) {
+			continue
+		}
+
+		unavailable++
+
+		if pod != nil && brokerFailing(cluster, pod, now) {
+			candidate = pod
+		}
+	}
+
+	if unavailable != 1 {
+		return nil
+	}
+
+	return candidate
+}
+
+// brokerFailing reports whether the redpanda container of the pod crash
+// loops and the pod has been unready for longer than the failure threshold
+func brokerFailing(cluster *redpandav1alpha1.Cluster, pod *corev1.Pod, now time.Time) bool {
+	if pod.DeletionTimestamp != nil {
+		return false
+	}
+
+	crashLooping := false
+
+	for i := range pod.Status.ContainerStatuses {
+		c := &pod.Status.ContainerStatuses[i]
+		if c.Name != redpandaContainerName {
+			continue
+		}
+
+		crashLooping = c.State.Waiting != nil && c.State.Waiting.Reason == crashLoopBackOff &&
+			c.RestartCount >= replacementRestarts(cluster)
+	}
+
+	if !crashLooping {
+		return false
+	}
+
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status != corev1.ConditionTrue &&
+				now.Sub(c.LastTransitionTime.Time) >= replacementThreshold(cluster)
+		}
+	}
+
+	return false
+}
+
+// brokerDead reports whether the Admin API confirms that the broker is not
+// alive. A broker whose liveness is not reported is never replaced.
+func brokerDead(brokers []admin.Broker, nodeID int) bool {
+	for _, b := range brokers {
+		if b.NodeID == nodeID {
+			return b.IsAlive != nil && !*b.IsAlive
+		}
+	}
+
+	return false
+}
+
+// reconcileReplacement replaces the broker of the Cluster failing
+// persistently. The broker is decommissioned first, so that its partitions
+// are replicated to the other brokers, then its data claim is deleted. Its
+// pod is deleted until the claim is released, and the StatefulSet then
+// recreates it on an empty volume. Redpanda rejects the join of a removed
+// node id, so the new broker gets the node id of the replaced one offset
+// by ReplacedNodeIDOffset, which the configurator reads from the base
+// ConfigMap. No broker is replaced during a scale down, a restart in
+// maintenance mode or a rollout, nor while the controller quorum is lost.
+func (r *ClusterReconciler) reconcileReplacement(
+	ctx context.Context,
+	cluster *redpandav1alpha1.Cluster,
+	sts *appsv1.StatefulSet,
+	pods []corev1.Pod,
+	adminAPIConfig *AdminAPIConfig,
+	status *redpandav1alpha1.ClusterStatus,
+) error {
+	if status.Replacement != nil {
+		return r.continueReplacement(ctx, cluster, pods, adminAPIConfig, status)
+	}
+
+	if !cluster.Spec.BrokerReplacement.Enabled || status.Decommission != nil || status.Maintenance != nil ||
+		r.quorumLost(status) || rolloutInProgress(sts) {
+		return nil
+	}
+
+	pod := replacementCandidate(cluster, pods, time.Now())
+	if pod == nil {
+		return nil
+	}
+
+	nodeID, ok := brokerNodeID(cluster, pod)
+	if !ok {
+		return nil
+	}
+
+	adminAPI, err := r.adminAPIClient(cluster, adminAPIConfig)
+	if err != nil {
+		return err
+	}
+
+	brokers, err := adminAPI.Brokers(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !brokerDead(brokers, nodeID) {
+		return nil
+	}
+
+	if err = adminAPI.DecommissionBroker(ctx, nodeID); err != nil {
+		return err
+	}
+
+	r.Log.Info("Decommissioning the failed broker to replace it", "NodeID", nodeID)
+	r.event(cluster, corev1.EventTypeWarning, reasonReplacingBroker, fmt.Sprintf("Broker %d failed for longer "+
+		"than %s and is not alive, it is replaced by a broker on an empty volume", nodeID, replacementThreshold(cluster)))
+
+	status.Replacement = &redpandav1alpha1.ReplacementStatus{NodeID: nodeID, Pod: pod.Name, StartTime: metav1.Now()}
+
+	return nil
+}
+
+// continueReplacement waits for the replaced broker to leave the cluster,
+// gives a new node id to its pod, then deletes its data claim and its pod
+func (r *ClusterReconciler) continueReplacement(
+	ctx context.Context,
+	cluster *redpandav1alpha1.Cluster,
+	pods []corev1.Pod,
+	adminAPIConfig *AdminAPIConfig,
+	status *redpandav1alpha1.ClusterStatus,
+) error {
+	replacement := status.Replacement
+
+	if !replacement.Decommissioned {
+		adminAPI, err := r.adminAPIClient(cluster, adminAPIConfig)
+		if err != nil {
+			return err
+		}
+
+		drained, err := brokerDrained(ctx, adminAPI, replacement.NodeID)
+		if err != nil {
+			return err
+		}
+
+		if !drained {
+			if elapsed := time.Since(replacement.StartTime.Time); elapsed > decommissionTimeout(cluster) {
+				setDegraded(status, reasonReplacementTimeout, fmt.Sprintf("Failed broker %d did not "+
+					"drain its partitions after %s", replacement.NodeID, elapsed.Round(time.Second)))
+			}
+
+			return nil
+		}
+
+		replacement.Decommissioned = true
+	}
+
+	if replacement.NewNodeID == 0 {
+		replacement.NewNodeID = replacement.NodeID + redpandav1alpha1.ReplacedNodeIDOffset
+
+		if status.NodeIDs == nil {
+			status.NodeIDs = map[string]int{}
+		}
+
+		status.NodeIDs[replacement.Pod] = replacement.NewNodeID
+	}
+
+	// The base ConfigMap lists the new node id once the status holding it
+	// was reconciled, the broker must not restart before
+	if cluster.Status.NodeIDs[replacement.Pod] != replacement.NewNodeID {
+		return nil
+	}
+
+	var pvc corev1.PersistentVolumeClaim
+
+	claimName := dataVolumeName + "-" + replacement.Pod
+	pod := findPod(pods, replacement.Pod)
+
+	err := r.Get(ctx, types.NamespacedName{Name: claimName, Namespace: cluster.Namespace}, &pvc)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	// The claim of the failed broker predates its decommissioning, unlike
+	// the claim of the new broker
+	claimFound := err == nil
+	if claimFound && (pvc.UID == replacement.ClaimUID ||
+		(replacement.ClaimUID == "" && pvc.CreationTimestamp.Before(&replacement.StartTime))) {
+		replacement.ClaimUID = pvc.UID
+
+		if pvc.DeletionTimestamp == nil {
+			if err = r.Delete(ctx, &pvc); err != nil && !apierrors.IsNotFound(err) {
+				return err
+			}
+		}
+
+		// The claim is only released once no pod uses it
+		return r.deleteReplacedPod(ctx, pod)
+	}
+
+	// A pod recreated while the claim was terminating still refers to it,
+	// and the StatefulSet only creates the claims of the pods it creates.
+	// It is deleted once more, so that it comes back with a new claim.
+	if pod != nil && pod.DeletionTimestamp == nil &&
+		(!claimFound || pod.CreationTimestamp.Before(&pvc.CreationTimestamp)) {
+		return r.deleteReplacedPod(ctx, pod)
+	}
+
+	r.Log.Info("Failed broker replaced", "NodeID", replacement.NodeID, "NewNodeID", replacement.NewNodeID)
+	r.event(cluster, corev1.EventTypeNormal, reasonBrokerReplaced, fmt.Sprintf("The data claim of broker %d "+
+		"was deleted, it restarts on an empty volume as broker %d", replacement.NodeID, replacement.NewNodeID))
+
+	status.Replacement = nil
+	clearDegraded(status, reasonBrokerReplaced, reasonReplacementTimeout)
+
+	return nil
+}
+
+// deleteReplacedPod deletes the pod of the replaced broker, unless it is
+// already gone or terminating
+func (r *ClusterReconciler) deleteReplacedPod(ctx context.Context, pod *corev1.Pod) error {
+	if pod == nil || pod.DeletionTimestamp != nil {
+		return nil
+	}
+
+	if err := r.Delete(ctx, pod); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,216 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	"github.com/vectorizedio/redpanda/src/go/k8s/pkg/admin"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+)
+
+func replacementPods(now time.Time) []corev1.Pod {
+	pods := make([]corev1.Pod, 3)
+	for i := range pods {
+		pods[i] = corev1.Pod{
+			ObjectMeta:	metav1.ObjectMeta{Name: fmt.Sprintf("cluster-%d", i)},
+			Status: corev1.PodStatus{
+				Conditions: []corev1.PodCondition{{
+					Type:			corev1.PodReady,
+					Status:			corev1.ConditionTrue,
+					LastTransitionTime:	metav1.NewTime(now.Add(-time.Hour)),
+				}},
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name:	redpandaContainerName,
+					State:	corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+				}},
+			},
+		}
+	}
+
+	return pods
+}
+
+// failPod makes the broker crash loop with the given restarts since the
+// given time
+func failPod(pod *corev1.Pod, restarts int32, since time.Time) {
+	pod.Status.Conditions[0].Status = corev1.ConditionFalse
+	pod.Status.Conditions[0].LastTransitionTime = metav1.NewTime(since)
+	pod.Status.ContainerStatuses[0].RestartCount = restarts
+	pod.Status.ContainerStatuses[0].State = corev1.ContainerState{
+		Waiting: &corev1.ContainerStateWaiting{Reason: crashLoopBackOff},
+	}
+}
+
+func TestReplacementCandidate(t *testing.T) {
+	now := time.Now()
+	longAgo := now.Add(-20 * time.Minute)
+
+	tests := []struct {
+		name		string
+		modify		func(cluster *redpandav1alpha1.Cluster, pods []corev1.Pod) []corev1.Pod
+		expected	string
+	}{
+		{
+			name:		"healthy brokers",
+			modify:		func(_ *redpandav1alpha1.Cluster, pods []corev1.Pod) []corev1.Pod { return pods },
+			expected:	"",
+		},
+		{
+			name:	"broker failing for longer than the threshold",
+			modify: func(_ *redpandav1alpha1.Cluster, pods []corev1.Pod) []corev1.Pod {
+				failPod(&pods[1], 8, longAgo)
+				return pods
+			},
+			expected:	"cluster-1",
+		},
+		{
+			name:	"broker failing for less than the threshold",
+			modify: func(_ *redpandav1alpha1.Cluster, pods []corev1.Pod) []corev1.Pod {
+				failPod(&pods[1], 8, now.Add(-10*time.Minute))
+				return pods
+			},
+			expected:	"",
+		},
+		{
+			name:	"broker failing for less than the custom threshold",
+			modify: func(cluster *redpandav1alpha1.Cluster, pods []corev1.Pod) []corev1.Pod {
+				cluster.Spec.BrokerReplacement.FailureThreshold = &metav1.Duration{Duration: time.Hour}
+				failPod(&pods[1], 8, longAgo)
+				return pods
+			},
+			expected:	"",
+		},
+		{
+			name:	"broker restarted less than the minimum",
+			modify: func(_ *redpandav1alpha1.Cluster, pods []corev1.Pod) []corev1.Pod {
+				failPod(&pods[1], 4, longAgo)
+				return pods
+			},
+			expected:	"",
+		},
+		{
+			name:	"broker restarted more than the custom minimum",
+			modify: func(cluster *redpandav1alpha1.Cluster, pods []corev1.Pod) []corev1.Pod {
+				cluster.Spec.BrokerReplacement.MinRestarts = 2
+				failPod(&pods[1], 2, longAgo)
+				return pods
+			},
+			expected:	"cluster-1",
+		},
+		{
+			name:	"unready broker not crash looping",
+			modify: func(_ *redpandav1alpha1.Cluster, pods []corev1.Pod) []corev1.Pod {
+				failPod(&pods[1], 8, longAgo)
+				pods[1].Status.ContainerStatuses[0].State = corev1.ContainerState{
+					Running: &corev1.ContainerStateRunning{},
+				}
+				return pods
+			},
+			expected:	"",
+		},
+		{
+			name:	"another broker unready",
+			modify: func(_ *redpandav1alpha1.Cluster, pods []corev1.Pod) []corev1.Pod {
+				failPod(&pods[1], 8, longAgo)
+				pods[2].Status.Conditions[0].Status = corev1.ConditionFalse
+				return pods
+			},
+			expected:	"",
+		},
+		{
+			name:	"another broker missing",
+			modify: func(_ *redpandav1alpha1.Cluster, pods []corev1.Pod) []corev1.Pod {
+				failPod(&pods[1], 8, longAgo)
+				return pods[:2]
+			},
+			expected:	"",
+		},
+		{
+			name:	"several brokers failing",
+			modify: func(_ *redpandav1alpha1.Cluster, pods []corev1.Pod) []corev1.Pod {
+				failPod(&pods[0], 8, longAgo)
+				failPod(&pods[1], 8, longAgo)
+				return pods
+			},
+			expected:	"",
+		},
+		{
+			name:	"broker of a group failing",
+			modify: func(cluster *redpandav1alpha1.Cluster, pods []corev1.Pod) []corev1.Pod {
+				cluster.Spec.BrokerGroups = []redpandav1alpha1.BrokerGroup{{Name: "big", Replicas: 1, FirstNodeID: 10}}
+				group := replacementPods(now)[0]
+				group.Name = "cluster-big-0"
+				failPod(&group, 8, longAgo)
+				return append(pods, group)
+			},
+			expected:	"cluster-big-0",
+		},
+		{
+			name:	"broker of a group missing",
+			modify: func(cluster *redpandav1alpha1.Cluster, pods []corev1.Pod) []corev1.Pod {
+				cluster.Spec.BrokerGroups = []redpandav1alpha1.BrokerGroup{{Name: "big", Replicas: 1, FirstNodeID: 10}}
+				failPod(&pods[1], 8, longAgo)
+				return pods
+			},
+			expected:	"",
+		},
+		{
+			name:	"failed broker deleted",
+			modify: func(_ *redpandav1alpha1.Cluster, pods []corev1.Pod) []corev1.Pod {
+				failPod(&pods[1], 8, longAgo)
+				pods[1].DeletionTimestamp = &metav1.Time{Time: now}
+				return pods
+			},
+			expected:	"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			cluster := &redpandav1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}}
+			cluster.Spec.Replicas = pointer.Int32Ptr(3)
+			cluster.Spec.BrokerReplacement.Enabled = true
+
+			pods := tt.modify(cluster, replacementPods(now))
+
+			name := ""
+			if pod := replacementCandidate(cluster, pods, now); pod != nil {
+				name = pod.Name
+			}
+			g.Expect(name).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestBrokerDead(t *testing.T) {
+	g := NewWithT(t)
+
+	brokers := []admin.Broker{
+		{NodeID: 0, IsAlive: pointer.BoolPtr(true)},
+		{NodeID: 1, IsAlive: pointer.BoolPtr(false)},
+		{NodeID: 2},
+	}
+
+	g.Expect(brokerDead(brokers, 0)).To(BeFalse())
+	g.Expect(brokerDead(brokers, 1)).To(BeTrue())
+	// The liveness is not reported by the older versions
+	g.Expect(brokerDead(brokers, 2)).To(BeFalse())
+	// The broker is unknown to the queried one
+	g.Expect(brokerDead(brokers, 3)).To(BeFalse())
+}
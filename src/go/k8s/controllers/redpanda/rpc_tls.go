@@ -0,0 +1,228 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	rpcTLSVolume	= "rpc-tls"
+	rpcTLSDir	= "/etc/tls/certs/rpc"
+
+	// Reasons of the Degraded condition set by the RPC certificate check
+	reasonRPCCertificateNames	= "RPCCertificateNamesMismatch"
+	reasonRPCCertificateValid	= "RPCCertificateValid"
+)
+
+var errInvalidCertificate = errors.New("secret does not contain a valid PEM encoded " + corev1.TLSCertKey)
+
+// rpcServerTLS returns the rpc_server_tls configuration, or nil when the
+// RPC server TLS is disabled. The brokers present the shared certificate
+// to each other, so the client authentication is always required.
+func rpcServerTLS(cluster *redpandav1alpha1.Cluster) map[string]interface{} {
+	if rpcCertSecretName(cluster) == "" {
+		return nil
+	}
+
+	return map[string]interface{}{
+		"enabled":		true,
+		"require_client_auth":	true,
+		"cert_file":		filepath.Join(rpcTLSDir, corev1.TLSCertKey),
+		"key_file":		filepath.Join(rpcTLSDir, corev1.TLSPrivateKeyKey),
+		"truststore_file":	filepath.Join(rpcTLSDir, caCertKey),
+	}
+}
+
+// rpcCertSecretName returns the name of the Secret holding the RPC server
+// certificate, or an empty string when the RPC server TLS is disabled
+func rpcCertSecretName(cluster *redpandav1alpha1.Cluster) string {
+	if cluster.Spec.TLS.SelfSigned {
+		return cluster.Name + selfSignedTLSSuffix
+	}
+
+	if rpcTLS := cluster.Spec.Configuration.RPCServer.TLS; rpcTLS.Enabled && rpcTLS.CertSecretRef != nil {
+		return rpcTLS.CertSecretRef.Name
+	}
+
+	return ""
+}
+
+// addRPCTLS mounts the RPC certificate Secret in the redpanda container
+func addRPCTLS(spec *corev1.PodSpec, secretName string) {
+	addCertificateVolume(spec, rpcTLSVolume, rpcTLSDir, secretName)
+}
+
+// addCertificateVolume mounts the certificate Secret in the directory of
+// the redpanda container
+func addCertificateVolume(spec *corev1.PodSpec, volume, dir, secretName string) {
+	spec.Volumes = append(spec.Volumes, corev1.Volume{
+		Name:	volume,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{SecretName: secretName},
+		},
+	})
+
+	for i := range spec.Containers {
+		if spec.Containers[i].Name == redpandaContainerName {
+			spec.Containers[i].VolumeMounts = append(spec.Containers[i].VolumeMounts, corev1.VolumeMount{
+				Name:		volume,
+				MountPath:	dir,
+				ReadOnly:	true,
+			})
+		}
+	}
+}
+
+// restoreCertificateVolume adds, updates or removes the volume mounting the
+// certificate Secret in the redpanda container, so that the TLS enabled or
+// disabled on an existing Cluster reaches the brokers. An empty secretName
+// removes the volume. Only the Secret name of the volume is compared, its
+// mode is defaulted by the API server. It returns true when the pod spec
+// changed.
+func restoreCertificateVolume(spec *corev1.PodSpec, volume, dir, secretName string) bool {
+	modified := false
+	index := -1
+
+	for i := range spec.Volumes {
+		if spec.Volumes[i].Name == volume {
+			index = i
+		}
+	}
+
+	source := corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: secretName}}
+
+	switch {
+	case secretName == "" && index >= 0:
+		spec.Volumes = append(spec.Volumes[:index], spec.Volumes[index+1:]...)
+		modified = true
+	case secretName != "" && index < 0:
+		spec.Volumes = append(spec.Volumes, corev1.Volume{Name: volume, VolumeSource: source})
+		modified = true
+	case secretName != "" && (spec.Volumes[index].Secret == nil || spec.Volumes[index].Secret.SecretName != secretName):
+		spec.Volumes[index].VolumeSource = source
+		modified = true
+	}
+
+	desired := corev1.VolumeMount{Name: volume, MountPath: dir, ReadOnly: true}
+
+	if restoreVolumeMount(spec, desired, secretName != "") {
+		modified = true
+	}
+
+	return modified
+}
+
+// restoreVolumeMount adds, updates or removes the mount of the redpanda
+// container with the name of the desired one. It returns true when the pod
+// spec changed.
+func restoreVolumeMount(spec *corev1.PodSpec, desired corev1.VolumeMount, mounted bool) bool {
+	modified := false
+
+	for i := range spec.Containers {
+		c := &spec.Containers[i]
+		if c.Name != redpandaContainerName {
+			continue
+		}
+
+		mount := -1
+
+		for j := range c.VolumeMounts {
+			if c.VolumeMounts[j].Name == desired.Name {
+				mount = j
+			}
+		}
+
+		switch {
+		case !mounted && mount >= 0:
+			c.VolumeMounts = append(c.VolumeMounts[:mount], c.VolumeMounts[mount+1:]...)
+			modified = true
+		case mounted && mount < 0:
+			c.VolumeMounts = append(c.VolumeMounts, desired)
+			modified = true
+		case mounted && c.VolumeMounts[mount] != desired:
+			c.VolumeMounts[mount] = desired
+			modified = true
+		}
+	}
+
+	return modified
+}
+
+// reconcileRPCCertificate reports the Cluster as degraded when the shared
+// RPC certificate does not cover the DNS name of every broker, as the
+// brokers would then fail to verify each other
+func (r *ClusterReconciler) reconcileRPCCertificate(
+	ctx context.Context,
+	cluster *redpandav1alpha1.Cluster,
+	status *redpandav1alpha1.ClusterStatus,
+) error {
+	rpcTLS := cluster.Spec.Configuration.RPCServer.TLS
+	if !rpcTLS.Enabled || rpcTLS.CertSecretRef == nil {
+		clearDegraded(status, reasonRPCCertificateValid, reasonRPCCertificateNames)
+
+		return nil
+	}
+
+	ref := rpcTLS.CertSecretRef
+
+	cert, err := r.fetchCertificate(ctx, cluster.Namespace, ref.Name, "RPC")
+	if err != nil {
+		return err
+	}
+
+	for _, name := range brokerPodNames(cluster) {
+		host := name + "." + serviceAddress(cluster)
+		if err := cert.VerifyHostname(host); err != nil {
+			setDegraded(status, reasonRPCCertificateNames,
+				fmt.Sprintf("The RPC certificate of secret %s does not cover the broker %s", ref.Name, host))
+
+			return nil
+		}
+	}
+
+	clearDegraded(status, reasonRPCCertificateValid, reasonRPCCertificateNames)
+
+	return nil
+}
+
+// fetchCertificate returns the certificate of the tls.crt key of the
+// Secret, the kind of the certificate is only used by the errors
+func (r *ClusterReconciler) fetchCertificate(
+	ctx context.Context, namespace, name, kind string,
+) (*x509.Certificate, error) {
+	var secret corev1.Secret
+
+	err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, &secret)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch %s certificate secret %s/%s: %w", kind, namespace, name, err)
+	}
+
+	block, _ := pem.Decode(secret.Data[corev1.TLSCertKey])
+	if block == nil {
+		return nil, fmt.Errorf("invalid %s certificate secret %s/%s: %w", kind, namespace, name, errInvalidCertificate)
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s certificate secret %s/%s: %w", kind, namespace, name, err)
+	}
+
+	return cert, nil
+}
@@ -0,0 +1,234 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Redpanda RPC server TLS", func() {
+	Context("When the RPC server TLS is enabled", func() {
+		It("Should render the mutual TLS configuration and mount the certificate", func() {
+			key := testKey("redpanda-rpc-tls")
+			createRPCCertificate(key.Name+"-rpc", "*."+key.Name+".default.svc.cluster.local")
+
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.Configuration.RPCServer.TLS = v1alpha1.RPCServerTLS{
+				Enabled:	true,
+				CertSecretRef:	&corev1.LocalObjectReference{Name: key.Name + "-rpc"},
+			}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			cfg := eventuallyRedpandaConfig(key)
+			Expect(cfg).Should(HaveKeyWithValue("rpc_server_tls", And(
+				HaveKeyWithValue("enabled", true),
+				HaveKeyWithValue("require_client_auth", true),
+				HaveKeyWithValue("cert_file", "/etc/tls/certs/rpc/tls.crt"),
+				HaveKeyWithValue("key_file", "/etc/tls/certs/rpc/tls.key"),
+				HaveKeyWithValue("truststore_file", "/etc/tls/certs/rpc/ca.crt"),
+			)))
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+			Expect(sts.Spec.Template.Spec.Volumes).Should(ContainElement(corev1.Volume{
+				Name:	"rpc-tls",
+				VolumeSource: corev1.VolumeSource{
+					Secret: &corev1.SecretVolumeSource{
+						SecretName:	key.Name + "-rpc",
+						DefaultMode:	&secretDefaultMode,
+					},
+				},
+			}))
+			Expect(sts.Spec.Template.Spec.Containers[0].VolumeMounts).Should(ContainElement(corev1.VolumeMount{
+				Name:		"rpc-tls",
+				MountPath:	"/etc/tls/certs/rpc",
+				ReadOnly:	true,
+			}))
+			Eventually(func() metav1.ConditionStatus {
+				return clusterCondition(key, v1alpha1.ClusterDegraded)
+			}, timeout, interval).Should(Equal(metav1.ConditionFalse))
+		})
+
+		It("Should report a certificate not covering the brokers", func() {
+			key := testKey("redpanda-rpc-tls-names")
+			createRPCCertificate(key.Name+"-rpc", "redpanda.example.com")
+
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.Configuration.RPCServer.TLS = v1alpha1.RPCServerTLS{
+				Enabled:	true,
+				CertSecretRef:	&corev1.LocalObjectReference{Name: key.Name + "-rpc"},
+			}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			Eventually(func() string {
+				return clusterConditionReason(key, v1alpha1.ClusterDegraded)
+			}, timeout, interval).Should(Equal("RPCCertificateNamesMismatch"))
+		})
+	})
+
+	Context("When the RPC server TLS is toggled on an existing Cluster", func() {
+		It("Should mount the certificate until it is disabled", func() {
+			key := testKey("redpanda-rpc-tls-toggle")
+			createRPCCertificate(key.Name+"-rpc", "*."+key.Name+".default.svc.cluster.local")
+			redpandaCluster := testCluster(key.Name)
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &appsv1.StatefulSet{})
+			}, timeout, interval).Should(Succeed())
+
+			By("Enabling the RPC server TLS")
+			setRPCServerTLS(key, v1alpha1.RPCServerTLS{
+				Enabled:	true,
+				CertSecretRef:	&corev1.LocalObjectReference{Name: key.Name + "-rpc"},
+			})
+			Eventually(func() []corev1.VolumeMount {
+				return redpandaVolumeMounts(key)
+			}, timeout, interval).Should(ContainElement(corev1.VolumeMount{
+				Name:		"rpc-tls",
+				MountPath:	"/etc/tls/certs/rpc",
+				ReadOnly:	true,
+			}))
+			Expect(statefulSetVolumeNames(key)).Should(ContainElement("rpc-tls"))
+
+			By("Disabling the RPC server TLS")
+			setRPCServerTLS(key, v1alpha1.RPCServerTLS{})
+			Eventually(func() []string {
+				return volumeMountNames(redpandaVolumeMounts(key))
+			}, timeout, interval).ShouldNot(ContainElement("rpc-tls"))
+			Expect(statefulSetVolumeNames(key)).ShouldNot(ContainElement("rpc-tls"))
+		})
+	})
+
+	Context("When the RPC server TLS is disabled", func() {
+		It("Should not render any RPC TLS configuration", func() {
+			key := testKey("redpanda-no-rpc-tls")
+			Expect(k8sClient.Create(context.Background(), testCluster(key.Name))).Should(Succeed())
+
+			Expect(eventuallyRedpandaConfig(key)).ShouldNot(HaveKey("rpc_server_tls"))
+		})
+	})
+})
+
+// setRPCServerTLS updates the RPC server TLS of the Cluster
+func setRPCServerTLS(key types.NamespacedName, tls v1alpha1.RPCServerTLS) {
+	Eventually(func() error {
+		var cluster v1alpha1.Cluster
+		if err := k8sClient.Get(context.Background(), key, &cluster); err != nil {
+			return err
+		}
+		cluster.Spec.Configuration.RPCServer.TLS = tls
+		return k8sClient.Update(context.Background(), &cluster)
+	}, timeout, interval).Should(Succeed())
+}
+
+// redpandaVolumeMounts returns the volume mounts of the redpanda container
+// of the StatefulSet
+func redpandaVolumeMounts(key types.NamespacedName) []corev1.VolumeMount {
+	var sts appsv1.StatefulSet
+	if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+		return nil
+	}
+
+	for _, c := range sts.Spec.Template.Spec.Containers {
+		if c.Name == "redpanda" {
+			return c.VolumeMounts
+		}
+	}
+
+	return nil
+}
+
+// volumeMountNames returns the names of the volume mounts
+func volumeMountNames(mounts []corev1.VolumeMount) []string {
+	names := make([]string, 0, len(mounts))
+	for _, m := range mounts {
+		names = append(names, m.Name)
+	}
+
+	return names
+}
+
+// statefulSetVolumeNames returns the names of the volumes of the
+// StatefulSet pod template
+func statefulSetVolumeNames(key types.NamespacedName) []string {
+	var sts appsv1.StatefulSet
+	if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(sts.Spec.Template.Spec.Volumes))
+	for _, v := range sts.Spec.Template.Spec.Volumes {
+		names = append(names, v.Name)
+	}
+
+	return names
+}
+
+// secretDefaultMode is the mode defaulted by the API server on Secret
+// volumes
+var secretDefaultMode int32 = 0644
+
+// createRPCCertificate creates a kubernetes.io/tls Secret holding a self
+// signed certificate for the given DNS name
+func createRPCCertificate(name, dnsName string) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:		name,
+			Namespace:	"default",
+		},
+		Type:	corev1.SecretTypeTLS,
+		Data:	rpcCertificateData(dnsName),
+	}
+	Expect(k8sClient.Create(context.Background(), secret)).Should(Succeed())
+}
+
+// rpcCertificateData returns the keys of a TLS Secret holding a self
+// signed certificate for the given DNS name, which expires in an hour
+func rpcCertificateData(dnsName string) map[string][]byte {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).ShouldNot(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber:	big.NewInt(1),
+		DNSNames:	[]string{dnsName},
+		NotBefore:	time.Now(),
+		NotAfter:	time.Now().Add(time.Hour),
+		IsCA:		true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	Expect(err).ShouldNot(HaveOccurred())
+	keyDer, err := x509.MarshalECPrivateKey(priv)
+	Expect(err).ShouldNot(HaveOccurred())
+
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	return map[string][]byte{
+		corev1.TLSCertKey:		cert,
+		corev1.TLSPrivateKeyKey:	pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}),
+		"ca.crt":			cert,
+	}
+}
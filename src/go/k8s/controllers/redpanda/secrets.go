@@ -0,0 +1,148 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// secretsHashAnnotation is the pod template annotation holding the digest
+// of the referenced Secrets. Rotating one of them changes the annotation,
+// which rolls the brokers out.
+const secretsHashAnnotation = "redpanda.vectorized.io/secrets-hash"
+
+// hashedSecretKeys are the Secret keys read by the brokers, sorted. The
+// digest only covers them, so that the metadata other tools keep in the
+// same Secrets, e.g. the time of the last rotation, does not roll the
+// brokers out on every reconciliation.
+var hashedSecretKeys = []string{
+	cloudStorageAccessKeyKey,
+	caCertKey,
+	passwordKey,
+	cloudStorageSecretKeyKey,
+	corev1.TLSCertKey,
+	corev1.TLSPrivateKeyKey,
+}
+
+// referencedSecrets returns the names of the user Secrets a Cluster
+// depends on
+func referencedSecrets(cluster *redpandav1alpha1.Cluster) []string {
+	var names []string
+	if ref := cluster.Spec.Configuration.AdminAPI.TLS.CASecretRef; ref != nil {
+		names = append(names, ref.Name)
+	}
+
+	if ref := cluster.Spec.SASL.SuperuserSecretRef; ref != nil {
+		names = append(names, ref.Name)
+	}
+
+	if ref := cluster.Spec.Configuration.RPCServer.TLS.CertSecretRef; ref != nil {
+		names = append(names, ref.Name)
+	}
+
+	if ref := cluster.Spec.Configuration.KafkaAPITLS.CertSecretRef; ref != nil && cluster.Spec.Configuration.KafkaAPITLS.Enabled {
+		names = append(names, ref.Name)
+	}
+
+	if name := pandaProxyCertSecretName(cluster); name != "" {
+		names = append(names, name)
+	}
+
+	if ref := cluster.Spec.CloudStorage.CredentialsSecretRef; ref != nil && cluster.Spec.CloudStorage.Enabled {
+		names = append(names, ref.Name)
+	}
+
+	return names
+}
+
+// secretsHash returns the digest of the keys of the referenced Secrets the
+// brokers read, or an empty string when the Cluster does not reference any
+// Secret
+func (r *ClusterReconciler) secretsHash(
+	ctx context.Context, cluster *redpandav1alpha1.Cluster,
+) (string, error) {
+	names := referencedSecrets(cluster)
+	// Renewing the generated certificate rolls the brokers out as well
+	if cluster.Spec.TLS.SelfSigned {
+		names = append(names, cluster.Name+selfSignedTLSSuffix)
+	}
+
+	if len(names) == 0 {
+		return "", nil
+	}
+
+	sort.Strings(names)
+
+	h := sha256.New()
+
+	for _, name := range names {
+		var secret corev1.Secret
+
+		err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: cluster.Namespace}, &secret)
+		if err != nil {
+			return "", fmt.Errorf("unable to fetch secret %s/%s: %w", cluster.Namespace, name, err)
+		}
+
+		fmt.Fprintf(h, "%s\n", name)
+
+		for _, k := range hashedSecretKeys {
+			if v, ok := secret.Data[k]; ok {
+				fmt.Fprintf(h, "%s=%x\n", k, v)
+			}
+		}
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// podAnnotations returns the operator managed pod template annotations
+func (r *ClusterReconciler) podAnnotations(
+	ctx context.Context, cluster *redpandav1alpha1.Cluster,
+) (map[string]string, error) {
+	annotations := map[string]string{
+		configuratorHashAnnotation: configuratorHash(cluster),
+	}
+
+	if restartedAt := cluster.Annotations[redpandav1alpha1.RestartedAtAnnotation]; restartedAt != "" {
+		annotations[redpandav1alpha1.RestartedAtAnnotation] = restartedAt
+	}
+
+	// The digest is left out until the reloadable properties are known,
+	// the pod template then keeps its current one
+	if hr := cluster.Status.HotReload; cluster.Spec.HotReload.Enabled && hr != nil && hr.RestartHash != "" {
+		annotations[configHashAnnotation] = hr.RestartHash
+	}
+
+	hash, err := r.secretsHash(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	if hash != "" {
+		annotations[secretsHashAnnotation] = hash
+	}
+
+	if hash, err = r.ioPropertiesHash(ctx, cluster); err != nil {
+		return nil, err
+	}
+
+	if hash != "" {
+		annotations[ioPropertiesHashAnnotation] = hash
+	}
+
+	return annotations, nil
+}
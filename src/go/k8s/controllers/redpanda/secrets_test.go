@@ -0,0 +1,142 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const secretsHashAnnotation = "redpanda.vectorized.io/secrets-hash"
+
+var _ = Describe("Redpanda referenced Secrets", func() {
+	Context("When a referenced Secret is rotated", func() {
+		It("Should reconcile and roll the brokers out", func() {
+			key := testKey("redpanda-secret-rotation")
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:		key.Name + "-credentials",
+					Namespace:	key.Namespace,
+				},
+				Data:	map[string][]byte{"password": []byte("first")},
+			}
+			Expect(k8sClient.Create(context.Background(), secret)).Should(Succeed())
+
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.SASL = v1alpha1.SASLConfig{
+				Enabled:		true,
+				SuperuserSecretRef:	&corev1.LocalObjectReference{Name: secret.Name},
+			}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() string {
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return ""
+				}
+				return sts.Spec.Template.Annotations[secretsHashAnnotation]
+			}, timeout, interval).ShouldNot(BeEmpty())
+			firstHash := sts.Spec.Template.Annotations[secretsHashAnnotation]
+
+			By("Updating the referenced Secret")
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), types.NamespacedName{Name: secret.Name, Namespace: key.Namespace}, secret); err != nil {
+					return err
+				}
+				secret.Data["password"] = []byte("second")
+				return k8sClient.Update(context.Background(), secret)
+			}, timeout, interval).Should(Succeed())
+
+			Eventually(func() string {
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return ""
+				}
+				return sts.Spec.Template.Annotations[secretsHashAnnotation]
+			}, timeout, interval).ShouldNot(Or(BeEmpty(), Equal(firstHash)))
+		})
+	})
+
+	Context("When the Cluster is reconciled again without any change", func() {
+		It("Should keep the pod template annotations and not roll the brokers out", func() {
+			key := testKey("redpanda-stable-hashes")
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:		key.Name + "-credentials",
+					Namespace:	key.Namespace,
+				},
+				Data:	map[string][]byte{"password": []byte("first")},
+			}
+			Expect(k8sClient.Create(context.Background(), secret)).Should(Succeed())
+
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.HotReload.Enabled = true
+			redpandaCluster.Spec.SASL = v1alpha1.SASLConfig{
+				Enabled:		true,
+				SuperuserSecretRef:	&corev1.LocalObjectReference{Name: secret.Name},
+			}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() string {
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return ""
+				}
+				return sts.Spec.Template.Annotations[secretsHashAnnotation]
+			}, timeout, interval).ShouldNot(BeEmpty())
+			// No property requiring a restart changed yet
+			Expect(sts.Spec.Template.Annotations).ShouldNot(HaveKey("redpanda.vectorized.io/config-hash"))
+			annotations := sts.Spec.Template.Annotations
+			generation := sts.Generation
+
+			By("Reconciling twice with a volatile key added to the Secret")
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), types.NamespacedName{Name: secret.Name, Namespace: key.Namespace}, secret); err != nil {
+					return err
+				}
+				secret.Data["rotated-at"] = []byte(time.Now().String())
+				return k8sClient.Update(context.Background(), secret)
+			}, timeout, interval).Should(Succeed())
+			for i := 0; i < 2; i++ {
+				updateCluster(key, func(c *v1alpha1.Cluster) {
+					c.Annotations = map[string]string{"resync": time.Now().String()}
+				})
+			}
+
+			Consistently(func() int64 {
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return 0
+				}
+				return sts.Generation
+			}, "2s", interval).Should(Equal(generation))
+			Expect(sts.Spec.Template.Annotations).Should(Equal(annotations))
+		})
+	})
+
+	Context("When no Secret is referenced", func() {
+		It("Should not annotate the pod template", func() {
+			key := testKey("redpanda-no-secrets")
+			Expect(k8sClient.Create(context.Background(), testCluster(key.Name))).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+			Expect(sts.Spec.Template.Annotations).ShouldNot(HaveKey(secretsHashAnnotation))
+		})
+	})
+})
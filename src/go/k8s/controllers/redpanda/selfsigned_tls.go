@@ -0,0 +1,353 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"reflect"
+	"time"
+
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	selfSignedCASuffix	= "-selfsigned-ca"
+	selfSignedTLSSuffix	= "-selfsigned-tls"
+	// selfSignedClientSuffix is the Secret of the client certificate of
+	// the kafka API clients deployed by the operator, e.g. the Console
+	selfSignedClientSuffix	= "-selfsigned-client"
+
+	caValidity		= 10 * 365 * 24 * time.Hour
+	certificateValidity	= 90 * 24 * time.Hour
+	// renewBefore is the remaining validity under which the generated
+	// certificates are renewed
+	renewBefore	= 30 * 24 * time.Hour
+)
+
+// keyPair is a certificate and its private key
+type keyPair struct {
+	cert	*x509.Certificate
+	key	crypto.Signer
+}
+
+// selfSignedDNSNames returns the subject alternative names of the generated
+// server certificate: the DNS names of every broker, through the headless
+// service wildcard, the names of the services of the Cluster and the per
+// broker addresses which are host names
+func selfSignedDNSNames(cluster *redpandav1alpha1.Cluster) []string {
+	names := []string{"*." + serviceAddress(cluster)}
+
+	for _, svc := range []string{cluster.Name, cluster.Name + adminSuffix, cluster.Name + externalSuffix} {
+		names = append(names,
+			svc,
+			svc+"."+cluster.Namespace,
+			svc+"."+cluster.Namespace+".svc",
+			svc+"."+cluster.Namespace+".svc.cluster.local")
+	}
+
+	for _, address := range cluster.Spec.ExternalConnectivity.PerBrokerAddresses {
+		if net.ParseIP(address) == nil {
+			names = append(names, address)
+		}
+	}
+
+	return names
+}
+
+// selfSignedIPAddresses returns the per broker addresses which are IP
+// addresses, in the form they are parsed from a certificate
+func selfSignedIPAddresses(cluster *redpandav1alpha1.Cluster) []net.IP {
+	var ips []net.IP
+
+	for _, address := range cluster.Spec.ExternalConnectivity.PerBrokerAddresses {
+		ip := net.ParseIP(address)
+		if ip == nil {
+			continue
+		}
+
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+
+		ips = append(ips, ip)
+	}
+
+	return ips
+}
+
+// needsRenewal returns true when the certificate expires within the
+// renewal window
+func needsRenewal(cert *x509.Certificate, now time.Time) bool {
+	return !now.Add(renewBefore).Before(cert.NotAfter)
+}
+
+// generateCA returns a new self-signed CA of the Cluster
+func generateCA(
+	cluster *redpandav1alpha1.Cluster, now time.Time,
+) (*keyPair, error) {
+	template := &x509.Certificate{
+		Subject:		pkix.Name{CommonName: cluster.Name + " CA", Organization: []string{"Redpanda"}},
+		NotBefore:		now.Add(-time.Hour),
+		NotAfter:		now.Add(caValidity),
+		KeyUsage:		x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid:	true,
+		IsCA:			true,
+	}
+
+	return signCertificate(template, nil)
+}
+
+// issueCertificate returns a new server certificate signed by the CA. The
+// brokers also present it as client certificate to each other. It never
+// outlives the CA.
+func issueCertificate(
+	ca *keyPair, dnsNames []string, ips []net.IP, now time.Time,
+) (*keyPair, error) {
+	notAfter := now.Add(certificateValidity)
+	if notAfter.After(ca.cert.NotAfter) {
+		notAfter = ca.cert.NotAfter
+	}
+
+	template := &x509.Certificate{
+		Subject:	pkix.Name{CommonName: dnsNames[0], Organization: []string{"Redpanda"}},
+		DNSNames:	dnsNames,
+		IPAddresses:	ips,
+		NotBefore:	now.Add(-time.Hour),
+		NotAfter:	notAfter,
+		KeyUsage:	x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:	[]x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	return signCertificate(template, ca)
+}
+
+// issueClientCertificate returns a new client certificate signed by the
+// CA, which can not be used to serve the brokers APIs. It never outlives
+// the CA.
+func issueClientCertificate(
+	ca *keyPair, commonName string, now time.Time,
+) (*keyPair, error) {
+	notAfter := now.Add(certificateValidity)
+	if notAfter.After(ca.cert.NotAfter) {
+		notAfter = ca.cert.NotAfter
+	}
+
+	template := &x509.Certificate{
+		Subject:	pkix.Name{CommonName: commonName, Organization: []string{"Redpanda"}},
+		NotBefore:	now.Add(-time.Hour),
+		NotAfter:	notAfter,
+		KeyUsage:	x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:	[]x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	return signCertificate(template, ca)
+}
+
+// signCertificate generates a key and signs the template with the CA, or
+// self-signs it when the CA is nil
+func signCertificate(template *x509.Certificate, ca *keyPair) (*keyPair, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	template.SerialNumber = serial
+
+	parent, signer := template, crypto.Signer(key)
+	if ca != nil {
+		parent, signer = ca.cert, ca.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), signer)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	return &keyPair{cert: cert, key: key}, nil
+}
+
+// parseKeyPair decodes the tls.crt and tls.key keys of the Secret
+func parseKeyPair(secret *corev1.Secret) (*keyPair, error) {
+	pair, err := tls.X509KeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, errInvalidCertificate
+	}
+
+	return &keyPair{cert: cert, key: key}, nil
+}
+
+// buildKeyPairSecret returns the TLS Secret holding the key pair, and the
+// certificate of its CA under the ca.crt key when the CA is not nil
+func buildKeyPairSecret(
+	cluster *redpandav1alpha1.Cluster, name string, pair, ca *keyPair,
+) (*corev1.Secret, error) {
+	key, err := x509.MarshalPKCS8PrivateKey(pair.key)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:	cluster.Namespace,
+			Name:		name,
+			Labels:		cluster.Labels,
+		},
+		Type:	corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: pair.cert.Raw}),
+			corev1.TLSPrivateKeyKey:	pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}),
+		},
+	}
+
+	if ca != nil {
+		secret.Data[caCertKey] = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})
+	}
+
+	return secret, nil
+}
+
+// reconcileSelfSignedTLS makes sure the generated CA and server certificate
+// exist and are not about to expire. A new CA always comes with a new
+// server certificate. The client certificate is only issued while the
+// kafka API requires the client authentication.
+func (r *ClusterReconciler) reconcileSelfSignedTLS(
+	ctx context.Context, cluster *redpandav1alpha1.Cluster,
+) error {
+	if !cluster.Spec.TLS.SelfSigned {
+		return nil
+	}
+
+	now := time.Now()
+
+	ca, err := r.reconcileKeyPairSecret(ctx, cluster, cluster.Name+selfSignedCASuffix, nil,
+		func(pair *keyPair) bool {
+			return !needsRenewal(pair.cert, now)
+		},
+		func() (*keyPair, error) {
+			return generateCA(cluster, now)
+		})
+	if err != nil {
+		return err
+	}
+
+	dnsNames, ips := selfSignedDNSNames(cluster), selfSignedIPAddresses(cluster)
+
+	_, err = r.reconcileKeyPairSecret(ctx, cluster, cluster.Name+selfSignedTLSSuffix, ca,
+		func(pair *keyPair) bool {
+			return !needsRenewal(pair.cert, now) &&
+				pair.cert.CheckSignatureFrom(ca.cert) == nil &&
+				reflect.DeepEqual(pair.cert.DNSNames, dnsNames) &&
+				reflect.DeepEqual(pair.cert.IPAddresses, ips)
+		},
+		func() (*keyPair, error) {
+			return issueCertificate(ca, dnsNames, ips, now)
+		})
+	if err != nil || !cluster.Spec.Configuration.KafkaAPITLS.RequireClientAuth {
+		return err
+	}
+
+	_, err = r.reconcileKeyPairSecret(ctx, cluster, cluster.Name+selfSignedClientSuffix, ca,
+		func(pair *keyPair) bool {
+			return !needsRenewal(pair.cert, now) && pair.cert.CheckSignatureFrom(ca.cert) == nil
+		},
+		func() (*keyPair, error) {
+			return issueClientCertificate(ca, cluster.Name+" client", now)
+		})
+
+	return err
+}
+
+// reconcileKeyPairSecret returns the key pair of the Secret owned by the
+// Cluster, which is replaced by a generated one when it is missing,
+// invalid or expiring
+func (r *ClusterReconciler) reconcileKeyPairSecret(
+	ctx context.Context,
+	cluster *redpandav1alpha1.Cluster,
+	name string,
+	ca *keyPair,
+	valid func(*keyPair) bool,
+	generate func() (*keyPair, error),
+) (*keyPair, error) {
+	var secret corev1.Secret
+
+	err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: cluster.Namespace}, &secret)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("unable to fetch secret %s/%s: %w", cluster.Namespace, name, err)
+	}
+
+	exists := err == nil
+	if exists {
+		if err = r.ensureOwner(ctx, cluster, &secret); err != nil {
+			return nil, err
+		}
+
+		if pair, parseErr := parseKeyPair(&secret); parseErr == nil && valid(pair) {
+			return pair, nil
+		}
+	}
+
+	pair, err := generate()
+	if err != nil {
+		return nil, err
+	}
+
+	desired, err := buildKeyPairSecret(cluster, name, pair, ca)
+	if err != nil {
+		return nil, err
+	}
+
+	if exists {
+		secret.Data = desired.Data
+
+		return pair, r.Update(ctx, &secret)
+	}
+
+	if err = controllerutil.SetControllerReference(cluster, desired, r.Scheme); err != nil {
+		return nil, err
+	}
+
+	return pair, r.Create(ctx, desired)
+}
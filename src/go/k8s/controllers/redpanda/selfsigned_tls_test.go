@@ -0,0 +1,197 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda_test
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Redpanda self-signed TLS", func() {
+	Context("When the self-signed certificate is enabled", func() {
+		It("Should generate a CA and a server certificate covering the brokers and services", func() {
+			key := testKey("redpanda-selfsigned")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.TLS.SelfSigned = true
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			ca := eventuallyCertificate(testKey(key.Name + "-selfsigned-ca"))
+			Expect(ca.IsCA).Should(BeTrue())
+
+			secret := eventuallySecret(testKey(key.Name + "-selfsigned-tls"))
+			Expect(secret.Type).Should(Equal(corev1.SecretTypeTLS))
+			Expect(metav1.IsControlledBy(&secret, redpandaCluster)).Should(BeTrue())
+
+			cert := parseCertificate(secret.Data[corev1.TLSCertKey])
+			Expect(cert.CheckSignatureFrom(ca)).Should(Succeed())
+			Expect(parseCertificate(secret.Data["ca.crt"]).Equal(ca)).Should(BeTrue())
+			Expect(cert.NotAfter).Should(BeTemporally(">", time.Now().Add(60*24*time.Hour)))
+			for _, host := range []string{
+				key.Name + "-0." + key.Name + ".default.svc.cluster.local",
+				key.Name + "-2." + key.Name + ".default.svc.cluster.local",
+				key.Name + ".default.svc",
+				key.Name + "-admin.default.svc.cluster.local",
+				key.Name + "-external.default",
+			} {
+				Expect(cert.VerifyHostname(host)).Should(Succeed(), host)
+			}
+
+			Expect(eventuallyRedpandaConfig(key)).Should(HaveKeyWithValue("rpc_server_tls",
+				HaveKeyWithValue("enabled", true)))
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+			Expect(sts.Spec.Template.Spec.Volumes).Should(ContainElement(corev1.Volume{
+				Name:	"rpc-tls",
+				VolumeSource: corev1.VolumeSource{
+					Secret: &corev1.SecretVolumeSource{
+						SecretName:	key.Name + "-selfsigned-tls",
+						DefaultMode:	&secretDefaultMode,
+					},
+				},
+			}))
+		})
+
+		It("Should issue a client certificate when the kafka API requires one", func() {
+			key := testKey("redpanda-selfsigned-client")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.TLS.SelfSigned = true
+			redpandaCluster.Spec.Configuration.KafkaAPITLS = v1alpha1.KafkaAPITLS{Enabled: true, RequireClientAuth: true}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			ca := eventuallyCertificate(testKey(key.Name + "-selfsigned-ca"))
+			secret := eventuallySecret(testKey(key.Name + "-selfsigned-client"))
+			Expect(metav1.IsControlledBy(&secret, redpandaCluster)).Should(BeTrue())
+
+			cert := parseCertificate(secret.Data[corev1.TLSCertKey])
+			Expect(cert.CheckSignatureFrom(ca)).Should(Succeed())
+			Expect(cert.ExtKeyUsage).Should(Equal([]x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}))
+			Expect(secret.Data[corev1.TLSPrivateKeyKey]).ShouldNot(Equal(
+				eventuallySecret(testKey(key.Name + "-selfsigned-tls")).Data[corev1.TLSPrivateKeyKey]))
+		})
+
+		It("Should renew the server certificate near expiry and roll the brokers out", func() {
+			key := testKey("redpanda-selfsigned-renewal")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.TLS.SelfSigned = true
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			tlsKey := testKey(key.Name + "-selfsigned-tls")
+			eventuallySecret(tlsKey)
+			ca := eventuallyCertificate(testKey(key.Name + "-selfsigned-ca"))
+
+			var sts appsv1.StatefulSet
+			Eventually(func() string {
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return ""
+				}
+				return sts.Spec.Template.Annotations["redpanda.vectorized.io/secrets-hash"]
+			}, timeout, interval).ShouldNot(BeEmpty())
+			firstHash := sts.Spec.Template.Annotations["redpanda.vectorized.io/secrets-hash"]
+
+			By("Replacing the certificate with one expiring in an hour")
+			Eventually(func() error {
+				var secret corev1.Secret
+				if err := k8sClient.Get(context.Background(), tlsKey, &secret); err != nil {
+					return err
+				}
+				secret.Data = rpcCertificateData("*." + key.Name + ".default.svc.cluster.local")
+				return k8sClient.Update(context.Background(), &secret)
+			}, timeout, interval).Should(Succeed())
+
+			Eventually(func() time.Time {
+				return eventuallyCertificate(tlsKey).NotAfter
+			}, timeout, interval).Should(BeTemporally(">", time.Now().Add(60*24*time.Hour)))
+			Expect(eventuallyCertificate(tlsKey).CheckSignatureFrom(ca)).Should(Succeed())
+
+			Eventually(func() string {
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return firstHash
+				}
+				return sts.Spec.Template.Annotations["redpanda.vectorized.io/secrets-hash"]
+			}, timeout, interval).ShouldNot(Equal(firstHash))
+		})
+
+		It("Should mount the certificate when enabled on an existing Cluster", func() {
+			key := testKey("redpanda-selfsigned-existing")
+			Expect(k8sClient.Create(context.Background(), testCluster(key.Name))).Should(Succeed())
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &appsv1.StatefulSet{})
+			}, timeout, interval).Should(Succeed())
+			Expect(statefulSetVolumeNames(key)).ShouldNot(ContainElement("rpc-tls"))
+
+			Eventually(func() error {
+				var cluster v1alpha1.Cluster
+				if err := k8sClient.Get(context.Background(), key, &cluster); err != nil {
+					return err
+				}
+				cluster.Spec.TLS.SelfSigned = true
+				return k8sClient.Update(context.Background(), &cluster)
+			}, timeout, interval).Should(Succeed())
+
+			eventuallySecret(testKey(key.Name + "-selfsigned-tls"))
+			Eventually(func() []corev1.Volume {
+				var sts appsv1.StatefulSet
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return nil
+				}
+				return sts.Spec.Template.Spec.Volumes
+			}, timeout, interval).Should(ContainElement(corev1.Volume{
+				Name:	"rpc-tls",
+				VolumeSource: corev1.VolumeSource{
+					Secret: &corev1.SecretVolumeSource{
+						SecretName:	key.Name + "-selfsigned-tls",
+						DefaultMode:	&secretDefaultMode,
+					},
+				},
+			}))
+			Expect(volumeMountNames(redpandaVolumeMounts(key))).Should(ContainElement("rpc-tls"))
+		})
+	})
+})
+
+// eventuallySecret waits for the Secret to exist and returns it
+func eventuallySecret(key types.NamespacedName) corev1.Secret {
+	var secret corev1.Secret
+	Eventually(func() error {
+		return k8sClient.Get(context.Background(), key, &secret)
+	}, timeout, interval).Should(Succeed())
+
+	return secret
+}
+
+// eventuallyCertificate returns the tls.crt certificate of the Secret
+func eventuallyCertificate(key types.NamespacedName) *x509.Certificate {
+	secret := eventuallySecret(key)
+
+	return parseCertificate(secret.Data[corev1.TLSCertKey])
+}
+
+func parseCertificate(data []byte) *x509.Certificate {
+	block, _ := pem.Decode(data)
+	Expect(block).ShouldNot(BeNil())
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	Expect(err).ShouldNot(HaveOccurred())
+
+	return cert
+}
@@ -0,0 +1,458 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	"context"
+	"fmt"
+
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	externalSuffix	= "-external"
+	adminSuffix	= "-admin"
+	clientSuffix	= "-client"
+)
+
+const reasonServiceTypeChanged = "ServiceTypeChanged"
+
+// serviceType returns the type of the service used by the clients
+func serviceType(cluster *redpandav1alpha1.Cluster) redpandav1alpha1.ServiceType {
+	if cluster.Spec.Service.Type == "" {
+		return redpandav1alpha1.ServiceTypeHeadless
+	}
+
+	return cluster.Spec.Service.Type
+}
+
+// reconcileService creates the headless service governing the
+// StatefulSet, and the ClusterIP service of the clients when the Cluster
+// requests it. As the cluster IP of a service is immutable, a governing
+// service which lost its headless type is deleted and created again. A
+// selector edited outside of the operator is restored, otherwise the
+// service stops matching the brokers and their DNS records disappear. The
+// ports follow the kafka API port of the Cluster.
+func (r *ClusterReconciler) reconcileService(
+	ctx context.Context,
+	cluster *redpandav1alpha1.Cluster,
+	status *redpandav1alpha1.ClusterStatus,
+) error {
+	var svc corev1.Service
+
+	err := r.Get(ctx, types.NamespacedName{Name: cluster.Name, Namespace: cluster.Namespace}, &svc)
+	if errors.IsNotFound(err) {
+		return r.createService(ctx, cluster, r.Scheme)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err = r.ensureOwner(ctx, cluster, &svc); err != nil {
+		return err
+	}
+
+	if svc.Spec.ClusterIP == corev1.ClusterIPNone {
+		desiredSvc := buildService(cluster)
+
+		// The starting brokers only resolve each other while their
+		// addresses are published before they are ready
+		portsModified := restoreServicePorts(&svc, desiredSvc.Spec.Ports)
+		if portsModified || !labels.Equals(svc.Spec.Selector, cluster.Labels) || !svc.Spec.PublishNotReadyAddresses {
+			svc.Spec.Selector = cluster.Labels
+			svc.Spec.PublishNotReadyAddresses = true
+
+			if err = r.Update(ctx, &svc); err != nil {
+				return err
+			}
+		}
+
+		return r.reconcileClientService(ctx, cluster)
+	}
+
+	if err = r.Delete(ctx, &svc); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:		redpandav1alpha1.ClusterServiceRecreated,
+		Status:		metav1.ConditionTrue,
+		Reason:		reasonServiceTypeChanged,
+		Message:	fmt.Sprintf("Service %s was recreated as a headless service", svc.Name),
+	})
+
+	if err = r.createService(ctx, cluster, r.Scheme); err != nil {
+		return err
+	}
+
+	return r.reconcileClientService(ctx, cluster)
+}
+
+// reconcileClientService creates the ClusterIP service of the clients
+// when the Cluster requests it, and deletes it otherwise
+func (r *ClusterReconciler) reconcileClientService(
+	ctx context.Context, cluster *redpandav1alpha1.Cluster,
+) error {
+	var svc corev1.Service
+
+	desired := buildClientService(cluster)
+
+	err := r.Get(ctx, types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, &svc)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+
+	if serviceType(cluster) != redpandav1alpha1.ServiceTypeClusterIP {
+		if err != nil || !metav1.IsControlledBy(&svc, cluster) {
+			return nil
+		}
+
+		if err = r.Delete(ctx, &svc); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+
+		return nil
+	}
+
+	if errors.IsNotFound(err) {
+		if err = controllerutil.SetControllerReference(cluster, desired, r.Scheme); err != nil {
+			return err
+		}
+
+		return r.Create(ctx, desired)
+	}
+
+	if err = r.ensureOwner(ctx, cluster, &svc); err != nil {
+		return err
+	}
+
+	if !restoreServicePorts(&svc, desired.Spec.Ports) && labels.Equals(svc.Spec.Selector, cluster.Labels) &&
+		!svc.Spec.PublishNotReadyAddresses {
+		return nil
+	}
+
+	svc.Spec.Selector = cluster.Labels
+	svc.Spec.PublishNotReadyAddresses = false
+
+	return r.Update(ctx, &svc)
+}
+
+// buildClientService returns the ClusterIP service balancing the
+// connections of the clients across the ready brokers. It exposes the same
+// ports as the headless service.
+func buildClientService(cluster *redpandav1alpha1.Cluster) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:	cluster.Namespace,
+			Name:		cluster.Name + clientSuffix,
+			Labels:		cluster.Labels,
+		},
+		Spec: corev1.ServiceSpec{
+			Type:		corev1.ServiceTypeClusterIP,
+			Ports:		buildService(cluster).Spec.Ports,
+			Selector:	cluster.Labels,
+		},
+	}
+}
+
+// reconcileExternalService creates the LoadBalancer service exposing the
+// kafka API when external connectivity is enabled and keeps its
+// annotations and ports in line with the Cluster definition
+func (r *ClusterReconciler) reconcileExternalService(
+	ctx context.Context, cluster *redpandav1alpha1.Cluster,
+) error {
+	if !cluster.Spec.ExternalConnectivity.Enabled {
+		return nil
+	}
+
+	var svc corev1.Service
+
+	err := r.Get(ctx, types.NamespacedName{Name: cluster.Name + externalSuffix, Namespace: cluster.Namespace}, &svc)
+	if errors.IsNotFound(err) {
+		return r.createExternalService(ctx, cluster)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err = r.ensureOwner(ctx, cluster, &svc); err != nil {
+		return err
+	}
+
+	// Annotations removed from the Cluster are left in place, as they can
+	// not be told apart from the ones added by the cloud provider.
+	metadataModified := restoreManagedMetadata(&svc.ObjectMeta, cluster.Labels, cluster.Spec.ExternalConnectivity.Annotations)
+	if !restoreServicePorts(&svc, buildExternalService(cluster).Spec.Ports) && !metadataModified &&
+		!svc.Spec.PublishNotReadyAddresses {
+		return nil
+	}
+
+	svc.Spec.PublishNotReadyAddresses = false
+
+	return r.Update(ctx, &svc)
+}
+
+func (r *ClusterReconciler) createExternalService(
+	ctx context.Context, cluster *redpandav1alpha1.Cluster,
+) error {
+	svc := buildExternalService(cluster)
+
+	err := controllerutil.SetControllerReference(cluster, svc, r.Scheme)
+	if err != nil {
+		return err
+	}
+
+	return r.Create(ctx, svc)
+}
+
+// buildExternalService returns the LoadBalancer service exposing the
+// kafka API outside of the Kubernetes cluster. Unlike the headless
+// service, it only routes the clients to the ready brokers.
+func buildExternalService(cluster *redpandav1alpha1.Cluster) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:	cluster.Namespace,
+			Name:		cluster.Name + externalSuffix,
+			Labels:		cluster.Labels,
+			Annotations:	cluster.Spec.ExternalConnectivity.Annotations,
+		},
+		Spec: corev1.ServiceSpec{
+			Type:	corev1.ServiceTypeLoadBalancer,
+			Ports: []corev1.ServicePort{
+				{
+					Name:		"kafka-tcp",
+					Protocol:	corev1.ProtocolTCP,
+					Port:		int32(kafkaAPIPort(cluster)),
+					TargetPort:	intstr.FromInt(kafkaAPIPort(cluster)),
+				},
+			},
+			Selector:	cluster.Labels,
+		},
+	}
+}
+
+// reconcileAdminService creates the ClusterIP service fronting the Admin
+// API of every broker when it is enabled. Any broker can serve the Admin
+// API requests, so the connections are simply balanced across them. The
+// port follows the Admin API port of the Cluster.
+func (r *ClusterReconciler) reconcileAdminService(
+	ctx context.Context, cluster *redpandav1alpha1.Cluster,
+) error {
+	if !cluster.Spec.Configuration.AdminAPI.ServiceEnabled {
+		return nil
+	}
+
+	var svc corev1.Service
+
+	desired := buildAdminService(cluster)
+
+	err := r.Get(ctx, types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, &svc)
+	if err == nil {
+		if err = r.ensureOwner(ctx, cluster, &svc); err != nil {
+			return err
+		}
+
+		if !restoreServicePorts(&svc, desired.Spec.Ports) {
+			return nil
+		}
+
+		return r.Update(ctx, &svc)
+	}
+
+	if !errors.IsNotFound(err) {
+		return err
+	}
+
+	err = controllerutil.SetControllerReference(cluster, desired, r.Scheme)
+	if err != nil {
+		return err
+	}
+
+	return r.Create(ctx, desired)
+}
+
+// buildAdminService returns the ClusterIP service fronting the Admin API
+// of every broker
+func buildAdminService(cluster *redpandav1alpha1.Cluster) *corev1.Service {
+	port := adminAPIPort(cluster)
+
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:	cluster.Namespace,
+			Name:		cluster.Name + adminSuffix,
+			Labels:		cluster.Labels,
+		},
+		Spec: corev1.ServiceSpec{
+			Type:	corev1.ServiceTypeClusterIP,
+			Ports: []corev1.ServicePort{
+				{
+					Name:		"admin",
+					Protocol:	corev1.ProtocolTCP,
+					Port:		int32(port),
+					TargetPort:	intstr.FromInt(port),
+				},
+			},
+			Selector:	cluster.Labels,
+		},
+	}
+}
+
+// brokerServiceName returns the name of the NodePort service of a broker
+func brokerServiceName(cluster *redpandav1alpha1.Cluster, ordinal int32) string {
+	return fmt.Sprintf("%s-%d%s", cluster.Name, ordinal, externalSuffix)
+}
+
+// brokerNodePort returns the node port of a broker
+func brokerNodePort(cluster *redpandav1alpha1.Cluster, ordinal int32) int32 {
+	return cluster.Spec.ExternalConnectivity.PerBrokerNodePorts.BasePort + ordinal
+}
+
+// buildBrokerService returns the NodePort service exposing the kafka API
+// of a single broker, selected through the pod name label set by the
+// StatefulSet controller
+func buildBrokerService(
+	cluster *redpandav1alpha1.Cluster, ordinal int32,
+) *corev1.Service {
+	selector := make(map[string]string, len(cluster.Labels)+1)
+	for k, v := range cluster.Labels {
+		selector[k] = v
+	}
+
+	selector[appsv1.StatefulSetPodNameLabel] = fmt.Sprintf("%s-%d", cluster.Name, ordinal)
+
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:	cluster.Namespace,
+			Name:		brokerServiceName(cluster, ordinal),
+			Labels:		cluster.Labels,
+		},
+		Spec: corev1.ServiceSpec{
+			Type:	corev1.ServiceTypeNodePort,
+			Ports: []corev1.ServicePort{
+				{
+					Name:		"kafka-tcp",
+					Protocol:	corev1.ProtocolTCP,
+					Port:		int32(kafkaAPIPort(cluster)),
+					TargetPort:	intstr.FromInt(kafkaAPIPort(cluster)),
+					NodePort:	brokerNodePort(cluster, ordinal),
+				},
+			},
+			Selector:	selector,
+		},
+	}
+}
+
+// reconcileBrokerServices creates the NodePort service of every broker
+// when per broker node ports are enabled, and deletes the services of the
+// removed brokers. A decommissioned broker keeps its service until the
+// StatefulSet is scaled down, as it is still advertised to the clients
+// while it drains.
+func (r *ClusterReconciler) reconcileBrokerServices(
+	ctx context.Context, cluster *redpandav1alpha1.Cluster,
+) error {
+	var replicas int32
+	if cluster.Spec.ExternalConnectivity.PerBrokerNodePorts != nil && cluster.Spec.Replicas != nil {
+		replicas = *cluster.Spec.Replicas
+
+		var sts appsv1.StatefulSet
+
+		err := r.Get(ctx, types.NamespacedName{Name: cluster.Name, Namespace: cluster.Namespace}, &sts)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+
+		if err == nil && sts.Spec.Replicas != nil && *sts.Spec.Replicas > replicas {
+			replicas = *sts.Spec.Replicas
+		}
+	}
+
+	desired := make(map[string]bool, replicas)
+
+	for i := int32(0); i < replicas; i++ {
+		svc := buildBrokerService(cluster, i)
+		desired[svc.Name] = true
+
+		if err := r.reconcileBrokerService(ctx, cluster, svc); err != nil {
+			return err
+		}
+	}
+
+	var services corev1.ServiceList
+	if err := r.List(ctx, &services, client.InNamespace(cluster.Namespace), client.MatchingLabels(cluster.Labels)); err != nil {
+		return err
+	}
+
+	for i := range services.Items {
+		svc := &services.Items[i]
+		if _, broker := svc.Spec.Selector[appsv1.StatefulSetPodNameLabel]; !broker ||
+			desired[svc.Name] || !metav1.IsControlledBy(svc, cluster) {
+			continue
+		}
+
+		if err := r.Delete(ctx, svc); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reconcileBrokerService creates the NodePort service of a broker, or
+// restores its ports and selector
+func (r *ClusterReconciler) reconcileBrokerService(
+	ctx context.Context, cluster *redpandav1alpha1.Cluster, desired *corev1.Service,
+) error {
+	var svc corev1.Service
+
+	err := r.Get(ctx, types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, &svc)
+	if errors.IsNotFound(err) {
+		if err = controllerutil.SetControllerReference(cluster, desired, r.Scheme); err != nil {
+			return err
+		}
+
+		return r.Create(ctx, desired)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err = r.ensureOwner(ctx, cluster, &svc); err != nil {
+		return err
+	}
+
+	// restoreServicePorts keeps the allocated node ports, the ones of the
+	// brokers are chosen by the operator
+	modified := restoreServicePorts(&svc, desired.Spec.Ports)
+	if svc.Spec.Ports[0].NodePort != desired.Spec.Ports[0].NodePort {
+		svc.Spec.Ports[0].NodePort = desired.Spec.Ports[0].NodePort
+		modified = true
+	}
+
+	if !modified && labels.Equals(svc.Spec.Selector, desired.Spec.Selector) {
+		return nil
+	}
+
+	svc.Spec.Selector = desired.Spec.Selector
+
+	return r.Update(ctx, &svc)
+}
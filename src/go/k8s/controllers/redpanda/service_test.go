@@ -0,0 +1,308 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda_test
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+)
+
+var _ = Describe("Redpanda services", func() {
+	Context("When external connectivity is enabled", func() {
+		It("Should reconcile the external service annotations", func() {
+			key := testKey("redpanda-external-annotations")
+			externalKey := testKey(key.Name + "-external")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.ExternalConnectivity = v1alpha1.ExternalConnectivityConfig{
+				Enabled:	true,
+				Annotations: map[string]string{
+					"service.beta.kubernetes.io/aws-load-balancer-type": "nlb",
+				},
+			}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			var svc corev1.Service
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), externalKey, &svc)
+			}, timeout, interval).Should(Succeed())
+			Expect(svc.Spec.Type).Should(Equal(corev1.ServiceTypeLoadBalancer))
+			Expect(svc.Annotations).Should(HaveKeyWithValue("service.beta.kubernetes.io/aws-load-balancer-type", "nlb"))
+			Expect(validOwner(redpandaCluster, svc.OwnerReferences)).Should(BeTrue())
+
+			By("Changing the annotations in the Cluster")
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), key, redpandaCluster); err != nil {
+					return err
+				}
+				redpandaCluster.Spec.ExternalConnectivity.Annotations = map[string]string{
+					"service.beta.kubernetes.io/aws-load-balancer-type":		"external",
+					"service.beta.kubernetes.io/aws-load-balancer-ssl-cert":	"arn:aws:acm:cert",
+				}
+				return k8sClient.Update(context.Background(), redpandaCluster)
+			}, timeout, interval).Should(Succeed())
+
+			Eventually(func() map[string]string {
+				if err := k8sClient.Get(context.Background(), externalKey, &svc); err != nil {
+					return nil
+				}
+				return svc.Annotations
+			}, timeout, interval).Should(And(
+				HaveKeyWithValue("service.beta.kubernetes.io/aws-load-balancer-type", "external"),
+				HaveKeyWithValue("service.beta.kubernetes.io/aws-load-balancer-ssl-cert", "arn:aws:acm:cert"),
+			))
+		})
+
+		It("Should publish the brokers which are not ready internally only", func() {
+			key := testKey("redpanda-external-readiness")
+			externalKey := testKey(key.Name + "-external")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.ExternalConnectivity.Enabled = true
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			var headless, external corev1.Service
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &headless)
+			}, timeout, interval).Should(Succeed())
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), externalKey, &external)
+			}, timeout, interval).Should(Succeed())
+			Expect(headless.Spec.PublishNotReadyAddresses).Should(BeTrue())
+			Expect(external.Spec.PublishNotReadyAddresses).Should(BeFalse())
+
+			By("Restoring the readiness of the external service when edited")
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), externalKey, &external); err != nil {
+					return err
+				}
+				external.Spec.PublishNotReadyAddresses = true
+				return k8sClient.Update(context.Background(), &external)
+			}, timeout, interval).Should(Succeed())
+			Eventually(func() bool {
+				if err := k8sClient.Get(context.Background(), externalKey, &external); err != nil {
+					return true
+				}
+				return external.Spec.PublishNotReadyAddresses
+			}, timeout, interval).Should(BeFalse())
+
+			By("Restoring the readiness of the headless service when edited")
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), key, &headless); err != nil {
+					return err
+				}
+				headless.Spec.PublishNotReadyAddresses = false
+				return k8sClient.Update(context.Background(), &headless)
+			}, timeout, interval).Should(Succeed())
+			Eventually(func() bool {
+				if err := k8sClient.Get(context.Background(), key, &headless); err != nil {
+					return false
+				}
+				return headless.Spec.PublishNotReadyAddresses
+			}, timeout, interval).Should(BeTrue())
+		})
+
+		It("Should not create the external service by default", func() {
+			key := testKey("redpanda-no-external")
+			Expect(k8sClient.Create(context.Background(), testCluster(key.Name))).Should(Succeed())
+
+			var svc corev1.Service
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &svc)
+			}, timeout, interval).Should(Succeed())
+			Consistently(func() error {
+				return k8sClient.Get(context.Background(), testKey(key.Name+"-external"), &svc)
+			}, "2s", interval).ShouldNot(Succeed())
+		})
+	})
+
+	Context("When the service type changes", func() {
+		It("Should add a client service and keep the headless one", func() {
+			key := testKey("redpanda-service-type")
+			clientKey := testKey(key.Name + "-client")
+			redpandaCluster := testCluster(key.Name)
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			var svc corev1.Service
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &svc)
+			}, timeout, interval).Should(Succeed())
+			Expect(svc.Spec.ClusterIP).Should(Equal(corev1.ClusterIPNone))
+			headlessUID := svc.UID
+
+			By("Switching to a ClusterIP service")
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), key, redpandaCluster); err != nil {
+					return err
+				}
+				redpandaCluster.Spec.Service.Type = v1alpha1.ServiceTypeClusterIP
+				return k8sClient.Update(context.Background(), redpandaCluster)
+			}, timeout, interval).Should(Succeed())
+
+			var client corev1.Service
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), clientKey, &client)
+			}, timeout, interval).Should(Succeed())
+			Expect(client.Spec.ClusterIP).ShouldNot(Equal(corev1.ClusterIPNone))
+			Expect(client.Spec.PublishNotReadyAddresses).Should(BeFalse())
+			Expect(validOwner(redpandaCluster, client.OwnerReferences)).Should(BeTrue())
+
+			Expect(k8sClient.Get(context.Background(), key, &svc)).Should(Succeed())
+			Expect(svc.UID).Should(Equal(headlessUID))
+			Expect(svc.Spec.ClusterIP).Should(Equal(corev1.ClusterIPNone))
+			Expect(svc.Spec.PublishNotReadyAddresses).Should(BeTrue())
+
+			By("Switching back to a headless service")
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), key, redpandaCluster); err != nil {
+					return err
+				}
+				redpandaCluster.Spec.Service.Type = v1alpha1.ServiceTypeHeadless
+				return k8sClient.Update(context.Background(), redpandaCluster)
+			}, timeout, interval).Should(Succeed())
+			Eventually(func() bool {
+				err := k8sClient.Get(context.Background(), clientKey, &client)
+				return apierrors.IsNotFound(err) || (err == nil && client.DeletionTimestamp != nil)
+			}, timeout, interval).Should(BeTrue())
+		})
+
+		It("Should recreate a governing service which is not headless", func() {
+			key := testKey("redpanda-service-recreate")
+			Expect(k8sClient.Create(context.Background(), &corev1.Service{
+				ObjectMeta:	metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Spec: corev1.ServiceSpec{
+					Ports:		[]corev1.ServicePort{{Name: "kafka-tcp", Port: 9092}},
+					Selector:	map[string]string{"app": key.Name},
+				},
+			})).Should(Succeed())
+			Expect(k8sClient.Create(context.Background(), testCluster(key.Name))).Should(Succeed())
+
+			var svc corev1.Service
+			Eventually(func() string {
+				if err := k8sClient.Get(context.Background(), key, &svc); err != nil {
+					return ""
+				}
+				return svc.Spec.ClusterIP
+			}, timeout, interval).Should(Equal(corev1.ClusterIPNone))
+			Expect(svc.Spec.PublishNotReadyAddresses).Should(BeTrue())
+			Eventually(func() string {
+				return clusterConditionReason(key, v1alpha1.ClusterServiceRecreated)
+			}, timeout, interval).Should(Equal("ServiceTypeChanged"))
+		})
+	})
+
+	Context("When the service selector drifts", func() {
+		It("Should restore the selector of the brokers", func() {
+			key := testKey("redpanda-service-selector")
+			Expect(k8sClient.Create(context.Background(), testCluster(key.Name))).Should(Succeed())
+
+			var svc corev1.Service
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &svc)
+			}, timeout, interval).Should(Succeed())
+
+			By("Editing the selector outside of the operator")
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), key, &svc); err != nil {
+					return err
+				}
+				svc.Spec.Selector = map[string]string{"app": "other"}
+				return k8sClient.Update(context.Background(), &svc)
+			}, timeout, interval).Should(Succeed())
+
+			Eventually(func() map[string]string {
+				if err := k8sClient.Get(context.Background(), key, &svc); err != nil {
+					return nil
+				}
+				return svc.Spec.Selector
+			}, timeout, interval).Should(Equal(map[string]string{"app": key.Name}))
+		})
+	})
+
+	Context("When the Admin API service is enabled", func() {
+		It("Should front the Admin API of the brokers", func() {
+			key := testKey("redpanda-admin-service")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.Configuration.AdminAPI.ServiceEnabled = true
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			var svc corev1.Service
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), testKey(key.Name+"-admin"), &svc)
+			}, timeout, interval).Should(Succeed())
+			Expect(svc.Spec.Type).Should(Equal(corev1.ServiceTypeClusterIP))
+			Expect(svc.Spec.ClusterIP).ShouldNot(Equal(corev1.ClusterIPNone))
+			Expect(svc.Spec.Ports).Should(HaveLen(1))
+			Expect(svc.Spec.Ports[0].Port).Should(Equal(int32(9644)))
+			Expect(svc.Spec.Selector).Should(Equal(redpandaCluster.Labels))
+			Expect(validOwner(redpandaCluster, svc.OwnerReferences)).Should(BeTrue())
+		})
+
+		It("Should not create the Admin API service by default", func() {
+			key := testKey("redpanda-no-admin-service")
+			Expect(k8sClient.Create(context.Background(), testCluster(key.Name))).Should(Succeed())
+
+			var svc corev1.Service
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &svc)
+			}, timeout, interval).Should(Succeed())
+			Consistently(func() error {
+				return k8sClient.Get(context.Background(), testKey(key.Name+"-admin"), &svc)
+			}, "2s", interval).ShouldNot(Succeed())
+		})
+	})
+
+	Context("When per broker node ports are enabled", func() {
+		It("Should create one NodePort service per broker and delete it on scale down", func() {
+			key := testKey("redpanda-broker-node-ports")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.Replicas = pointer.Int32Ptr(2)
+			redpandaCluster.Spec.ExternalConnectivity.PerBrokerNodePorts = &v1alpha1.PerBrokerNodePorts{BasePort: 31200}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			for ordinal, name := range []string{key.Name + "-0-external", key.Name + "-1-external"} {
+				var svc corev1.Service
+				Eventually(func() error {
+					return k8sClient.Get(context.Background(), testKey(name), &svc)
+				}, timeout, interval).Should(Succeed())
+				Expect(svc.Spec.Type).Should(Equal(corev1.ServiceTypeNodePort))
+				Expect(svc.Spec.Ports).Should(HaveLen(1))
+				Expect(svc.Spec.Ports[0].NodePort).Should(Equal(int32(31200 + ordinal)))
+				Expect(svc.Spec.Selector).Should(HaveKeyWithValue("statefulset.kubernetes.io/pod-name",
+					fmt.Sprintf("%s-%d", key.Name, ordinal)))
+				Expect(validOwner(redpandaCluster, svc.OwnerReferences)).Should(BeTrue())
+			}
+			Expect(configuratorScript(key)).Should(And(
+				ContainSubstring("redpanda.advertised_kafka_api.address $HOST_IP;"),
+				ContainSubstring("redpanda.advertised_kafka_api.port $((31200 + ORDINAL_INDEX));"),
+			))
+
+			By("Deleting the service of the removed broker")
+			Eventually(func() int32 {
+				return statefulSetReplicas(key)
+			}, timeout, interval).Should(Equal(int32(2)))
+			scaleCluster(key, 1)
+			Eventually(func() bool {
+				var svc corev1.Service
+				return apierrors.IsNotFound(k8sClient.Get(context.Background(), testKey(key.Name+"-1-external"), &svc))
+			}, timeout, interval).Should(BeTrue())
+			Expect(statefulSetReplicas(key)).Should(Equal(int32(1)))
+
+			var svc corev1.Service
+			Expect(k8sClient.Get(context.Background(), testKey(key.Name+"-0-external"), &svc)).Should(Succeed())
+		})
+	})
+})
@@ -0,0 +1,515 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	"context"
+	"reflect"
+
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// reconcileStatefulSet brings an existing StatefulSet back in line with the
+// Cluster definition. Only the fields the operator owns are compared, so
+// changes made by other controllers are left untouched. The image and the
+// replicas are the ones allowed by the upgrade guard and the broker
+// decommissioning.
+func (r *ClusterReconciler) reconcileStatefulSet(
+	ctx context.Context,
+	cluster *redpandav1alpha1.Cluster,
+	sts *appsv1.StatefulSet,
+	image string,
+	replicas *int32,
+) error {
+	if err := r.ensureOwner(ctx, cluster, sts); err != nil {
+		return err
+	}
+
+	// modified tracks the pod template changes, each of them rolls the
+	// brokers out
+	modified := false
+
+	command, args := redpandaCommand(cluster)
+	probe := startupProbe(cluster)
+
+	for i := range sts.Spec.Template.Spec.Containers {
+		c := &sts.Spec.Template.Spec.Containers[i]
+		if c.Name != redpandaContainerName {
+			continue
+		}
+
+		if !reflect.DeepEqual(c.Command, command) || !reflect.DeepEqual(c.Args, args) {
+			c.Command = command
+			c.Args = args
+			modified = true
+		}
+
+		if !reflect.DeepEqual(c.StartupProbe, probe) {
+			c.StartupProbe = probe
+			modified = true
+		}
+
+		// The ports follow the rendered redpanda.yaml
+		if ports := containerPorts(cluster); !reflect.DeepEqual(c.Ports, ports) {
+			c.Ports = ports
+			modified = true
+		}
+
+		for j := range c.VolumeMounts {
+			m := &c.VolumeMounts[j]
+			if m.Name == "datadir" && m.ReadOnly != cluster.Spec.Debug.ReadOnlyData {
+				m.ReadOnly = cluster.Spec.Debug.ReadOnlyData
+				modified = true
+			}
+		}
+	}
+
+	// The init containers run the redpanda image as well
+	current := containerImage(sts.Spec.Template.Spec.Containers)
+	if current != image {
+		setImage(sts.Spec.Template.Spec.InitContainers, current, image)
+		setImage(sts.Spec.Template.Spec.Containers, current, image)
+		modified = true
+	}
+
+	if restoreDataDirectoryVerifier(&sts.Spec.Template.Spec, cluster, image) {
+		modified = true
+	}
+
+	if restoreDependenciesWaiter(&sts.Spec.Template.Spec, cluster, image) {
+		modified = true
+	}
+
+	if restoreConfiguratorEnv(&sts.Spec.Template.Spec, cluster) {
+		modified = true
+	}
+
+	if restoreInitContainerResources(&sts.Spec.Template.Spec, cluster) {
+		modified = true
+	}
+
+	if restoreCertificateVolume(&sts.Spec.Template.Spec, rpcTLSVolume, rpcTLSDir, rpcCertSecretName(cluster)) {
+		modified = true
+	}
+
+	if restoreCertificateVolume(&sts.Spec.Template.Spec, kafkaTLSVolume, kafkaTLSDir, kafkaCertSecretName(cluster)) {
+		modified = true
+	}
+
+	if restoreCertificateVolume(&sts.Spec.Template.Spec, pandaProxyTLSVolume, pandaProxyTLSDir,
+		pandaProxyCertSecretName(cluster)) {
+		modified = true
+	}
+
+	if restoreIOProperties(&sts.Spec.Template.Spec, cluster, cluster.Name+baseSuffix) {
+		modified = true
+	}
+
+	if restoreConfigMapItems(&sts.Spec.Template.Spec, cluster) {
+		modified = true
+	}
+
+	if sc := podSecurityContext(cluster); !securityContextMatches(cluster, sts.Spec.Template.Spec.SecurityContext, sc) {
+		sts.Spec.Template.Spec.SecurityContext = sc
+		modified = true
+	}
+
+	// Like any pod template change, new constraints roll the brokers out,
+	// the pods are then rescheduled one at a time
+	if antiAffinity := podAntiAffinity(cluster); sts.Spec.Template.Spec.Affinity == nil ||
+		!reflect.DeepEqual(sts.Spec.Template.Spec.Affinity.PodAntiAffinity, antiAffinity) {
+		if sts.Spec.Template.Spec.Affinity == nil {
+			sts.Spec.Template.Spec.Affinity = &corev1.Affinity{}
+		}
+
+		sts.Spec.Template.Spec.Affinity.PodAntiAffinity = antiAffinity
+		modified = true
+	}
+
+	if gates := readinessGates(cluster); !reflect.DeepEqual(sts.Spec.Template.Spec.ReadinessGates, gates) {
+		sts.Spec.Template.Spec.ReadinessGates = gates
+		modified = true
+	}
+
+	constraints := topologySpreadConstraints(cluster)
+	if !reflect.DeepEqual(sts.Spec.Template.Spec.TopologySpreadConstraints, constraints) {
+		sts.Spec.Template.Spec.TopologySpreadConstraints = constraints
+		modified = true
+	}
+
+	// A rotated Secret changes the pod template annotations, which makes
+	// the StatefulSet roll the brokers out
+	podAnnotations, err := r.podAnnotations(ctx, cluster)
+	if err != nil {
+		return err
+	}
+
+	holdRestart(sts, podAnnotations)
+
+	// Without the configurator digest, the brokers are only rolled out when
+	// the script they were started with is replaced. Otherwise the digest
+	// is only added along with another change rolling the brokers out.
+	_, hasDigest := sts.Spec.Template.Annotations[configuratorHashAnnotation]
+	digest := podAnnotations[configuratorHashAnnotation]
+
+	if !hasDigest {
+		replaced, err := r.configuratorReplaced(ctx, cluster, digest)
+		if err != nil {
+			return err
+		}
+
+		if replaced {
+			hasDigest = true
+		} else {
+			delete(podAnnotations, configuratorHashAnnotation)
+		}
+	}
+
+	if restoreManagedMetadata(&sts.Spec.Template.ObjectMeta, podLabels(cluster), podAnnotations) {
+		modified = true
+	}
+
+	if !hasDigest && modified {
+		restoreManagedMetadata(&sts.Spec.Template.ObjectMeta, nil, map[string]string{configuratorHashAnnotation: digest})
+	}
+
+	// The liveness probe is relaxed along with the change starting a
+	// rollout, which spares the brokers a second restart, and kept relaxed
+	// until the rollout completes. The rollout restoring the probe is not
+	// relaxed again, otherwise the brokers would never stop rolling out.
+	for i := range sts.Spec.Template.Spec.Containers {
+		c := &sts.Spec.Template.Spec.Containers[i]
+		if c.Name != redpandaContainerName {
+			continue
+		}
+
+		relaxed := reflect.DeepEqual(c.LivenessProbe, livenessProbe(cluster, true))
+		liveness := livenessProbe(cluster, modified || (relaxed && rolloutInProgress(sts)))
+
+		if !reflect.DeepEqual(c.LivenessProbe, liveness) {
+			c.LivenessProbe = liveness
+			modified = true
+		}
+	}
+
+	// With the maintenance mode the brokers are released one at a time by
+	// reconcileMaintenance
+	if modified && cluster.Spec.MaintenanceMode.Enabled && replicas != nil {
+		holdRollout(sts, *replicas)
+	}
+
+	// Ensure StatefulSet #replicas equals cluster requirement.
+	if !reflect.DeepEqual(sts.Spec.Replicas, replicas) {
+		sts.Spec.Replicas = replicas
+		modified = true
+	}
+
+	// The operator does not manage any StatefulSet annotation yet, only
+	// the labels copied from the Cluster are restored.
+	if restoreManagedMetadata(&sts.ObjectMeta, cluster.Labels, nil) {
+		modified = true
+	}
+
+	if !modified {
+		return nil
+	}
+
+	return r.Update(ctx, sts)
+}
+
+// The configurator modes. A bootstrapped cluster is formed by the broker
+// with ordinal 0, which starts without seed servers. Brokers rejoining a
+// cluster always keep the seed servers.
+const (
+	configuratorBootstrap	= "bootstrap"
+	configuratorRejoin	= "rejoin"
+)
+
+// configuratorMode decides whether the StatefulSet being created forms a
+// new cluster or rejoins the one stored on retained data volumes, e.g.
+// after the StatefulSet was deleted while its claims were kept
+func (r *ClusterReconciler) configuratorMode(
+	ctx context.Context, cluster *redpandav1alpha1.Cluster,
+) (string, error) {
+	var pvcs corev1.PersistentVolumeClaimList
+
+	err := r.List(ctx, &pvcs, &client.ListOptions{
+		LabelSelector:	labels.SelectorFromSet(cluster.Labels),
+		Namespace:	cluster.Namespace,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if len(pvcs.Items) > 0 {
+		return configuratorRejoin, nil
+	}
+
+	return configuratorBootstrap, nil
+}
+
+// setImage replaces the image of the containers running the old one
+func setImage(containers []corev1.Container, old, image string) {
+	for i := range containers {
+		if containers[i].Image == old {
+			containers[i].Image = image
+		}
+	}
+}
+
+// holdRestart keeps the previous restart of the brokers until they are all
+// ready and rolled out, so that a restart never takes down a broker while
+// another one is unavailable. The StatefulSet then restarts the brokers one
+// at a time, each once the previous one is ready.
+func holdRestart(sts *appsv1.StatefulSet, podAnnotations map[string]string) {
+	key := redpandav1alpha1.RestartedAtAnnotation
+
+	current, restarted := sts.Spec.Template.Annotations[key]
+	if podAnnotations[key] == current {
+		return
+	}
+
+	ready := sts.Spec.Replicas != nil && sts.Status.ReadyReplicas >= *sts.Spec.Replicas
+	if ready && !rolloutInProgress(sts) {
+		return
+	}
+
+	if restarted {
+		podAnnotations[key] = current
+	} else {
+		delete(podAnnotations, key)
+	}
+}
+
+// restoreManagedMetadata sets every operator managed label and annotation
+// back to its desired value. Keys that are not managed by the operator are
+// preserved, which avoids fighting with other tools decorating the same
+// object. It reports whether the object metadata was changed.
+func restoreManagedMetadata(
+	meta *metav1.ObjectMeta, labels, annotations map[string]string,
+) bool {
+	modified := false
+
+	for k, v := range labels {
+		if current, ok := meta.Labels[k]; !ok || current != v {
+			if meta.Labels == nil {
+				meta.Labels = make(map[string]string, len(labels))
+			}
+
+			meta.Labels[k] = v
+			modified = true
+		}
+	}
+
+	for k, v := range annotations {
+		if current, ok := meta.Annotations[k]; !ok || current != v {
+			if meta.Annotations == nil {
+				meta.Annotations = make(map[string]string, len(annotations))
+			}
+
+			meta.Annotations[k] = v
+			modified = true
+		}
+	}
+
+	return modified
+}
+
+// restoreConfigMapItems sets the files of the base ConfigMap volume and
+// their modes back to the configured ones, so that the FileModes changed on
+// an existing Cluster reach the brokers. It returns true when the pod spec
+// changed.
+func restoreConfigMapItems(spec *corev1.PodSpec, cluster *redpandav1alpha1.Cluster) bool {
+	items := configMapItems(cluster)
+
+	for i := range spec.Volumes {
+		cm := spec.Volumes[i].ConfigMap
+		if spec.Volumes[i].Name != "configmap-dir" || cm == nil {
+			continue
+		}
+
+		if !reflect.DeepEqual(cm.Items, items) {
+			cm.Items = items
+			return true
+		}
+	}
+
+	return false
+}
+
+// restoreDataDirectoryVerifier adds or removes the init container verifying
+// the data directory, which never runs on a read-only data directory. It
+// is added right after the configurator, like in a new StatefulSet. It
+// returns true when the pod spec changed.
+func restoreDataDirectoryVerifier(
+	spec *corev1.PodSpec, cluster *redpandav1alpha1.Cluster, image string,
+) bool {
+	index, configurator := -1, -1
+
+	for i := range spec.InitContainers {
+		switch spec.InitContainers[i].Name {
+		case dataDirectoryVerifierName:
+			index = i
+		case configuratorContainerName:
+			configurator = i
+		}
+	}
+
+	enabled := cluster.Spec.Storage.VerifyDataDirectory && !cluster.Spec.Debug.ReadOnlyData
+	if enabled == (index >= 0) {
+		return false
+	}
+
+	if !enabled {
+		spec.InitContainers = append(spec.InitContainers[:index], spec.InitContainers[index+1:]...)
+
+		return true
+	}
+
+	desired := dataDirectoryVerifier(cluster)
+	desired.Image = image
+
+	initContainers := append([]corev1.Container{}, spec.InitContainers[:configurator+1]...)
+	initContainers = append(initContainers, desired)
+	spec.InitContainers = append(initContainers, spec.InitContainers[configurator+1:]...)
+
+	return true
+}
+
+// restoreDependenciesWaiter adds, updates or removes the init container
+// waiting for the dependencies of the Cluster. Only its arguments are
+// compared, the other fields are defaulted by the API server. It returns
+// true when the pod spec changed.
+func restoreDependenciesWaiter(
+	spec *corev1.PodSpec, cluster *redpandav1alpha1.Cluster, image string,
+) bool {
+	index := -1
+
+	for i := range spec.InitContainers {
+		if spec.InitContainers[i].Name == dependenciesWaiterName {
+			index = i
+		}
+	}
+
+	if len(cluster.Spec.DependsOn) == 0 {
+		if index < 0 {
+			return false
+		}
+
+		spec.InitContainers = append(spec.InitContainers[:index], spec.InitContainers[index+1:]...)
+
+		return true
+	}
+
+	desired := dependenciesWaiter(cluster)
+	desired.Image = image
+
+	if index < 0 {
+		spec.InitContainers = append(spec.InitContainers, desired)
+
+		return true
+	}
+
+	if reflect.DeepEqual(spec.InitContainers[index].Args, desired.Args) {
+		return false
+	}
+
+	spec.InitContainers[index].Args = desired.Args
+
+	return true
+}
+
+// restoreInitContainerResources sets the resources of the init containers
+// managed by the operator back to the configured ones. The defaults are
+// only set on new StatefulSets, so that upgrading the operator does not
+// roll out every Cluster. The quantities are compared semantically, as the
+// API server may format them differently. It returns true when the pod
+// spec changed.
+func restoreInitContainerResources(
+	spec *corev1.PodSpec, cluster *redpandav1alpha1.Cluster,
+) bool {
+	if cluster.Spec.InitContainerResources == nil {
+		return false
+	}
+
+	desired := initContainerResources(cluster)
+	modified := false
+
+	for i := range spec.InitContainers {
+		c := &spec.InitContainers[i]
+
+		switch c.Name {
+		case configuratorContainerName, dataDirectoryVerifierName, dependenciesWaiterName:
+		default:
+			continue
+		}
+
+		if !apiequality.Semantic.DeepEqual(c.Resources, desired) {
+			c.Resources = *desired.DeepCopy()
+			modified = true
+		}
+	}
+
+	return modified
+}
+
+// restoreConfiguratorEnv updates the environment of the configurator, e.g.
+// when the tiered storage credentials change. The configurator mode chosen
+// when the StatefulSet was created is kept. It returns true when the pod
+// spec changed.
+func restoreConfiguratorEnv(
+	spec *corev1.PodSpec, cluster *redpandav1alpha1.Cluster,
+) bool {
+	for i := range spec.InitContainers {
+		c := &spec.InitContainers[i]
+		if c.Name != configuratorContainerName {
+			continue
+		}
+
+		mode := ""
+
+		for _, env := range c.Env {
+			if env.Name == configuratorModeEnv {
+				mode = env.Value
+			}
+		}
+
+		if env := configuratorEnv(cluster, mode); !reflect.DeepEqual(c.Env, env) {
+			c.Env = env
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// securityContextMatches reports whether the pod template has the desired
+// security context. A template without the default fsGroupChangePolicy
+// still matches: the API servers without the ConfigurableFSGroupPolicy
+// feature drop it, and the StatefulSets created by a previous operator
+// version only get it with their next rollout.
+func securityContextMatches(
+	cluster *redpandav1alpha1.Cluster, current, desired *corev1.PodSecurityContext,
+) bool {
+	configured := cluster.Spec.PodSecurityContext != nil && cluster.Spec.PodSecurityContext.FSGroupChangePolicy != nil
+	if current != nil && current.FSGroupChangePolicy == nil && !configured {
+		desired = desired.DeepCopy()
+		desired.FSGroupChangePolicy = nil
+	}
+
+	return reflect.DeepEqual(current, desired)
+}
@@ -0,0 +1,1289 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	redpandacontrollers "github.com/vectorizedio/redpanda/src/go/k8s/controllers/redpanda"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+)
+
+var _ = Describe("Redpanda StatefulSet", func() {
+	Context("When the data directory verification is enabled", func() {
+		It("Should add the verification init container", func() {
+			key := testKey("redpanda-verify-data")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.Storage = v1alpha1.StorageSpec{
+				VerifyDataDirectory: true,
+			}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+
+			initContainers := sts.Spec.Template.Spec.InitContainers
+			Expect(initContainers).Should(HaveLen(2))
+			Expect(initContainers[1].Name).Should(Equal("redpanda-data-verifier"))
+			Expect(initContainers[1].Image).Should(Equal("vectorized/redpanda:" + redpandaContainerTag))
+			Expect(initContainers[1].VolumeMounts).Should(ConsistOf(corev1.VolumeMount{
+				Name:		"datadir",
+				MountPath:	"/var/lib/redpanda/data",
+			}))
+			// The write check runs as the redpanda user, like the broker
+			Expect(initContainers[1].SecurityContext).Should(Equal(initContainers[0].SecurityContext))
+			Expect(*initContainers[1].SecurityContext.RunAsUser).Should(Equal(int64(101)))
+		})
+	})
+
+	Context("When the data directory is made read-only", func() {
+		It("Should mount it read-only without the verification", func() {
+			key := testKey("redpanda-read-only-data")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.Storage.VerifyDataDirectory = true
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			dataMount := func() *corev1.VolumeMount {
+				var sts appsv1.StatefulSet
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return nil
+				}
+				for _, m := range sts.Spec.Template.Spec.Containers[0].VolumeMounts {
+					if m.Name == "datadir" {
+						return &m
+					}
+				}
+				return nil
+			}
+			initContainers := func() []string {
+				var sts appsv1.StatefulSet
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return nil
+				}
+				var names []string
+				for _, c := range sts.Spec.Template.Spec.InitContainers {
+					names = append(names, c.Name)
+				}
+				return names
+			}
+			Eventually(initContainers, timeout, interval).Should(Equal([]string{"redpanda-configurator", "redpanda-data-verifier"}))
+			Expect(dataMount().ReadOnly).Should(BeFalse())
+
+			updateCluster(key, func(cluster *v1alpha1.Cluster) {
+				cluster.Spec.Debug = v1alpha1.DebugSpec{OverrideCommand: true, ReadOnlyData: true}
+			})
+			Eventually(func() bool {
+				m := dataMount()
+				return m != nil && m.ReadOnly
+			}, timeout, interval).Should(BeTrue())
+			Expect(initContainers()).Should(Equal([]string{"redpanda-configurator"}))
+
+			By("Restoring the writable data directory")
+			updateCluster(key, func(cluster *v1alpha1.Cluster) {
+				cluster.Spec.Debug = v1alpha1.DebugSpec{}
+			})
+			Eventually(initContainers, timeout, interval).Should(Equal([]string{"redpanda-configurator", "redpanda-data-verifier"}))
+			Expect(dataMount().ReadOnly).Should(BeFalse())
+		})
+	})
+
+	Context("When configuring the init container resources", func() {
+		It("Should default them and apply the configured ones to every init container", func() {
+			key := testKey("redpanda-init-resources")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.Storage.VerifyDataDirectory = true
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+			Expect(sts.Spec.Template.Spec.InitContainers).Should(HaveLen(2))
+			for _, c := range sts.Spec.Template.Spec.InitContainers {
+				Expect(c.Resources.Requests.Cpu().String()).Should(Equal("100m"), c.Name)
+				Expect(c.Resources.Limits.Memory().String()).Should(Equal("128Mi"), c.Name)
+			}
+
+			By("Applying the configured resources")
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), key, redpandaCluster); err != nil {
+					return err
+				}
+				redpandaCluster.Spec.InitContainerResources = &corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")},
+				}
+				return k8sClient.Update(context.Background(), redpandaCluster)
+			}, timeout, interval).Should(Succeed())
+			Eventually(func() []string {
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return nil
+				}
+				var memory []string
+				for _, c := range sts.Spec.Template.Spec.InitContainers {
+					memory = append(memory, c.Resources.Requests.Memory().String())
+				}
+				return memory
+			}, timeout, interval).Should(Equal([]string{"256Mi", "256Mi"}))
+			Expect(sts.Spec.Template.Spec.InitContainers[0].Resources.Limits).Should(BeEmpty())
+		})
+
+		It("Should not set the defaults on an existing StatefulSet", func() {
+			key := testKey("redpanda-init-resources-upgrade")
+			Expect(k8sClient.Create(context.Background(), testCluster(key.Name))).Should(Succeed())
+
+			By("Removing the resources like a StatefulSet created by an older operator")
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return err
+				}
+				for i := range sts.Spec.Template.Spec.InitContainers {
+					sts.Spec.Template.Spec.InitContainers[i].Resources = corev1.ResourceRequirements{}
+				}
+				return k8sClient.Update(context.Background(), &sts)
+			}, timeout, interval).Should(Succeed())
+
+			updateCluster(key, func(c *v1alpha1.Cluster) {
+				c.Annotations = map[string]string{"resync": "1"}
+			})
+			Consistently(func() bool {
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return false
+				}
+				for _, c := range sts.Spec.Template.Spec.InitContainers {
+					if len(c.Resources.Requests) > 0 || len(c.Resources.Limits) > 0 {
+						return false
+					}
+				}
+				return true
+			}, 2*time.Second, interval).Should(BeTrue())
+		})
+	})
+
+	Context("When creating the StatefulSet", func() {
+		It("Should run the configurator as a restricted container", func() {
+			key := testKey("redpanda-restricted-configurator")
+			Expect(k8sClient.Create(context.Background(), testCluster(key.Name))).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+
+			configurator := sts.Spec.Template.Spec.InitContainers[0]
+			Expect(configurator.Name).Should(Equal("redpanda-configurator"))
+			Expect(configurator.SecurityContext).ShouldNot(BeNil())
+			sc := configurator.SecurityContext
+			Expect(sc.RunAsNonRoot).Should(Equal(pointer.BoolPtr(true)))
+			Expect(sc.RunAsUser).Should(Equal(pointer.Int64Ptr(101)))
+			Expect(sc.ReadOnlyRootFilesystem).Should(Equal(pointer.BoolPtr(true)))
+			Expect(sc.AllowPrivilegeEscalation).Should(Equal(pointer.BoolPtr(false)))
+			Expect(sc.Capabilities.Drop).Should(ConsistOf(corev1.Capability("ALL")))
+		})
+	})
+
+	Context("When memory locking is enabled", func() {
+		It("Should pass the flag and grant IPC_LOCK to redpanda", func() {
+			key := testKey("redpanda-lock-memory")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.Resources = v1alpha1.RedpandaResourceRequirements{
+				ResourceRequirements: corev1.ResourceRequirements{
+					Limits: corev1.ResourceList{
+						corev1.ResourceMemory: resource.MustParse("2Gi"),
+					},
+				},
+				LockMemory:	true,
+			}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+
+			container := sts.Spec.Template.Spec.Containers[0]
+			Expect(container.Args).Should(ContainElement("--lock-memory=true"))
+			Expect(container.SecurityContext).ShouldNot(BeNil())
+			Expect(container.SecurityContext.Capabilities.Add).Should(ConsistOf(corev1.Capability("IPC_LOCK")))
+		})
+
+		It("Should not lock memory by default", func() {
+			key := testKey("redpanda-default-memory")
+			Expect(k8sClient.Create(context.Background(), testCluster(key.Name))).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+
+			container := sts.Spec.Template.Spec.Containers[0]
+			Expect(container.Args).ShouldNot(ContainElement("--lock-memory=true"))
+			Expect(container.SecurityContext).Should(BeNil())
+		})
+	})
+
+	Context("When a share of the memory is reserved", func() {
+		It("Should reserve the percentage of the memory limit", func() {
+			for i, tc := range []struct {
+				limit	string
+				percent	int
+				arg	string
+			}{
+				{"2Gi", 0, "--reserve-memory 0M"},
+				{"2Gi", 10, "--reserve-memory 204M"},
+				{"4Gi", 25, "--reserve-memory 1024M"},
+				{"1Gi", 50, "--reserve-memory 512M"},
+			} {
+				key := testKey(fmt.Sprintf("redpanda-reserve-memory-%d", i))
+				redpandaCluster := testCluster(key.Name)
+				redpandaCluster.Spec.Resources.Limits = corev1.ResourceList{
+					corev1.ResourceMemory: resource.MustParse(tc.limit),
+				}
+				redpandaCluster.Spec.Resources.ReserveMemoryPercent = tc.percent
+				Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+				var sts appsv1.StatefulSet
+				Eventually(func() error {
+					return k8sClient.Get(context.Background(), key, &sts)
+				}, timeout, interval).Should(Succeed())
+				Expect(sts.Spec.Template.Spec.Containers[0].Args).Should(ContainElement(tc.arg))
+			}
+		})
+	})
+
+	Context("When configuring the log format", func() {
+		It("Should map the log format to the redpanda args", func() {
+			for i, tc := range []struct {
+				format	v1alpha1.LogFormat
+				json	bool
+			}{
+				{"", false},
+				{v1alpha1.LogFormatText, false},
+				{v1alpha1.LogFormatJSON, true},
+			} {
+				key := testKey(fmt.Sprintf("redpanda-log-format-%d", i))
+				redpandaCluster := testCluster(key.Name)
+				redpandaCluster.Spec.LogFormat = tc.format
+				Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+				var sts appsv1.StatefulSet
+				Eventually(func() error {
+					return k8sClient.Get(context.Background(), key, &sts)
+				}, timeout, interval).Should(Succeed())
+
+				args := sts.Spec.Template.Spec.Containers[0].Args
+				if tc.json {
+					Expect(args).Should(ContainElement("--logger-format=json"))
+				} else {
+					Expect(args).ShouldNot(ContainElement(HavePrefix("--logger-format")))
+				}
+			}
+		})
+
+		It("Should reject an unknown log format", func() {
+			key := testKey("redpanda-invalid-log-format")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.LogFormat = "XML"
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).ShouldNot(Succeed())
+		})
+	})
+
+	Context("When additional arguments are configured", func() {
+		It("Should update the pod template when the arguments change", func() {
+			key := testKey("redpanda-additional-args")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.AdditionalCommandlineArguments = map[string]string{
+				"abort-on-seastar-bad-alloc": "",
+			}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+			Expect(sts.Spec.Template.Spec.Containers[0].Args).Should(ContainElement("--abort-on-seastar-bad-alloc"))
+
+			By("Replacing the arguments")
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), key, redpandaCluster); err != nil {
+					return err
+				}
+				redpandaCluster.Spec.AdditionalCommandlineArguments = map[string]string{
+					"max-networking-io-control-blocks": "1000",
+				}
+				return k8sClient.Update(context.Background(), redpandaCluster)
+			}, timeout, interval).Should(Succeed())
+			Eventually(func() []string {
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return nil
+				}
+				return sts.Spec.Template.Spec.Containers[0].Args
+			}, timeout, interval).Should(And(
+				ContainElement("--max-networking-io-control-blocks=1000"),
+				Not(ContainElement("--abort-on-seastar-bad-alloc")),
+				ContainElement("start"),
+			))
+		})
+	})
+
+	Context("When dependencies are configured", func() {
+		It("Should wait for the dependencies until they are removed", func() {
+			key := testKey("redpanda-depends-on")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.DependsOn = []v1alpha1.Dependency{
+				{Name: "object-store", Address: "minio.storage.svc.cluster.local", Port: 9000},
+			}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			waiterArgs := func() []string {
+				var sts appsv1.StatefulSet
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return nil
+				}
+				for _, c := range sts.Spec.Template.Spec.InitContainers {
+					if c.Name == "redpanda-wait-for-dependencies" {
+						return c.Args[1:]
+					}
+				}
+				return nil
+			}
+			Eventually(waiterArgs, timeout, interval).Should(Equal([]string{
+				"wait-for-dependencies", "object-store=minio.storage.svc.cluster.local:9000",
+			}))
+
+			By("Adding a dependency")
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), key, redpandaCluster); err != nil {
+					return err
+				}
+				redpandaCluster.Spec.DependsOn = append(redpandaCluster.Spec.DependsOn,
+					v1alpha1.Dependency{Name: "schema-registry", Address: "10.0.0.12", Port: 8081})
+				return k8sClient.Update(context.Background(), redpandaCluster)
+			}, timeout, interval).Should(Succeed())
+			Eventually(waiterArgs, timeout, interval).Should(ContainElement("schema-registry=10.0.0.12:8081"))
+
+			By("Removing the dependencies")
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), key, redpandaCluster); err != nil {
+					return err
+				}
+				redpandaCluster.Spec.DependsOn = nil
+				return k8sClient.Update(context.Background(), redpandaCluster)
+			}, timeout, interval).Should(Succeed())
+			Eventually(waiterArgs, timeout, interval).Should(BeNil())
+		})
+	})
+
+	Context("When a cpuset is configured", func() {
+		It("Should pin seastar to the given CPUs", func() {
+			key := testKey("redpanda-cpuset")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.Resources.CPUSet = "0-3"
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+
+			args := sts.Spec.Template.Spec.Containers[0].Args
+			Expect(args).Should(ContainElement("--cpuset=0-3"))
+			Expect(args).Should(ContainElement("start"))
+		})
+
+		It("Should leave the CPU set to seastar by default", func() {
+			key := testKey("redpanda-default-cpuset")
+			Expect(k8sClient.Create(context.Background(), testCluster(key.Name))).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+
+			for _, arg := range sts.Spec.Template.Spec.Containers[0].Args {
+				Expect(arg).ShouldNot(HavePrefix("--cpuset"))
+			}
+		})
+
+		It("Should reject an invalid cpuset", func() {
+			key := testKey("redpanda-invalid-cpuset")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.Resources.CPUSet = "all"
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).ShouldNot(Succeed())
+		})
+	})
+
+	Context("When the debug command override is toggled", func() {
+		It("Should idle the broker only while enabled", func() {
+			key := testKey("redpanda-debug-command")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.Debug.OverrideCommand = true
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+
+			container := sts.Spec.Template.Spec.Containers[0]
+			Expect(container.Command).Should(Equal([]string{"/bin/sh", "-c"}))
+			Expect(container.Args).Should(HaveLen(1))
+			Expect(container.Args[0]).Should(ContainSubstring("sleep"))
+			Expect(container.VolumeMounts).Should(ContainElement(corev1.VolumeMount{
+				Name:		"datadir",
+				MountPath:	"/var/lib/redpanda/data",
+			}))
+			Eventually(func() string {
+				return clusterConditionReason(key, v1alpha1.ClusterDegraded)
+			}, timeout, interval).Should(Equal("DebugCommandOverride"))
+
+			By("Disabling the override")
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), key, redpandaCluster); err != nil {
+					return err
+				}
+				redpandaCluster.Spec.Debug.OverrideCommand = false
+				return k8sClient.Update(context.Background(), redpandaCluster)
+			}, timeout, interval).Should(Succeed())
+			Eventually(func() []string {
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return nil
+				}
+				return sts.Spec.Template.Spec.Containers[0].Command
+			}, timeout, interval).Should(BeNil())
+			Expect(sts.Spec.Template.Spec.Containers[0].Args).Should(ContainElement("start"))
+			Eventually(func() metav1.ConditionStatus {
+				return clusterCondition(key, v1alpha1.ClusterDegraded)
+			}, timeout, interval).Should(Equal(metav1.ConditionFalse))
+		})
+
+		It("Should start redpanda by default", func() {
+			key := testKey("redpanda-default-command")
+			Expect(k8sClient.Create(context.Background(), testCluster(key.Name))).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+
+			container := sts.Spec.Template.Spec.Containers[0]
+			Expect(container.Command).Should(BeNil())
+			Expect(container.Args).Should(ContainElement("start"))
+		})
+	})
+
+	Context("When a restart is requested", func() {
+		It("Should roll the brokers out once they are ready", func() {
+			key := testKey("redpanda-restarted-at")
+			Expect(k8sClient.Create(context.Background(), testCluster(key.Name))).Should(Succeed())
+
+			restartedAt := func() string {
+				var sts appsv1.StatefulSet
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return ""
+				}
+				return sts.Spec.Template.Annotations[v1alpha1.RestartedAtAnnotation]
+			}
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &appsv1.StatefulSet{})
+			}, timeout, interval).Should(Succeed())
+
+			updateCluster(key, func(redpandaCluster *v1alpha1.Cluster) {
+				redpandaCluster.Annotations = map[string]string{
+					v1alpha1.RestartedAtAnnotation: "2021-06-01T10:00:00Z",
+				}
+			})
+			Consistently(restartedAt, 3*time.Second, interval).Should(BeEmpty())
+
+			By("Restarting the brokers once they are ready")
+			setReadyReplicas(key, 1)
+			Eventually(restartedAt, timeout, interval).Should(Equal("2021-06-01T10:00:00Z"))
+		})
+	})
+
+	Context("When the StatefulSet is created", func() {
+		It("Should bootstrap a cluster without existing volumes", func() {
+			key := testKey("redpanda-bootstrap")
+			Expect(k8sClient.Create(context.Background(), testCluster(key.Name))).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+
+			Expect(sts.Spec.Template.Spec.InitContainers[0].Env).Should(ContainElement(corev1.EnvVar{
+				Name:	"CONFIGURATOR_MODE",
+				Value:	"bootstrap",
+			}))
+		})
+
+		It("Should rejoin the cluster stored on retained volumes", func() {
+			key := testKey("redpanda-rejoin")
+			Expect(k8sClient.Create(context.Background(), &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:		"datadir-" + key.Name + "-0",
+					Namespace:	key.Namespace,
+					Labels:		map[string]string{"app": key.Name},
+				},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					AccessModes:	[]corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{
+							corev1.ResourceStorage: resource.MustParse("100Gi"),
+						},
+					},
+				},
+			})).Should(Succeed())
+			Expect(k8sClient.Create(context.Background(), testCluster(key.Name))).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+
+			Expect(sts.Spec.Template.Spec.InitContainers[0].Env).Should(ContainElement(corev1.EnvVar{
+				Name:	"CONFIGURATOR_MODE",
+				Value:	"rejoin",
+			}))
+		})
+	})
+
+	Context("When a volume selector is configured", func() {
+		It("Should select the pre-provisioned volumes", func() {
+			key := testKey("redpanda-volume-selector")
+			selector := &metav1.LabelSelector{
+				MatchLabels: map[string]string{"disk": "nvme"},
+			}
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.Storage = v1alpha1.StorageSpec{Selector: selector}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+
+			pvc := sts.Spec.VolumeClaimTemplates[0].Spec
+			Expect(pvc.Selector).Should(Equal(selector))
+			Expect(pvc.StorageClassName).Should(Equal(pointer.StringPtr("")))
+			Expect(pvc.Resources.Requests).Should(HaveKeyWithValue(corev1.ResourceStorage, resource.MustParse("100Gi")))
+		})
+
+		It("Should keep the dynamic provisioning by default", func() {
+			key := testKey("redpanda-volume-default")
+			Expect(k8sClient.Create(context.Background(), testCluster(key.Name))).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+
+			pvc := sts.Spec.VolumeClaimTemplates[0].Spec
+			Expect(pvc.Selector).Should(BeNil())
+			Expect(pvc.StorageClassName).Should(BeNil())
+		})
+	})
+
+	Context("When a storage class is configured", func() {
+		It("Should report the missing StorageClass until it is created", func() {
+			key := testKey("redpanda-storage-class")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.Storage = v1alpha1.StorageSpec{StorageClassName: "redpanda-fast"}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			Eventually(func() string {
+				return clusterConditionReason(key, v1alpha1.ClusterStorageClassMissing)
+			}, timeout, interval).Should(Equal("StorageClassNotFound"))
+			Expect(clusterCondition(key, v1alpha1.ClusterStorageClassMissing)).Should(Equal(metav1.ConditionTrue))
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+			Expect(sts.Spec.VolumeClaimTemplates[0].Spec.StorageClassName).Should(Equal(pointer.StringPtr("redpanda-fast")))
+
+			By("Creating the StorageClass")
+			Expect(k8sClient.Create(context.Background(), &storagev1.StorageClass{
+				ObjectMeta:	metav1.ObjectMeta{Name: "redpanda-fast"},
+				Provisioner:	"kubernetes.io/no-provisioner",
+			})).Should(Succeed())
+			Eventually(func() metav1.ConditionStatus {
+				return clusterCondition(key, v1alpha1.ClusterStorageClassMissing)
+			}, timeout, interval).Should(Equal(metav1.ConditionFalse))
+		})
+	})
+
+	Context("When IO properties are configured", func() {
+		It("Should mount the inline properties and pass the flag", func() {
+			key := testKey("redpanda-io-properties")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.Storage = v1alpha1.StorageSpec{
+				IOProperties: &v1alpha1.IOPropertiesSource{Inline: "disks: []"},
+			}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+
+			container := sts.Spec.Template.Spec.Containers[0]
+			Expect(container.Args).Should(ContainElement("--io-properties-file=/mnt/io-properties/io-properties.yaml"))
+			Expect(container.VolumeMounts).Should(ContainElement(corev1.VolumeMount{
+				Name:		"io-properties",
+				MountPath:	"/mnt/io-properties",
+				ReadOnly:	true,
+			}))
+
+			var cm corev1.ConfigMap
+			Expect(k8sClient.Get(context.Background(), types.NamespacedName{
+				Name:		key.Name + "-base",
+				Namespace:	key.Namespace,
+			}, &cm)).Should(Succeed())
+			Expect(cm.Data).Should(HaveKeyWithValue("io-properties.yaml", "disks: []"))
+		})
+
+		It("Should mount the properties of the referenced ConfigMap", func() {
+			key := testKey("redpanda-io-properties-ref")
+			io := &corev1.ConfigMap{
+				ObjectMeta:	metav1.ObjectMeta{Name: "benchmarked-io", Namespace: key.Namespace},
+				Data:		map[string]string{"io-properties.yaml": "disks: []"},
+			}
+			Expect(k8sClient.Create(context.Background(), io)).Should(Succeed())
+
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.Storage = v1alpha1.StorageSpec{
+				IOProperties: &v1alpha1.IOPropertiesSource{
+					ConfigMapRef: &corev1.LocalObjectReference{Name: "benchmarked-io"},
+				},
+			}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+
+			var volume *corev1.Volume
+			for i := range sts.Spec.Template.Spec.Volumes {
+				if sts.Spec.Template.Spec.Volumes[i].Name == "io-properties" {
+					volume = &sts.Spec.Template.Spec.Volumes[i]
+				}
+			}
+			Expect(volume).ShouldNot(BeNil())
+			Expect(volume.ConfigMap.Name).Should(Equal("benchmarked-io"))
+
+			By("Restarting the brokers when the properties change")
+			hash := sts.Spec.Template.Annotations["redpanda.vectorized.io/io-properties-hash"]
+			Expect(hash).ShouldNot(BeEmpty())
+
+			io.Data["io-properties.yaml"] = "disks: [{mountpoint: /var/lib/redpanda/data}]"
+			Expect(k8sClient.Update(context.Background(), io)).Should(Succeed())
+
+			Eventually(func() string {
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return ""
+				}
+				return sts.Spec.Template.Annotations["redpanda.vectorized.io/io-properties-hash"]
+			}, timeout, interval).ShouldNot(Or(BeEmpty(), Equal(hash)))
+		})
+
+		It("Should mount the properties configured on an existing Cluster", func() {
+			key := testKey("redpanda-io-properties-update")
+			Expect(k8sClient.Create(context.Background(), testCluster(key.Name))).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+
+			updateCluster(key, func(c *v1alpha1.Cluster) {
+				c.Spec.Storage.IOProperties = &v1alpha1.IOPropertiesSource{Inline: "disks: []"}
+			})
+
+			mount := corev1.VolumeMount{Name: "io-properties", MountPath: "/mnt/io-properties", ReadOnly: true}
+			Eventually(func() []corev1.VolumeMount {
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return nil
+				}
+				return sts.Spec.Template.Spec.Containers[0].VolumeMounts
+			}, timeout, interval).Should(ContainElement(mount))
+			Expect(sts.Spec.Template.Spec.Containers[0].Args).Should(
+				ContainElement("--io-properties-file=/mnt/io-properties/io-properties.yaml"))
+
+			By("Removing the volume with the properties")
+			updateCluster(key, func(c *v1alpha1.Cluster) {
+				c.Spec.Storage.IOProperties = nil
+			})
+
+			Eventually(func() []corev1.VolumeMount {
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return nil
+				}
+				return sts.Spec.Template.Spec.Containers[0].VolumeMounts
+			}, timeout, interval).ShouldNot(ContainElement(mount))
+			for _, v := range sts.Spec.Template.Spec.Volumes {
+				Expect(v.Name).ShouldNot(Equal("io-properties"))
+			}
+		})
+
+		It("Should let redpanda detect the properties by default", func() {
+			key := testKey("redpanda-io-properties-default")
+			Expect(k8sClient.Create(context.Background(), testCluster(key.Name))).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+
+			container := sts.Spec.Template.Spec.Containers[0]
+			Expect(container.Args).ShouldNot(ContainElement(HavePrefix("--io-properties-file")))
+			for _, m := range container.VolumeMounts {
+				Expect(m.Name).ShouldNot(Equal("io-properties"))
+			}
+		})
+	})
+
+	Context("When file modes are configured on an existing Cluster", func() {
+		It("Should mount the files of the base ConfigMap with the modes", func() {
+			key := testKey("redpanda-file-modes")
+			Expect(k8sClient.Create(context.Background(), testCluster(key.Name))).Should(Succeed())
+
+			items := func() []corev1.KeyToPath {
+				var sts appsv1.StatefulSet
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return nil
+				}
+				for _, v := range sts.Spec.Template.Spec.Volumes {
+					if v.Name == "configmap-dir" {
+						return v.ConfigMap.Items
+					}
+				}
+				return nil
+			}
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &appsv1.StatefulSet{})
+			}, timeout, interval).Should(Succeed())
+			Expect(items()).Should(BeEmpty())
+
+			updateCluster(key, func(c *v1alpha1.Cluster) {
+				c.Spec.ConfigDir.FileModes = map[string]int32{"redpanda.yaml": 0640}
+			})
+			Eventually(items, timeout, interval).Should(ContainElement(corev1.KeyToPath{
+				Key:	"redpanda.yaml",
+				Path:	"redpanda.yaml",
+				Mode:	pointer.Int32Ptr(0640),
+			}))
+
+			By("Restoring the default mode of every file")
+			updateCluster(key, func(c *v1alpha1.Cluster) {
+				c.Spec.ConfigDir.FileModes = nil
+			})
+			Eventually(items, timeout, interval).Should(BeEmpty())
+		})
+	})
+
+	Context("When configuring the pod anti-affinity", func() {
+		It("Should use the hostname topology key by default", func() {
+			key := testKey("redpanda-default-anti-affinity")
+			Expect(k8sClient.Create(context.Background(), testCluster(key.Name))).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+
+			antiAffinity := sts.Spec.Template.Spec.Affinity.PodAntiAffinity
+			Expect(antiAffinity.RequiredDuringSchedulingIgnoredDuringExecution[0].TopologyKey).Should(Equal(corev1.LabelHostname))
+			Expect(antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution[0].PodAffinityTerm.TopologyKey).Should(Equal(corev1.LabelHostname))
+		})
+
+		It("Should use the chosen topology key", func() {
+			key := testKey("redpanda-zone-anti-affinity")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.Scheduling = v1alpha1.SchedulingSpec{
+				AntiAffinityTopologyKey: corev1.LabelZoneFailureDomainStable,
+			}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+
+			antiAffinity := sts.Spec.Template.Spec.Affinity.PodAntiAffinity
+			Expect(antiAffinity.RequiredDuringSchedulingIgnoredDuringExecution[0].TopologyKey).Should(Equal(corev1.LabelZoneFailureDomainStable))
+			Expect(antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution[0].PodAffinityTerm.TopologyKey).Should(Equal(corev1.LabelZoneFailureDomainStable))
+		})
+
+		It("Should only prefer the anti-affinity in developer mode", func() {
+			key := testKey("redpanda-developer-anti-affinity")
+			Expect(k8sClient.Create(context.Background(), testCluster(key.Name))).Should(Succeed())
+
+			antiAffinity := func() *corev1.PodAntiAffinity {
+				var sts appsv1.StatefulSet
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil || sts.Spec.Template.Spec.Affinity == nil {
+					return nil
+				}
+				return sts.Spec.Template.Spec.Affinity.PodAntiAffinity
+			}
+			Eventually(antiAffinity, timeout, interval).ShouldNot(BeNil())
+			Expect(antiAffinity().RequiredDuringSchedulingIgnoredDuringExecution).Should(HaveLen(1))
+
+			updateCluster(key, func(redpandaCluster *v1alpha1.Cluster) {
+				redpandaCluster.Spec.Configuration.DeveloperMode = true
+			})
+			Eventually(func() []corev1.PodAffinityTerm {
+				return antiAffinity().RequiredDuringSchedulingIgnoredDuringExecution
+			}, timeout, interval).Should(BeEmpty())
+			Expect(antiAffinity().PreferredDuringSchedulingIgnoredDuringExecution).Should(HaveLen(1))
+		})
+	})
+
+	Context("When configuring the config-dir volume", func() {
+		It("Should keep the configuration in memory with a size limit", func() {
+			key := testKey("redpanda-memory-config-dir")
+			sizeLimit := resource.MustParse("1Mi")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.ConfigDir = v1alpha1.ConfigDirSpec{
+				Medium:		corev1.StorageMediumMemory,
+				SizeLimit:	&sizeLimit,
+			}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+
+			Expect(configDirVolume(&sts)).Should(Equal(&corev1.EmptyDirVolumeSource{
+				Medium:		corev1.StorageMediumMemory,
+				SizeLimit:	&sizeLimit,
+			}))
+		})
+
+		It("Should use the node disk without limit by default", func() {
+			key := testKey("redpanda-default-config-dir")
+			Expect(k8sClient.Create(context.Background(), testCluster(key.Name))).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+
+			Expect(configDirVolume(&sts)).Should(Equal(&corev1.EmptyDirVolumeSource{}))
+		})
+	})
+
+	Context("When the pod security context changes", func() {
+		It("Should update the pod template and keep the default fsGroup", func() {
+			key := testKey("redpanda-pod-security-context")
+			redpandaCluster := testCluster(key.Name)
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+			Expect(sts.Spec.Template.Spec.SecurityContext.FSGroup).Should(Equal(pointer.Int64Ptr(101)))
+
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), key, redpandaCluster); err != nil {
+					return err
+				}
+				redpandaCluster.Spec.PodSecurityContext = &corev1.PodSecurityContext{
+					RunAsNonRoot:		pointer.BoolPtr(true),
+					SupplementalGroups:	[]int64{2000},
+				}
+				return k8sClient.Update(context.Background(), redpandaCluster)
+			}, timeout, interval).Should(Succeed())
+
+			Eventually(func() *corev1.PodSecurityContext {
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return nil
+				}
+				return sts.Spec.Template.Spec.SecurityContext
+			}, timeout, interval).Should(Equal(&corev1.PodSecurityContext{
+				RunAsNonRoot:		pointer.BoolPtr(true),
+				SupplementalGroups:	[]int64{2000},
+				FSGroup:		pointer.Int64Ptr(101),
+			}))
+		})
+	})
+
+	Context("When configuring the topology spread constraints", func() {
+		It("Should spread the brokers across the zones by default", func() {
+			key := testKey("redpanda-default-spread")
+			Expect(k8sClient.Create(context.Background(), testCluster(key.Name))).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+
+			constraints := sts.Spec.Template.Spec.TopologySpreadConstraints
+			Expect(constraints).Should(HaveLen(1))
+			Expect(constraints[0].TopologyKey).Should(Equal(corev1.LabelZoneFailureDomainStable))
+		})
+
+		It("Should update the pod template when the constraints change", func() {
+			key := testKey("redpanda-spread")
+			redpandaCluster := testCluster(key.Name)
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), key, redpandaCluster); err != nil {
+					return err
+				}
+				redpandaCluster.Spec.Scheduling.TopologySpreadConstraints = []corev1.TopologySpreadConstraint{
+					{
+						MaxSkew:		2,
+						TopologyKey:		corev1.LabelHostname,
+						WhenUnsatisfiable:	corev1.DoNotSchedule,
+					},
+				}
+				return k8sClient.Update(context.Background(), redpandaCluster)
+			}, timeout, interval).Should(Succeed())
+
+			Eventually(func() []corev1.TopologySpreadConstraint {
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return nil
+				}
+				return sts.Spec.Template.Spec.TopologySpreadConstraints
+			}, timeout, interval).Should(Equal([]corev1.TopologySpreadConstraint{
+				{
+					MaxSkew:		2,
+					TopologyKey:		corev1.LabelHostname,
+					WhenUnsatisfiable:	corev1.DoNotSchedule,
+					LabelSelector:		metav1.SetAsLabelSelector(redpandaCluster.Labels),
+				},
+			}))
+		})
+	})
+
+	Context("When configuring the startup probe", func() {
+		It("Should probe the Admin API readiness with the defaults", func() {
+			key := testKey("redpanda-default-startup-probe")
+			Expect(k8sClient.Create(context.Background(), testCluster(key.Name))).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+
+			probe := sts.Spec.Template.Spec.Containers[0].StartupProbe
+			Expect(probe).ShouldNot(BeNil())
+			Expect(probe.HTTPGet.Path).Should(Equal("/v1/status/ready"))
+			Expect(probe.HTTPGet.Port.IntValue()).Should(Equal(9644))
+			Expect(probe.FailureThreshold).Should(Equal(int32(60)))
+			Expect(probe.PeriodSeconds).Should(Equal(int32(10)))
+		})
+
+		It("Should probe the configured health check scheme and path", func() {
+			key := testKey("redpanda-health-check")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.Configuration.AdminAPI.HealthCheck = v1alpha1.AdminAPIHealthCheck{
+				Scheme:	corev1.URISchemeHTTPS,
+				Path:	"/redpanda/v1/status/ready",
+			}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+
+			container := sts.Spec.Template.Spec.Containers[0]
+			for _, probe := range []*corev1.Probe{container.StartupProbe, container.LivenessProbe} {
+				Expect(probe.HTTPGet.Scheme).Should(Equal(corev1.URISchemeHTTPS))
+				Expect(probe.HTTPGet.Path).Should(Equal("/redpanda/v1/status/ready"))
+			}
+
+			By("Reaching the Admin API over https on the configured path")
+			setReadyReplicas(key, 1)
+			Eventually(func() *redpandacontrollers.AdminAPIConfig {
+				return testAdminAPIs.get(key.Name).connectionConfig()
+			}, timeout, interval).ShouldNot(BeNil())
+			cfg := testAdminAPIs.get(key.Name).connectionConfig()
+			Expect(cfg.TLS).ShouldNot(BeNil())
+			Expect(cfg.ReadyPath).Should(Equal("/redpanda/v1/status/ready"))
+		})
+
+		It("Should apply the configured thresholds", func() {
+			key := testKey("redpanda-startup-probe")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.StartupProbe = v1alpha1.StartupProbeSpec{
+				FailureThreshold:	120,
+				PeriodSeconds:		30,
+			}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+
+			probe := sts.Spec.Template.Spec.Containers[0].StartupProbe
+			Expect(probe.FailureThreshold).Should(Equal(int32(120)))
+			Expect(probe.PeriodSeconds).Should(Equal(int32(30)))
+
+			By("Updating the probe when the thresholds change")
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), key, redpandaCluster); err != nil {
+					return err
+				}
+				redpandaCluster.Spec.StartupProbe.FailureThreshold = 180
+				return k8sClient.Update(context.Background(), redpandaCluster)
+			}, timeout, interval).Should(Succeed())
+			Eventually(func() int32 {
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return 0
+				}
+				return sts.Spec.Template.Spec.Containers[0].StartupProbe.FailureThreshold
+			}, timeout, interval).Should(Equal(int32(180)))
+		})
+	})
+
+	Context("When configuring the liveness probe", func() {
+		It("Should relax the probe while the operator rolls the brokers out", func() {
+			key := testKey("redpanda-rollout-liveness")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.LivenessProbe.RolloutFailureThreshold = 30
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			livenessThreshold := func() int32 {
+				var sts appsv1.StatefulSet
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return 0
+				}
+				if probe := sts.Spec.Template.Spec.Containers[0].LivenessProbe; probe != nil {
+					return probe.FailureThreshold
+				}
+				return 0
+			}
+			Eventually(livenessThreshold, timeout, interval).Should(Equal(int32(3)))
+
+			By("Changing the pod template while the StatefulSet rolls out")
+			setRevisions(key, "rev-1", "rev-2")
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), key, redpandaCluster); err != nil {
+					return err
+				}
+				redpandaCluster.Spec.StartupProbe.FailureThreshold = 90
+				return k8sClient.Update(context.Background(), redpandaCluster)
+			}, timeout, interval).Should(Succeed())
+			Eventually(livenessThreshold, timeout, interval).Should(Equal(int32(30)))
+			Consistently(livenessThreshold, time.Second, interval).Should(Equal(int32(30)))
+			Eventually(func() metav1.ConditionStatus {
+				return clusterCondition(key, v1alpha1.ClusterRollingOut)
+			}, timeout, interval).Should(Equal(metav1.ConditionTrue))
+
+			By("Restoring the probe once the rollout completes")
+			setRevisions(key, "rev-2", "rev-2")
+			Eventually(livenessThreshold, timeout, interval).Should(Equal(int32(3)))
+			Eventually(func() string {
+				return clusterConditionReason(key, v1alpha1.ClusterRollingOut)
+			}, timeout, interval).Should(Equal("RolloutComplete"))
+
+			By("Not relaxing the probe for the rollout restoring it")
+			setRevisions(key, "rev-2", "rev-3")
+			Consistently(livenessThreshold, time.Second, interval).Should(Equal(int32(3)))
+		})
+
+		It("Should keep the probe during rollouts by default", func() {
+			key := testKey("redpanda-default-liveness")
+			redpandaCluster := testCluster(key.Name)
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+			probe := sts.Spec.Template.Spec.Containers[0].LivenessProbe
+			Expect(probe).ShouldNot(BeNil())
+			Expect(probe.HTTPGet.Path).Should(Equal("/v1/status/ready"))
+			Expect(probe.PeriodSeconds).Should(Equal(int32(10)))
+
+			setRevisions(key, "rev-1", "rev-2")
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), key, redpandaCluster); err != nil {
+					return err
+				}
+				redpandaCluster.Spec.StartupProbe.FailureThreshold = 90
+				return k8sClient.Update(context.Background(), redpandaCluster)
+			}, timeout, interval).Should(Succeed())
+			Eventually(func() int32 {
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return 0
+				}
+				return sts.Spec.Template.Spec.Containers[0].StartupProbe.FailureThreshold
+			}, timeout, interval).Should(Equal(int32(90)))
+			Expect(sts.Spec.Template.Spec.Containers[0].LivenessProbe.FailureThreshold).Should(Equal(int32(3)))
+		})
+	})
+
+	Context("When extra pod labels are configured", func() {
+		It("Should label the pods without changing the selector", func() {
+			key := testKey("redpanda-pod-labels")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.PodTemplate.Labels = map[string]string{
+				"team":	"storage",
+				"app":	"override",
+			}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+			Expect(sts.Spec.Template.Labels).Should(Equal(map[string]string{
+				"app":	key.Name,
+				"team":	"storage",
+			}))
+			Expect(sts.Spec.Selector.MatchLabels).Should(Equal(map[string]string{"app": key.Name}))
+
+			By("Adding an extra label")
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), key, redpandaCluster); err != nil {
+					return err
+				}
+				redpandaCluster.Spec.PodTemplate.Labels["cost-center"] = "42"
+				return k8sClient.Update(context.Background(), redpandaCluster)
+			}, timeout, interval).Should(Succeed())
+			Eventually(func() map[string]string {
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return nil
+				}
+				return sts.Spec.Template.Labels
+			}, timeout, interval).Should(HaveKeyWithValue("cost-center", "42"))
+			Expect(sts.Spec.Template.Labels).Should(HaveKeyWithValue("app", key.Name))
+			Expect(sts.Spec.Selector.MatchLabels).Should(Equal(map[string]string{"app": key.Name}))
+		})
+	})
+
+	Context("When the Pandaproxy TLS is enabled on an existing Cluster", func() {
+		It("Should mount the certificate until the proxy is disabled", func() {
+			key := testKey("redpanda-pandaproxy-tls")
+			createRPCCertificate(key.Name+"-proxy", key.Name+".default.svc.cluster.local")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.PandaProxy.Enabled = true
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &appsv1.StatefulSet{})
+			}, timeout, interval).Should(Succeed())
+			Expect(statefulSetVolumeNames(key)).ShouldNot(ContainElement("pandaproxy-tls"))
+
+			By("Enabling the TLS of the proxy")
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), key, redpandaCluster); err != nil {
+					return err
+				}
+				redpandaCluster.Spec.PandaProxy.TLS = v1alpha1.PandaProxyTLS{
+					Enabled:	true,
+					CertSecretRef:	&corev1.LocalObjectReference{Name: key.Name + "-proxy"},
+				}
+				return k8sClient.Update(context.Background(), redpandaCluster)
+			}, timeout, interval).Should(Succeed())
+			Eventually(func() []corev1.VolumeMount {
+				return redpandaVolumeMounts(key)
+			}, timeout, interval).Should(ContainElement(corev1.VolumeMount{
+				Name:		"pandaproxy-tls",
+				MountPath:	"/etc/tls/certs/pandaproxy",
+				ReadOnly:	true,
+			}))
+			Expect(statefulSetVolumeNames(key)).Should(ContainElement("pandaproxy-tls"))
+
+			By("Disabling the proxy")
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), key, redpandaCluster); err != nil {
+					return err
+				}
+				redpandaCluster.Spec.PandaProxy.Enabled = false
+				return k8sClient.Update(context.Background(), redpandaCluster)
+			}, timeout, interval).Should(Succeed())
+			Eventually(func() []string {
+				return statefulSetVolumeNames(key)
+			}, timeout, interval).ShouldNot(ContainElement("pandaproxy-tls"))
+			Expect(volumeMountNames(redpandaVolumeMounts(key))).ShouldNot(ContainElement("pandaproxy-tls"))
+		})
+	})
+
+	Context("When the StatefulSet metadata is edited externally", func() {
+		It("Should restore the operator managed labels only", func() {
+			key := testKey("redpanda-label-drift")
+			Expect(k8sClient.Create(context.Background(), testCluster(key.Name))).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, &sts)
+			}, timeout, interval).Should(Succeed())
+
+			By("Overwriting a managed label and adding an external one")
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return err
+				}
+				sts.Labels["app"] = "changed"
+				sts.Labels["example.com/team"] = "streaming"
+				return k8sClient.Update(context.Background(), &sts)
+			}, timeout, interval).Should(Succeed())
+
+			Eventually(func() bool {
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return false
+				}
+				return sts.Labels["app"] == key.Name &&
+					sts.Labels["example.com/team"] == "streaming"
+			}, timeout, interval).Should(BeTrue())
+		})
+	})
+})
+
+// configDirVolume returns the emptyDir source of the config-dir volume
+func configDirVolume(sts *appsv1.StatefulSet) *corev1.EmptyDirVolumeSource {
+	for _, v := range sts.Spec.Template.Spec.Volumes {
+		if v.Name == "config-dir" {
+			return v.EmptyDir
+		}
+	}
+	return nil
+}
+
+// setRevisions reports the current and update revisions of the Cluster
+// StatefulSet, as no StatefulSet controller runs in the test environment
+func setRevisions(key types.NamespacedName, current, update string) {
+	Eventually(func() error {
+		var sts appsv1.StatefulSet
+		if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+			return err
+		}
+		sts.Status.CurrentRevision = current
+		sts.Status.UpdateRevision = update
+		return k8sClient.Status().Update(context.Background(), &sts)
+	}, timeout, interval).Should(Succeed())
+}
@@ -0,0 +1,78 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	"context"
+	"fmt"
+
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	reasonStorageClassNotFound	= "StorageClassNotFound"
+	reasonStorageClassFound		= "StorageClassFound"
+)
+
+// reconcileStorageClass reports whether the StorageClass of the data
+// volumes exists. The StatefulSet is created anyway, its claims are bound
+// once the class is created.
+func (r *ClusterReconciler) reconcileStorageClass(
+	ctx context.Context,
+	cluster *redpandav1alpha1.Cluster,
+	status *redpandav1alpha1.ClusterStatus,
+) error {
+	name := cluster.Spec.Storage.StorageClassName
+	if name == "" {
+		meta.RemoveStatusCondition(&status.Conditions, redpandav1alpha1.ClusterStorageClassMissing)
+
+		return nil
+	}
+
+	var class storagev1.StorageClass
+
+	err := r.Get(ctx, types.NamespacedName{Name: name}, &class)
+	if errors.IsNotFound(err) {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:		redpandav1alpha1.ClusterStorageClassMissing,
+			Status:		metav1.ConditionTrue,
+			Reason:		reasonStorageClassNotFound,
+			Message:	fmt.Sprintf("StorageClass %s does not exist, the data volumes can not be provisioned", name),
+		})
+
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("unable to fetch StorageClass %s: %w", name, err)
+	}
+
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:	redpandav1alpha1.ClusterStorageClassMissing,
+		Status:	metav1.ConditionFalse,
+		Reason:	reasonStorageClassFound,
+	})
+
+	return nil
+}
+
+// referencedStorageClasses returns the StorageClass of the data volumes
+func referencedStorageClasses(cluster *redpandav1alpha1.Cluster) []string {
+	if cluster.Spec.Storage.StorageClassName == "" {
+		return nil
+	}
+
+	return []string{cluster.Spec.Storage.StorageClassName}
+}
@@ -19,6 +19,7 @@ import (
 	"github.com/onsi/gomega/gexec"
 	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
 	redpandacontrollers "github.com/vectorizedio/redpanda/src/go/k8s/controllers/redpanda"
+	"github.com/vectorizedio/redpanda/src/go/k8s/pkg/admin"
 	"k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -33,6 +34,7 @@ import (
 
 var k8sClient client.Client
 var testEnv *envtest.Environment
+var testAdminAPIs = newMockAdminAPIs()
 
 func TestAPIs(t *testing.T) {
 	RegisterFailHandler(Fail)
@@ -70,6 +72,18 @@ var _ = BeforeSuite(func(done Done) {
 		Client:	k8sManager.GetClient(),
 		Log:	ctrl.Log.WithName("controllers").WithName("core").WithName("RedpandaCluster"),
 		Scheme:	k8sManager.GetScheme(),
+		AdminAPIClientFactory: func(cluster *redpandav1alpha1.Cluster, cfg *redpandacontrollers.AdminAPIConfig) (admin.AdminAPIClient, error) {
+			return testAdminAPIs.connect(cluster.Name, cfg), nil
+		},
+		QuorumLossGracePeriod:	2 * time.Second,
+		Recorder:		k8sManager.GetEventRecorderFor("redpanda-controller"),
+	}).SetupWithManager(k8sManager)
+	Expect(err).ToNot(HaveOccurred())
+
+	err = (&redpandacontrollers.ConsoleReconciler{
+		Client:	k8sManager.GetClient(),
+		Log:	ctrl.Log.WithName("controllers").WithName("core").WithName("RedpandaConsole"),
+		Scheme:	k8sManager.GetScheme(),
 	}).SetupWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
@@ -0,0 +1,282 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	"github.com/vectorizedio/redpanda/src/go/k8s/pkg/admin"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	superuserSuffix		= "-superuser"
+	defaultSuperuserName	= "admin"
+
+	usernameKey	= "username"
+	passwordKey	= "password"
+
+	passwordLength	= 32
+	passwordChars	= "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+	reasonSuperuserMechanismRemoved	= "SuperuserMechanismRemoved"
+	reasonSuperuserMechanismOffered	= "SuperuserMechanismOffered"
+	reasonCredentialsRejected	= "SuperuserCredentialsRejected"
+	reasonCredentialsAccepted	= "SuperuserCredentialsAccepted"
+)
+
+var errMissingPassword = errors.New("secret does not contain the " + passwordKey + " key")
+
+// superuserName returns the name of the bootstrap superuser
+func superuserName(cluster *redpandav1alpha1.Cluster) string {
+	if name := cluster.Spec.SASL.SuperuserName; name != "" {
+		return name
+	}
+
+	return defaultSuperuserName
+}
+
+// superuserMechanism returns the SASL mechanism of the bootstrap superuser:
+// the one it was created with, or the first offered mechanism
+func superuserMechanism(cluster *redpandav1alpha1.Cluster, status *redpandav1alpha1.ClusterStatus) string {
+	if m := status.SuperuserMechanism; m != "" {
+		return m
+	}
+
+	return saslMechanisms(cluster)[0]
+}
+
+// reconcileSuperuser makes sure the bootstrap superuser credentials exist
+// and creates the user through the Admin API once a broker is ready. The
+// user creation is idempotent, so it is retried on every reconciliation,
+// and it sets the password of the Secret again when it changed. The user
+// keeps the mechanism it was created with, the Cluster is degraded when
+// that mechanism is no longer offered. Once the Admin API requires the
+// authentication, a changed password is rejected as the operator
+// authenticates with it, the Cluster is degraded until the previous
+// password is restored.
+func (r *ClusterReconciler) reconcileSuperuser(
+	ctx context.Context,
+	cluster *redpandav1alpha1.Cluster,
+	sts *appsv1.StatefulSet,
+	adminAPIConfig *AdminAPIConfig,
+	status *redpandav1alpha1.ClusterStatus,
+) error {
+	if !cluster.Spec.SASL.Enabled {
+		return nil
+	}
+
+	mechanism := superuserMechanism(cluster, status)
+	if contains(saslMechanisms(cluster), mechanism) {
+		clearDegraded(status, reasonSuperuserMechanismOffered, reasonSuperuserMechanismRemoved)
+	} else {
+		setDegraded(status, reasonSuperuserMechanismRemoved, fmt.Sprintf(
+			"The bootstrap superuser was created with %s, which is no longer offered", mechanism))
+	}
+
+	password, err := r.superuserPassword(ctx, cluster)
+	if err != nil {
+		return err
+	}
+
+	// The Admin API is not reachable before the first broker is ready
+	if sts.Status.ReadyReplicas == 0 {
+		return nil
+	}
+
+	adminAPI, err := r.adminAPIClient(cluster, adminAPIConfig)
+	if err != nil {
+		return err
+	}
+
+	err = adminAPI.CreateUser(ctx, superuserName(cluster), password, mechanism)
+	if credentialsRejected(err) {
+		setDegraded(status, reasonCredentialsRejected, fmt.Sprintf(
+			"The Admin API rejects the password of the bootstrap superuser %s, it differs from the one the user was created with",
+			superuserName(cluster)))
+	}
+
+	if err != nil {
+		return err
+	}
+
+	clearDegraded(status, reasonCredentialsAccepted, reasonCredentialsRejected)
+
+	status.SuperuserMechanism = mechanism
+
+	return nil
+}
+
+// credentialsRejected returns whether the Admin API rejected the
+// credentials of the operator
+func credentialsRejected(err error) bool {
+	return errors.Is(err, admin.ErrUnauthorized)
+}
+
+// adminAPIRequireAuthProperty is the cluster property making the Admin API
+// only accept the requests of the superusers
+const adminAPIRequireAuthProperty = "admin_api_require_auth"
+
+// reconcileAdminAPIAuth sets admin_api_require_auth through the Admin API.
+// It is not part of the bootstrap configuration, as the operator could not
+// create the bootstrap superuser it authenticates with, so it is only
+// enabled once the superuser exists. The next calls of the reconciliation
+// are authenticated too.
+func (r *ClusterReconciler) reconcileAdminAPIAuth(
+	ctx context.Context,
+	cluster *redpandav1alpha1.Cluster,
+	sts *appsv1.StatefulSet,
+	adminAPIConfig *AdminAPIConfig,
+	status *redpandav1alpha1.ClusterStatus,
+) error {
+	required := cluster.Spec.Configuration.AdminAPI.RequireAuth
+	if !required && !status.AdminAPIAuthRequired {
+		return nil
+	}
+
+	if (required && status.SuperuserMechanism == "") || sts.Status.ReadyReplicas == 0 {
+		return nil
+	}
+
+	if adminAPIConfig.Username == "" {
+		if err := r.setAdminAPICredentials(ctx, cluster, adminAPIConfig); err != nil {
+			return err
+		}
+	}
+
+	adminAPI, err := r.adminAPIClient(cluster, adminAPIConfig)
+	if err != nil {
+		return err
+	}
+
+	// The current value is compared, so that the property reset outside
+	// of the operator is set again
+	current, err := adminAPI.ClusterConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	if enabled, _ := current[adminAPIRequireAuthProperty].(bool); enabled != required {
+		err = adminAPI.PatchClusterConfig(ctx, map[string]interface{}{adminAPIRequireAuthProperty: required}, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	status.AdminAPIAuthRequired = required
+
+	return nil
+}
+
+// superuserPassword returns the password of the bootstrap superuser. It is
+// read from the user supplied Secret, or from the Secret owned by the
+// Cluster which is created with a random password the first time.
+func (r *ClusterReconciler) superuserPassword(
+	ctx context.Context, cluster *redpandav1alpha1.Cluster,
+) (string, error) {
+	name := cluster.Name + superuserSuffix
+	if ref := cluster.Spec.SASL.SuperuserSecretRef; ref != nil {
+		name = ref.Name
+	}
+
+	var secret corev1.Secret
+
+	err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: cluster.Namespace}, &secret)
+	if err == nil {
+		// Only the generated Secret belongs to the Cluster
+		if cluster.Spec.SASL.SuperuserSecretRef == nil {
+			if err = r.ensureOwner(ctx, cluster, &secret); err != nil {
+				return "", err
+			}
+		}
+
+		password, ok := secret.Data[passwordKey]
+		if !ok {
+			return "", fmt.Errorf("invalid superuser secret %s/%s: %w", cluster.Namespace, name, errMissingPassword)
+		}
+
+		return string(password), nil
+	}
+
+	if !apierrors.IsNotFound(err) || cluster.Spec.SASL.SuperuserSecretRef != nil {
+		return "", fmt.Errorf("unable to fetch superuser secret %s/%s: %w", cluster.Namespace, name, err)
+	}
+
+	password, err := generatePassword(passwordLength)
+	if err != nil {
+		return "", err
+	}
+
+	if err = r.createSuperuserSecret(ctx, cluster, password); err != nil {
+		return "", err
+	}
+
+	return password, nil
+}
+
+func (r *ClusterReconciler) createSuperuserSecret(
+	ctx context.Context, cluster *redpandav1alpha1.Cluster, password string,
+) error {
+	secret := buildSuperuserSecret(cluster, password)
+
+	err := controllerutil.SetControllerReference(cluster, secret, r.Scheme)
+	if err != nil {
+		return err
+	}
+
+	return r.Create(ctx, secret)
+}
+
+// buildSuperuserSecret returns the Secret holding the credentials of the
+// generated bootstrap superuser
+func buildSuperuserSecret(
+	cluster *redpandav1alpha1.Cluster, password string,
+) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:	cluster.Namespace,
+			Name:		cluster.Name + superuserSuffix,
+			Labels:		cluster.Labels,
+		},
+		Type:	corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			usernameKey:	[]byte(superuserName(cluster)),
+			passwordKey:	[]byte(password),
+		},
+	}
+}
+
+// generatePassword returns a random alphanumeric password read from the
+// cryptographically secure random generator
+func generatePassword(length int) (string, error) {
+	max := big.NewInt(int64(len(passwordChars)))
+	password := make([]byte, length)
+
+	for i := range password {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+
+		password[i] = passwordChars[n.Int64()]
+	}
+
+	return string(password), nil
+}
@@ -0,0 +1,202 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Redpanda bootstrap superuser", func() {
+	Context("When SASL is enabled without superuser credentials", func() {
+		It("Should generate the credentials once and create the user", func() {
+			key := testKey("redpanda-sasl")
+			secretKey := types.NamespacedName{Name: key.Name + "-superuser", Namespace: key.Namespace}
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.SASL = v1alpha1.SASLConfig{
+				Enabled:	true,
+				SuperuserName:	"bootstrap",
+			}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			By("Storing the generated credentials in an owned Secret")
+			var secret corev1.Secret
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), secretKey, &secret)
+			}, timeout, interval).Should(Succeed())
+			Expect(validOwner(redpandaCluster, secret.OwnerReferences)).Should(BeTrue())
+			Expect(string(secret.Data["username"])).Should(Equal("bootstrap"))
+			Expect(string(secret.Data["password"])).Should(MatchRegexp("^[a-zA-Z0-9]{32}$"))
+			password := string(secret.Data["password"])
+
+			By("Enabling SASL in the redpanda configuration")
+			Expect(eventuallyRedpandaConfig(key)).Should(And(
+				HaveKeyWithValue("enable_sasl", true),
+				HaveKeyWithValue("superusers", ConsistOf("bootstrap")),
+			))
+
+			By("Creating the user once a broker is ready")
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return err
+				}
+				sts.Status.Replicas = 1
+				sts.Status.ReadyReplicas = 1
+				return k8sClient.Status().Update(context.Background(), &sts)
+			}, timeout, interval).Should(Succeed())
+			Eventually(func() string {
+				return testAdminAPIs.get(key.Name).password("bootstrap")
+			}, timeout, interval).Should(Equal(password))
+
+			By("Keeping the password on later reconciliations")
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), key, redpandaCluster); err != nil {
+					return err
+				}
+				redpandaCluster.Annotations = map[string]string{"resync": time.Now().String()}
+				return k8sClient.Update(context.Background(), redpandaCluster)
+			}, timeout, interval).Should(Succeed())
+			Consistently(func() string {
+				if err := k8sClient.Get(context.Background(), secretKey, &secret); err != nil {
+					return err.Error()
+				}
+				return string(secret.Data["password"])
+			}, 3*time.Second, interval).Should(Equal(password))
+		})
+	})
+
+	Context("When the superuser password is supplied", func() {
+		It("Should use the referenced Secret", func() {
+			key := testKey("redpanda-sasl-supplied")
+			Expect(k8sClient.Create(context.Background(), &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:		"supplied-superuser",
+					Namespace:	key.Namespace,
+				},
+				Data:	map[string][]byte{"password": []byte("supplied-password")},
+			})).Should(Succeed())
+
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.SASL = v1alpha1.SASLConfig{
+				Enabled:		true,
+				SuperuserName:		"supplied",
+				SuperuserSecretRef:	&corev1.LocalObjectReference{Name: "supplied-superuser"},
+			}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return err
+				}
+				sts.Status.Replicas = 1
+				sts.Status.ReadyReplicas = 1
+				return k8sClient.Status().Update(context.Background(), &sts)
+			}, timeout, interval).Should(Succeed())
+			Eventually(func() string {
+				return testAdminAPIs.get(key.Name).password("supplied")
+			}, timeout, interval).Should(Equal("supplied-password"))
+
+			var secret corev1.Secret
+			err := k8sClient.Get(context.Background(), types.NamespacedName{Name: key.Name + "-superuser", Namespace: key.Namespace}, &secret)
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("Should set the changed password on the existing user", func() {
+			key := testKey("redpanda-sasl-changed")
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:		key.Name + "-credentials",
+					Namespace:	key.Namespace,
+				},
+				Data:	map[string][]byte{"password": []byte("first")},
+			}
+			Expect(k8sClient.Create(context.Background(), secret)).Should(Succeed())
+
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.SASL = v1alpha1.SASLConfig{
+				Enabled:		true,
+				SuperuserSecretRef:	&corev1.LocalObjectReference{Name: secret.Name},
+			}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+			setReadyReplicas(key, 1)
+			Eventually(func() string {
+				return testAdminAPIs.get(key.Name).password("admin")
+			}, timeout, interval).Should(Equal("first"))
+
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), types.NamespacedName{Name: secret.Name, Namespace: key.Namespace}, secret); err != nil {
+					return err
+				}
+				secret.Data["password"] = []byte("second")
+				return k8sClient.Update(context.Background(), secret)
+			}, timeout, interval).Should(Succeed())
+			Eventually(func() string {
+				return testAdminAPIs.get(key.Name).password("admin")
+			}, timeout, interval).Should(Equal("second"))
+		})
+	})
+
+	Context("When the SASL mechanisms change", func() {
+		It("Should keep the mechanism of the superuser", func() {
+			key := testKey("redpanda-sasl-mechanism")
+			redpandaCluster := testCluster(key.Name)
+			redpandaCluster.Spec.SASL = v1alpha1.SASLConfig{
+				Enabled:	true,
+				Mechanisms:	[]v1alpha1.SASLMechanism{v1alpha1.SASLMechanismScramSha512},
+			}
+			Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+			var sts appsv1.StatefulSet
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+					return err
+				}
+				sts.Status.Replicas = 1
+				sts.Status.ReadyReplicas = 1
+				return k8sClient.Status().Update(context.Background(), &sts)
+			}, timeout, interval).Should(Succeed())
+			Eventually(func() string {
+				var c v1alpha1.Cluster
+				if err := k8sClient.Get(context.Background(), key, &c); err != nil {
+					return ""
+				}
+				return c.Status.SuperuserMechanism
+			}, timeout, interval).Should(Equal("SCRAM-SHA-512"))
+			Expect(testAdminAPIs.get(key.Name).mechanism("admin")).Should(Equal("SCRAM-SHA-512"))
+
+			By("Degrading the Cluster once the mechanism is no longer offered")
+			updateCluster(key, func(c *v1alpha1.Cluster) {
+				c.Spec.SASL.Mechanisms = []v1alpha1.SASLMechanism{v1alpha1.SASLMechanismScramSha256}
+			})
+			Eventually(func() string {
+				return clusterConditionReason(key, v1alpha1.ClusterDegraded)
+			}, timeout, interval).Should(Equal("SuperuserMechanismRemoved"))
+
+			updateCluster(key, func(c *v1alpha1.Cluster) {
+				c.Spec.SASL.Mechanisms = []v1alpha1.SASLMechanism{
+					v1alpha1.SASLMechanismScramSha256, v1alpha1.SASLMechanismScramSha512,
+				}
+			})
+			Eventually(func() string {
+				return clusterConditionReason(key, v1alpha1.ClusterDegraded)
+			}, timeout, interval).Should(Equal("SuperuserMechanismOffered"))
+		})
+	})
+})
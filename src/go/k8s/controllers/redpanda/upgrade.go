@@ -0,0 +1,307 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	"github.com/vectorizedio/redpanda/src/go/k8s/pkg/admin"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const redpandaContainerName = "redpanda"
+
+// Reasons of the Progressing and Degraded conditions set by the upgrade
+// guard
+const (
+	reasonUpgrading			= "Upgrading"
+	reasonVerifyingUpgrade		= "VerifyingUpgrade"
+	reasonUpgradeComplete		= "UpgradeComplete"
+	reasonUpgradeAllowed		= "UpgradeAllowed"
+	reasonUpgradeInProgress		= "UpgradeInProgress"
+	reasonUnsupportedVersionSkew	= "UnsupportedVersionSkew"
+)
+
+// upgradeReasons are the Degraded reasons resolved by the upgrade guard
+var upgradeReasons = []string{reasonUpgradeAllowed, reasonUpgradeInProgress, reasonUnsupportedVersionSkew}
+
+// upgradeImage returns the redpanda image the StatefulSet should run. The
+// desired version is only rolled out once every broker runs the current
+// one, when it does not skip a minor version and while the controller has
+// a quorum, otherwise the current image is kept. The outcome is reported in
+// the status conditions, an upgrade rolled out to every broker stays in
+// progress until verifyUpgrade completes it.
+func (r *ClusterReconciler) upgradeImage(
+	cluster *redpandav1alpha1.Cluster,
+	sts *appsv1.StatefulSet,
+	pods []corev1.Pod,
+	status *redpandav1alpha1.ClusterStatus,
+) string {
+	desired := redpandaImage(cluster)
+	current := containerImage(sts.Spec.Template.Spec.Containers)
+
+	inProgress := false
+
+	for i := range pods {
+		if containerImage(pods[i].Spec.Containers) != current {
+			inProgress = true
+
+			break
+		}
+	}
+
+	switch {
+	case current == "" || current == desired:
+		if inProgress || !upgradeProgressing(status) {
+			setProgressing(status, inProgress)
+		}
+
+		clearDegraded(status, reasonUpgradeAllowed, upgradeReasons...)
+
+		return desired
+	case r.quorumLost(status):
+		// Restarting the brokers could prevent the controller from
+		// electing a leader again
+		setProgressing(status, false)
+
+		return current
+	case inProgress:
+		setProgressing(status, true)
+		setDegraded(status, reasonUpgradeInProgress,
+			fmt.Sprintf("Upgrade to %s is blocked until every broker runs %s", desired, current))
+
+		return current
+	case skipsMinorVersion(imageTag(current), cluster.Spec.Version):
+		setProgressing(status, false)
+		setDegraded(status, reasonUnsupportedVersionSkew,
+			fmt.Sprintf("Upgrading from %s to %s skips a minor version", imageTag(current), cluster.Spec.Version))
+
+		return current
+	default:
+		setProgressing(status, true)
+		clearDegraded(status, reasonUpgradeAllowed, upgradeReasons...)
+
+		return desired
+	}
+}
+
+// upgradeProgressing reports whether the brokers are upgraded
+func upgradeProgressing(status *redpandav1alpha1.ClusterStatus) bool {
+	return meta.IsStatusConditionTrue(status.Conditions, redpandav1alpha1.ClusterProgressing)
+}
+
+// verifyingUpgrade reports whether the upgrade waits for the brokers to
+// report the new version
+func verifyingUpgrade(status *redpandav1alpha1.ClusterStatus) bool {
+	progressing := meta.FindStatusCondition(status.Conditions, redpandav1alpha1.ClusterProgressing)
+
+	return progressing != nil && progressing.Status == metav1.ConditionTrue && progressing.Reason == reasonVerifyingUpgrade
+}
+
+// verifyUpgrade completes the upgrade rolled out to every broker once the
+// brokers are healthy: every broker is ready, the controller has a quorum,
+// and the Admin API reports every broker as alive and running the desired
+// version. The brokers which do not report their version, and the versions
+// which are not of the [v]major.minor[.patch] form, are not compared.
+func (r *ClusterReconciler) verifyUpgrade(
+	ctx context.Context,
+	cluster *redpandav1alpha1.Cluster,
+	sts *appsv1.StatefulSet,
+	pods []corev1.Pod,
+	adminAPIConfig *AdminAPIConfig,
+	status *redpandav1alpha1.ClusterStatus,
+) error {
+	desired := containerImage(sts.Spec.Template.Spec.Containers)
+	if !upgradeProgressing(status) || desired != redpandaImage(cluster) {
+		return nil
+	}
+
+	for i := range pods {
+		if containerImage(pods[i].Spec.Containers) != desired {
+			return nil
+		}
+	}
+
+	// The StatefulSet controller reports the readiness of the latest pod
+	// template once it observed it
+	if sts.Status.ObservedGeneration < sts.Generation || sts.Spec.Replicas == nil ||
+		sts.Status.ReadyReplicas < *sts.Spec.Replicas {
+		setVerifyingUpgrade(status, "Waiting for every broker to be ready")
+
+		return nil
+	}
+
+	if r.quorumLost(status) {
+		setVerifyingUpgrade(status, "Waiting for the controller to elect a leader")
+
+		return nil
+	}
+
+	adminAPI, err := r.adminAPIClient(cluster, adminAPIConfig)
+	if err != nil {
+		return err
+	}
+
+	brokers, err := adminAPI.Brokers(ctx)
+	if err != nil {
+		setVerifyingUpgrade(status, "Waiting for the Admin API to report the brokers")
+
+		return err
+	}
+
+	if message := brokersUpgraded(brokers, cluster.Spec.Version); message != "" {
+		setVerifyingUpgrade(status, message)
+
+		return nil
+	}
+
+	setProgressing(status, false)
+
+	return nil
+}
+
+// brokersUpgraded explains why the brokers are not upgraded to the version,
+// it returns an empty string once they are
+func brokersUpgraded(brokers []admin.Broker, version string) string {
+	var dead, outdated []string
+
+	for _, b := range brokers {
+		if b.IsAlive != nil && !*b.IsAlive {
+			dead = append(dead, strconv.Itoa(b.NodeID))
+		}
+
+		if !brokerRunsVersion(b.Version, version) {
+			outdated = append(outdated, fmt.Sprintf("%d (%s)", b.NodeID, strings.Fields(b.Version)[0]))
+		}
+	}
+
+	switch {
+	case len(dead) > 0:
+		return fmt.Sprintf("Waiting for brokers %s to be alive", strings.Join(dead, ", "))
+	case len(outdated) > 0:
+		return fmt.Sprintf("Waiting for brokers %s to report version %s", strings.Join(outdated, ", "), version)
+	default:
+		return ""
+	}
+}
+
+// brokerRunsVersion reports whether the version reported by a broker is the
+// given one, or can not be compared with it
+func brokerRunsVersion(reported, version string) bool {
+	fields := strings.Fields(reported)
+	if _, _, ok := majorMinor(version); !ok || len(fields) == 0 {
+		return true
+	}
+
+	return strings.TrimPrefix(fields[0], "v") == strings.TrimPrefix(version, "v")
+}
+
+func setVerifyingUpgrade(status *redpandav1alpha1.ClusterStatus, message string) {
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:		redpandav1alpha1.ClusterProgressing,
+		Status:		metav1.ConditionTrue,
+		Reason:		reasonVerifyingUpgrade,
+		Message:	message,
+	})
+}
+
+// redpandaImage returns the image reference of the desired version, or of
+// the pinned digest
+func redpandaImage(cluster *redpandav1alpha1.Cluster) string {
+	if cluster.Spec.ImageDigest != "" {
+		return cluster.Spec.Image + "@" + cluster.Spec.ImageDigest
+	}
+
+	return cluster.Spec.Image + ":" + cluster.Spec.Version
+}
+
+func setProgressing(status *redpandav1alpha1.ClusterStatus, progressing bool) {
+	condition := metav1.Condition{
+		Type:		redpandav1alpha1.ClusterProgressing,
+		Status:		metav1.ConditionFalse,
+		Reason:		reasonUpgradeComplete,
+		Message:	"Every broker runs the desired version",
+	}
+	if progressing {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = reasonUpgrading
+		condition.Message = "The brokers are rolled out to a new version"
+	}
+
+	meta.SetStatusCondition(&status.Conditions, condition)
+}
+
+// containerImage returns the image of the redpanda container
+func containerImage(containers []corev1.Container) string {
+	for i := range containers {
+		if containers[i].Name == redpandaContainerName {
+			return containers[i].Image
+		}
+	}
+
+	return ""
+}
+
+// imageTag returns the tag of an image reference
+func imageTag(image string) string {
+	if i := strings.LastIndex(image, ":"); i >= 0 && !strings.Contains(image[i:], "/") {
+		return image[i+1:]
+	}
+
+	return ""
+}
+
+// skipsMinorVersion reports whether upgrading between the given versions
+// skips at least one minor version. A major version change is always
+// considered a skip. Versions which are not of the [v]major.minor[.patch]
+// form can not be compared and are never considered a skip.
+func skipsMinorVersion(from, to string) bool {
+	fromMajor, fromMinor, ok := majorMinor(from)
+	if !ok {
+		return false
+	}
+
+	toMajor, toMinor, ok := majorMinor(to)
+	if !ok {
+		return false
+	}
+
+	if toMajor != fromMajor {
+		return toMajor > fromMajor
+	}
+
+	return toMinor > fromMinor+1
+}
+
+func majorMinor(version string) (major, minor int, ok bool) {
+	parts := strings.SplitN(strings.TrimPrefix(version, "v"), ".", 3)
+	if len(parts) < 2 {
+		return 0, 0, false
+	}
+
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, false
+	}
+
+	minor, err = strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return major, minor, true
+}
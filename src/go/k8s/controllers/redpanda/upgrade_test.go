@@ -0,0 +1,173 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Redpanda upgrade guard", func() {
+	Context("When upgrading to the next minor version", func() {
+		It("Should roll out the new image", func() {
+			key := createVersionedCluster("redpanda-upgrade-allowed", "v21.4.1")
+
+			setClusterVersion(key, "v21.5.0")
+			Eventually(func() string {
+				return statefulSetImage(key)
+			}, timeout, interval).Should(Equal(redpandaContainerImage + ":v21.5.0"))
+			Expect(clusterCondition(key, v1alpha1.ClusterDegraded)).Should(Equal(metav1.ConditionFalse))
+		})
+	})
+
+	Context("When the upgraded brokers report their version", func() {
+		It("Should only complete the upgrade once every broker runs it", func() {
+			key := createVersionedCluster("redpanda-upgrade-verified", "v21.4.1")
+			adminAPI := testAdminAPIs.get(key.Name)
+			adminAPI.setVersion(0, "v21.5.0 - 3b9f5f5b")
+			adminAPI.setVersion(1, "v21.4.1 - 7d1a2c3e")
+
+			setClusterVersion(key, "v21.5.0")
+			Eventually(func() string {
+				return statefulSetImage(key)
+			}, timeout, interval).Should(Equal(redpandaContainerImage + ":v21.5.0"))
+
+			By("Waiting for the brokers reporting mixed versions")
+			observeStatefulSet(key, "rev-1")
+			Eventually(func() string {
+				return clusterConditionReason(key, v1alpha1.ClusterProgressing)
+			}, timeout, interval).Should(Equal("VerifyingUpgrade"))
+			Consistently(func() metav1.ConditionStatus {
+				return clusterCondition(key, v1alpha1.ClusterProgressing)
+			}, "1s", interval).Should(Equal(metav1.ConditionTrue))
+
+			By("Completing the upgrade once the versions are uniform")
+			adminAPI.setVersion(1, "v21.5.0 - 3b9f5f5b")
+			Eventually(func() string {
+				return clusterConditionReason(key, v1alpha1.ClusterProgressing)
+			}, timeout, interval).Should(Equal("UpgradeComplete"))
+			Expect(clusterCondition(key, v1alpha1.ClusterProgressing)).Should(Equal(metav1.ConditionFalse))
+		})
+	})
+
+	Context("When the upgrade skips a minor version", func() {
+		It("Should keep the current image and report the skew", func() {
+			key := createVersionedCluster("redpanda-upgrade-skew", "v21.4.1")
+
+			setClusterVersion(key, "v21.6.0")
+			Eventually(func() string {
+				return clusterConditionReason(key, v1alpha1.ClusterDegraded)
+			}, timeout, interval).Should(Equal("UnsupportedVersionSkew"))
+			Expect(clusterCondition(key, v1alpha1.ClusterDegraded)).Should(Equal(metav1.ConditionTrue))
+			Consistently(func() string {
+				return statefulSetImage(key)
+			}, 3*time.Second, interval).Should(Equal(redpandaContainerImage + ":v21.4.1"))
+		})
+	})
+
+	Context("When brokers still run different versions", func() {
+		It("Should block a new upgrade", func() {
+			key := createVersionedCluster("redpanda-upgrade-in-progress", "v21.4.1")
+
+			By("Running a broker on the previous version")
+			Expect(k8sClient.Create(context.Background(), &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:		key.Name + "-0",
+					Namespace:	key.Namespace,
+					Labels:		map[string]string{"app": key.Name},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:	"redpanda",
+						Image:	redpandaContainerImage + ":v21.3.2",
+					}},
+				},
+			})).Should(Succeed())
+			// The reconciler lists the pods from the cache of the client
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), testKey(key.Name+"-0"), &corev1.Pod{})
+			}, timeout, interval).Should(Succeed())
+
+			setClusterVersion(key, "v21.5.0")
+			Eventually(func() string {
+				return clusterConditionReason(key, v1alpha1.ClusterDegraded)
+			}, timeout, interval).Should(Equal("UpgradeInProgress"))
+			Expect(clusterCondition(key, v1alpha1.ClusterProgressing)).Should(Equal(metav1.ConditionTrue))
+			Consistently(func() string {
+				return statefulSetImage(key)
+			}, 3*time.Second, interval).Should(Equal(redpandaContainerImage + ":v21.4.1"))
+		})
+	})
+})
+
+func createVersionedCluster(name, version string) types.NamespacedName {
+	key := testKey(name)
+	redpandaCluster := testCluster(key.Name)
+	redpandaCluster.Spec.Version = version
+	Expect(k8sClient.Create(context.Background(), redpandaCluster)).Should(Succeed())
+
+	Eventually(func() string {
+		return statefulSetImage(key)
+	}, timeout, interval).Should(Equal(redpandaContainerImage + ":" + version))
+
+	return key
+}
+
+func setClusterVersion(key types.NamespacedName, version string) {
+	Eventually(func() error {
+		var redpandaCluster v1alpha1.Cluster
+		if err := k8sClient.Get(context.Background(), key, &redpandaCluster); err != nil {
+			return err
+		}
+		redpandaCluster.Spec.Version = version
+		return k8sClient.Update(context.Background(), &redpandaCluster)
+	}, timeout, interval).Should(Succeed())
+}
+
+func statefulSetImage(key types.NamespacedName) string {
+	var sts appsv1.StatefulSet
+	if err := k8sClient.Get(context.Background(), key, &sts); err != nil {
+		return ""
+	}
+	return sts.Spec.Template.Spec.Containers[0].Image
+}
+
+func clusterCondition(
+	key types.NamespacedName, conditionType string,
+) metav1.ConditionStatus {
+	var redpandaCluster v1alpha1.Cluster
+	if err := k8sClient.Get(context.Background(), key, &redpandaCluster); err != nil {
+		return metav1.ConditionUnknown
+	}
+	if c := meta.FindStatusCondition(redpandaCluster.Status.Conditions, conditionType); c != nil {
+		return c.Status
+	}
+	return metav1.ConditionUnknown
+}
+
+func clusterConditionReason(key types.NamespacedName, conditionType string) string {
+	var redpandaCluster v1alpha1.Cluster
+	if err := k8sClient.Get(context.Background(), key, &redpandaCluster); err != nil {
+		return ""
+	}
+	if c := meta.FindStatusCondition(redpandaCluster.Status.Conditions, conditionType); c != nil {
+		return c.Reason
+	}
+	return ""
+}
@@ -0,0 +1,73 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	"context"
+
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// referencingClusters returns a MapFunc enqueueing the Clusters that
+// reference the changed object, refs lists the names of the objects of
+// that kind referenced by a Cluster. Only Clusters of the object
+// namespace are considered as references are always local, the Clusters
+// of every namespace reference the cluster scoped objects.
+func (r *ClusterReconciler) referencingClusters(
+	refs func(*redpandav1alpha1.Cluster) []string,
+) handler.MapFunc {
+	return func(obj client.Object) []reconcile.Request {
+		var clusters redpandav1alpha1.ClusterList
+		if err := r.List(context.Background(), &clusters, client.InNamespace(obj.GetNamespace())); err != nil {
+			r.Log.Error(err, "Unable to list Clusters referencing object",
+				"Namespace", obj.GetNamespace(), "Name", obj.GetName())
+
+			return nil
+		}
+
+		var requests []reconcile.Request
+
+		for i := range clusters.Items {
+			if !r.manages(&clusters.Items[i]) {
+				continue
+			}
+
+			for _, name := range refs(&clusters.Items[i]) {
+				if name == obj.GetName() {
+					requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+						Name:		clusters.Items[i].Name,
+						Namespace:	clusters.Items[i].Namespace,
+					}})
+
+					break
+				}
+			}
+		}
+
+		return requests
+	}
+}
+
+// manages returns true when the Cluster is selected by the ClusterSelector
+// of the operator
+func (r *ClusterReconciler) manages(obj client.Object) bool {
+	return selected(r.ClusterSelector, obj)
+}
+
+// selected returns true when the selector is nil or matches the labels of
+// the Cluster
+func selected(selector labels.Selector, obj client.Object) bool {
+	return selector == nil || selector.Matches(labels.Set(obj.GetLabels()))
+}
@@ -0,0 +1,53 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package redpanda
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+func TestClusterSelector(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := func(team string) *redpandav1alpha1.Cluster {
+		return &redpandav1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{
+			Name:	"cluster",
+			Labels:	map[string]string{"team": team},
+		}}
+	}
+	payments, search := cluster("payments"), cluster("search")
+
+	r := &ClusterReconciler{}
+	filter := predicate.NewPredicateFuncs(r.manages)
+	g.Expect(filter.Create(event.CreateEvent{Object: payments})).To(BeTrue())
+	g.Expect(filter.Create(event.CreateEvent{Object: search})).To(BeTrue())
+
+	selector, err := labels.Parse("team=payments")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	r.ClusterSelector = selector
+	filter = predicate.NewPredicateFuncs(r.manages)
+	g.Expect(filter.Create(event.CreateEvent{Object: payments})).To(BeTrue())
+	g.Expect(filter.Create(event.CreateEvent{Object: search})).To(BeFalse())
+	g.Expect(filter.Update(event.UpdateEvent{ObjectOld: payments, ObjectNew: search})).To(BeFalse())
+	g.Expect(filter.Delete(event.DeleteEvent{Object: search})).To(BeFalse())
+	g.Expect(filter.Generic(event.GenericEvent{Object: payments})).To(BeTrue())
+
+	// The Consoles of the other Clusters are not reconciled either
+	consoles := &ConsoleReconciler{ClusterSelector: selector}
+	g.Expect(consoles.referencingConsoles(search)).To(BeEmpty())
+}
@@ -16,6 +16,7 @@ require (
 	k8s.io/client-go v0.19.2
 	k8s.io/utils v0.0.0-20200912215256-4140de9c8800
 	sigs.k8s.io/controller-runtime v0.7.0
+	sigs.k8s.io/yaml v1.2.0
 )
 
 replace github.com/vectorizedio/redpanda/src/go/rpk => ../rpk
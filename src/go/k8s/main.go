@@ -11,9 +11,11 @@ package main
 import (
 	"flag"
 	"os"
+	"time"
 
 	redpandav1alpha1 "github.com/vectorizedio/redpanda/src/go/k8s/apis/redpanda/v1alpha1"
 	redpandacontrollers "github.com/vectorizedio/redpanda/src/go/k8s/controllers/redpanda"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
@@ -44,6 +46,15 @@ func main() {
 		enableLeaderElection	bool
 		probeAddr		string
 		webhookEnabled		bool
+		resyncPeriod		time.Duration
+		maxConcurrentReconciles	int
+		maxBackoff		time.Duration
+		backoffJitter		float64
+		quorumLossGracePeriod	time.Duration
+		informerResyncPeriod	time.Duration
+		watchNamespace		string
+		clusterSelector		string
+		leaderElectionID	string
 	)
 
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
@@ -51,7 +62,27 @@ func main() {
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&leaderElectionID, "leader-election-id", "aa9fc693.vectorized.io",
+		"The name of the leader election lock. The operators reconciling different Clusters need different locks.")
 	flag.BoolVar(&webhookEnabled, "webhook-enabled", false, "Enable webhook Manager")
+	flag.DurationVar(&resyncPeriod, "resync-period", time.Minute,
+		"The interval after which every Cluster is reconciled again to refresh its status. "+
+			"Zero disables the periodic resync.")
+	flag.IntVar(&maxConcurrentReconciles, "max-concurrent-reconciles", 1,
+		"The number of Clusters reconciled in parallel. A single Cluster is never reconciled concurrently.")
+	flag.DurationVar(&maxBackoff, "max-reconcile-backoff", 5*time.Minute,
+		"The maximum delay before retrying a failed reconciliation, the delay doubles on every failure.")
+	flag.Float64Var(&backoffJitter, "reconcile-backoff-jitter", 0.2,
+		"The maximum share, between 0 and 1, by which each retry delay is randomly shortened.")
+	flag.DurationVar(&quorumLossGracePeriod, "quorum-loss-grace-period", time.Minute,
+		"How long the brokers may be without a controller leader before the Cluster is reported as degraded.")
+	flag.DurationVar(&informerResyncPeriod, "informer-resync-period", 10*time.Hour,
+		"The interval after which the informers replay every cached object, which reconciles every Cluster. "+
+			"Shorter periods recover sooner from missed events at the cost of CPU and memory churn.")
+	flag.StringVar(&watchNamespace, "watch-namespace", "",
+		"The only namespace whose Clusters are reconciled, and whose objects are cached. Empty watches every namespace.")
+	flag.StringVar(&clusterSelector, "cluster-selector", "",
+		"The label selector of the reconciled Clusters, e.g. team=payments. Empty reconciles every Cluster.")
 
 	opts := zap.Options{
 		Development: true,
@@ -63,28 +94,56 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:			scheme,
-		MetricsBindAddress:	metricsAddr,
-		Port:			9443,
-		HealthProbeBindAddress:	probeAddr,
-		LeaderElection:		enableLeaderElection,
-		LeaderElectionID:	"aa9fc693.vectorized.io",
-	})
+	if backoffJitter < 0 || backoffJitter > 1 {
+		setupLog.Error(nil, "The reconcile backoff jitter must be between 0 and 1", "jitter", backoffJitter)
+		os.Exit(1)
+	}
+
+	if informerResyncPeriod <= 0 {
+		setupLog.Error(nil, "The informer resync period must be positive", "period", informerResyncPeriod)
+		os.Exit(1)
+	}
+
+	selector, err := labels.Parse(clusterSelector)
+	if err != nil {
+		setupLog.Error(err, "Invalid Cluster label selector", "selector", clusterSelector)
+		os.Exit(1)
+	}
+
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(),
+		managerOptions(metricsAddr, probeAddr, leaderElectionID, enableLeaderElection, informerResyncPeriod, watchNamespace))
 	if err != nil {
 		setupLog.Error(err, "Unable to start manager")
 		os.Exit(1)
 	}
 
 	if err = (&redpandacontrollers.ClusterReconciler{
-		Client:	mgr.GetClient(),
-		Log:	ctrl.Log.WithName("controllers").WithName("redpanda").WithName("Cluster"),
-		Scheme:	mgr.GetScheme(),
+		Client:				mgr.GetClient(),
+		Log:				ctrl.Log.WithName("controllers").WithName("redpanda").WithName("Cluster"),
+		Scheme:				mgr.GetScheme(),
+		ResyncPeriod:			resyncPeriod,
+		AdminAPIClientFactory:		redpandacontrollers.NewAdminAPIClient,
+		MaxConcurrentReconciles:	maxConcurrentReconciles,
+		MaxBackoff:			maxBackoff,
+		BackoffJitter:			backoffJitter,
+		QuorumLossGracePeriod:		quorumLossGracePeriod,
+		Recorder:			mgr.GetEventRecorderFor("redpanda-controller"),
+		ClusterSelector:		selector,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "Unable to create controller", "controller", "Cluster")
 		os.Exit(1)
 	}
 
+	if err = (&redpandacontrollers.ConsoleReconciler{
+		Client:			mgr.GetClient(),
+		Log:			ctrl.Log.WithName("controllers").WithName("redpanda").WithName("Console"),
+		Scheme:			mgr.GetScheme(),
+		ClusterSelector:	selector,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "Unable to create controller", "controller", "Console")
+		os.Exit(1)
+	}
+
 	if webhookEnabled {
 		setupLog.Info("Setup webhook")
 
@@ -112,3 +171,24 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// managerOptions returns the options of the manager, whose informers
+// replay their cache every syncPeriod. An empty namespace caches the
+// objects of every namespace.
+func managerOptions(
+	metricsAddr, probeAddr, leaderElectionID string,
+	leaderElection bool,
+	syncPeriod time.Duration,
+	namespace string,
+) ctrl.Options {
+	return ctrl.Options{
+		Scheme:			scheme,
+		MetricsBindAddress:	metricsAddr,
+		Port:			9443,
+		HealthProbeBindAddress:	probeAddr,
+		LeaderElection:		leaderElection,
+		LeaderElectionID:	leaderElectionID,
+		SyncPeriod:		&syncPeriod,
+		Namespace:		namespace,
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestManagerOptions(t *testing.T) {
+	g := NewWithT(t)
+
+	opts := managerOptions(":8080", ":8081", "team-a.vectorized.io", true, 30*time.Minute, "team-a")
+	g.Expect(opts.SyncPeriod).NotTo(BeNil())
+	g.Expect(*opts.SyncPeriod).To(Equal(30 * time.Minute))
+	g.Expect(opts.MetricsBindAddress).To(Equal(":8080"))
+	g.Expect(opts.HealthProbeBindAddress).To(Equal(":8081"))
+	g.Expect(opts.LeaderElection).To(BeTrue())
+	g.Expect(opts.LeaderElectionID).To(Equal("team-a.vectorized.io"))
+	g.Expect(opts.Namespace).To(Equal("team-a"))
+	g.Expect(opts.Scheme).To(BeIdenticalTo(scheme))
+}
@@ -0,0 +1,512 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+// Package admin contains the client used by the operator to talk to the
+// redpanda Admin API
+package admin
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout	= 10 * time.Second
+
+	readyPath		= "/v1/status/ready"
+	usersPath		= "/v1/security/users"
+	brokersPath		= "/v1/brokers"
+	clusterPartitionsPath	= "/v1/cluster/partitions"
+	clusterConfigPath	= "/v1/cluster_config"
+	configSchemaPath	= "/v1/cluster_config/schema"
+	licensePath		= "/v1/features/license"
+	cloudStoragePath	= "/v1/cloud_storage/status"
+	kafkaNamespace		= "kafka"
+	controllerPath		= "/v1/partitions/redpanda/controller/0"
+
+	// NoLeader is the leader id of a partition without an elected leader
+	NoLeader	= -1
+
+	// ScramSha256 is the default SASL mechanism of the users created by
+	// the operator
+	ScramSha256	= "SCRAM-SHA-256"
+)
+
+var (
+	// ErrNoBrokers is returned when the client is created without any
+	// Admin API address
+	ErrNoBrokers	= errors.New("at least one Admin API address is required")
+	// ErrInvalidURL is returned when the client is created with a base URL
+	// which is not an http or https URL
+	ErrInvalidURL	= errors.New("invalid Admin API URL")
+	// ErrUnexpectedStatus is returned when the Admin API responds with a
+	// non 2xx status code
+	ErrUnexpectedStatus	= errors.New("unexpected Admin API response status")
+	// ErrUnauthorized is returned when the Admin API rejects the
+	// credentials of the client
+	ErrUnauthorized	= errors.New("Admin API credentials rejected")
+
+	errConflict	= errors.New("resource already exists")
+)
+
+// AdminAPIClient is a client for the redpanda Admin API
+type AdminAPIClient interface {
+	// Ready returns nil when at least one broker reports it is ready
+	Ready(ctx context.Context) error
+	// CreateUser creates a SCRAM user with the given mechanism. A user
+	// that already exists is updated with the given password, so the call
+	// can be safely retried.
+	CreateUser(ctx context.Context, username, password, mechanism string) error
+	// Brokers returns the resource usage reported by every broker
+	Brokers(ctx context.Context) ([]Broker, error)
+	// ClusterPartitions returns the partitions of the cluster along with
+	// the brokers hosting their replicas
+	ClusterPartitions(ctx context.Context) ([]Partition, error)
+	// DecommissionBroker starts moving the partitions away from a broker
+	// so it can be removed from the cluster
+	DecommissionBroker(ctx context.Context, nodeID int) error
+	// ClusterConfig returns the cluster properties set at runtime
+	ClusterConfig(ctx context.Context) (map[string]interface{}, error)
+	// PatchClusterConfig sets the upsert cluster properties and resets the
+	// remove ones to their default, the others are left untouched
+	PatchClusterConfig(ctx context.Context, upsert map[string]interface{}, remove []string) error
+	// ClusterConfigSchema returns the cluster properties known to the
+	// brokers
+	ClusterConfigSchema(ctx context.Context) (map[string]ConfigProperty, error)
+	// License returns the enterprise license loaded by the cluster
+	License(ctx context.Context) (License, error)
+	// SetLicense loads an enterprise license in the cluster
+	SetLicense(ctx context.Context, license []byte) error
+	// CloudStorageStatus returns the progress of the tiered storage
+	// uploads of a partition of a Kafka topic
+	CloudStorageStatus(ctx context.Context, topic string, partition int) (CloudStorageStatus, error)
+	// ControllerLeader returns the node id of the controller leader, or
+	// NoLeader when the brokers lost the quorum of the controller
+	ControllerLeader(ctx context.Context) (int, error)
+	// EnableMaintenanceMode makes a broker transfer the leadership of its
+	// partitions to the other brokers, so that it can be restarted
+	EnableMaintenanceMode(ctx context.Context, nodeID int) error
+	// DisableMaintenanceMode makes a broker lead partitions again
+	DisableMaintenanceMode(ctx context.Context, nodeID int) error
+}
+
+// CloudStorageStatus is the progress of the tiered storage uploads of a
+// partition. The offsets and the time since the last upload are omitted
+// until the partition uploaded a segment.
+type CloudStorageStatus struct {
+	// Mode is the tiered storage mode of the topic, e.g. disabled or full
+	Mode	string	`json:"cloud_storage_mode"`
+	// MsSinceLastSegmentUpload is the time since the partition last
+	// uploaded a segment, in milliseconds
+	MsSinceLastSegmentUpload	*int64	`json:"ms_since_last_segment_upload,omitempty"`
+	// LocalLogLastOffset is the last offset written on the broker
+	LocalLogLastOffset	*int64	`json:"local_log_last_offset,omitempty"`
+	// CloudLogLastOffset is the last offset uploaded to the object store
+	CloudLogLastOffset	*int64	`json:"cloud_log_last_offset,omitempty"`
+}
+
+// KafkaTopic returns whether the partition belongs to a Kafka topic, the
+// internal partitions are not uploaded to the object store
+func (p *Partition) KafkaTopic() bool {
+	return p.Namespace == kafkaNamespace
+}
+
+// License is the enterprise license state of the cluster
+type License struct {
+	// Loaded is false on the community edition, without any license
+	Loaded		bool			`json:"loaded"`
+	Properties	LicenseProperties	`json:"license"`
+}
+
+// LicenseProperties describes a loaded license
+type LicenseProperties struct {
+	Organization	string	`json:"org"`
+	Type		string	`json:"type"`
+	// Expires is the expiry of the license, in seconds since the epoch
+	Expires	int64	`json:"expires"`
+	// Checksum is the hex encoded SHA-256 of the license
+	Checksum	string	`json:"sha256"`
+}
+
+// ConfigProperty describes a cluster property
+type ConfigProperty struct {
+	// NeedsRestart is true when the brokers only apply the property once
+	// restarted
+	NeedsRestart bool `json:"needs_restart"`
+}
+
+type configSchema struct {
+	Properties map[string]ConfigProperty `json:"properties"`
+}
+
+type controllerPartition struct {
+	LeaderID int `json:"leader_id"`
+}
+
+type clusterConfigPatch struct {
+	Upsert	map[string]interface{}	`json:"upsert"`
+	Remove	[]string		`json:"remove"`
+}
+
+// MembershipRemoved is the membership status of a broker which completed
+// its decommissioning
+const MembershipRemoved = "removed"
+
+// Broker is the resource usage reported by a broker
+type Broker struct {
+	NodeID			int		`json:"node_id"`
+	MembershipStatus	string		`json:"membership_status"`
+	DiskSpace		[]DiskSpace	`json:"disk_space"`
+	// IsAlive is whether the queried broker hears from the broker, it is
+	// only reported by the recent versions
+	IsAlive	*bool	`json:"is_alive,omitempty"`
+	// Version is the redpanda version of the broker, e.g. v21.11.2 - <sha>.
+	// It is only reported by the recent versions.
+	Version	string	`json:"version,omitempty"`
+	// Maintenance is only reported by the brokers supporting the
+	// maintenance mode
+	Maintenance	*MaintenanceStatus	`json:"maintenance_status,omitempty"`
+}
+
+// Partition is a partition of the cluster and its replicas
+type Partition struct {
+	Namespace	string		`json:"ns"`
+	Topic		string		`json:"topic"`
+	PartitionID	int		`json:"partition_id"`
+	Replicas	[]Replica	`json:"replicas"`
+}
+
+// Replica is a broker hosting a replica of a partition
+type Replica struct {
+	NodeID int `json:"node_id"`
+}
+
+// MaintenanceStatus is the progress of a broker in maintenance mode
+type MaintenanceStatus struct {
+	// Draining is true while the broker is in maintenance mode
+	Draining	bool	`json:"draining"`
+	// Finished is true once the broker transferred the leadership of its
+	// partitions
+	Finished	bool	`json:"finished"`
+	// Errors is true when some leadership could not be transferred
+	Errors	bool	`json:"errors"`
+}
+
+// DiskSpace is the usage of one of the broker data disks, in bytes
+type DiskSpace struct {
+	Path	string	`json:"path"`
+	Free	int64	`json:"free"`
+	Total	int64	`json:"total"`
+}
+
+type newUser struct {
+	Username	string	`json:"username"`
+	Password	string	`json:"password"`
+	Algorithm	string	`json:"algorithm"`
+}
+
+var _ AdminAPIClient = &AdminAPI{}
+
+// AdminAPI is the http implementation of AdminAPIClient. Every call
+// tries the brokers in order and returns the first successful response.
+type AdminAPI struct {
+	urls	[]string
+	client	*http.Client
+
+	username	string
+	password	string
+
+	readyPath	string
+}
+
+// NewAdminAPI creates a client for the Admin API served on the given
+// host:port addresses. When tlsConfig is nil the client uses plain http,
+// otherwise it uses https and verifies the servers with tlsConfig.
+func NewAdminAPI(addresses []string, tlsConfig *tls.Config) (*AdminAPI, error) {
+	if len(addresses) == 0 {
+		return nil, ErrNoBrokers
+	}
+
+	scheme := "http"
+	if tlsConfig != nil {
+		scheme = "https"
+	}
+
+	urls := make([]string, 0, len(addresses))
+	for _, a := range addresses {
+		urls = append(urls, scheme+"://"+a)
+	}
+
+	return newAdminAPI(urls, tlsConfig), nil
+}
+
+// NewAdminAPIFromURLs creates a client for the Admin API served at the
+// given base URLs, e.g. a proxy in front of the brokers. Their scheme
+// selects http or https, the https servers are verified with tlsConfig, or
+// the system roots when it is nil. A path prefix is kept in front of every
+// Admin API path.
+func NewAdminAPIFromURLs(baseURLs []string, tlsConfig *tls.Config) (*AdminAPI, error) {
+	if len(baseURLs) == 0 {
+		return nil, ErrNoBrokers
+	}
+
+	urls := make([]string, 0, len(baseURLs))
+
+	for _, b := range baseURLs {
+		u, err := url.Parse(b)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %v", ErrInvalidURL, b, err)
+		}
+
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+			return nil, fmt.Errorf("%w %q", ErrInvalidURL, b)
+		}
+
+		urls = append(urls, strings.TrimSuffix(b, "/"))
+	}
+
+	return newAdminAPI(urls, tlsConfig), nil
+}
+
+func newAdminAPI(urls []string, tlsConfig *tls.Config) *AdminAPI {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return &AdminAPI{
+		urls:		urls,
+		readyPath:	readyPath,
+		client: &http.Client{
+			Timeout:	defaultTimeout,
+			Transport:	transport,
+		},
+	}
+}
+
+// SetBasicAuth authenticates the following requests with the given SCRAM
+// user, for the Admin API requiring authentication
+func (a *AdminAPI) SetBasicAuth(username, password string) {
+	a.username = username
+	a.password = password
+}
+
+// SetReadyPath replaces the path of the endpoint checked by Ready, for
+// the Admin API served behind a proxy. It defaults to /v1/status/ready.
+func (a *AdminAPI) SetReadyPath(path string) {
+	a.readyPath = path
+}
+
+// Ready implements AdminAPIClient
+func (a *AdminAPI) Ready(ctx context.Context) error {
+	return a.sendAny(ctx, http.MethodGet, a.readyPath, nil, nil)
+}
+
+// Brokers implements AdminAPIClient
+func (a *AdminAPI) Brokers(ctx context.Context) ([]Broker, error) {
+	var brokers []Broker
+	if err := a.sendAny(ctx, http.MethodGet, brokersPath, nil, &brokers); err != nil {
+		return nil, err
+	}
+
+	return brokers, nil
+}
+
+// ClusterPartitions implements AdminAPIClient
+func (a *AdminAPI) ClusterPartitions(ctx context.Context) ([]Partition, error) {
+	var partitions []Partition
+	if err := a.sendAny(ctx, http.MethodGet, clusterPartitionsPath, nil, &partitions); err != nil {
+		return nil, err
+	}
+
+	return partitions, nil
+}
+
+// ClusterConfig implements AdminAPIClient
+func (a *AdminAPI) ClusterConfig(
+	ctx context.Context,
+) (map[string]interface{}, error) {
+	var cfg map[string]interface{}
+	if err := a.sendAny(ctx, http.MethodGet, clusterConfigPath, nil, &cfg); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// PatchClusterConfig implements AdminAPIClient
+func (a *AdminAPI) PatchClusterConfig(
+	ctx context.Context, upsert map[string]interface{}, remove []string,
+) error {
+	if remove == nil {
+		remove = []string{}
+	}
+
+	body, err := json.Marshal(clusterConfigPatch{Upsert: upsert, Remove: remove})
+	if err != nil {
+		return err
+	}
+
+	return a.sendAny(ctx, http.MethodPut, clusterConfigPath, body, nil)
+}
+
+// ClusterConfigSchema implements AdminAPIClient
+func (a *AdminAPI) ClusterConfigSchema(
+	ctx context.Context,
+) (map[string]ConfigProperty, error) {
+	var schema configSchema
+	if err := a.sendAny(ctx, http.MethodGet, configSchemaPath, nil, &schema); err != nil {
+		return nil, err
+	}
+
+	return schema.Properties, nil
+}
+
+// License implements AdminAPIClient
+func (a *AdminAPI) License(ctx context.Context) (License, error) {
+	var license License
+	err := a.sendAny(ctx, http.MethodGet, licensePath, nil, &license)
+
+	return license, err
+}
+
+// SetLicense implements AdminAPIClient
+func (a *AdminAPI) SetLicense(ctx context.Context, license []byte) error {
+	return a.sendAny(ctx, http.MethodPut, licensePath, license, nil)
+}
+
+// CloudStorageStatus implements AdminAPIClient
+func (a *AdminAPI) CloudStorageStatus(
+	ctx context.Context, topic string, partition int,
+) (CloudStorageStatus, error) {
+	var status CloudStorageStatus
+	path := fmt.Sprintf("%s/%s/%d", cloudStoragePath, url.PathEscape(topic), partition)
+	err := a.sendAny(ctx, http.MethodGet, path, nil, &status)
+
+	return status, err
+}
+
+// ControllerLeader implements AdminAPIClient
+func (a *AdminAPI) ControllerLeader(ctx context.Context) (int, error) {
+	partition := controllerPartition{LeaderID: NoLeader}
+	err := a.sendAny(ctx, http.MethodGet, controllerPath, nil, &partition)
+
+	return partition.LeaderID, err
+}
+
+// DecommissionBroker implements AdminAPIClient
+func (a *AdminAPI) DecommissionBroker(ctx context.Context, nodeID int) error {
+	path := fmt.Sprintf("%s/%d/decommission", brokersPath, nodeID)
+
+	return a.sendAny(ctx, http.MethodPut, path, nil, nil)
+}
+
+// EnableMaintenanceMode implements AdminAPIClient
+func (a *AdminAPI) EnableMaintenanceMode(ctx context.Context, nodeID int) error {
+	path := fmt.Sprintf("%s/%d/maintenance", brokersPath, nodeID)
+
+	return a.sendAny(ctx, http.MethodPut, path, nil, nil)
+}
+
+// DisableMaintenanceMode implements AdminAPIClient
+func (a *AdminAPI) DisableMaintenanceMode(ctx context.Context, nodeID int) error {
+	path := fmt.Sprintf("%s/%d/maintenance", brokersPath, nodeID)
+
+	return a.sendAny(ctx, http.MethodDelete, path, nil, nil)
+}
+
+// CreateUser implements AdminAPIClient
+func (a *AdminAPI) CreateUser(
+	ctx context.Context, username, password, mechanism string,
+) error {
+	body, err := json.Marshal(newUser{
+		Username:	username,
+		Password:	password,
+		Algorithm:	mechanism,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = a.sendAny(ctx, http.MethodPost, usersPath, body, nil)
+	if errors.Is(err, errConflict) {
+		return a.sendAny(ctx, http.MethodPut, usersPath+"/"+url.PathEscape(username), body, nil)
+	}
+
+	return err
+}
+
+// sendAny sends the request to the brokers in order until one of them
+// answers. When out is not nil the JSON response is decoded into it.
+func (a *AdminAPI) sendAny(
+	ctx context.Context, method, path string, body []byte, out interface{},
+) error {
+	var err error
+	for _, url := range a.urls {
+		if err = a.send(ctx, method, url+path, body, out); err == nil || errors.Is(err, errConflict) {
+			return err
+		}
+	}
+
+	return err
+}
+
+func (a *AdminAPI) send(
+	ctx context.Context, method, url string, body []byte, out interface{},
+) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if a.username != "" {
+		req.SetBasicAuth(a.username, a.password)
+	}
+
+	res, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	// Drain the body so the connection can be reused
+	defer func() { _, _ = io.Copy(ioutil.Discard, res.Body) }()
+
+	if res.StatusCode == http.StatusConflict {
+		return fmt.Errorf("%w: %s %s", errConflict, method, url)
+	}
+
+	if res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("%w: %s %s returned %d", ErrUnauthorized, method, url, res.StatusCode)
+	}
+
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("%w: %s %s returned %d", ErrUnexpectedStatus, method, url, res.StatusCode)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.NewDecoder(res.Body).Decode(out)
+}
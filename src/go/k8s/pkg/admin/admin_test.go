@@ -0,0 +1,464 @@
+// Copyright 2021 Vectorized, Inc.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.md
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0
+
+package admin_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/vectorizedio/redpanda/src/go/k8s/pkg/admin"
+	"k8s.io/utils/pointer"
+)
+
+func readyServer() *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/status/ready" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+func TestNewAdminAPIRequiresAddress(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := admin.NewAdminAPI(nil, nil)
+	g.Expect(err).To(MatchError(admin.ErrNoBrokers))
+}
+
+func TestAdminAPIBasicAuth(t *testing.T) {
+	g := NewWithT(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "admin" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	a, err := admin.NewAdminAPI([]string{strings.TrimPrefix(srv.URL, "http://")}, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	err = a.Ready(context.Background())
+	g.Expect(errors.Is(err, admin.ErrUnauthorized)).To(BeTrue())
+	g.Expect(err).To(MatchError(ContainSubstring("401")))
+
+	a.SetBasicAuth("admin", "secret")
+	g.Expect(a.Ready(context.Background())).To(Succeed())
+}
+
+func TestAdminAPIReadyPath(t *testing.T) {
+	g := NewWithT(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/redpanda/v1/status/ready" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	a, err := admin.NewAdminAPI([]string{strings.TrimPrefix(srv.URL, "http://")}, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(a.Ready(context.Background())).To(MatchError(ContainSubstring("404")))
+
+	a.SetReadyPath("/redpanda/v1/status/ready")
+	g.Expect(a.Ready(context.Background())).To(Succeed())
+}
+
+func TestAdminAPIWithCustomCA(t *testing.T) {
+	g := NewWithT(t)
+
+	srv := readyServer()
+	defer srv.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+
+	a, err := admin.NewAdminAPI(
+		[]string{strings.TrimPrefix(srv.URL, "https://")},
+		&tls.Config{MinVersion: tls.VersionTLS12, RootCAs: pool},
+	)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(a.Ready(context.Background())).To(Succeed())
+}
+
+func TestAdminAPIRejectsUnknownCA(t *testing.T) {
+	g := NewWithT(t)
+
+	srv := readyServer()
+	defer srv.Close()
+
+	a, err := admin.NewAdminAPI(
+		[]string{strings.TrimPrefix(srv.URL, "https://")},
+		&tls.Config{MinVersion: tls.VersionTLS12, RootCAs: x509.NewCertPool()},
+	)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(a.Ready(context.Background())).NotTo(Succeed())
+}
+
+func TestAdminAPIFallsBackToNextBroker(t *testing.T) {
+	g := NewWithT(t)
+
+	srv := readyServer()
+	defer srv.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+
+	a, err := admin.NewAdminAPI(
+		[]string{"127.0.0.1:1", strings.TrimPrefix(srv.URL, "https://")},
+		&tls.Config{MinVersion: tls.VersionTLS12, RootCAs: pool},
+	)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(a.Ready(context.Background())).To(Succeed())
+}
+
+func TestAdminAPICreateUser(t *testing.T) {
+	g := NewWithT(t)
+
+	users := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var u struct {
+			Username	string	`json:"username"`
+			Password	string	`json:"password"`
+			Algorithm	string	`json:"algorithm"`
+		}
+		if json.NewDecoder(r.Body).Decode(&u) != nil || u.Algorithm != admin.ScramSha256 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, exists := users[u.Username]
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/security/users":
+			if exists {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+		case r.Method == http.MethodPut && r.URL.Path == "/v1/security/users/"+u.Username:
+			if !exists {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		users[u.Username] = u.Password
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	a, err := admin.NewAdminAPI([]string{strings.TrimPrefix(srv.URL, "http://")}, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(a.CreateUser(context.Background(), "admin", "secret", admin.ScramSha256)).To(Succeed())
+	g.Expect(users).To(Equal(map[string]string{"admin": "secret"}))
+
+	// Creating the same user again updates its password
+	g.Expect(a.CreateUser(context.Background(), "admin", "other", admin.ScramSha256)).To(Succeed())
+	g.Expect(users).To(Equal(map[string]string{"admin": "other"}))
+}
+
+func TestAdminAPIBrokers(t *testing.T) {
+	g := NewWithT(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/v1/brokers" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`[{"node_id":1,` +
+			`"disk_space":[{"path":"/var/lib/redpanda/data","free":25,"total":100}]}]`))
+	}))
+	defer srv.Close()
+
+	a, err := admin.NewAdminAPI([]string{strings.TrimPrefix(srv.URL, "http://")}, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	brokers, err := a.Brokers(context.Background())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(brokers).To(Equal([]admin.Broker{{
+		NodeID:	1,
+		DiskSpace: []admin.DiskSpace{
+			{Path: "/var/lib/redpanda/data", Free: 25, Total: 100},
+		},
+	}}))
+}
+
+func TestAdminAPIClusterPartitions(t *testing.T) {
+	g := NewWithT(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/v1/cluster/partitions" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`[{"ns":"kafka","topic":"orders","partition_id":0,` +
+			`"replicas":[{"node_id":0,"core":1},{"node_id":2,"core":0}],"leader_id":0}]`))
+	}))
+	defer srv.Close()
+
+	a, err := admin.NewAdminAPI([]string{strings.TrimPrefix(srv.URL, "http://")}, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	partitions, err := a.ClusterPartitions(context.Background())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(partitions).To(Equal([]admin.Partition{{
+		Namespace:	"kafka",
+		Topic:		"orders",
+		PartitionID:	0,
+		Replicas:	[]admin.Replica{{NodeID: 0}, {NodeID: 2}},
+	}}))
+}
+
+func TestAdminAPICloudStorageStatus(t *testing.T) {
+	g := NewWithT(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/v1/cloud_storage/status/orders/3" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"cloud_storage_mode":"full","ms_since_last_segment_upload":90000,` +
+			`"local_log_last_offset":120,"cloud_log_last_offset":100}`))
+	}))
+	defer srv.Close()
+
+	a, err := admin.NewAdminAPI([]string{strings.TrimPrefix(srv.URL, "http://")}, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	status, err := a.CloudStorageStatus(context.Background(), "orders", 3)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(status).To(Equal(admin.CloudStorageStatus{
+		Mode:				"full",
+		MsSinceLastSegmentUpload:	pointer.Int64Ptr(90000),
+		LocalLogLastOffset:		pointer.Int64Ptr(120),
+		CloudLogLastOffset:		pointer.Int64Ptr(100),
+	}))
+}
+
+func TestAdminAPIControllerLeader(t *testing.T) {
+	g := NewWithT(t)
+
+	leader := `{"ns":"redpanda","topic":"controller","partition_id":0,"leader_id":2}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/v1/partitions/redpanda/controller/0" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(leader))
+	}))
+	defer srv.Close()
+
+	a, err := admin.NewAdminAPI([]string{strings.TrimPrefix(srv.URL, "http://")}, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	id, err := a.ControllerLeader(context.Background())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(id).To(Equal(2))
+
+	leader = `{"ns":"redpanda","topic":"controller","partition_id":0,"leader_id":-1}`
+	id, err = a.ControllerLeader(context.Background())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(id).To(Equal(admin.NoLeader))
+}
+
+func TestAdminAPIDecommissionBroker(t *testing.T) {
+	g := NewWithT(t)
+
+	var decommissioned string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		decommissioned = r.URL.Path
+	}))
+	defer srv.Close()
+
+	a, err := admin.NewAdminAPI([]string{strings.TrimPrefix(srv.URL, "http://")}, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(a.DecommissionBroker(context.Background(), 2)).To(Succeed())
+	g.Expect(decommissioned).To(Equal("/v1/brokers/2/decommission"))
+}
+
+func TestAdminAPIMaintenanceMode(t *testing.T) {
+	g := NewWithT(t)
+
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && r.URL.Path == "/v1/brokers" {
+			_, _ = w.Write([]byte(`[{"node_id":2,"maintenance_status":{"draining":true,"finished":true,"errors":false}}]`))
+			return
+		}
+		requests = append(requests, r.Method+" "+r.URL.Path)
+	}))
+	defer srv.Close()
+
+	a, err := admin.NewAdminAPI([]string{strings.TrimPrefix(srv.URL, "http://")}, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(a.EnableMaintenanceMode(context.Background(), 2)).To(Succeed())
+	g.Expect(a.DisableMaintenanceMode(context.Background(), 2)).To(Succeed())
+	g.Expect(requests).To(Equal([]string{"PUT /v1/brokers/2/maintenance", "DELETE /v1/brokers/2/maintenance"}))
+
+	brokers, err := a.Brokers(context.Background())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(brokers).To(Equal([]admin.Broker{{
+		NodeID:		2,
+		Maintenance:	&admin.MaintenanceStatus{Draining: true, Finished: true},
+	}}))
+}
+
+func TestAdminAPIClusterConfig(t *testing.T) {
+	g := NewWithT(t)
+
+	cfg := map[string]interface{}{"log_segment_size": "1024", "log_retention_ms": "1000"}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/cluster_config/schema" {
+			_, _ = w.Write([]byte(`{"properties":{"log_segment_size":{"needs_restart":false},` +
+				`"disk_reservation_percent":{"needs_restart":true}}}`))
+			return
+		}
+		if r.URL.Path != "/v1/cluster_config" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			_ = json.NewEncoder(w).Encode(cfg)
+		case http.MethodPut:
+			var patch struct {
+				Upsert	map[string]interface{}	`json:"upsert"`
+				Remove	[]string		`json:"remove"`
+			}
+			if json.NewDecoder(r.Body).Decode(&patch) != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			for k, v := range patch.Upsert {
+				cfg[k] = v
+			}
+			for _, k := range patch.Remove {
+				delete(cfg, k)
+			}
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer srv.Close()
+
+	a, err := admin.NewAdminAPI([]string{strings.TrimPrefix(srv.URL, "http://")}, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(a.PatchClusterConfig(context.Background(), map[string]interface{}{
+		"auto_create_topics_enabled": "false",
+	}, []string{"log_retention_ms"})).To(Succeed())
+
+	current, err := a.ClusterConfig(context.Background())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(current).To(Equal(map[string]interface{}{
+		"log_segment_size":		"1024",
+		"auto_create_topics_enabled":	"false",
+	}))
+
+	schema, err := a.ClusterConfigSchema(context.Background())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(schema).To(Equal(map[string]admin.ConfigProperty{
+		"log_segment_size":		{NeedsRestart: false},
+		"disk_reservation_percent":	{NeedsRestart: true},
+	}))
+}
+
+func TestAdminAPILicense(t *testing.T) {
+	g := NewWithT(t)
+
+	var loaded []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/features/license" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			if loaded == nil {
+				_, _ = w.Write([]byte(`{"loaded":false}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"loaded":true,"license":{"org":"vectorized","type":"enterprise","expires":1700000000,"sha256":"abc"}}`))
+		case http.MethodPut:
+			loaded, _ = ioutil.ReadAll(r.Body)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer srv.Close()
+
+	a, err := admin.NewAdminAPI([]string{strings.TrimPrefix(srv.URL, "http://")}, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	license, err := a.License(context.Background())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(license.Loaded).To(BeFalse())
+
+	g.Expect(a.SetLicense(context.Background(), []byte("license-data"))).To(Succeed())
+	g.Expect(loaded).To(Equal([]byte("license-data")))
+
+	license, err = a.License(context.Background())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(license).To(Equal(admin.License{
+		Loaded:	true,
+		Properties: admin.LicenseProperties{
+			Organization:	"vectorized",
+			Type:		"enterprise",
+			Expires:	1700000000,
+			Checksum:	"abc",
+		},
+	}))
+}
+
+func TestAdminAPIFromURLs(t *testing.T) {
+	g := NewWithT(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/proxy/redpanda/v1/status/ready" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	a, err := admin.NewAdminAPIFromURLs([]string{srv.URL + "/proxy/redpanda/"}, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(a.Ready(context.Background())).To(Succeed())
+
+	_, err = admin.NewAdminAPIFromURLs(nil, nil)
+	g.Expect(err).To(MatchError(admin.ErrNoBrokers))
+
+	for _, u := range []string{"redpanda:9644", "ftp://redpanda:9644", "https://", "http://redpanda:9644?x=1"} {
+		_, err = admin.NewAdminAPIFromURLs([]string{u}, nil)
+		g.Expect(err).To(MatchError(admin.ErrInvalidURL), u)
+	}
+}